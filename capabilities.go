@@ -0,0 +1,76 @@
+package tea
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RequestTerminalAttributes is a command that asks the terminal for its
+// primary device attributes (DA1), which describe its features. The terminal
+// answers with a CapabilitiesMsg.
+//
+// Terminals that don't answer won't send a message, so don't wait for one
+// before rendering anything.
+func RequestTerminalAttributes() Msg {
+	return requestTerminalAttributesMsg{}
+}
+
+// requestTerminalAttributesMsg is an internal message that asks the terminal
+// for its primary device attributes. To send a requestTerminalAttributesMsg,
+// use the RequestTerminalAttributes command.
+type requestTerminalAttributesMsg struct{}
+
+func (requestTerminalAttributesMsg) internalMsg() {}
+
+// CapabilitiesMsg is sent when the terminal reports its primary device
+// attributes, in response to RequestTerminalAttributes.
+type CapabilitiesMsg struct {
+	// Params are the reported attributes, as is. The first one is the
+	// conformance level of the terminal, such as 62 for VT220 or 64 for
+	// VT420, and the others are the features it supports.
+	Params []int
+
+	// Sixel reports whether the terminal supports sixel graphics.
+	Sixel bool
+
+	// Color reports whether the terminal supports ANSI color.
+	Color bool
+}
+
+// Features reported in the primary device attributes.
+const (
+	daSixel     = 4
+	daANSIColor = 22
+)
+
+// deviceAttributesRe matches the primary device attributes report:
+//
+//	CSI ? Ps ; ... c
+var deviceAttributesRe = regexp.MustCompile(`^\x1b\[\?(\d+(?:;\d+)*)c`)
+
+// detectDeviceAttributes detects a primary device attributes report.
+func detectDeviceAttributes(input []byte) (hasDA bool, width int, msg Msg) {
+	m := deviceAttributesRe.FindSubmatch(input)
+	if m == nil {
+		return false, 0, nil
+	}
+
+	var caps CapabilitiesMsg
+	for _, s := range strings.Split(string(m[1]), ";") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return false, 0, nil
+		}
+		caps.Params = append(caps.Params, n)
+	}
+	for _, p := range caps.Params[1:] {
+		switch p {
+		case daSixel:
+			caps.Sixel = true
+		case daANSIColor:
+			caps.Color = true
+		}
+	}
+	return true, len(m[0]), caps
+}
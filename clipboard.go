@@ -0,0 +1,108 @@
+package tea
+
+import (
+	"bytes"
+	"encoding/base64"
+	"time"
+)
+
+// ReadClipboard is a command that asks the terminal for the contents of the
+// clipboard using OSC 52. Terminals that allow it answer with a ClipboardMsg.
+//
+// Many terminals don't support reading the clipboard, or ask the user for
+// permission first, so don't rely on receiving an answer.
+func ReadClipboard() Msg {
+	return readClipboardMsg{}
+}
+
+// readClipboardMsg is an internal message that asks the terminal for the
+// contents of the clipboard. To send a readClipboardMsg, use the
+// ReadClipboard command.
+type readClipboardMsg struct{}
+
+func (readClipboardMsg) internalMsg() {}
+
+// ClipboardMsg is sent when the terminal reports the contents of the
+// clipboard, in response to ReadClipboard. Content is empty if the terminal
+// denied access to the clipboard.
+type ClipboardMsg struct {
+	Content string
+}
+
+// Sequences to query the clipboard, and to detect the answer:
+//
+//	OSC 52 ; c ; base64 data ST
+//
+// where ST may be BEL or ESC \.
+const (
+	queryClipboardSeq = "52;c;?\a"
+	clipboardPrefix   = "\x1b]52;"
+)
+
+// maxClipboardReplyLen is how long an answer to a clipboard query may get
+// before it's given up on, in case its terminator was lost.
+const maxClipboardReplyLen = 1 << 20
+
+// clipboardTimeout is how long the input can go quiet in the middle of an
+// answer to a clipboard query before the rest of it is taken to be lost.
+var clipboardTimeout = 2 * time.Second
+
+// isClipboardReplyByte reports whether c can be part of an answer to a
+// clipboard query before its terminator: a selection parameter, or base64
+// data.
+func isClipboardReplyByte(c byte) bool {
+	switch {
+	case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		return true
+	}
+	return bytes.IndexByte([]byte("+/=;?"), c) != -1
+}
+
+// detectClipboard detects the terminal's answer to a clipboard query. An
+// answer without a terminator is waited on while more input can arrive, up
+// to maxClipboardReplyLen, and dropped otherwise. An answer that's followed
+// by input that can't be part of it, such as a key typed after its end was
+// lost, is dropped up to that input.
+func detectClipboard(input []byte, canHaveMoreData bool) (hasClipboard bool, width int, msg Msg) {
+	if !bytes.HasPrefix(input, []byte(clipboardPrefix)) {
+		return false, 0, nil
+	}
+
+	// Find the end of the sequence.
+	end, stLen := -1, 0
+	for i := len(clipboardPrefix); i < len(input) && end == -1; i++ {
+		switch c := input[i]; {
+		case c == '\a':
+			end, stLen = i, 1
+		case c == '\x1b' && i+1 < len(input) && input[i+1] == '\\':
+			end, stLen = i, 2
+		case c == '\x1b' && i+1 == len(input) && canHaveMoreData:
+			// Possibly the start of the terminator.
+		case !isClipboardReplyByte(c):
+			// The answer was cut off before this.
+			return true, i, nil
+		}
+	}
+	if end == -1 {
+		if canHaveMoreData && len(input) < maxClipboardReplyLen {
+			// The rest of the answer is still to be read.
+			return true, 0, nil
+		}
+		// The answer was cut off, so there's no telling where it ends.
+		return true, len(input), nil
+	}
+
+	// Skip over the selection parameter.
+	data := input[len(clipboardPrefix):end]
+	if i := bytes.IndexByte(data, ';'); i != -1 {
+		data = data[i+1:]
+	}
+
+	content, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		// Terminals that deny access answer with something other than
+		// base64, if at all.
+		content = nil
+	}
+	return true, end + stLen, ClipboardMsg{Content: string(content)}
+}
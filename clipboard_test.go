@@ -0,0 +1,70 @@
+package tea
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadClipboardInput(t *testing.T) {
+	// Keys before and after the answer, which arrives in two reads.
+	msgs := testReadInputs(t, &chunkedReader{chunks: []string{
+		"a\x1b]52;c;aGVs",
+		"bG8=\x1b\\b",
+	}})
+
+	expected := []Msg{
+		KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+		ClipboardMsg{Content: "hello"},
+		KeyMsg{Type: KeyRunes, Runes: []rune{'b'}},
+	}
+	if !reflect.DeepEqual(expected, msgs) {
+		t.Errorf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+func TestReadTruncatedClipboardInput(t *testing.T) {
+	defer func(timeout time.Duration) { clipboardTimeout = timeout }(clipboardTimeout)
+	clipboardTimeout = 10 * time.Millisecond
+
+	// An answer that lost its end doesn't hold up the keys read after the
+	// input went quiet.
+	msgs := testReadInputs(t, &slowReader{chunks: []string{
+		"\x1b]52;c;aGVs",
+		"b",
+	}, delay: 50 * time.Millisecond})
+
+	expected := []Msg{KeyMsg{Type: KeyRunes, Runes: []rune{'b'}}}
+	if !reflect.DeepEqual(expected, msgs) {
+		t.Errorf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+func TestReadCutOffClipboardInput(t *testing.T) {
+	// Keys typed after an answer that lost its end aren't taken to be part
+	// of it.
+	msgs := testReadInputs(t, &chunkedReader{chunks: []string{
+		"\x1b]52;c;aGVs",
+		"\x1b[A\r",
+	}})
+
+	expected := []Msg{KeyMsg{Type: KeyUp}, KeyMsg{Type: KeyEnter}}
+	if !reflect.DeepEqual(expected, msgs) {
+		t.Errorf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+// chunkedReader returns each of its chunks in a separate read.
+type chunkedReader struct {
+	chunks []string
+}
+
+func (r *chunkedReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
@@ -0,0 +1,159 @@
+package tea
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/muesli/termenv"
+)
+
+// downgradeColors rewrites the colors set by SGR escape sequences in s to
+// ones the given color profile supports, such as true colors to the nearest
+// of the 256 colors. With the Ascii profile colors are removed, leaving other
+// attributes like bold alone.
+func downgradeColors(s string, profile termenv.Profile) string {
+	if profile == termenv.TrueColor || !strings.Contains(s, termenv.CSI) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for {
+		i := strings.Index(s, termenv.CSI)
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		sb.WriteString(s[:i])
+		s = s[i:]
+
+		// Find the end of the sequence, leaving anything that isn't SGR as
+		// it is.
+		end := len(termenv.CSI)
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == ';' || s[end] == ':') {
+			end++
+		}
+		if end == len(s) || s[end] != 'm' {
+			sb.WriteString(s[:len(termenv.CSI)])
+			s = s[len(termenv.CSI):]
+			continue
+		}
+
+		params := s[len(termenv.CSI):end]
+		if params == "" {
+			sb.WriteString(s[:end+1])
+		} else if params = downgradeSGR(params, profile); params != "" {
+			sb.WriteString(termenv.CSI + params + "m")
+		}
+		s = s[end+1:]
+	}
+}
+
+// activeStyle returns the SGR sequences in effect at the end of s, those
+// after the last reset, so that text written after s can pick up its style.
+func activeStyle(s string) string {
+	var style []string
+	for {
+		i := strings.Index(s, termenv.CSI)
+		if i < 0 {
+			break
+		}
+		s = s[i:]
+		end := len(termenv.CSI)
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == ';' || s[end] == ':') {
+			end++
+		}
+		if end < len(s) && s[end] == 'm' {
+			if params := s[len(termenv.CSI):end]; params == "" || params == "0" {
+				style = style[:0]
+			} else {
+				style = append(style, s[:end+1])
+			}
+		}
+		s = s[end:]
+	}
+	return strings.Join(style, "")
+}
+
+// downgradeSGR rewrites the colors in the parameters of an SGR sequence,
+// returning the new parameters, which are empty if nothing is left.
+func downgradeSGR(params string, profile termenv.Profile) string {
+	in := strings.Split(params, ";")
+	out := make([]string, 0, len(in))
+	for i := 0; i < len(in); i++ {
+		p := in[i]
+
+		// Extended colors, either as 38;5;n and 38;2;r;g;b, or with colons
+		// as in 38:5:n.
+		if p == "38" || p == "48" || strings.HasPrefix(p, "38:") || strings.HasPrefix(p, "48:") {
+			var args []string
+			if strings.Contains(p, ":") {
+				args = strings.Split(p, ":")
+			} else {
+				args, i = extendedColorArgs(in, i)
+			}
+			if c := extendedColor(args[1:]); c != nil {
+				if seq := profile.Convert(c).Sequence(args[0] == "48"); seq != "" {
+					out = append(out, seq)
+				}
+			}
+			continue
+		}
+
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			out = append(out, p)
+			continue
+		}
+		switch {
+		case n >= 30 && n <= 37, n >= 40 && n <= 47, n >= 90 && n <= 97, n >= 100 && n <= 107,
+			n == 39, n == 49:
+			// Basic colors are supported by every profile but Ascii.
+			if profile != termenv.Ascii {
+				out = append(out, p)
+			}
+		default:
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+// extendedColorArgs returns the parameters of the extended color starting at
+// index i of params, including the 38 or 48, and the index of the last one.
+func extendedColorArgs(params []string, i int) ([]string, int) {
+	n := 1
+	if i+1 < len(params) {
+		switch params[i+1] {
+		case "5":
+			n = 3
+		case "2":
+			n = 5
+		}
+	}
+	if i+n > len(params) {
+		n = len(params) - i
+	}
+	return params[i : i+n], i + n - 1
+}
+
+// extendedColor parses the arguments of an extended color, following the 38
+// or 48, returning nil if they're malformed.
+func extendedColor(args []string) termenv.Color {
+	nums := make([]int, len(args))
+	for i, a := range args {
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 || n > 255 {
+			return nil
+		}
+		nums[i] = n
+	}
+	switch {
+	case len(nums) == 2 && nums[0] == 5:
+		return termenv.ANSI256Color(nums[1])
+	case len(nums) == 4 && nums[0] == 2:
+		return termenv.RGBColor(fmt.Sprintf("#%02x%02x%02x", nums[1], nums[2], nums[3]))
+	}
+	return nil
+}
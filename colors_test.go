@@ -0,0 +1,69 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestDowngradeColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  termenv.Profile
+		in       string
+		expected string
+	}{
+		{"true color", termenv.TrueColor, "\x1b[38;2;255;0;0mred\x1b[0m", "\x1b[38;2;255;0;0mred\x1b[0m"},
+		{"true color to 256", termenv.ANSI256, "\x1b[38;2;255;0;0mred\x1b[0m", "\x1b[38;5;196mred\x1b[0m"},
+		{"true color to 16", termenv.ANSI, "\x1b[48;2;255;0;0mred\x1b[0m", "\x1b[101mred\x1b[0m"},
+		{"256 to 16", termenv.ANSI, "\x1b[38;5;196mred\x1b[0m", "\x1b[91mred\x1b[0m"},
+		{"16 kept", termenv.ANSI, "\x1b[31mred\x1b[39m", "\x1b[31mred\x1b[39m"},
+		{"ascii", termenv.Ascii, "\x1b[1;38;5;196;44mbold\x1b[0m", "\x1b[1mbold\x1b[0m"},
+		{"ascii drops color only sequences", termenv.Ascii, "\x1b[31mred\x1b[39m plain", "red plain"},
+		{"ascii colons", termenv.Ascii, "\x1b[38:5:196mred\x1b[m", "red\x1b[m"},
+		{"other sequences", termenv.Ascii, "\x1b[2K\x1b[31mred\x1b[1A", "\x1b[2Kred\x1b[1A"},
+		{"no sequences", termenv.Ascii, "plain", "plain"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if out := downgradeColors(test.in, test.profile); out != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, out)
+			}
+		})
+	}
+}
+
+type coloredModel struct{}
+
+func (m coloredModel) Init() Cmd { return Quit }
+
+func (m coloredModel) Update(msg Msg) (Model, Cmd) { return m, nil }
+
+func (m coloredModel) View() string {
+	return "\x1b[1;38;2;255;0;0mcolored\x1b[0m\n"
+}
+
+func TestTeaOutputProfile(t *testing.T) {
+	t.Run("termenv output", func(t *testing.T) {
+		var buf bytes.Buffer
+		out := termenv.NewOutput(&buf, termenv.WithProfile(termenv.Ascii))
+		if _, err := NewProgram(coloredModel{}, WithInput(nil), WithOutput(out)).Run(); err != nil {
+			t.Fatal(err)
+		}
+		if s := buf.String(); !strings.Contains(s, "\x1b[1mcolored\x1b[0m") || strings.Contains(s, "38;") {
+			t.Errorf("expected colors to be removed, got %q", s)
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := NewProgram(coloredModel{}, WithInput(nil), WithOutput(&buf)).Run(); err != nil {
+			t.Fatal(err)
+		}
+		if s := buf.String(); !strings.Contains(s, strings.TrimSuffix(coloredModel{}.View(), "\n")) {
+			t.Errorf("expected colors to be written as they are, got %q", s)
+		}
+	})
+}
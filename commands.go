@@ -1,6 +1,8 @@
 package tea
 
 import (
+	"context"
+	"sync"
 	"time"
 )
 
@@ -38,6 +40,11 @@ type BatchMsg []Cmd
 
 // Sequence runs the given commands one at a time, in order. Contrast this with
 // Batch, which runs commands concurrently.
+//
+// Each command's message is sent to Update before the next command runs, so
+// messages arrive in the order of the commands. Nil commands are skipped. A
+// Batch or Sequence returned by one of the commands runs to completion before
+// the sequence moves on.
 func Sequence(cmds ...Cmd) Cmd {
 	return func() Msg {
 		return sequenceMsg(cmds)
@@ -47,6 +54,36 @@ func Sequence(cmds ...Cmd) Cmd {
 // sequenceMsg is used internally to run the given commands in order.
 type sequenceMsg []Cmd
 
+func (sequenceMsg) internalMsg() {}
+
+// CmdWithContext produces a command that's given a context when it runs. The
+// context is canceled when the program exits, whether it quits, is killed,
+// or the context passed with WithContext is canceled, so long running I/O can
+// be aborted rather than outliving the program.
+//
+// Messages returned after the context has been canceled are dropped, since
+// the program is no longer processing messages.
+//
+//	cmd := CmdWithContext(func(ctx context.Context) Msg {
+//	    req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+//	    res, err := http.DefaultClient.Do(req)
+//	    if err != nil {
+//	        return errMsg{err}
+//	    }
+//	    return responseMsg{res}
+//	})
+func CmdWithContext(fn func(ctx context.Context) Msg) Cmd {
+	return func() Msg {
+		return contextCmdMsg(fn)
+	}
+}
+
+// contextCmdMsg is an internal message that runs a command with the program's
+// context. You can send a contextCmdMsg with CmdWithContext.
+type contextCmdMsg func(context.Context) Msg
+
+func (contextCmdMsg) internalMsg() {}
+
 // Every is a command that ticks in sync with the system clock. So, if you
 // wanted to tick with the system clock every second, minute or hour you
 // could use this. It's also handy for having different things tick in sync.
@@ -57,7 +94,13 @@ type sequenceMsg []Cmd
 // seconds later.
 //
 // To produce the command, pass a duration and a function which returns
-// a message containing the time at which the tick occurred.
+// a message containing the time at which the tick occurred. The time passed to
+// the function is the boundary the tick was scheduled for, such as 12:35:00,
+// rather than the slightly later time the timer actually fired.
+//
+// Since every tick is scheduled from the current time, ticks don't drift no
+// matter how long Update takes. If the program stalls for longer than the
+// duration, the missed ticks are skipped rather than delivered in a burst.
 //
 //	type TickMsg time.Time
 //
@@ -94,14 +137,59 @@ type sequenceMsg []Cmd
 //
 // Every is analogous to Tick in the Elm Architecture.
 func Every(duration time.Duration, fn func(time.Time) Msg) Cmd {
+	return every(duration, fn, nil)
+}
+
+// EveryWithCancel is like Every, but also returns a function that cancels the
+// tick. The command can be returned again after each tick, as with Every,
+// until the tick is canceled. Once canceled, the command no longer schedules
+// ticks, and a tick that was already scheduled or in flight is dropped rather
+// than delivered to Update. It's safe to cancel more than once and from any
+// goroutine.
+//
+//	func newModel() model {
+//	    tick, stop := EveryWithCancel(time.Second, func(t time.Time) Msg {
+//	        return TickMsg(t)
+//	    })
+//	    return model{tick: tick, stopTick: stop}
+//	}
+//
+//	func (m model) Init() Cmd {
+//	    return m.tick
+//	}
+func EveryWithCancel(duration time.Duration, fn func(time.Time) Msg) (Cmd, func()) {
+	done, cancel := newCancelFunc()
+	return every(duration, fn, done), cancel
+}
+
+func every(duration time.Duration, fn func(time.Time) Msg, done <-chan struct{}) Cmd {
 	return func() Msg {
-		n := time.Now()
-		d := n.Truncate(duration).Add(duration).Sub(n)
-		t := time.NewTimer(d)
-		return fn(<-t.C)
+		var next time.Time
+		start := func() (<-chan time.Time, func() bool) {
+			n := clockNow()
+			next = n.Truncate(duration).Add(duration)
+			return clockTimer(next.Sub(n))
+		}
+		return waitTick(start, done, func(time.Time) Msg {
+			return fn(next)
+		})
 	}
 }
 
+// clockNow and clockTimer are the clock Every and Tick schedule ticks with. They're
+// variables so tests can use a fake clock.
+var (
+	clockNow   = time.Now
+	clockTimer = newClockTimer
+)
+
+// newClockTimer starts a timer, returning the channel it fires on and a
+// function that stops it, so canceled ticks don't hold on to their timers.
+func newClockTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
 // Tick produces a command at an interval independent of the system clock at
 // the given duration. That is, the timer begins precisely when invoked,
 // and runs for its entire duration.
@@ -141,9 +229,72 @@ func Every(duration time.Duration, fn func(time.Time) Msg) Cmd {
 //	    return m, nil
 //	}
 func Tick(d time.Duration, fn func(time.Time) Msg) Cmd {
+	return tick(d, fn, nil)
+}
+
+// TickWithCancel is like Tick, but also returns a function that cancels the
+// tick. See EveryWithCancel for details.
+func TickWithCancel(d time.Duration, fn func(time.Time) Msg) (Cmd, func()) {
+	done, cancel := newCancelFunc()
+	return tick(d, fn, done), cancel
+}
+
+func tick(d time.Duration, fn func(time.Time) Msg, done <-chan struct{}) Cmd {
 	return func() Msg {
-		t := time.NewTimer(d)
-		return fn(<-t.C)
+		return waitTick(func() (<-chan time.Time, func() bool) { return clockTimer(d) }, done, fn)
+	}
+}
+
+// waitTick starts a timer and returns the tick message once it fires. If done
+// is closed first, it stops the timer and returns nil. The message is wrapped
+// so that it's dropped if done is closed before it reaches Update.
+func waitTick(start func() (<-chan time.Time, func() bool), done <-chan struct{}, fn func(time.Time) Msg) Msg {
+	if done == nil {
+		c, _ := start()
+		return fn(<-c)
+	}
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	c, stop := start()
+	select {
+	case t := <-c:
+		return cancelableMsg{done: done, msg: fn(t)}
+	case <-done:
+		stop()
+		return nil
+	}
+}
+
+// newCancelFunc returns a channel and a function that closes it. The function
+// can be called any number of times.
+func newCancelFunc() (<-chan struct{}, func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	return done, func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// cancelableMsg is an internal message wrapping the message of a cancelable
+// tick. It's delivered to Update unwrapped, unless the tick was canceled in
+// the meantime.
+type cancelableMsg struct {
+	done <-chan struct{}
+	msg  Msg
+}
+
+func (cancelableMsg) internalMsg() {}
+
+// canceled reports whether the tick was canceled.
+func (c cancelableMsg) canceled() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -178,6 +329,8 @@ func Sequentially(cmds ...Cmd) Cmd {
 // setWindowTitleMsg is an internal message used to set the window title.
 type setWindowTitleMsg string
 
+func (setWindowTitleMsg) internalMsg() {}
+
 // SetWindowTitle produces a command that sets the terminal title.
 //
 // For example:
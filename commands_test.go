@@ -16,6 +16,53 @@ func TestEvery(t *testing.T) {
 	}
 }
 
+func TestEveryAligned(t *testing.T) {
+	cur := time.Date(2024, 1, 1, 12, 0, 0, int(300*time.Millisecond), time.UTC)
+	var waits []time.Duration
+	defer func(now func() time.Time, timer func(time.Duration) (<-chan time.Time, func() bool)) {
+		clockNow, clockTimer = now, timer
+	}(clockNow, clockTimer)
+	clockNow = func() time.Time { return cur }
+	clockTimer = func(d time.Duration) (<-chan time.Time, func() bool) {
+		waits = append(waits, d)
+		// The timer fires a little late.
+		cur = cur.Add(d + 5*time.Millisecond)
+		c := make(chan time.Time, 1)
+		c <- cur
+		return c, func() bool { return false }
+	}
+
+	tick := func() time.Time {
+		return Every(time.Second, func(t time.Time) Msg { return t })().(time.Time)
+	}
+	at := func(sec int) time.Time {
+		return time.Date(2024, 1, 1, 12, 0, sec, 0, time.UTC)
+	}
+
+	if got := tick(); !got.Equal(at(1)) {
+		t.Errorf("expected the first tick at the next second, got %v", got)
+	}
+
+	// A slow update doesn't shift the next tick.
+	cur = cur.Add(200 * time.Millisecond)
+	if got := tick(); !got.Equal(at(2)) {
+		t.Errorf("expected the second tick to stay aligned, got %v", got)
+	}
+
+	// After a stall, missed ticks are skipped.
+	cur = cur.Add(3500 * time.Millisecond)
+	if got := tick(); !got.Equal(at(6)) {
+		t.Errorf("expected the tick after a stall to skip ahead, got %v", got)
+	}
+
+	expected := []time.Duration{700 * time.Millisecond, 795 * time.Millisecond, 495 * time.Millisecond}
+	for i, d := range expected {
+		if waits[i] != d {
+			t.Errorf("tick %d: expected to wait %v, waited %v", i, d, waits[i])
+		}
+	}
+}
+
 func TestTick(t *testing.T) {
 	expected := "tick"
 	msg := Tick(time.Millisecond, func(t time.Time) Msg {
@@ -26,6 +73,79 @@ func TestTick(t *testing.T) {
 	}
 }
 
+func TestTickWithCancel(t *testing.T) {
+	defer func(timer func(time.Duration) (<-chan time.Time, func() bool)) {
+		clockTimer = timer
+	}(clockTimer)
+	timers := make(chan chan time.Time, 1)
+	stopped := make(chan struct{}, 1)
+	clockTimer = func(time.Duration) (<-chan time.Time, func() bool) {
+		c := make(chan time.Time, 1)
+		timers <- c
+		return c, func() bool {
+			stopped <- struct{}{}
+			return true
+		}
+	}
+
+	cmd, cancel := TickWithCancel(time.Second, func(t time.Time) Msg { return t })
+
+	// A tick that fires is delivered.
+	now := time.Now()
+	go func() { (<-timers) <- now }()
+	msg, ok := cmd().(cancelableMsg)
+	if !ok || msg.msg != now || msg.canceled() {
+		t.Fatalf("expected a tick at %v, got %#v", now, msg)
+	}
+
+	// Canceling while waiting stops the tick, even if the clock advances
+	// afterwards.
+	msgs := make(chan Msg)
+	go func() { msgs <- cmd() }()
+	timer := <-timers
+	cancel()
+	if msg := <-msgs; msg != nil {
+		t.Errorf("expected no tick after canceling, got %#v", msg)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("expected the timer to be stopped when canceling")
+	}
+	timer <- now
+
+	// Once canceled, no more ticks are scheduled.
+	if msg := cmd(); msg != nil {
+		t.Errorf("expected no tick after canceling, got %#v", msg)
+	}
+	select {
+	case <-timers:
+		t.Errorf("expected no timer to be started after canceling")
+	default:
+	}
+
+	// Ticks that were in flight are dropped. Canceling twice is fine.
+	cancel()
+	if !msg.canceled() {
+		t.Errorf("expected the in-flight tick to be canceled")
+	}
+}
+
+func TestEveryWithCancel(t *testing.T) {
+	cmd, cancel := EveryWithCancel(time.Hour, func(t time.Time) Msg { return t })
+	msgs := make(chan Msg)
+	go func() { msgs <- cmd() }()
+	cancel()
+	select {
+	case msg := <-msgs:
+		if msg != nil {
+			t.Errorf("expected no tick after canceling, got %#v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected canceling to stop waiting for the tick")
+	}
+}
+
 func TestSequentially(t *testing.T) {
 	expectedErrMsg := fmt.Errorf("some err")
 	expectedStrMsg := "some msg"
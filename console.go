@@ -0,0 +1,70 @@
+package tea
+
+import (
+	"fmt"
+	"os"
+)
+
+// Console modes of an output handle on Windows.
+const (
+	// enableVirtualTerminalProcessing makes the console interpret escape
+	// sequences rather than printing them.
+	enableVirtualTerminalProcessing = 0x0004
+
+	// disableNewlineAutoReturn stops the console from moving to the start of
+	// the line on a line feed, which is what terminals do.
+	disableNewlineAutoReturn = 0x0008
+)
+
+// consoleModer gets and sets the mode of a console handle. On Windows it uses
+// the console API; elsewhere there's no console, and it's nil. Tests replace
+// it to check the calls without a console.
+type consoleModer interface {
+	GetConsoleMode(fd uintptr) (uint32, error)
+	SetConsoleMode(fd uintptr, mode uint32) error
+}
+
+// enableVTProcessing makes the console of f interpret escape sequences,
+// returning a function that restores its previous mode. If f isn't a
+// console, there's nothing to do, and the function does nothing.
+func enableVTProcessing(c consoleModer, f *os.File) (func() error, error) {
+	restore := func() error { return nil }
+	if c == nil || f == nil {
+		return restore, nil
+	}
+
+	// Getting the mode fails for anything that isn't a console, such as a
+	// file or a pipe.
+	mode, err := c.GetConsoleMode(f.Fd())
+	if err != nil {
+		return restore, nil
+	}
+	const vtMode = enableVirtualTerminalProcessing | disableNewlineAutoReturn
+	if mode&vtMode == vtMode {
+		return restore, nil
+	}
+
+	if err := c.SetConsoleMode(f.Fd(), mode|vtMode); err != nil {
+		return restore, fmt.Errorf("error enabling virtual terminal processing: %w", err)
+	}
+	return func() error {
+		// Holding on to f rather than its handle keeps it from being closed
+		// by a finalizer.
+		if err := c.SetConsoleMode(f.Fd(), mode); err != nil {
+			return fmt.Errorf("error restoring console mode: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// enableVTProcessing makes the console the program renders to interpret
+// escape sequences, on Windows. Older consoles can't, in which case frames
+// are written as plain text instead.
+func (p *Program) enableVTProcessing() {
+	f, _ := p.output.TTY().(*os.File)
+	restore, err := enableVTProcessing(console, f)
+	p.restoreOutput = restore
+	if err != nil {
+		p.noVTProcessing = true
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+// +build !windows
+
+package tea
+
+// console is nil, as there's no console outside of Windows.
+var console consoleModer
@@ -0,0 +1,110 @@
+package tea
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// fakeConsole records the console modes set on it.
+type fakeConsole struct {
+	mode    uint32
+	getErr  error
+	setErr  error
+	setCall []uint32
+}
+
+func (c *fakeConsole) GetConsoleMode(uintptr) (uint32, error) {
+	return c.mode, c.getErr
+}
+
+func (c *fakeConsole) SetConsoleMode(_ uintptr, mode uint32) error {
+	c.setCall = append(c.setCall, mode)
+	if c.setErr != nil {
+		return c.setErr
+	}
+	c.mode = mode
+	return nil
+}
+
+func TestEnableVTProcessing(t *testing.T) {
+	const vtMode = enableVirtualTerminalProcessing | disableNewlineAutoReturn
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		console *fakeConsole
+		calls   []uint32
+		err     bool
+	}{
+		{"legacy console", &fakeConsole{mode: 0x3}, []uint32{0x3 | vtMode, 0x3}, false},
+		{"already enabled", &fakeConsole{mode: 0x3 | vtMode}, nil, false},
+		{"not a console", &fakeConsole{getErr: errFailed}, nil, false},
+		{"unsupported", &fakeConsole{mode: 0x3, setErr: errFailed}, []uint32{0x3 | vtMode}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			restore, err := enableVTProcessing(test.console, os.Stdout)
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := restore(); err != nil {
+				t.Fatalf("unexpected error restoring: %v", err)
+			}
+			if !reflect.DeepEqual(test.console.setCall, test.calls) {
+				t.Errorf("expected modes %#x to be set, got %#x", test.calls, test.console.setCall)
+			}
+		})
+	}
+
+	t.Run("no console", func(t *testing.T) {
+		restore, err := enableVTProcessing(nil, os.Stdout)
+		if err != nil || restore() != nil {
+			t.Errorf("expected nothing to do without a console")
+		}
+	})
+}
+
+func TestTeaVTProcessing(t *testing.T) {
+	run := func(t *testing.T, c *fakeConsole) *Program {
+		t.Helper()
+
+		prev := console
+		console = c
+		t.Cleanup(func() { console = prev })
+
+		f, err := os.CreateTemp(t.TempDir(), "output")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close() //nolint:errcheck
+
+		p := NewProgram(coloredModel{}, WithInput(nil), WithOutput(f))
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	t.Run("restored on exit", func(t *testing.T) {
+		c := &fakeConsole{mode: 0x3}
+		p := run(t, c)
+		if c.mode != 0x3 || len(c.setCall) != 2 {
+			t.Errorf("expected the console mode to be set and restored, got %#x", c.setCall)
+		}
+		if p.noVTProcessing {
+			t.Errorf("expected escape sequences to be supported")
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		p := run(t, &fakeConsole{mode: 0x3, setErr: errors.New("invalid parameter")})
+		if !p.noVTProcessing || !p.usePlainOutput() {
+			t.Errorf("expected frames to be written as plain text")
+		}
+		if _, ok := p.renderer.(*plainRenderer); !ok {
+			t.Errorf("expected the plain renderer, got %T", p.renderer)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package tea
+
+import "golang.org/x/sys/windows"
+
+var console consoleModer = windowsConsole{}
+
+// windowsConsole gets and sets console modes with the Windows console API.
+type windowsConsole struct{}
+
+func (windowsConsole) GetConsoleMode(fd uintptr) (uint32, error) {
+	var mode uint32
+	err := windows.GetConsoleMode(windows.Handle(fd), &mode)
+	return mode, err //nolint:wrapcheck
+}
+
+func (windowsConsole) SetConsoleMode(fd uintptr, mode uint32) error {
+	return windows.SetConsoleMode(windows.Handle(fd), mode) //nolint:wrapcheck
+}
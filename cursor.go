@@ -0,0 +1,82 @@
+package tea
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/termenv"
+)
+
+// cursorMarker marks where the cursor goes in a view. It's a noncharacter,
+// which text doesn't contain.
+const cursorMarker = "\uFDD0"
+
+// CursorPosition returns a marker to put in the view where the terminal's
+// cursor should be, such as at the caret of a text input, so that the cursor
+// blinks there and input methods show their candidates next to it. The marker
+// is removed before the view is rendered, and the cursor is moved to the cell
+// it was in, and shown, rather than left at the start of the last line.
+//
+// Only the first marker in a view counts.
+func CursorPosition() string {
+	return cursorMarker
+}
+
+// cursorCell is the cell of a frame the cursor is placed in, if set.
+type cursorCell struct {
+	line, col int
+	set       bool
+}
+
+// extractCursor removes the cursor markers from a frame, returning the cell
+// the first one was in, measuring the cells before it with cond.
+func extractCursor(s string, cond *runewidth.Condition) (string, cursorCell) {
+	i := strings.Index(s, cursorMarker)
+	if i < 0 {
+		return s, cursorCell{}
+	}
+	start := strings.LastIndexByte(s[:i], '\n') + 1
+	cell := cursorCell{
+		line: strings.Count(s[:start], "\n"),
+		col:  stringWidth(s[start:i], cond),
+		set:  true,
+	}
+	return strings.ReplaceAll(s, cursorMarker, ""), cell
+}
+
+// placeCursor moves the cursor to the cell the last frame rendered asks for
+// it to be in, or to the start of the last line as usual, where each flush
+// leaves it. The cursor is shown while it's placed in a cell.
+func (r *standardRenderer) placeCursor(out *termenv.Output) {
+	line := r.cursor.line - r.droppedLines
+	if !r.cursor.set || line < 0 || line >= r.linesRendered {
+		r.cursor = cursorCell{}
+		r.parkCursor(out)
+		if r.cursorShown {
+			r.cursorShown = false
+			if r.cursorHidden {
+				out.HideCursor()
+			}
+		}
+		return
+	}
+
+	col := r.cursor.col
+	if r.width > 0 && col >= r.width {
+		col = r.width - 1
+	}
+	if r.altScreenActive {
+		out.MoveCursor(line+1, col+1)
+		r.renderingHead = line
+	} else {
+		r.moveRenderingHead(out, line)
+		out.CursorBack(r.width)
+		if col > 0 {
+			out.CursorForward(col)
+		}
+	}
+	if r.cursorHidden && !r.cursorShown {
+		out.ShowCursor()
+		r.cursorShown = true
+	}
+}
@@ -0,0 +1,122 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestExtractCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		expected string
+		cell     cursorCell
+	}{
+		{"none", "a\nb", "a\nb", cursorCell{}},
+		{"start", CursorPosition() + "a", "a", cursorCell{line: 0, col: 0, set: true}},
+		{"middle", "first\nsec" + CursorPosition() + "ond", "first\nsecond", cursorCell{line: 1, col: 3, set: true}},
+		{"styled", "\x1b[1mab\x1b[0m" + CursorPosition(), "\x1b[1mab\x1b[0m", cursorCell{line: 0, col: 2, set: true}},
+		{"wide", "日本" + CursorPosition(), "日本", cursorCell{line: 0, col: 4, set: true}},
+		{"first counts", "a" + CursorPosition() + "\nb" + CursorPosition(), "a\nb", cursorCell{line: 0, col: 1, set: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, cell := extractCursor(test.frame, newWidthCondition(false))
+			if got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+			if cell != test.cell {
+				t.Errorf("expected %+v, got %+v", test.cell, cell)
+			}
+		})
+	}
+}
+
+func TestFlushCursorPosition(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.cursorHidden = true
+
+	// The cursor is moved up from the last line to the marker in the middle
+	// of the second, and shown.
+	r.write("first\nsec" + CursorPosition() + "ond\nthird")
+	r.flush()
+	if expected := "first\r\nsecond\r\nthird\x1b[1A\x1b[0D\x1b[3C\x1b[?25h"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// Moving the marker moves the cursor, and the diff starts from its line.
+	r.write("first\nseco" + CursorPosition() + "nd\nthird")
+	r.flush()
+	if expected := "\x1b[1A\x1b[0D\x1b[2Kfirst\r\n\x1b[0D\x1b[4C"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// Without one, the cursor goes back to the start of the last line, and
+	// is hidden again.
+	r.write("first\nsecond\nthird")
+	r.flush()
+	if expected := "\x1b[1A\x1b[0D\x1b[2Kfirst\r\n\x1b[1B\x1b[0D\x1b[?25l"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFlushAppendCursorPosition(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("> hel" + CursorPosition())
+	r.flush()
+	buf.Reset()
+
+	// Typing appends to the line, and the cursor follows.
+	r.write("> hell" + CursorPosition())
+	r.flush()
+	if expected := "\x1b[0D\x1b[5Cl\x1b[0D\x1b[6C"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestSaveRestoreCursor(t *testing.T) {
+	tests := []struct {
+		name          string
+		sco           bool
+		save, restore string
+	}{
+		{"dec", false, "\x1b7", "\x1b8"},
+		{"sco", true, "\x1b[s", "\x1b[u"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+			r.scoCursorSave = test.sco
+			r.write("a\nb\nc")
+			r.flush()
+			buf.Reset()
+
+			r.handleMessages(saveCursorMsg{})
+			if buf.String() != test.save {
+				t.Errorf("expected %q, got %q", test.save, buf.String())
+			}
+			buf.Reset()
+
+			r.handleMessages(restoreCursorMsg{})
+			if buf.String() != test.restore {
+				t.Errorf("expected %q, got %q", test.restore, buf.String())
+			}
+			buf.Reset()
+
+			// The same frame is painted again in full.
+			r.write("a\nb\nc")
+			r.flush()
+			if expected := "\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nb\r\nc\x1b[0D"; buf.String() != expected {
+				t.Errorf("expected %q, got %q", expected, buf.String())
+			}
+		})
+	}
+}
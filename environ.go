@@ -0,0 +1,72 @@
+package tea
+
+import (
+	"os"
+	"strings"
+
+	"github.com/muesli/termenv"
+)
+
+// ColorProfileMsg reports the color profile of the terminal the program
+// renders to. It's sent right after Init, before any other message, when the
+// profile was set with WithEnviron or WithColorProfile rather than detected
+// from the process environment, as styling libraries detect their profile
+// from the process environment and would get it wrong.
+type ColorProfileMsg struct {
+	Profile termenv.Profile
+}
+
+// environ holds environment variables in the "key=value" form of os.Environ.
+// It implements termenv.Environ, so that terminal detection can use an
+// environment other than the process's.
+type environ []string
+
+// Environ returns the variables.
+func (e environ) Environ() []string {
+	return e
+}
+
+// Getenv returns the value of the variable with the given key, or an empty
+// string if it's not set. Later variables override earlier ones, as they do
+// for exec.Cmd.
+func (e environ) Getenv(key string) string {
+	for i := len(e) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(e[i], "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// getenv returns the value of an environment variable of the terminal, from
+// the environment set with WithEnviron, or the process's.
+func (p *Program) getenv(key string) string {
+	if p.environ != nil {
+		return p.environ.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+// detectColorProfile sets the color profile of the program's output from the
+// environment set with WithEnviron, or to the one set with WithColorProfile,
+// which takes precedence.
+func (p *Program) detectColorProfile() {
+	if p.environ != nil {
+		// The environment describes the terminal the output ends up in,
+		// even if it's not one we can see, such as an SSH channel.
+		termenv.WithEnvironment(p.environ)(p.output)
+		termenv.WithTTY(true)(p.output)
+		p.output.Profile = p.output.EnvColorProfile()
+	}
+	if p.colorProfile != nil {
+		p.output.Profile = *p.colorProfile
+	}
+}
+
+// colorProfileMsg returns the ColorProfileMsg to send at startup, if any.
+func (p *Program) colorProfileMsg() (ColorProfileMsg, bool) {
+	if p.environ == nil && p.colorProfile == nil {
+		return ColorProfileMsg{}, false
+	}
+	return ColorProfileMsg{Profile: p.output.Profile}, true
+}
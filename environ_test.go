@@ -0,0 +1,106 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// profileModel styles its view with the color profile it's told about.
+type profileModel struct {
+	profile termenv.Profile
+}
+
+func (m profileModel) Init() Cmd { return nil }
+
+func (m profileModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(ColorProfileMsg); ok {
+		m.profile = msg.Profile
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m profileModel) View() string {
+	return termenv.String("styled").Foreground(m.profile.Color("#ff0000")).String() + "\n"
+}
+
+func TestTeaEnviron(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ProgramOption
+		profile termenv.Profile
+		frame   string
+	}{
+		{
+			name:    "dumb",
+			opts:    []ProgramOption{WithEnviron([]string{"TERM=dumb"})},
+			profile: termenv.Ascii,
+			frame:   "styled\n",
+		},
+		{
+			name:    "256 colors",
+			opts:    []ProgramOption{WithEnviron([]string{"TERM=xterm-256color"})},
+			profile: termenv.ANSI256,
+			frame:   "\x1b[38;5;196mstyled\x1b[0m\r\n",
+		},
+		{
+			name:    "no color",
+			opts:    []ProgramOption{WithEnviron([]string{"TERM=xterm-256color", "NO_COLOR=1"})},
+			profile: termenv.Ascii,
+			frame:   "styled\r\n",
+		},
+		{
+			name: "explicit profile wins",
+			opts: []ProgramOption{
+				WithColorProfile(termenv.ANSI256),
+				WithEnviron([]string{"TERM=xterm"}),
+			},
+			profile: termenv.ANSI256,
+			frame:   "\x1b[38;5;196mstyled\x1b[0m\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]ProgramOption{WithInput(nil), WithOutput(&buf)}, test.opts...)
+			m, err := NewProgram(profileModel{profile: termenv.TrueColor}, opts...).Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if profile := m.(profileModel).profile; profile != test.profile {
+				t.Errorf("expected profile %v, got %v", test.profile, profile)
+			}
+			if out := buf.String(); !strings.Contains(out, test.frame) {
+				t.Errorf("expected frame %q, got %q", test.frame, out)
+			}
+		})
+	}
+
+	t.Run("process environment", func(t *testing.T) {
+		var msgs []Msg
+		_, err := NewProgram(sizeModel{msgs: &msgs, quit: 1}, WithInput(nil), WithOutput(&bytes.Buffer{})).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := msgs[0].(ColorProfileMsg); ok {
+			t.Errorf("expected no ColorProfileMsg without WithEnviron or WithColorProfile")
+		}
+	})
+}
+
+func TestEnvironGetenv(t *testing.T) {
+	env := environ{"TERM=dumb", "EMPTY=", "TERM=xterm"}
+	if v := env.Getenv("TERM"); v != "xterm" {
+		t.Errorf("expected the last TERM to win, got %q", v)
+	}
+	if v := env.Getenv("EMPTY"); v != "" {
+		t.Errorf("expected an empty value, got %q", v)
+	}
+	if v := env.Getenv("MISSING"); v != "" {
+		t.Errorf("expected no value for a missing variable, got %q", v)
+	}
+}
@@ -12,6 +12,8 @@ type execMsg struct {
 	fn  ExecCallback
 }
 
+func (execMsg) internalMsg() {}
+
 // Exec is used to perform arbitrary I/O in a blocking fashion, effectively
 // pausing the Program while execution is running and resuming it when
 // execution has completed.
@@ -0,0 +1,59 @@
+package tea
+
+import "time"
+
+// DroppedFramesMsg is sent, at most once a second, when the renderer fell
+// behind in the last second, because writing frames to the terminal took
+// longer than the time between them, as with a slow terminal or a slow
+// network connection. Programs can react by lowering their frame rate, or
+// simplifying their view. It's only sent to programs run with
+// WithDroppedFrameReports.
+type DroppedFramesMsg struct {
+	// Dropped is how many frames were skipped because the renderer was
+	// still busy with the one before.
+	Dropped int
+
+	// MaxWriteLatency is the longest it took to write a frame to the
+	// terminal.
+	MaxWriteLatency time.Duration
+}
+
+// droppedFramesInterval is how often dropped frames are reported, at most.
+var droppedFramesInterval = time.Second
+
+// framePacing tracks the frames the renderer drops, and how long writing them
+// takes, over the interval they're reported for.
+type framePacing struct {
+	lastTick        time.Time
+	intervalStart   time.Time
+	dropped         int
+	maxWriteLatency time.Duration
+}
+
+// trackTick counts the ticks that were dropped before the one that fired at
+// the given time, as the renderer was busy, and reports the dropped frames
+// once the interval is over. The mutex must be held.
+func (r *standardRenderer) trackTick(tick time.Time) {
+	p := &r.pacing
+	if p.lastTick.IsZero() {
+		p.lastTick, p.intervalStart = tick, tick
+		return
+	}
+
+	// Tickers skip ticks no one was there to receive, so the time between
+	// the ones received tells how many were skipped.
+	if missed := int((tick.Sub(p.lastTick)+r.framerate/2)/r.framerate) - 1; missed > 0 {
+		p.dropped += missed
+	}
+	p.lastTick = tick
+
+	if tick.Sub(p.intervalStart) < droppedFramesInterval {
+		return
+	}
+	if p.dropped > 0 {
+		r.onDroppedFrames(DroppedFramesMsg{Dropped: p.dropped, MaxWriteLatency: p.maxWriteLatency})
+	}
+	p.intervalStart = tick
+	p.dropped = 0
+	p.maxWriteLatency = 0
+}
@@ -0,0 +1,80 @@
+package tea
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+// slowWriter takes a while to write, like a terminal over a slow network.
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (w slowWriter) Write(b []byte) (int, error) {
+	time.Sleep(w.delay)
+	return len(b), nil
+}
+
+func TestDroppedFrames(t *testing.T) {
+	defer func(d time.Duration) { droppedFramesInterval = d }(droppedFramesInterval)
+	droppedFramesInterval = 200 * time.Millisecond
+
+	r := newRenderer(termenv.NewOutput(slowWriter{delay: 50 * time.Millisecond}), false, 60, false).(*standardRenderer)
+	var (
+		mtx     sync.Mutex
+		reports []DroppedFramesMsg
+	)
+	r.onDroppedFrames = func(msg DroppedFramesMsg) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		reports = append(reports, msg)
+	}
+
+	r.start()
+	for i := 0; i < 25; i++ {
+		r.write(fmt.Sprintf("frame %d", i))
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.stop()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(reports) == 0 {
+		t.Fatal("expected dropped frames to be reported")
+	}
+	for _, msg := range reports {
+		if msg.Dropped < 1 || msg.MaxWriteLatency < 50*time.Millisecond {
+			t.Errorf("expected frames dropped behind slow writes, got %+v", msg)
+		}
+	}
+}
+
+func TestNoDroppedFrames(t *testing.T) {
+	r := newRenderer(termenv.NewOutput(slowWriter{}), false, 60, false).(*standardRenderer)
+	reported := false
+	r.onDroppedFrames = func(DroppedFramesMsg) { reported = true }
+
+	// Ticks on time, with a slow write now and then.
+	start := time.Now()
+	for i := 0; i <= 30; i++ {
+		r.pacing.maxWriteLatency = 5 * time.Millisecond
+		r.trackTick(start.Add(time.Duration(i) * r.framerate))
+	}
+	if reported {
+		t.Error("expected no dropped frames to be reported")
+	}
+
+	// A tick that comes three frames late follows two that were dropped.
+	r.trackTick(start.Add(33 * r.framerate))
+	if r.pacing.dropped != 2 {
+		t.Errorf("expected 2 dropped frames, got %d", r.pacing.dropped)
+	}
+	r.trackTick(start.Add(droppedFramesInterval))
+	if !reported {
+		t.Error("expected dropped frames to be reported")
+	}
+}
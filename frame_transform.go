@@ -0,0 +1,34 @@
+package tea
+
+import "fmt"
+
+// FrameTransformErrorMsg is sent when a frame transform set with
+// WithFrameTransform panics. The frame is rendered without the transforms
+// from the one that panicked on.
+type FrameTransformErrorMsg struct {
+	Err error
+}
+
+// transformFrame applies the frame transforms to frame in order. If one
+// panics, the frame is returned as transformed by the ones before it, along
+// with an error.
+func transformFrame(frame string, transforms []func(string) string) (string, error) {
+	for i, fn := range transforms {
+		out, err := safeTransform(fn, frame)
+		if err != nil {
+			return frame, fmt.Errorf("frame transform %d: %w", i, err)
+		}
+		frame = out
+	}
+	return frame, nil
+}
+
+// safeTransform calls fn, turning a panic into an error.
+func safeTransform(fn func(string) string, frame string) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(frame), nil
+}
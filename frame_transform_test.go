@@ -0,0 +1,69 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// transformModel quits when a frame transform fails, or right away if
+// quit is set.
+type transformModel struct {
+	quit bool
+	err  error
+}
+
+func (m transformModel) Init() Cmd {
+	if m.quit {
+		return Quit
+	}
+	return nil
+}
+
+func (m transformModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(FrameTransformErrorMsg); ok {
+		m.err = msg.Err
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m transformModel) View() string { return "hello\n" }
+
+func TestTeaFrameTransform(t *testing.T) {
+	t.Run("in order", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := NewProgram(transformModel{quit: true},
+			WithInput(nil),
+			WithOutput(&buf),
+			WithFrameTransform(strings.ToUpper),
+			WithFrameTransform(func(s string) string { return "> " + s }),
+		).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := buf.String(); !strings.Contains(out, "> HELLO\r\n") || strings.Contains(out, "hello") {
+			t.Errorf("expected the transformed frame, got %q", out)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(transformModel{},
+			WithInput(nil),
+			WithOutput(&buf),
+			WithFrameTransform(strings.ToUpper),
+			WithFrameTransform(func(string) string { panic("oops") }),
+		)
+		m, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.(transformModel).err; err == nil || !strings.Contains(err.Error(), "frame transform 1: panic: oops") {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if out := buf.String(); !strings.Contains(out, "HELLO\r\n") {
+			t.Errorf("expected the frame to be rendered with the transforms before the panic, got %q", out)
+		}
+	})
+}
@@ -0,0 +1,266 @@
+package tea
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/termenv"
+)
+
+// GraphicsRegion is a rectangle of cells in the view that graphics, such as
+// a sixel image, are drawn over. Line is the line of the view it starts on,
+// from 0, and Col the column, and it's Rows lines tall and Cols columns
+// wide. The ID tells regions apart.
+type GraphicsRegion struct {
+	ID   string
+	Line int
+	Col  int
+	Rows int
+	Cols int
+}
+
+// contains reports whether the region covers part of the given line.
+func (g GraphicsRegion) contains(line int) bool {
+	return line >= g.Line && line < g.Line+g.Rows
+}
+
+// SetGraphicsRegion draws graphics, such as a sixel image, at the top left of
+// a region of the view, and has the renderer leave the region's cells alone
+// from then on: lines of the view that run through it are painted around it,
+// rather than cleared, so the graphics aren't cut apart. The view should
+// leave the region blank.
+//
+// Setting a region with the ID of one that's set replaces it. When the
+// graphics are lost, as when the terminal is resized or the screen cleared,
+// a RegionDamagedMsg is sent, and the region is left blank until it's set
+// again.
+func SetGraphicsRegion(region GraphicsRegion, graphics string) Cmd {
+	return func() Msg {
+		return setGraphicsRegionMsg{region: region, graphics: graphics}
+	}
+}
+
+// ClearGraphicsRegion erases the region with the given ID, and hands its
+// cells back to the renderer, which paints the view there again.
+func ClearGraphicsRegion(id string) Cmd {
+	return func() Msg {
+		return clearGraphicsRegionMsg(id)
+	}
+}
+
+// RegionDamagedMsg is sent when the graphics drawn in a region set with
+// SetGraphicsRegion are lost, as when the terminal is resized, the screen is
+// cleared, or lines are printed above the view. Set the region again to
+// redraw them.
+type RegionDamagedMsg struct {
+	Region GraphicsRegion
+}
+
+// setGraphicsRegionMsg is an internal message that sets a graphics region.
+// To send a setGraphicsRegionMsg, use the SetGraphicsRegion command.
+type setGraphicsRegionMsg struct {
+	region   GraphicsRegion
+	graphics string
+}
+
+func (setGraphicsRegionMsg) internalMsg() {}
+
+// clearGraphicsRegionMsg is an internal message that clears a graphics
+// region. To send a clearGraphicsRegionMsg, use the ClearGraphicsRegion
+// command.
+type clearGraphicsRegionMsg string
+
+func (clearGraphicsRegionMsg) internalMsg() {}
+
+// graphicsRegion is a graphics region set on the renderer, and the graphics
+// to draw in it, until they're drawn.
+type graphicsRegion struct {
+	GraphicsRegion
+	graphics string
+}
+
+// setGraphicsRegion sets a graphics region, drawing its graphics right away
+// if the lines it starts on have been rendered, or after the next flush
+// otherwise.
+func (r *standardRenderer) setGraphicsRegion(region GraphicsRegion, graphics string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	g := graphicsRegion{GraphicsRegion: region, graphics: graphics}
+	replaced := false
+	for i := range r.graphicsRegions {
+		if r.graphicsRegions[i].ID == region.ID {
+			r.graphicsRegions[i] = g
+			replaced = true
+		}
+	}
+	if !replaced {
+		r.graphicsRegions = append(r.graphicsRegions, g)
+	}
+
+	// The lines around the region are painted again, around it.
+	r.lastRender = ""
+	for i := range r.lastLines {
+		if region.contains(i) {
+			r.lastLines[i] = "\n"
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	r.drawGraphics(r.newOutput(buf))
+	_, _ = r.out.Write(buf.Bytes())
+}
+
+// drawGraphics draws the graphics of the regions that haven't been drawn yet,
+// if the line they start on has been rendered. The cursor is saved and
+// restored around them, as we can't tell where they leave it.
+func (r *standardRenderer) drawGraphics(out *termenv.Output) {
+	for i := range r.graphicsRegions {
+		g := &r.graphicsRegions[i]
+		if g.graphics == "" || g.Line >= r.linesRendered {
+			continue
+		}
+		_, _ = out.WriteString(saveCursorSeq)
+		head := r.renderingHead
+		r.moveRenderingHead(out, g.Line)
+		out.CursorBack(r.width)
+		if g.Col > 0 {
+			out.CursorForward(g.Col)
+		}
+		_, _ = out.WriteString(g.graphics)
+		_, _ = out.WriteString(restoreCursorSeq)
+		r.renderingHead = head
+		g.graphics = ""
+	}
+}
+
+// clearGraphicsRegion erases the region with the given ID, and paints the
+// lines of the view it covered again on the next flush.
+func (r *standardRenderer) clearGraphicsRegion(id string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	buf := &bytes.Buffer{}
+	out := r.newOutput(buf)
+	regions := r.graphicsRegions[:0]
+	for _, g := range r.graphicsRegions {
+		if g.ID != id {
+			regions = append(regions, g)
+			continue
+		}
+		for i := g.Line; i < g.Line+g.Rows && i < r.linesRendered; i++ {
+			r.moveRenderingHead(out, i)
+			out.CursorBack(r.width)
+			if g.Col > 0 {
+				out.CursorForward(g.Col)
+			}
+			fmt.Fprintf(out, termenv.CSI+"%dX", g.Cols)
+			if i < len(r.lastLines) {
+				r.lastLines[i] = "\n"
+			}
+		}
+	}
+	r.graphicsRegions = regions
+	r.lastRender = ""
+	if buf.Len() > 0 {
+		r.placeCursor(out)
+		_, _ = r.out.Write(buf.Bytes())
+	}
+}
+
+// damageGraphics forgets the graphics drawn in the graphics regions, which
+// are lost, and asks for them again. The regions stay set, and are left
+// blank until they are.
+func (r *standardRenderer) damageGraphics() {
+	for i := range r.graphicsRegions {
+		r.graphicsRegions[i].graphics = ""
+		if r.onRegionDamaged != nil {
+			r.onRegionDamaged(RegionDamagedMsg{Region: r.graphicsRegions[i].GraphicsRegion})
+		}
+	}
+}
+
+// graphicsSpans returns the columns of a line taken up by graphics regions,
+// as pairs of the first column and the one past the last, in order.
+func (r *standardRenderer) graphicsSpans(line int) [][2]int {
+	var spans [][2]int
+	for _, g := range r.graphicsRegions {
+		if !g.contains(line) || g.Cols <= 0 {
+			continue
+		}
+		span := [2]int{g.Col, g.Col + g.Cols}
+		i := len(spans)
+		for i > 0 && spans[i-1][0] > span[0] {
+			i--
+		}
+		spans = append(spans, [2]int{})
+		copy(spans[i+1:], spans[i:])
+		spans[i] = span
+	}
+	return spans
+}
+
+// paintAround writes a line of the view, from the start of the line, leaving
+// the cells of the graphics regions on it alone. The cells around them are
+// overwritten, with spaces where the line doesn't reach, and what's past the
+// last one is cleared.
+func (r *standardRenderer) paintAround(out *termenv.Output, line string, spans [][2]int) {
+	col := 0
+	for _, span := range spans {
+		if span[0] > col {
+			part := cutLine(line, col, span[0], r.widthCond)
+			_, _ = out.WriteString(part)
+			_, _ = out.WriteString(strings.Repeat(" ", span[0]-col-stringWidth(part, r.widthCond)))
+			col = span[0]
+		}
+		if span[1] > col {
+			out.CursorForward(span[1] - col)
+			col = span[1]
+		}
+	}
+	_, _ = out.WriteString(cutLine(line, col, -1, r.widthCond))
+	out.ClearLineRight()
+}
+
+// cutLine returns the cells of s from the first column given up to but not
+// including the last, or to the end if it's negative. The escape sequences
+// before the first column are kept, so the text keeps its style, and the
+// style is reset at the end. Wide characters cut in two are left out, with a
+// space in place of the part at the start.
+func cutLine(s string, from, to int, cond *runewidth.Condition) string {
+	var (
+		b      strings.Builder
+		cells  int
+		styled bool
+	)
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			n := ansi.SequenceLen(s[i:])
+			b.WriteString(s[i : i+n])
+			styled = true
+			i += n
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		w := cond.RuneWidth(c)
+		switch {
+		case to >= 0 && cells+w > to:
+			i = len(s)
+			continue
+		case cells >= from:
+			b.WriteString(s[i : i+size])
+		case cells+w > from:
+			b.WriteString(strings.Repeat(" ", cells+w-from))
+		}
+		cells += w
+		i += size
+	}
+	if styled {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+const testSixel = "\x1bPq#0;2;0;0;0#0~~@@vv@@~~\x1b\\"
+
+// newGraphicsRenderer returns a renderer with a three line view rendered,
+// and a graphics region set over columns 2 to 5 of its first two lines,
+// along with the regions reported as damaged.
+func newGraphicsRenderer(t *testing.T) (*standardRenderer, *bytes.Buffer, *[]RegionDamagedMsg) {
+	t.Helper()
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.width, r.height = 20, 10
+	damaged := &[]RegionDamagedMsg{}
+	r.onRegionDamaged = func(msg RegionDamagedMsg) {
+		*damaged = append(*damaged, msg)
+	}
+
+	r.write("aa    aaaa\nbb    bbbb\ncccc")
+	r.flush()
+	buf.Reset()
+
+	r.handleMessages(setGraphicsRegionMsg{
+		region:   GraphicsRegion{ID: "img", Line: 0, Col: 2, Rows: 2, Cols: 4},
+		graphics: testSixel,
+	})
+	if !strings.Contains(buf.String(), testSixel) {
+		t.Fatalf("expected the graphics to be drawn, got %q", buf.String())
+	}
+	buf.Reset()
+	return r, &buf, damaged
+}
+
+func TestGraphicsRegionLeftAlone(t *testing.T) {
+	r, buf, _ := newGraphicsRenderer(t)
+
+	// Every line changes, and the ones through the region are painted
+	// around it.
+	r.write("AA    AAAA\nBBBBBBBBBBBB\nCCCC")
+	r.flush()
+	out := buf.String()
+	expected := "\x1b[20DAA\x1b[4CAAAA\x1b[0K\r\nBB\x1b[4CBBBBBB\x1b[0K\r\n"
+	if !strings.Contains(out, expected) {
+		t.Errorf("expected the lines to be painted around the region as %q, got %q", expected, out)
+	}
+	if strings.Count(out, "\x1b[2K") != 1 {
+		t.Errorf("expected only the last line to be cleared, got %q", out)
+	}
+	if strings.Contains(out, testSixel) {
+		t.Errorf("expected the graphics not to be drawn again, got %q", out)
+	}
+}
+
+func TestGraphicsRegionDamaged(t *testing.T) {
+	r, buf, damaged := newGraphicsRenderer(t)
+
+	r.handleMessages(WindowSizeMsg{Width: 30, Height: 10})
+	if len(*damaged) != 1 || (*damaged)[0].Region.ID != "img" {
+		t.Fatalf("expected the region to be damaged, got %+v", *damaged)
+	}
+
+	// The region is left blank until it's set again.
+	r.write("aa    aaaa\nbb    bbbb\ncccc")
+	r.flush()
+	if strings.Contains(buf.String(), testSixel) || !strings.Contains(buf.String(), "aa\x1b[4Caaaa") {
+		t.Errorf("expected the lines to be painted around the blank region, got %q", buf.String())
+	}
+
+	// The same size again doesn't damage it.
+	r.handleMessages(WindowSizeMsg{Width: 30, Height: 10})
+	if len(*damaged) != 1 {
+		t.Errorf("expected no more damage, got %+v", *damaged)
+	}
+}
+
+func TestClearGraphicsRegion(t *testing.T) {
+	r, buf, _ := newGraphicsRenderer(t)
+
+	r.handleMessages(clearGraphicsRegionMsg("img"))
+	if n := strings.Count(buf.String(), "\x1b[4X"); n != 2 {
+		t.Errorf("expected both rows of the region to be erased, got %q", buf.String())
+	}
+	buf.Reset()
+
+	// The lines are painted in full again.
+	r.write("aa    aaaa\nbb    bbbb\ncccc")
+	r.flush()
+	if strings.Count(buf.String(), "\x1b[2K") != 2 || !strings.Contains(buf.String(), "aa    aaaa\r\nbb    bbbb") {
+		t.Errorf("expected the lines to be painted again, got %q", buf.String())
+	}
+}
+
+func TestCutLine(t *testing.T) {
+	cond := newWidthCondition(false)
+	tests := []struct {
+		line     string
+		from, to int
+		expected string
+	}{
+		{"abcdef", 2, 4, "cd"},
+		{"abcdef", 4, -1, "ef"},
+		{"ab", 4, -1, ""},
+		{"\x1b[1mabcdef", 2, 4, "\x1b[1mcd\x1b[0m"},
+		{"a日本", 2, -1, " 本"},
+		{"a日本", 0, 2, "a"},
+	}
+	for _, test := range tests {
+		if got := cutLine(test.line, test.from, test.to, cond); got != test.expected {
+			t.Errorf("%q [%d:%d]: expected %q, got %q", test.line, test.from, test.to, test.expected, got)
+		}
+	}
+}
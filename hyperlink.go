@@ -0,0 +1,113 @@
+package tea
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/termenv"
+)
+
+// Hyperlink returns text that links to url, wrapped in OSC 8 sequences, for
+// use in a view. Terminals that support OSC 8 make the text a link, and
+// others show it as plain text.
+//
+// When the program is run with WithHyperlinkFallback, for terminals that
+// don't support OSC 8, the renderer leaves the sequences out and makes the
+// text clickable instead: a left click on it sends an OpenLinkMsg with the
+// URL, which the program can open itself. Clicks are only reported when the
+// mouse is enabled.
+func Hyperlink(url, text string) string {
+	return termenv.OSC + "8;;" + url + termenv.ST + text + termenv.OSC + "8;;" + termenv.ST
+}
+
+// OpenLinkMsg is sent when a link made with Hyperlink is clicked, in programs
+// run with WithHyperlinkFallback. The program can act on it, by opening the
+// URL in a browser, for instance.
+type OpenLinkMsg struct {
+	URL string
+}
+
+// hyperlinkRe matches the OSC 8 sequences that start and end hyperlinks,
+// capturing the URL, which is empty at the end of a link:
+//
+//	OSC 8 ; params ; URL ST
+var hyperlinkRe = regexp.MustCompile("\x1b\\]8;[^;\a\x1b]*;([^\a\x1b]*)(?:\a|\x1b\\\\)")
+
+// linkArea is where the text of a link is in a frame: the line it's on, from
+// 0, and the columns it starts and ends at. Links that span lines take up an
+// area on each of them.
+type linkArea struct {
+	line, start, end int
+	url              string
+}
+
+// extractLinks removes the OSC 8 sequences around hyperlinks from a frame,
+// returning the frame without them and the areas their text takes up.
+func extractLinks(s string, cond *runewidth.Condition) (string, []linkArea) {
+	locs := hyperlinkRe.FindAllStringSubmatchIndex(s, -1)
+	if locs == nil {
+		return s, nil
+	}
+
+	var (
+		b          strings.Builder
+		links      []linkArea
+		line, col  int
+		start      int
+		url        string
+		lastOffset int
+	)
+	b.Grow(len(s))
+
+	// end adds the area of the link being walked, up to the current column.
+	end := func() {
+		if url != "" && col > start {
+			links = append(links, linkArea{line: line, start: start, end: col, url: url})
+		}
+	}
+	// advance writes text to the frame, moving past it.
+	advance := func(text string) {
+		for {
+			i := strings.IndexByte(text, '\n')
+			if i < 0 {
+				col += stringWidth(text, cond)
+				b.WriteString(text)
+				return
+			}
+			col += stringWidth(text[:i], cond)
+			end()
+			b.WriteString(text[:i+1])
+			text = text[i+1:]
+			line, col, start = line+1, 0, 0
+		}
+	}
+
+	for _, loc := range locs {
+		advance(s[lastOffset:loc[0]])
+		end()
+		url, start = s[loc[2]:loc[3]], col
+		lastOffset = loc[1]
+	}
+	advance(s[lastOffset:])
+	end()
+	return b.String(), links
+}
+
+// linkAt returns the URL of the link a mouse event is on, if any, in a frame
+// rendered without OSC 8 sequences.
+func (r *standardRenderer) linkAt(m MouseEvent) (string, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !m.InView {
+		return "", false
+	}
+	line := m.ViewY + r.droppedLines
+	for _, l := range r.links {
+		if l.line == line && m.ViewX >= l.start && m.ViewX < l.end {
+			return l.url, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,143 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+func TestExtractLinks(t *testing.T) {
+	const url = "https://example.com"
+	tests := []struct {
+		name     string
+		frame    string
+		expected string
+		links    []linkArea
+	}{
+		{"none", "plain text", "plain text", nil},
+		{"link", "see " + Hyperlink(url, "here"), "see here", []linkArea{{0, 4, 8, url}}},
+		{
+			"styled",
+			"\x1b[1mab\x1b[0m " + Hyperlink(url, "\x1b[4mlink\x1b[0m") + " after",
+			"\x1b[1mab\x1b[0m \x1b[4mlink\x1b[0m after",
+			[]linkArea{{0, 3, 7, url}},
+		},
+		{"wide", "日本 " + Hyperlink(url, "語"), "日本 語", []linkArea{{0, 5, 7, url}}},
+		{"bel", "\x1b]8;id=1;" + url + "\alink\x1b]8;;\a", "link", []linkArea{{0, 0, 4, url}}},
+		{
+			"lines",
+			"a\n" + Hyperlink(url, "one\ntwo") + "\n" + Hyperlink("b", "x"),
+			"a\none\ntwo\nx",
+			[]linkArea{{1, 0, 3, url}, {2, 0, 3, url}, {3, 0, 1, "b"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frame, links := extractLinks(test.frame, newWidthCondition(false))
+			if frame != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, frame)
+			}
+			if !reflect.DeepEqual(links, test.links) {
+				t.Errorf("expected links %+v, got %+v", test.links, links)
+			}
+		})
+	}
+}
+
+func TestHyperlinkWidth(t *testing.T) {
+	link := Hyperlink("https://example.com/a/long/path", "link")
+	if w := stringWidth(link, newWidthCondition(false)); w != 4 {
+		t.Errorf("expected a width of 4, got %d", w)
+	}
+	if s := truncateLine("> "+link, 4, newWidthCondition(false)); stringWidth(s, newWidthCondition(false)) != 4 || !strings.Contains(s, "example.com") {
+		t.Errorf("expected the link to be cut to 2 cells, got %q", s)
+	}
+}
+
+func TestRendererHyperlinks(t *testing.T) {
+	view := "see " + Hyperlink("https://example.com", "here")
+	click := MouseEvent{X: 5, Y: 0, Action: MouseActionPress, Button: MouseButtonLeft}
+
+	t.Run("supported", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+		r.altScreenActive = true
+		r.write(view)
+		r.flush()
+
+		if !strings.Contains(buf.String(), "\x1b]8;;https://example.com\x1b\\here") {
+			t.Errorf("expected the link to be rendered, got %q", buf.String())
+		}
+		if url, ok := r.linkAt(r.viewMouseEvent(click)); ok {
+			t.Errorf("expected no clickable link, got %q", url)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		var buf bytes.Buffer
+		r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+		r.altScreenActive = true
+		r.linkFallback = true
+		r.write(view)
+		r.flush()
+
+		if strings.Contains(buf.String(), "\x1b]8") || !strings.Contains(buf.String(), "see here") {
+			t.Errorf("expected the link as plain text, got %q", buf.String())
+		}
+		if url, ok := r.linkAt(r.viewMouseEvent(click)); !ok || url != "https://example.com" {
+			t.Errorf("expected a click on the link, got %q", url)
+		}
+		click.X = 2
+		if url, ok := r.linkAt(r.viewMouseEvent(click)); ok {
+			t.Errorf("expected no link before it, got %q", url)
+		}
+	})
+}
+
+// linkModel records the links that are opened, quitting after the first.
+type linkModel struct {
+	opened []string
+}
+
+func (m linkModel) Init() Cmd { return nil }
+
+func (m linkModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(OpenLinkMsg); ok {
+		m.opened = append(m.opened, msg.URL)
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m linkModel) View() string {
+	return "see " + Hyperlink("https://example.com", "here")
+}
+
+func TestOpenLink(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(linkModel{},
+		WithInput(&slowReader{
+			// A click beside the link, then one on it once it's rendered.
+			chunks: []string{"\x1b[<0;2;1M", "\x1b[<0;6;1M"},
+			delay:  100 * time.Millisecond,
+		}),
+		WithOutput(&buf),
+		WithAltScreen(),
+		WithMouseCellMotion(),
+		WithHyperlinkFallback())
+	timer := time.AfterFunc(5*time.Second, p.Quit)
+	defer timer.Stop()
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opened := m.(linkModel).opened; !reflect.DeepEqual(opened, []string{"https://example.com"}) {
+		t.Errorf("expected the link to be opened once, got %q", opened)
+	}
+}
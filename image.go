@@ -0,0 +1,158 @@
+package tea
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+)
+
+// Introducers of the sequences that draw inline images: iTerm2's OSC 1337
+// and the kitty graphics protocol's APC G. The renderer treats them as
+// taking up no cells, like other escape sequences, and never cuts them
+// short.
+const (
+	iterm2ImageSeq = "\x1b]1337;File="
+	kittyImageSeq  = "\x1b_G"
+)
+
+// isImageSequence reports whether seq draws an inline image.
+func isImageSequence(seq string) bool {
+	return strings.HasPrefix(seq, iterm2ImageSeq) || strings.HasPrefix(seq, kittyImageSeq)
+}
+
+// hasImages reports whether s might contain sequences that draw inline
+// images, which most frames don't.
+func hasImages(s string) bool {
+	return strings.Contains(s, iterm2ImageSeq) || strings.Contains(s, kittyImageSeq)
+}
+
+// imageHash returns a hash of the inline images a line draws, or 0 if it
+// draws none, so that lines can be compared by their images without keeping
+// their payloads around.
+func imageHash(line string) uint64 {
+	if !hasImages(line) {
+		return 0
+	}
+	h := fnv.New64a()
+	for s := line; ; {
+		i := strings.IndexByte(s, '\x1b')
+		if i < 0 {
+			break
+		}
+		n := ansi.SequenceLen(s[i:])
+		if seq := s[i : i+n]; isImageSequence(seq) {
+			_, _ = h.Write([]byte(seq))
+		}
+		s = s[i+n:]
+	}
+	if sum := h.Sum64(); sum != 0 {
+		return sum
+	}
+	return 1
+}
+
+// stripImages removes the sequences that draw inline images from a line,
+// for when the images it draws are already on screen.
+func stripImages(line string) string {
+	var b strings.Builder
+	b.Grow(len(line))
+	for {
+		i := strings.IndexByte(line, '\x1b')
+		if i < 0 {
+			b.WriteString(line)
+			return b.String()
+		}
+		n := ansi.SequenceLen(line[i:])
+		b.WriteString(line[:i])
+		if seq := line[i : i+n]; !isImageSequence(seq) {
+			b.WriteString(seq)
+		}
+		line = line[i+n:]
+	}
+}
+
+// imageRowsMarker surrounds the number of rows in the marker ImageRows
+// returns. Like the cursor marker, it's a noncharacter, which text doesn't
+// contain.
+const imageRowsMarker = "\uFDD1"
+
+// ImageRows returns a marker to put on the line of the view an inline image
+// is drawn on, saying how many rows of the screen the image covers, from
+// that line down. The view should leave the lines below it that the image
+// covers empty.
+//
+// While the image on the line stays the same, the renderer leaves those rows
+// alone, rather than clearing them when the lines around the image change,
+// which would erase parts of the image in some terminals. The marker is
+// removed before the view is rendered.
+func ImageRows(rows int) string {
+	return imageRowsMarker + strconv.Itoa(rows) + imageRowsMarker
+}
+
+// imageRegion is the rows of a frame an inline image covers: the line it's
+// drawn on, from 0, and the number of rows from there down.
+type imageRegion struct {
+	line, rows int
+}
+
+// extractImageRows removes the markers made with ImageRows from a frame,
+// returning the regions they mark.
+func extractImageRows(s string) (string, []imageRegion) {
+	if !strings.Contains(s, imageRowsMarker) {
+		return s, nil
+	}
+
+	var (
+		b       strings.Builder
+		regions []imageRegion
+		line    int
+	)
+	b.Grow(len(s))
+	for {
+		i := strings.Index(s, imageRowsMarker)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), regions
+		}
+		b.WriteString(s[:i])
+		line += strings.Count(s[:i], "\n")
+		s = s[i+len(imageRowsMarker):]
+
+		j := strings.Index(s, imageRowsMarker)
+		if j < 0 {
+			// A stray marker, without a number.
+			continue
+		}
+		if rows, err := strconv.Atoi(s[:j]); err == nil && rows > 1 {
+			regions = append(regions, imageRegion{line: line, rows: rows})
+		}
+		s = s[j+len(imageRowsMarker):]
+	}
+}
+
+// coveredRows marks the rows of the lines about to be rendered that are
+// covered by an inline image that's already on screen, drawn by the same
+// line as before, so they aren't cleared or painted. Lines dropped from the
+// top of the frame shift where its regions are.
+func (r *standardRenderer) coveredRows(covered []bool, hashes []uint64) {
+	for _, region := range r.imageRegions {
+		top := region.line - r.droppedLines
+		if top < 0 || top >= len(hashes) || top >= len(r.imageHashes) ||
+			hashes[top] == 0 || hashes[top] != r.imageHashes[top] {
+			continue
+		}
+		for i := top + 1; i < top+region.rows && i < len(covered); i++ {
+			covered[i] = true
+		}
+	}
+}
+
+// imageOnScreen reports whether the images line i of the frame being
+// rendered draws are on screen already, drawn by the same line of the last
+// frame. Printed lines push the view down, leaving them behind.
+func (r *standardRenderer) imageOnScreen(i int, hashes []uint64, flushQueuedMessages bool) bool {
+	return !flushQueuedMessages && i < len(hashes) && i < len(r.imageHashes) &&
+		hashes[i] != 0 && hashes[i] == r.imageHashes[i]
+}
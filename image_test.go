@@ -0,0 +1,95 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// testImage is an iTerm2 inline image, with a payload much longer than a
+// line of the screen.
+var testImage = iterm2ImageSeq + "inline=1:" + strings.Repeat("iVBORw0KGgo", 200) + "\a"
+
+func TestImageWidth(t *testing.T) {
+	cond := newWidthCondition(false)
+	kitty := kittyImageSeq + "a=T,f=100;" + strings.Repeat("QUJD", 100) + "\x1b\\"
+	for _, img := range []string{testImage, kitty} {
+		if w := stringWidth("ab"+img+"cd", cond); w != 4 {
+			t.Errorf("expected a width of 4, got %d", w)
+		}
+		if s := truncateLine("ab"+img+"cdef", 3, cond); !strings.HasPrefix(s, "ab"+img+"c") {
+			t.Errorf("expected the image to be kept whole, got %q", s)
+		}
+	}
+}
+
+func TestImageRedrawnOnlyWhenChanged(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.width = 80
+	render := func(view string) string {
+		buf.Reset()
+		r.write(view)
+		r.flush()
+		return buf.String()
+	}
+
+	if out := render("a" + testImage + "b\ncount 1"); !strings.Contains(out, testImage) {
+		t.Fatalf("expected the image to be drawn, got %q", out)
+	}
+
+	// Text next to the image changes, on its line and the next.
+	out := render("a" + testImage + "c\ncount 2")
+	if strings.Contains(out, testImage) {
+		t.Errorf("expected the image not to be drawn again, got %q", out)
+	}
+	if !strings.Contains(out, "ac\x1b[0K") || !strings.Contains(out, "count 2") {
+		t.Errorf("expected the text to be redrawn, got %q", out)
+	}
+
+	// Printing above the view pushes it down, so the image is drawn again.
+	r.handleMessages(printLineMessage{messageBody: "printed"})
+	if out := render("a" + testImage + "c\ncount 3"); !strings.Contains(out, testImage) {
+		t.Errorf("expected the image to be drawn again, got %q", out)
+	}
+
+	other := strings.Replace(testImage, "inline=1", "inline=1;width=2", 1)
+	if out := render("a" + other + "c\ncount 3"); !strings.Contains(out, other) {
+		t.Errorf("expected the new image to be drawn, got %q", out)
+	}
+}
+
+func TestExtractImageRows(t *testing.T) {
+	frame, regions := extractImageRows("top\n" + ImageRows(3) + "img\n\n\nx" + ImageRows(1) + "\n" + imageRowsMarker)
+	if frame != "top\nimg\n\n\nx\n" {
+		t.Errorf("expected the markers to be removed, got %q", frame)
+	}
+	if expected := []imageRegion{{line: 1, rows: 3}}; !reflect.DeepEqual(regions, expected) {
+		t.Errorf("expected regions %+v, got %+v", expected, regions)
+	}
+}
+
+func TestImageRows(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.width = 80
+	view := func(count string) string {
+		return "top\n" + ImageRows(3) + testImage + "\n\n\n" + count
+	}
+
+	r.write(view("count 1"))
+	r.flush()
+	buf.Reset()
+
+	// The last line changes, and the first is always redrawn, but the rows
+	// the image covers aren't touched.
+	r.write(view("count 2"))
+	r.flush()
+	expected := "\x1b[80D\x1b[2K\x1b[4A\x1b[80D\x1b[2Ktop\r\n\x1b[3Bcount 2\x1b[80D"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
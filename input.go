@@ -0,0 +1,82 @@
+package tea
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/muesli/cancelreader"
+)
+
+// additionalInput is an input source read alongside the program's main input.
+type additionalInput struct {
+	input io.Reader
+	parse func([]byte) Msg
+
+	reader cancelreader.CancelReader
+	done   chan struct{}
+}
+
+// startAdditionalInputs starts reading from all additional input sources, each
+// on its own goroutine.
+func (p *Program) startAdditionalInputs() error {
+	for _, in := range p.additionalInputs {
+		r, err := cancelreader.NewReader(in.input)
+		if err != nil {
+			return fmt.Errorf("error creating cancelreader: %w", err)
+		}
+		in.reader = r
+		in.done = make(chan struct{})
+		go p.readAdditionalInput(in)
+	}
+	return nil
+}
+
+// readAdditionalInput sends the messages parsed from an additional input
+// source until the source is exhausted or the program shuts down, followed by
+// an InputEOFMsg once it's exhausted. Errors reading from the source only
+// stop this source.
+func (p *Program) readAdditionalInput(in *additionalInput) {
+	defer close(in.done)
+
+	var buf [4096]byte
+	for {
+		n, err := in.reader.Read(buf[:])
+		if n > 0 {
+			if msg := in.parse(append([]byte(nil), buf[:n]...)); msg != nil {
+				select {
+				case p.msgs <- stampInput(msg, time.Now()):
+				case <-p.ctx.Done():
+					return
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			select {
+			case p.msgs <- InputEOFMsg{Input: in.input}:
+			case <-p.ctx.Done():
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stopAdditionalInputs stops reading from the additional input sources.
+func (p *Program) stopAdditionalInputs() {
+	for _, in := range p.additionalInputs {
+		if in.reader == nil {
+			continue
+		}
+		if in.reader.Cancel() {
+			select {
+			case <-in.done:
+			case <-time.After(500 * time.Millisecond): //nolint:gomnd
+				// The reader couldn't be canceled after all.
+			}
+		}
+		_ = in.reader.Close()
+	}
+}
@@ -0,0 +1,122 @@
+package tea
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+type sourceMsg struct {
+	source string
+	data   string
+}
+
+type sourceModel struct {
+	received chan sourceMsg
+	eof      chan io.Reader
+}
+
+func (m sourceModel) Init() Cmd {
+	return nil
+}
+
+func (m sourceModel) Update(msg Msg) (Model, Cmd) {
+	switch msg := msg.(type) {
+	case sourceMsg:
+		m.received <- msg
+	case InputEOFMsg:
+		m.eof <- msg.Input
+	}
+	return m, nil
+}
+
+func (m sourceModel) View() string {
+	return ""
+}
+
+func TestAdditionalInputs(t *testing.T) {
+	parser := func(source string) func([]byte) Msg {
+		return func(b []byte) Msg {
+			return sourceMsg{source: source, data: string(b)}
+		}
+	}
+
+	ra, wa, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ra.Close() //nolint:errcheck
+	rb, wb, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close() //nolint:errcheck
+	defer wb.Close() //nolint:errcheck
+
+	var buf bytes.Buffer
+	m := sourceModel{received: make(chan sourceMsg), eof: make(chan io.Reader, 1)}
+	p := NewProgram(m,
+		WithInput(nil),
+		WithOutput(&buf),
+		WithAdditionalInput(ra, parser("a")),
+		WithAdditionalInput(rb, parser("b")),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := p.Run(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	expect := func(w *os.File, source, data string) {
+		t.Helper()
+		if _, err := w.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-m.received:
+			if msg.source != source || msg.data != data {
+				t.Fatalf("expected %q from %s, got %q from %s", data, source, msg.data, msg.source)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q from %s", data, source)
+		}
+	}
+
+	expect(wa, "a", "one")
+	expect(wb, "b", "two")
+	expect(wa, "a", "three")
+	expect(wb, "b", "four")
+
+	// Closing a source reports its end, and doesn't affect the others.
+	if err := wa.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case in := <-m.eof:
+		if in != ra {
+			t.Errorf("expected the end of source a, got %v", in)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the end of source a")
+	}
+	expect(wb, "b", "five")
+
+	p.Quit()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return")
+	}
+	for _, in := range p.additionalInputs {
+		select {
+		case <-in.done:
+		default:
+			t.Error("additional input is still being read")
+		}
+	}
+}
@@ -12,3 +12,9 @@ import (
 func newInputReader(r io.Reader) (cancelreader.CancelReader, error) {
 	return cancelreader.NewReader(r)
 }
+
+// isConsoleInputReader reports whether r reads from the Windows console
+// directly, which is never the case outside of Windows.
+func isConsoleInputReader(cancelreader.CancelReader) bool {
+	return false
+}
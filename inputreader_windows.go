@@ -65,6 +65,13 @@ func newInputReader(r io.Reader) (cancelreader.CancelReader, error) {
 	}, nil
 }
 
+// isConsoleInputReader reports whether r reads from the Windows console
+// directly rather than from a stream of VT sequences.
+func isConsoleInputReader(r cancelreader.CancelReader) bool {
+	_, ok := r.(*conInputReader)
+	return ok
+}
+
 // Cancel implements cancelreader.CancelReader.
 func (r *conInputReader) Cancel() bool {
 	r.setCanceled()
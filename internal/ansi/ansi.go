@@ -0,0 +1,64 @@
+// Package ansi scans the ANSI escape sequences in text written to a terminal.
+package ansi
+
+import "strings"
+
+// SequenceLen returns the length of the escape sequence s starts with. CSI
+// sequences run to their final byte, strings such as OSC, DCS and APC run to
+// their BEL or ST terminator, whatever they hold, and other escapes run past
+// their intermediate bytes to their final one. Sequences cut short run to the
+// end of s.
+func SequenceLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		// CSI: parameters and intermediates up to a final byte.
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']', 'P', '_', '^':
+		// OSC and other strings, terminated by BEL or ST.
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	// Other escapes, such as "\x1b(B", may have intermediates before their
+	// final byte.
+	i := 1
+	for i < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+		i++
+	}
+	if i < len(s) {
+		i++
+	}
+	return i
+}
+
+// Strip removes escape sequences from s, leaving the text. A sequence cut
+// short at the end of s is removed too.
+func Strip(s string) string {
+	i := strings.IndexByte(s, '\x1b')
+	if i < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for ; i >= 0; i = strings.IndexByte(s, '\x1b') {
+		b.WriteString(s[:i])
+		s = s[i+SequenceLen(s[i:]):]
+	}
+	b.WriteString(s)
+	return b.String()
+}
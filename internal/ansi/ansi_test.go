@@ -0,0 +1,50 @@
+package ansi
+
+import "testing"
+
+func TestSequenceLen(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		len  int
+	}{
+		{"csi", "\x1b[38;5;1mred", 9},
+		{"csi with tilde", "\x1b[2~a", 4},
+		{"osc with bel", "\x1b]0;title\atext", 10},
+		{"osc with st", "\x1b]8;;http://x.com\x1b\\a", 19},
+		{"dcs", "\x1bPq#0;2;0;0;0\x1b\\a", 15},
+		{"apc", "\x1b_Gf=100;AAAA\x1b\\a", 15},
+		{"two bytes", "\x1b7saved", 2},
+		{"intermediate", "\x1b(Bab", 3},
+		{"cut short", "\x1b[1;2", 5},
+		{"cut short string", "\x1b]0;title", 9},
+		{"lone escape", "\x1b", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if n := SequenceLen(test.s); n != test.len {
+				t.Errorf("expected %d, got %d", test.len, n)
+			}
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	for in, expected := range map[string]string{
+		"plain":                           "plain",
+		"\x1b[38;5;1mred\x1b[0m":          "red",
+		"\x1b]0;title\x07text":            "text",
+		"\x1b]52;c;?\x1b\\text":           "text",
+		"\x1b]8;;http://x.com\x1b\\link":  "link",
+		"\x1b7saved\x1b8":                 "saved",
+		"\x1b(B\x1b[mreset":               "reset",
+		"\x1b[?25l\x1b[2J\x1b[1;1Hscreen": "screen",
+		"trailing\x1b[":                   "trailing",
+		"trailing\x1b":                    "trailing",
+	} {
+		if s := Strip(in); s != expected {
+			t.Errorf("expected %q to be stripped to %q, got %q", in, expected, s)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package tea
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -50,12 +53,46 @@ func (k KeyMsg) String() (str string) {
 	return Key(k).String()
 }
 
+// KeyReleaseMsg is sent when a key is released. Key releases are only
+// reported by terminals supporting the kitty keyboard protocol, and only
+// after they've been enabled with the WithKeyReleases ProgramOption or the
+// EnableKeyReleases command.
+type KeyReleaseMsg Key
+
+// String returns a string representation for a key release message.
+func (k KeyReleaseMsg) String() string {
+	return Key(k).String()
+}
+
 // Key contains information about a keypress.
+//
+// Ctrl and Shift are only set when the modifier can't be expressed by the
+// key type itself, such as ctrl+shift+p reported by terminals using the
+// CSI u or modifyOtherKeys encodings. Legacy combinations like ctrl+a or
+// shift+up are still reported through their dedicated key types.
 type Key struct {
 	Type  KeyType
 	Runes []rune
 	Alt   bool
+	Ctrl  bool
+	Shift bool
 	Paste bool
+
+	// PasteTruncated is set on a paste that was cut short, because it was
+	// too long, or because the terminal never marked where it ends. What
+	// was pasted after the cut is dropped in the first case, and read as
+	// keys in the second.
+	PasteTruncated bool
+
+	// Repeat is set when the terminal reports the key as auto-repeating
+	// because it's being held down. Only terminals supporting the kitty
+	// keyboard protocol report this, and only when key releases are enabled.
+	Repeat bool
+
+	// Time is when the key was read from the input, or when it was passed
+	// to Program.Send. It holds a monotonic clock reading, so the time
+	// between two events can be measured reliably with Sub.
+	Time time.Time
 }
 
 // String returns a friendly string representation for a key. It's safe (and
@@ -69,6 +106,12 @@ func (k Key) String() (str string) {
 	if k.Alt {
 		buf.WriteString("alt+")
 	}
+	if k.Ctrl {
+		buf.WriteString("ctrl+")
+	}
+	if k.Shift {
+		buf.WriteString("shift+")
+	}
 	if k.Type == KeyRunes {
 		if k.Paste {
 			// Note: bubbles/keys bindings currently do string compares to
@@ -351,7 +394,7 @@ var keyNames = map[KeyType]string{
 }
 
 // Sequence mappings.
-var sequences = map[string]Key{
+var sequences = withModifiedKeys(map[string]Key{
 	// Arrow keys
 	"\x1b[A":    {Type: KeyUp},
 	"\x1b[B":    {Type: KeyDown},
@@ -403,23 +446,14 @@ var sequences = map[string]Key{
 	// Miscellaneous keys
 	"\x1b[Z": {Type: KeyShiftTab},
 
-	"\x1b[2~":   {Type: KeyInsert},
-	"\x1b[3;2~": {Type: KeyInsert, Alt: true},
+	"\x1b[2~": {Type: KeyInsert},
+	"\x1b[3~": {Type: KeyDelete},
 
-	"\x1b[3~":   {Type: KeyDelete},
-	"\x1b[3;3~": {Type: KeyDelete, Alt: true},
+	"\x1b[5~": {Type: KeyPgUp},
+	"\x1b[5^": {Type: KeyCtrlPgUp}, // urxvt
 
-	"\x1b[5~":   {Type: KeyPgUp},
-	"\x1b[5;3~": {Type: KeyPgUp, Alt: true},
-	"\x1b[5;5~": {Type: KeyCtrlPgUp},
-	"\x1b[5^":   {Type: KeyCtrlPgUp}, // urxvt
-	"\x1b[5;7~": {Type: KeyCtrlPgUp, Alt: true},
-
-	"\x1b[6~":   {Type: KeyPgDown},
-	"\x1b[6;3~": {Type: KeyPgDown, Alt: true},
-	"\x1b[6;5~": {Type: KeyCtrlPgDown},
-	"\x1b[6^":   {Type: KeyCtrlPgDown}, // urxvt
-	"\x1b[6;7~": {Type: KeyCtrlPgDown, Alt: true},
+	"\x1b[6~": {Type: KeyPgDown},
+	"\x1b[6^": {Type: KeyCtrlPgDown}, // urxvt
 
 	"\x1b[1~":   {Type: KeyHome},
 	"\x1b[H":    {Type: KeyHome},                     // xterm, lxterm
@@ -464,11 +498,6 @@ var sequences = map[string]Key{
 	"\x1bOR": {Type: KeyF3}, // vt100, xterm
 	"\x1bOS": {Type: KeyF4}, // vt100, xterm
 
-	"\x1b[1;3P": {Type: KeyF1, Alt: true}, // vt100, xterm
-	"\x1b[1;3Q": {Type: KeyF2, Alt: true}, // vt100, xterm
-	"\x1b[1;3R": {Type: KeyF3, Alt: true}, // vt100, xterm
-	"\x1b[1;3S": {Type: KeyF4, Alt: true}, // vt100, xterm
-
 	"\x1b[11~": {Type: KeyF1}, // urxvt
 	"\x1b[12~": {Type: KeyF2}, // urxvt
 	"\x1b[13~": {Type: KeyF3}, // urxvt
@@ -476,49 +505,21 @@ var sequences = map[string]Key{
 
 	"\x1b[15~": {Type: KeyF5}, // vt100, xterm, also urxvt
 
-	"\x1b[15;3~": {Type: KeyF5, Alt: true}, // vt100, xterm, also urxvt
-
 	"\x1b[17~": {Type: KeyF6},  // vt100, xterm, also urxvt
 	"\x1b[18~": {Type: KeyF7},  // vt100, xterm, also urxvt
 	"\x1b[19~": {Type: KeyF8},  // vt100, xterm, also urxvt
 	"\x1b[20~": {Type: KeyF9},  // vt100, xterm, also urxvt
 	"\x1b[21~": {Type: KeyF10}, // vt100, xterm, also urxvt
 
-	"\x1b[17;3~": {Type: KeyF6, Alt: true},  // vt100, xterm
-	"\x1b[18;3~": {Type: KeyF7, Alt: true},  // vt100, xterm
-	"\x1b[19;3~": {Type: KeyF8, Alt: true},  // vt100, xterm
-	"\x1b[20;3~": {Type: KeyF9, Alt: true},  // vt100, xterm
-	"\x1b[21;3~": {Type: KeyF10, Alt: true}, // vt100, xterm
-
 	"\x1b[23~": {Type: KeyF11}, // vt100, xterm, also urxvt
 	"\x1b[24~": {Type: KeyF12}, // vt100, xterm, also urxvt
 
-	"\x1b[23;3~": {Type: KeyF11, Alt: true}, // vt100, xterm
-	"\x1b[24;3~": {Type: KeyF12, Alt: true}, // vt100, xterm
-
-	"\x1b[1;2P": {Type: KeyF13},
-	"\x1b[1;2Q": {Type: KeyF14},
-
 	"\x1b[25~": {Type: KeyF13}, // vt100, xterm, also urxvt
 	"\x1b[26~": {Type: KeyF14}, // vt100, xterm, also urxvt
 
-	"\x1b[25;3~": {Type: KeyF13, Alt: true}, // vt100, xterm
-	"\x1b[26;3~": {Type: KeyF14, Alt: true}, // vt100, xterm
-
-	"\x1b[1;2R": {Type: KeyF15},
-	"\x1b[1;2S": {Type: KeyF16},
-
 	"\x1b[28~": {Type: KeyF15}, // vt100, xterm, also urxvt
 	"\x1b[29~": {Type: KeyF16}, // vt100, xterm, also urxvt
 
-	"\x1b[28;3~": {Type: KeyF15, Alt: true}, // vt100, xterm
-	"\x1b[29;3~": {Type: KeyF16, Alt: true}, // vt100, xterm
-
-	"\x1b[15;2~": {Type: KeyF17},
-	"\x1b[17;2~": {Type: KeyF18},
-	"\x1b[18;2~": {Type: KeyF19},
-	"\x1b[19;2~": {Type: KeyF20},
-
 	"\x1b[31~": {Type: KeyF17},
 	"\x1b[32~": {Type: KeyF18},
 	"\x1b[33~": {Type: KeyF19},
@@ -529,6 +530,109 @@ var sequences = map[string]Key{
 	"\x1bOB": {Type: KeyDown, Alt: false},
 	"\x1bOC": {Type: KeyRight, Alt: false},
 	"\x1bOD": {Type: KeyLeft, Alt: false},
+
+	// Keypad keys in application keypad mode (DECKPAM). Some programs leave
+	// the keypad in this mode; report the keys as if it were in numeric mode.
+	"\x1bOp": {Type: KeyRunes, Runes: []rune{'0'}}, // vt100, xterm
+	"\x1bOq": {Type: KeyRunes, Runes: []rune{'1'}}, // vt100, xterm
+	"\x1bOr": {Type: KeyRunes, Runes: []rune{'2'}}, // vt100, xterm
+	"\x1bOs": {Type: KeyRunes, Runes: []rune{'3'}}, // vt100, xterm
+	"\x1bOt": {Type: KeyRunes, Runes: []rune{'4'}}, // vt100, xterm
+	"\x1bOu": {Type: KeyRunes, Runes: []rune{'5'}}, // vt100, xterm
+	"\x1bOv": {Type: KeyRunes, Runes: []rune{'6'}}, // vt100, xterm
+	"\x1bOw": {Type: KeyRunes, Runes: []rune{'7'}}, // vt100, xterm
+	"\x1bOx": {Type: KeyRunes, Runes: []rune{'8'}}, // vt100, xterm
+	"\x1bOy": {Type: KeyRunes, Runes: []rune{'9'}}, // vt100, xterm
+	"\x1bOj": {Type: KeyRunes, Runes: []rune{'*'}}, // xterm
+	"\x1bOk": {Type: KeyRunes, Runes: []rune{'+'}}, // xterm
+	"\x1bOl": {Type: KeyRunes, Runes: []rune{','}}, // vt100
+	"\x1bOm": {Type: KeyRunes, Runes: []rune{'-'}}, // vt100, xterm
+	"\x1bOn": {Type: KeyRunes, Runes: []rune{'.'}}, // vt100, xterm
+	"\x1bOo": {Type: KeyRunes, Runes: []rune{'/'}}, // xterm
+	"\x1bOX": {Type: KeyRunes, Runes: []rune{'='}}, // xterm
+	"\x1bOM": {Type: KeyEnter},                     // vt100, xterm
+})
+
+// Navigation key codes as used in xterm-style CSI n ~ sequences.
+var navigationKeyCodes = map[KeyType]int{
+	KeyHome:   1,
+	KeyInsert: 2,
+	KeyDelete: 3,
+	KeyEnd:    4,
+	KeyPgUp:   5,
+	KeyPgDown: 6,
+}
+
+// Function key codes as used in xterm-style CSI n ~ sequences. F1-F4 are
+// reported as CSI 1 ; m P through S when modified.
+var functionKeyCodes = map[KeyType]int{
+	KeyF5:  15,
+	KeyF6:  17,
+	KeyF7:  18,
+	KeyF8:  19,
+	KeyF9:  20,
+	KeyF10: 21,
+	KeyF11: 23,
+	KeyF12: 24,
+	KeyF13: 25,
+	KeyF14: 26,
+	KeyF15: 28,
+	KeyF16: 29,
+	KeyF17: 31,
+	KeyF18: 32,
+	KeyF19: 33,
+	KeyF20: 34,
+}
+
+// modifiedKeyTypes lists the key types that have dedicated variants for a
+// combination of the ctrl and shift modifiers.
+var modifiedKeyTypes = map[KeyType]map[int]KeyType{
+	KeyUp:     {modShift: KeyShiftUp, modCtrl: KeyCtrlUp, modCtrl | modShift: KeyCtrlShiftUp},
+	KeyDown:   {modShift: KeyShiftDown, modCtrl: KeyCtrlDown, modCtrl | modShift: KeyCtrlShiftDown},
+	KeyRight:  {modShift: KeyShiftRight, modCtrl: KeyCtrlRight, modCtrl | modShift: KeyCtrlShiftRight},
+	KeyLeft:   {modShift: KeyShiftLeft, modCtrl: KeyCtrlLeft, modCtrl | modShift: KeyCtrlShiftLeft},
+	KeyHome:   {modShift: KeyShiftHome, modCtrl: KeyCtrlHome, modCtrl | modShift: KeyCtrlShiftHome},
+	KeyEnd:    {modShift: KeyShiftEnd, modCtrl: KeyCtrlEnd, modCtrl | modShift: KeyCtrlShiftEnd},
+	KeyPgUp:   {modCtrl: KeyCtrlPgUp},
+	KeyPgDown: {modCtrl: KeyCtrlPgDown},
+}
+
+// modifiedKey returns the key for t reported with the given xterm-style
+// modifier parameter. Dedicated key types such as KeyCtrlUp are used where
+// they exist, and the Ctrl and Shift flags are set otherwise.
+func modifiedKey(t KeyType, mod int) Key {
+	m := mod - 1
+	k := Key{Type: t, Alt: m&modAlt != 0, Ctrl: m&modCtrl != 0, Shift: m&modShift != 0}
+	if mt, ok := modifiedKeyTypes[t][m&(modCtrl|modShift)]; ok {
+		k.Type, k.Ctrl, k.Shift = mt, false, false
+	}
+	return k
+}
+
+// withModifiedKeys adds the sequences for keys reported with an xterm-style
+// modifier parameter to the given sequence table. The parameter is one plus
+// a bitmask of shift (1), alt (2) and ctrl (4).
+func withModifiedKeys(seqs map[string]Key) map[string]Key {
+	for mod := 2; mod <= 8; mod++ {
+		for t, code := range navigationKeyCodes {
+			seqs[fmt.Sprintf("\x1b[%d;%d~", code, mod)] = modifiedKey(t, mod) // xterm, tmux
+		}
+		for i, final := range "PQRS" {
+			k := modifiedKey(KeyF1-KeyType(i), mod)
+			seqs[fmt.Sprintf("\x1b[1;%d%c", mod, final)] = k // xterm, tmux
+			seqs[fmt.Sprintf("\x1bO%d%c", mod, final)] = k   // older xterm, konsole
+		}
+		for t, code := range functionKeyCodes {
+			seqs[fmt.Sprintf("\x1b[%d;%d~", code, mod)] = modifiedKey(t, mod) // xterm, tmux
+		}
+	}
+
+	// urxvt reports ctrl with a trailing ^ rather than a parameter.
+	for i, code := range []int{11, 12, 13, 14, 15, 17, 18, 19, 20, 21, 23, 24} {
+		seqs[fmt.Sprintf("\x1b[%d^", code)] = Key{Type: KeyF1 - KeyType(i), Ctrl: true}
+	}
+
+	return seqs
 }
 
 // unknownInputByteMsg is reported by the input reader when an invalid
@@ -537,6 +641,8 @@ var sequences = map[string]Key{
 // to troubleshoot invalid inputs.
 type unknownInputByteMsg byte
 
+func (unknownInputByteMsg) internalMsg() {}
+
 func (u unknownInputByteMsg) String() string {
 	return fmt.Sprintf("?%#02x?", int(u))
 }
@@ -547,6 +653,8 @@ func (u unknownInputByteMsg) String() string {
 // makes it possible to troubleshoot invalid inputs.
 type unknownCSISequenceMsg []byte
 
+func (unknownCSISequenceMsg) internalMsg() {}
+
 func (u unknownCSISequenceMsg) String() string {
 	return fmt.Sprintf("?CSI%+v?", []byte(u)[2:])
 }
@@ -555,91 +663,389 @@ var spaceRunes = []rune{' '}
 
 // readAnsiInputs reads keypress and mouse inputs from a TTY and produces messages
 // containing information about the key or mouse events accordingly.
-func readAnsiInputs(ctx context.Context, msgs chan<- Msg, input io.Reader) error {
+func readAnsiInputs(ctx context.Context, msgs chan<- Msg, input io.Reader, parser *inputParser) error {
 	var buf [256]byte
 
-	var leftOverFromPrevIteration []byte
-loop:
-	for {
-		// Read and block.
-		numBytes, err := input.Read(buf[:])
-		if err != nil {
-			return fmt.Errorf("error reading input: %w", err)
+	send := func(msg Msg) error {
+		msg, ok := parser.deliver(msg)
+		if !ok {
+			return nil
 		}
-		b := buf[:numBytes]
-		if leftOverFromPrevIteration != nil {
-			b = append(leftOverFromPrevIteration, b...)
+		select {
+		case msgs <- stampInput(msg, time.Now()):
+			return nil
+		case <-ctx.Done():
+			err := ctx.Err()
+			if err != nil {
+				err = fmt.Errorf("found context error while reading input: %w", err)
+			}
+			return err
 		}
+	}
 
-		// If we had a short read (numBytes < len(buf)), we're sure that
-		// the end of this read is an event boundary, so there is no doubt
-		// if we are encountering the end of the buffer while parsing a message.
-		// However, if we've succeeded in filling up the buffer, there may
-		// be more data in the OS buffer ready to be read in, to complete
-		// the last message in the input. In that case, we will retry with
-		// the left over data in the next iteration.
-		canHaveMoreData := numBytes == len(buf)
+	// A paste that doesn't fit in a read is collected until its end is
+	// in, and then parsed as a whole. If the terminal doesn't mark its
+	// end, a timer ends it once the input has been quiet for pasteTimeout.
+	// mtx guards what's been read against the timer.
+	var (
+		mtx        sync.Mutex
+		paste      *pendingPaste
+		pasteTimer *time.Timer
+		parse      func(b []byte, canHaveMoreData bool) error
+	)
+
+	// endPaste ends a paste whose end the input went quiet without: at the
+	// last end sequence in it, parsing what was read after it, or cut short
+	// if there's none.
+	endPaste := func() error {
+		pending := paste
+		paste = nil
+		text, rest, ok := pending.endAtLast()
+		if pending.dropping {
+			if ok {
+				return parse(rest, false)
+			}
+			return nil
+		}
+		if !ok {
+			msg := parser.pasteMsg(pending.data)
+			msg.PasteTruncated = true
+			return send(msg)
+		}
+		msg := parser.pasteMsg(text)
+		msg.PasteTruncated = len(text) < pending.lastEnd
+		if err := send(msg); err != nil {
+			return err
+		}
+		return parse(rest, false)
+	}
+	expirePaste := func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		// Input may have come in while the timer went off.
+		if paste == nil || !paste.timedOut(time.Now()) {
+			return
+		}
+		_ = endPaste()
+	}
+	waitForPaste := func() {
+		if pasteTimer == nil {
+			pasteTimer = time.AfterFunc(pasteTimeout, expirePaste)
+			return
+		}
+		pasteTimer.Reset(pasteTimeout)
+	}
+
+	// What's left of a read is held on to for the next one. If it's an
+	// answer to a clipboard query whose end was lost, a timer drops it once
+	// the input has been quiet for clipboardTimeout.
+	var (
+		leftOverFromPrevIteration []byte
+		leftOverRead              time.Time
+		clipboardTimer            *time.Timer
+	)
+	clipboardTimedOut := func(now time.Time) bool {
+		return bytes.HasPrefix(leftOverFromPrevIteration, []byte(clipboardPrefix)) &&
+			now.Sub(leftOverRead) >= clipboardTimeout
+	}
+	expireClipboard := func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		// Input may have come in while the timer went off.
+		if clipboardTimedOut(time.Now()) {
+			leftOverFromPrevIteration = nil
+		}
+	}
+	waitForClipboard := func() {
+		if clipboardTimer == nil {
+			clipboardTimer = time.AfterFunc(clipboardTimeout, expireClipboard)
+			return
+		}
+		clipboardTimer.Reset(clipboardTimeout)
+	}
+
+	defer func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if pasteTimer != nil {
+			pasteTimer.Stop()
+		}
+		if clipboardTimer != nil {
+			clipboardTimer.Stop()
+		}
+		paste = nil
+		leftOverFromPrevIteration = nil
+	}()
+
+	// parse parses what was read, holding on to what's left of it for the
+	// next read.
+	parse = func(b []byte, canHaveMoreData bool) error {
+		now := time.Now()
+		if paste != nil && paste.timedOut(now) {
+			// The terminal didn't mark the end of the paste, so what was
+			// read now isn't part of it.
+			if err := endPaste(); err != nil {
+				return err
+			}
+		}
+
+		if paste != nil {
+			rest, done := paste.add(b, now)
+			if cut := paste.truncate(); cut != nil {
+				msg := parser.pasteMsg(cut)
+				msg.PasteTruncated = true
+				if err := send(msg); err != nil {
+					return err
+				}
+			}
+			if !done {
+				waitForPaste()
+				return nil
+			}
+			paste = nil
+			pasteTimer.Stop()
+			b = rest
+		} else if leftOverFromPrevIteration != nil {
+			if clipboardTimedOut(now) {
+				// The end of the answer to a clipboard query was lost, so
+				// what was read now isn't part of it.
+				leftOverFromPrevIteration = nil
+			} else {
+				b = append(leftOverFromPrevIteration, b...)
+			}
+		}
 
 		var i, w int
 		for i, w = 0, 0; i < len(b); i += w {
+			// Answers to clipboard queries can take more than one read.
+			moreData := canHaveMoreData || bytes.HasPrefix(b[i:], []byte(clipboardPrefix))
+
 			var msg Msg
-			w, msg = detectOneMsg(b[i:], canHaveMoreData)
+			w, msg = parser.detectOneMsg(b[i:], moreData)
 			if w == 0 {
+				if bytes.HasPrefix(b[i:], []byte(pasteStartSeq)) {
+					// A paste that goes on past this read.
+					paste = newPendingPaste(b[i:], time.Now())
+					leftOverFromPrevIteration = nil
+					waitForPaste()
+					return nil
+				}
+
 				// Expecting more bytes beyond the current buffer. Try waiting
 				// for more input.
 				leftOverFromPrevIteration = make([]byte, 0, len(b[i:])+len(buf))
 				leftOverFromPrevIteration = append(leftOverFromPrevIteration, b[i:]...)
-				continue loop
-			}
-
-			select {
-			case msgs <- msg:
-			case <-ctx.Done():
-				err := ctx.Err()
-				if err != nil {
-					err = fmt.Errorf("found context error while reading input: %w", err)
+				leftOverRead = time.Now()
+				if bytes.HasPrefix(b[i:], []byte(clipboardPrefix)) {
+					waitForClipboard()
 				}
+				return nil
+			}
+			if msg == nil {
+				// Input that doesn't warrant a message, such as a modifier
+				// key by itself.
+				continue
+			}
+			if err := send(msg); err != nil {
 				return err
 			}
 		}
 		leftOverFromPrevIteration = nil
+		return nil
+	}
+
+	for {
+		// Read and block.
+		numBytes, err := input.Read(buf[:])
+
+		mtx.Lock()
+		if err != nil {
+			if paste != nil {
+				_ = endPaste()
+			}
+			mtx.Unlock()
+			return fmt.Errorf("error reading input: %w", err)
+		}
+
+		// If we had a short read (numBytes < len(buf)), we're sure that
+		// the end of this read is an event boundary, so there is no doubt
+		// if we are encountering the end of the buffer while parsing a message.
+		// However, if we've succeeded in filling up the buffer, there may
+		// be more data in the OS buffer ready to be read in, to complete
+		// the last message in the input. In that case, we will retry with
+		// the left over data in the next iteration.
+		err = parse(buf[:numBytes], numBytes == len(buf))
+		mtx.Unlock()
+		if err != nil {
+			return err
+		}
 	}
 }
 
+// stampInput sets the time an input event arrived on key and mouse messages
+// that don't have one yet. Other messages are returned unchanged.
+func stampInput(msg Msg, t time.Time) Msg {
+	switch msg := msg.(type) {
+	case KeyMsg:
+		if msg.Time.IsZero() {
+			msg.Time = t
+		}
+		return msg
+	case KeyReleaseMsg:
+		if msg.Time.IsZero() {
+			msg.Time = t
+		}
+		return msg
+	case MouseMsg:
+		if msg.Time.IsZero() {
+			msg.Time = t
+		}
+		return msg
+	}
+	return msg
+}
+
+// isIncompleteSGRMouse reports whether b, which follows an SGR mouse event
+// introducer, could be the beginning of a mouse event that was cut off at the
+// end of the input buffer.
+func isIncompleteSGRMouse(b []byte) bool {
+	for _, c := range b {
+		if (c < '0' || c > '9') && c != ';' {
+			return false
+		}
+	}
+	return true
+}
+
 var (
 	unknownCSIRe  = regexp.MustCompile(`^\x1b\[[\x30-\x3f]*[\x20-\x2f]*[\x40-\x7e]`)
 	mouseSGRRegex = regexp.MustCompile(`(\d+);(\d+);(\d+)([Mm])`)
+
+	mouseURXVTRegex = regexp.MustCompile(`^\x1b\[(\d+);(\d+);(\d+)M`)
 )
 
-func detectOneMsg(b []byte, canHaveMoreData bool) (w int, msg Msg) {
+// ParseSequence parses the first input event in b, as read from a terminal,
+// the way a program does: a key press or a run of typed characters as a
+// KeyMsg, a mouse event as a MouseMsg, a paste, or an answer to a query such
+// as QueryWindowSize. It returns the message and how many bytes of b it
+// took up.
+//
+// b is taken to hold all the input there is, except when it ends in the
+// middle of a paste or a similar sequence that can't be made sense of until
+// it's complete, in which case it returns 0 bytes: more input is needed.
+// Input that doesn't warrant a message, such as a modifier key by itself,
+// takes up bytes but returns a nil message.
+func ParseSequence(b []byte) (Msg, int) {
+	w, msg := defaultInputParser.detectOneMsg(b, false)
+	return msg, w
+}
+
+func (p *inputParser) detectOneMsg(b []byte, canHaveMoreData bool) (w int, msg Msg) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	// Detect key sequences added by the application first, so they win
+	// over any built-in interpretation.
+	var foundCustom bool
+	foundCustom, w, msg = p.detectCustomSequence(b)
+	if foundCustom {
+		return
+	}
+
 	// Detect mouse events.
 	// X10 mouse events have a length of 6 bytes
 	const mouseEventX10Len = 6
-	if len(b) >= mouseEventX10Len && b[0] == '\x1b' && b[1] == '[' {
+	if len(b) >= 3 && b[0] == '\x1b' && b[1] == '[' {
 		switch b[2] {
 		case 'M':
-			return mouseEventX10Len, MouseMsg(parseX10MouseEvent(b))
+			if len(b) >= mouseEventX10Len {
+				return mouseEventX10Len, MouseMsg(parseX10MouseEvent(b))
+			}
+			if canHaveMoreData {
+				// The rest of the event is still to be read.
+				return 0, nil
+			}
 		case '<':
 			if matchIndices := mouseSGRRegex.FindSubmatchIndex(b[3:]); matchIndices != nil {
 				// SGR mouse events length is the length of the match plus the length of the escape sequence
 				mouseEventSGRLen := matchIndices[1] + 3
 				return mouseEventSGRLen, MouseMsg(parseSGRMouseEvent(b))
 			}
+			if canHaveMoreData && isIncompleteSGRMouse(b[3:]) {
+				// The rest of the event is still to be read.
+				return 0, nil
+			}
+		default:
+			if loc := mouseURXVTRegex.FindIndex(b); loc != nil {
+				return loc[1], MouseMsg(parseURXVTMouseEvent(b))
+			}
 		}
 	}
 
 	// Detect bracketed paste.
 	var foundbp bool
-	foundbp, w, msg = detectBracketedPaste(b)
+	foundbp, w, msg = p.detectBracketedPaste(b)
 	if foundbp {
 		return
 	}
 
+	// Detect keys reported with the CSI u or modifyOtherKeys encodings.
+	var foundKey bool
+	foundKey, w, msg = detectCSIuKey(b)
+	if foundKey {
+		return
+	}
+
+	// Detect keys reported in win32-input-mode.
+	foundKey, w, msg = detectWin32InputKey(b)
+	if foundKey {
+		return
+	}
+
+	// Detect the terminal's answer to RequestTerminalAttributes.
+	var foundDA bool
+	foundDA, w, msg = detectDeviceAttributes(b)
+	if foundDA {
+		return
+	}
+
+	// Detect the terminal's answer to a request for the cursor position.
+	var foundPosition bool
+	foundPosition, w, msg = detectCursorPositionReport(b)
+	if foundPosition {
+		return
+	}
+
+	// Detect the terminal's answer to QueryWindowSize.
+	var foundSize bool
+	foundSize, w, msg = detectWindowSizeReport(b)
+	if foundSize {
+		return
+	}
+
+	// Detect the terminal's answer to a request for pixel mouse mode.
+	var foundPixels bool
+	foundPixels, w, msg = detectMousePixelsReport(b)
+	if foundPixels {
+		return
+	}
+
+	// Detect the terminal's answer to ReadClipboard.
+	var foundClipboard bool
+	foundClipboard, w, msg = detectClipboard(b, canHaveMoreData)
+	if foundClipboard {
+		return
+	}
+
 	// Detect escape sequence and control characters other than NUL,
 	// possibly with an escape character in front to mark the Alt
 	// modifier.
 	var foundSeq bool
-	foundSeq, w, msg = detectSequence(b)
+	foundSeq, w, msg = p.detectSequence(b)
 	if foundSeq {
 		return
 	}
@@ -653,7 +1059,7 @@ func detectOneMsg(b []byte, canHaveMoreData bool) (w int, msg Msg) {
 		i++
 	}
 
-	// Are we seeing a standalone NUL? This is not handled by detectSequence().
+	// Are we seeing a standalone NUL? This is not handled by detectSequence.
 	if i < len(b) && b[i] == 0 {
 		return i + 1, KeyMsg{Type: keyNUL, Alt: alt}
 	}
@@ -8,6 +8,6 @@ import (
 	"io"
 )
 
-func readInputs(ctx context.Context, msgs chan<- Msg, input io.Reader) error {
-	return readAnsiInputs(ctx, msgs, input)
+func readInputs(ctx context.Context, msgs chan<- Msg, input io.Reader, parser *inputParser) error {
+	return readAnsiInputs(ctx, msgs, input, parser)
 }
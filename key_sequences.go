@@ -1,8 +1,10 @@
 package tea
 
 import (
-	"bytes"
+	"regexp"
 	"sort"
+	"strconv"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -52,11 +54,170 @@ var seqLengths = func() []int {
 	return lsizes
 }()
 
+// inputParser turns raw input into messages. It holds the key sequences to
+// detect, which applications can extend with WithKeySequences.
+type inputParser struct {
+	// sequences and seqLengths are like extSequences and seqLengths, plus
+	// the custom sequences.
+	sequences  map[string]Key
+	seqLengths []int
+
+	// custom holds the sequences added by the application, including their
+	// alt variants. They take precedence over all built-in detection.
+	custom map[string]Key
+
+	// rawPaste disables sanitizing pasted text.
+	rawPaste bool
+
+	// keyReleases reports whether key releases are delivered. Some input
+	// modes, like win32-input-mode, report them regardless of whether they
+	// were asked for. They're all delivered when it's nil.
+	keyReleases func() bool
+
+	// pixelMouse translates mouse events reported in pixels, and view
+	// places them in the view, if set.
+	pixelMouse *pixelMouse
+	view       mouseView
+}
+
+// mouseView places mouse events in the view, once the terminal told where the
+// cursor is.
+type mouseView interface {
+	placeView(cursorPositionReportMsg)
+	viewMouseEvent(MouseEvent) MouseEvent
+}
+
+// defaultInputParser detects the built-in key sequences only.
+var defaultInputParser = &inputParser{
+	sequences:  extSequences,
+	seqLengths: seqLengths,
+}
+
+// newInputParser returns a parser that detects the given key sequences on top
+// of the built-in ones, preferring the given ones where they conflict. If
+// rawPaste is set, pasted text is delivered as is.
+func newInputParser(custom map[string]Key, rawPaste bool) *inputParser {
+	if len(custom) == 0 && !rawPaste {
+		return defaultInputParser
+	}
+	if len(custom) == 0 {
+		return &inputParser{
+			sequences:  extSequences,
+			seqLengths: seqLengths,
+			rawPaste:   rawPaste,
+		}
+	}
+
+	p := &inputParser{
+		sequences: make(map[string]Key, len(extSequences)+2*len(custom)),
+		custom:    make(map[string]Key, 2*len(custom)),
+		rawPaste:  rawPaste,
+	}
+	for seq, key := range custom {
+		p.custom[seq] = key
+		if !key.Alt {
+			key.Alt = true
+			p.custom["\x1b"+seq] = key
+		}
+	}
+	for seq, key := range extSequences {
+		p.sequences[seq] = key
+	}
+	for seq, key := range p.custom {
+		p.sequences[seq] = key
+	}
+
+	sizes := map[int]struct{}{}
+	for seq := range p.sequences {
+		sizes[len(seq)] = struct{}{}
+	}
+	for sz := range sizes {
+		p.seqLengths = append(p.seqLengths, sz)
+	}
+	sort.Slice(p.seqLengths, func(i, j int) bool { return p.seqLengths[i] > p.seqLengths[j] })
+	return p
+}
+
+// withKeyReleases returns a copy of the parser that drops the key releases
+// it reads while keyReleases reports false.
+func (p *inputParser) withKeyReleases(keyReleases func() bool) *inputParser {
+	c := *p
+	c.keyReleases = keyReleases
+	return &c
+}
+
+// withPixelMouse returns a copy of the parser that translates the mouse
+// events it reads once the terminal confirmed reporting them in pixels.
+func (p *inputParser) withPixelMouse(m *pixelMouse) *inputParser {
+	c := *p
+	c.pixelMouse = m
+	return &c
+}
+
+// withView returns a copy of the parser that places the mouse events it
+// reads in the view.
+func (p *inputParser) withView(view mouseView) *inputParser {
+	c := *p
+	c.view = view
+	return &c
+}
+
+// deliver returns msg as it's delivered to the program, or false if it isn't.
+func (p *inputParser) deliver(msg Msg) (Msg, bool) {
+	switch m := msg.(type) {
+	case KeyReleaseMsg:
+		if p.keyReleases != nil && !p.keyReleases() {
+			return nil, false
+		}
+	case cursorPositionReportMsg:
+		if p.view != nil {
+			p.view.placeView(m)
+			return nil, false
+		}
+	case mousePixelsReportMsg:
+		if p.pixelMouse != nil {
+			p.pixelMouse.confirm(m.on)
+			return nil, false
+		}
+	case MouseMsg:
+		ev := MouseEvent(m)
+		if p.pixelMouse != nil {
+			ev = p.pixelMouse.translate(ev)
+		}
+		if p.view != nil {
+			ev = p.view.viewMouseEvent(ev)
+		}
+		return MouseMsg(ev), true
+	}
+	return msg, true
+}
+
+// detectCustomSequence detects a custom key sequence, unless it is a prefix of
+// a longer built-in sequence found in the input.
+func (p *inputParser) detectCustomSequence(input []byte) (hasSeq bool, width int, msg Msg) {
+	if len(p.custom) == 0 {
+		return false, 0, nil
+	}
+	for _, sz := range p.seqLengths {
+		if sz > len(input) {
+			continue
+		}
+		prefix := string(input[:sz])
+		if key, ok := p.custom[prefix]; ok {
+			return true, sz, KeyMsg(key)
+		}
+		if _, ok := p.sequences[prefix]; ok {
+			return false, 0, nil
+		}
+	}
+	return false, 0, nil
+}
+
 // detectSequence uses a longest prefix match over the input
 // sequence and a hash map.
-func detectSequence(input []byte) (hasSeq bool, width int, msg Msg) {
-	seqs := extSequences
-	for _, sz := range seqLengths {
+func (p *inputParser) detectSequence(input []byte) (hasSeq bool, width int, msg Msg) {
+	seqs := p.sequences
+	for _, sz := range p.seqLengths {
 		if sz > len(input) {
 			continue
 		}
@@ -80,9 +241,9 @@ func detectSequence(input []byte) (hasSeq bool, width int, msg Msg) {
 // Note: this function is a no-op if bracketed paste was not enabled
 // on the terminal, since in that case we'd never see this
 // particular escape sequence.
-func detectBracketedPaste(input []byte) (hasBp bool, width int, msg Msg) {
+func (p *inputParser) detectBracketedPaste(input []byte) (hasBp bool, width int, msg Msg) {
 	// Detect the start sequence.
-	const bpStart = "\x1b[200~"
+	const bpStart = pasteStartSeq
 	if len(input) < len(bpStart) || string(input[:len(bpStart)]) != bpStart {
 		return false, 0, nil
 	}
@@ -92,19 +253,23 @@ func detectBracketedPaste(input []byte) (hasBp bool, width int, msg Msg) {
 
 	// If we saw the start sequence, then we must have an end sequence
 	// as well. Find it.
-	const bpEnd = "\x1b[201~"
-	idx := bytes.Index(input, []byte(bpEnd))
-	inputLen := len(bpStart) + idx + len(bpEnd)
+	idx, _ := findPasteEnd(input, 0, -1)
 	if idx == -1 {
 		// We have encountered the end of the input buffer without seeing
 		// the marker for the end of the bracketed paste.
 		// Tell the outer loop we have done a short read and we want more.
 		return true, 0, nil
 	}
+	inputLen := len(bpStart) + idx + len(pasteEndSeq)
 
 	// The paste is everything in-between.
 	paste := input[:idx]
 
+	return true, inputLen, p.pasteMsg(paste)
+}
+
+// pasteMsg returns the message for pasted text.
+func (p *inputParser) pasteMsg(paste []byte) KeyMsg {
 	// All there is in-between is runes, not to be interpreted further.
 	k := Key{Type: KeyRunes, Paste: true}
 	for len(paste) > 0 {
@@ -114,6 +279,189 @@ func detectBracketedPaste(input []byte) (hasBp bool, width int, msg Msg) {
 		}
 		paste = paste[w:]
 	}
+	if !p.rawPaste {
+		k.Runes = sanitizePaste(k.Runes)
+	}
+	return KeyMsg(k)
+}
 
-	return true, inputLen, KeyMsg(k)
+// sanitizePaste removes control characters from pasted text, so that escape
+// sequences in it can't affect the terminal when the text is displayed. Line
+// breaks and tabs are kept.
+func sanitizePaste(runes []rune) []rune {
+	res := runes[:0]
+	for _, r := range runes {
+		switch {
+		case r == '\r', r == '\n', r == '\t':
+			res = append(res, r)
+		case unicode.IsControl(r):
+			// C0 and C1 control characters, and DEL.
+		default:
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+var (
+	// csiuKeyRe matches keys reported with the fixterms/kitty encoding:
+	//
+	//	CSI code[:alternates] [; modifiers[:event] [; text]] u
+	csiuKeyRe = regexp.MustCompile(`^\x1b\[(\d+)(?::\d*)*(?:;(\d*)(?::(\d+))?)?(?:;[\d:]*)?u`)
+
+	// modifyOtherKeysRe matches keys reported with xterm's modifyOtherKeys
+	// encoding:
+	//
+	//	CSI 27 ; modifiers ; code ~
+	modifyOtherKeysRe = regexp.MustCompile(`^\x1b\[27;(\d+);(\d+)~`)
+
+	// keyEventRe matches legacy functional keys that carry a kitty event
+	// type, such as the release of the up arrow:
+	//
+	//	CSI 1 ; modifiers : event A
+	keyEventRe = regexp.MustCompile(`^\x1b\[(\d+);(\d+):(\d+)([A-Z~])`)
+)
+
+// Key event types as reported by the kitty keyboard protocol.
+const (
+	keyEventPress   = 1
+	keyEventRepeat  = 2
+	keyEventRelease = 3
+)
+
+// detectCSIuKey detects keys sent using the CSI u or modifyOtherKeys
+// encodings, as well as legacy keys annotated with a kitty event type.
+// Sequences with parameters we don't understand are left for detectSequence
+// to report as unknown CSI sequences.
+func detectCSIuKey(input []byte) (hasKey bool, width int, msg Msg) {
+	if m := keyEventRe.FindSubmatch(input); m != nil {
+		k, ok := eventKey(string(m[1]), string(m[2]), m[4][0])
+		if !ok {
+			return false, 0, nil
+		}
+		event, _ := strconv.Atoi(string(m[3]))
+		return true, len(m[0]), keyEventMsg(k, event)
+	}
+
+	var code, mod, event []byte
+	if m := csiuKeyRe.FindSubmatch(input); m != nil {
+		width, code, mod, event = len(m[0]), m[1], m[2], m[3]
+	} else if m := modifyOtherKeysRe.FindSubmatch(input); m != nil {
+		width, code, mod = len(m[0]), m[2], m[1]
+	} else {
+		return false, 0, nil
+	}
+
+	c, err := strconv.Atoi(string(code))
+	if err != nil {
+		return false, 0, nil
+	}
+	m := 1
+	if len(mod) > 0 {
+		if m, err = strconv.Atoi(string(mod)); err != nil {
+			return false, 0, nil
+		}
+	}
+	e := keyEventPress
+	if len(event) > 0 {
+		if e, err = strconv.Atoi(string(event)); err != nil {
+			return false, 0, nil
+		}
+	}
+
+	k, ok := csiuKey(rune(c), m)
+	if !ok {
+		return false, 0, nil
+	}
+	return true, width, keyEventMsg(k, e)
+}
+
+// eventKey looks up a legacy functional key with its event type stripped,
+// so that CSI 1;5:3A resolves to the same key as CSI 1;5A.
+func eventKey(num, mod string, final byte) (Key, bool) {
+	var seq string
+	switch {
+	case mod != "1" && final == '~':
+		seq = "\x1b[" + num + ";" + mod + "~"
+	case mod != "1":
+		seq = "\x1b[1;" + mod + string(final)
+	case final == '~':
+		seq = "\x1b[" + num + "~"
+	case final >= 'P' && final <= 'S':
+		seq = "\x1bO" + string(final)
+	default:
+		seq = "\x1b[" + string(final)
+	}
+	k, ok := sequences[seq]
+	return k, ok
+}
+
+// keyEventMsg wraps a key in the message matching its kitty event type.
+func keyEventMsg(k Key, event int) Msg {
+	switch event {
+	case keyEventRelease:
+		return KeyReleaseMsg(k)
+	case keyEventRepeat:
+		k.Repeat = true
+	}
+	return KeyMsg(k)
+}
+
+// Modifier bits as encoded in the parameters of xterm-style sequences, after
+// subtracting one.
+const (
+	modShift = 0b0001
+	modAlt   = 0b0010
+	modCtrl  = 0b0100
+)
+
+// csiuKey translates a code point and an xterm-style modifier parameter into
+// a Key, preferring the legacy key types where they exist so that, for
+// example, ctrl+a reported as CSI 97;5u is indistinguishable from a plain
+// ctrl+a.
+func csiuKey(r rune, mod int) (Key, bool) {
+	if mod < 1 {
+		return Key{}, false
+	}
+	mod--
+	shift, alt, ctrl := mod&modShift != 0, mod&modAlt != 0, mod&modCtrl != 0
+
+	k := Key{Alt: alt}
+	switch {
+	case r == rune(keyCR):
+		k.Type = KeyEnter
+	case r == rune(keyHT) && shift:
+		k.Type = KeyShiftTab
+		shift = false
+	case r == rune(keyHT):
+		k.Type = KeyTab
+	case r == rune(keyESC):
+		k.Type = KeyEscape
+	case r == rune(keyDEL), r == rune(keyBS):
+		k.Type = KeyBackspace
+	case r == ' ':
+		k.Type = KeySpace
+		k.Runes = spaceRunes
+	case ctrl && !shift && r >= 'a' && r <= 'z':
+		k.Type = KeyCtrlA + KeyType(r-'a')
+		ctrl = false
+	case r < ' ' || !utf8.ValidRune(r) || unicode.Is(unicode.Co, r):
+		// Other control characters and the private use area, which kitty
+		// uses for functional keys we have no key type for.
+		return Key{}, false
+	default:
+		k.Type = KeyRunes
+		if unicode.IsLetter(r) {
+			if shift && !ctrl {
+				// shift+a is just A.
+				r = unicode.ToUpper(r)
+				shift = false
+			} else if ctrl {
+				r = unicode.ToLower(r)
+			}
+		}
+		k.Runes = []rune{r}
+	}
+	k.Ctrl, k.Shift = ctrl, shift
+	return k, true
 }
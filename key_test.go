@@ -36,6 +36,17 @@ func TestKeyString(t *testing.T) {
 		}
 	})
 
+	t.Run("ctrl+shift+p", func(t *testing.T) {
+		if got := KeyMsg(Key{
+			Type:  KeyRunes,
+			Runes: []rune{'p'},
+			Ctrl:  true,
+			Shift: true,
+		}).String(); got != "ctrl+shift+p" {
+			t.Fatalf(`expected a "ctrl+shift+p", got %q`, got)
+		}
+	})
+
 	t.Run("invalid", func(t *testing.T) {
 		if got := KeyMsg(Key{
 			Type: KeyType(99999),
@@ -115,7 +126,7 @@ func TestDetectSequence(t *testing.T) {
 	td := buildBaseSeqTests()
 	for _, tc := range td {
 		t.Run(fmt.Sprintf("%q", string(tc.seq)), func(t *testing.T) {
-			hasSeq, width, msg := detectSequence(tc.seq)
+			hasSeq, width, msg := defaultInputParser.detectSequence(tc.seq)
 			if !hasSeq {
 				t.Fatalf("no sequence found")
 			}
@@ -205,7 +216,161 @@ func TestDetectOneMsg(t *testing.T) {
 
 	for _, tc := range td {
 		t.Run(fmt.Sprintf("%q", string(tc.seq)), func(t *testing.T) {
-			width, msg := detectOneMsg(tc.seq, false /* canHaveMoreData */)
+			width, msg := defaultInputParser.detectOneMsg(tc.seq, false /* canHaveMoreData */)
+			if width != len(tc.seq) {
+				t.Errorf("parser did not consume the entire input: got %d, expected %d", width, len(tc.seq))
+			}
+			if !reflect.DeepEqual(tc.msg, msg) {
+				t.Errorf("expected event %#v (%T), got %#v (%T)", tc.msg, tc.msg, msg, msg)
+			}
+		})
+	}
+}
+
+func TestDetectFunctionKeys(t *testing.T) {
+	td := []struct {
+		name string
+		seq  string
+	}{
+		// xterm
+		{"f1", "\x1bOP"},
+		{"shift+f1", "\x1b[1;2P"},
+		{"alt+f4", "\x1b[1;3S"},
+		{"ctrl+f2", "\x1b[1;5Q"},
+		{"alt+ctrl+shift+f3", "\x1b[1;8R"},
+		{"shift+f5", "\x1b[15;2~"},
+		{"ctrl+f12", "\x1b[24;5~"},
+		{"f13", "\x1b[25~"},
+		{"shift+f13", "\x1b[25;2~"},
+		{"alt+ctrl+f20", "\x1b[34;7~"},
+		{"alt+f13", "\x1b[25;3~"},
+		{"alt+f13", "\x1b\x1b[25~"},
+
+		// urxvt
+		{"f1", "\x1b[11~"},
+		{"ctrl+f1", "\x1b[11^"},
+		{"ctrl+f12", "\x1b[24^"},
+		{"f17", "\x1b[31~"},
+
+		// tmux with xterm-keys, konsole
+		{"shift+f1", "\x1bO2P"},
+		{"ctrl+f4", "\x1bO5S"},
+		{"alt+shift+f9", "\x1b[20;4~"},
+	}
+
+	for _, tc := range td {
+		t.Run(fmt.Sprintf("%q", tc.seq), func(t *testing.T) {
+			width, msg := defaultInputParser.detectOneMsg([]byte(tc.seq), false)
+			if width != len(tc.seq) {
+				t.Errorf("parser did not consume the entire input: got %d, expected %d", width, len(tc.seq))
+			}
+			k, ok := msg.(KeyMsg)
+			if !ok {
+				t.Fatalf("expected a KeyMsg, got %#v (%T)", msg, msg)
+			}
+			if k.String() != tc.name {
+				t.Errorf("expected key %q, got %q", tc.name, k.String())
+			}
+		})
+	}
+
+	t.Run("unknown modifier", func(t *testing.T) {
+		seq := []byte("\x1b[25;99~")
+		width, msg := defaultInputParser.detectOneMsg(seq, false)
+		if width != len(seq) {
+			t.Errorf("parser did not consume the entire input: got %d, expected %d", width, len(seq))
+		}
+		if _, ok := msg.(unknownCSISequenceMsg); !ok {
+			t.Errorf("expected an unknown CSI sequence, got %#v (%T)", msg, msg)
+		}
+	})
+}
+
+func TestDetectModifiedNavigationKeys(t *testing.T) {
+	keys := []struct {
+		name string
+		seq  string // with %d standing in for the modifier parameter
+	}{
+		{"up", "\x1b[1;%dA"},
+		{"down", "\x1b[1;%dB"},
+		{"right", "\x1b[1;%dC"},
+		{"left", "\x1b[1;%dD"},
+		{"home", "\x1b[1;%dH"},
+		{"end", "\x1b[1;%dF"},
+		{"home", "\x1b[1;%d~"},
+		{"insert", "\x1b[2;%d~"},
+		{"delete", "\x1b[3;%d~"},
+		{"end", "\x1b[4;%d~"},
+		{"pgup", "\x1b[5;%d~"},
+		{"pgdown", "\x1b[6;%d~"},
+	}
+
+	for _, k := range keys {
+		for mod := 1; mod <= 8; mod++ {
+			seq := fmt.Sprintf(k.seq, mod)
+			if mod == 1 {
+				// The unmodified forms omit the modifier parameter, and the
+				// leading 1 for the keys that aren't tilde-terminated.
+				seq = strings.Replace(seq, ";1", "", 1)
+				if !strings.HasSuffix(seq, "~") {
+					seq = strings.Replace(seq, "[1", "[", 1)
+				}
+			}
+
+			var name string
+			if (mod-1)&0b010 != 0 {
+				name += "alt+"
+			}
+			if (mod-1)&0b100 != 0 {
+				name += "ctrl+"
+			}
+			if (mod-1)&0b001 != 0 {
+				name += "shift+"
+			}
+			name += k.name
+
+			t.Run(fmt.Sprintf("%q", seq), func(t *testing.T) {
+				width, msg := defaultInputParser.detectOneMsg([]byte(seq), false)
+				if width != len(seq) {
+					t.Errorf("parser did not consume the entire input: got %d, expected %d", width, len(seq))
+				}
+				if s, ok := msg.(fmt.Stringer); !ok || s.String() != name {
+					t.Errorf("expected key %q, got %#v (%T)", name, msg, msg)
+				}
+			})
+		}
+	}
+}
+
+func TestDetectCustomKeySequences(t *testing.T) {
+	parser := newInputParser(map[string]Key{
+		"\x1b[99~":  {Type: KeyF20},
+		"\x1b[A":    {Type: KeyDown},
+		"\x1b[1":    {Type: KeyF19},
+		"\x1b[97u":  {Type: KeyF18},
+		"\x1bOz":    {Type: KeyRunes, Runes: []rune{'z'}, Alt: true},
+		"\x1b[200~": {Type: KeyF17},
+	}, false)
+
+	td := []struct {
+		name string
+		seq  string
+		msg  Msg
+	}{
+		{"custom", "\x1b[99~", KeyMsg{Type: KeyF20}},
+		{"custom with alt", "\x1b\x1b[99~", KeyMsg{Type: KeyF20, Alt: true}},
+		{"custom with alt already set", "\x1bOz", KeyMsg{Type: KeyRunes, Runes: []rune{'z'}, Alt: true}},
+		{"overrides built-in sequence", "\x1b[A", KeyMsg{Type: KeyDown}},
+		{"overrides CSI u", "\x1b[97u", KeyMsg{Type: KeyF18}},
+		{"overrides bracketed paste", "\x1b[200~", KeyMsg{Type: KeyF17}},
+		{"prefix of longer built-in sequence", "\x1b[1;5A", KeyMsg{Type: KeyCtrlUp}},
+		{"prefix by itself", "\x1b[1", KeyMsg{Type: KeyF19}},
+		{"built-in sequence", "\x1b[B", KeyMsg{Type: KeyDown}},
+	}
+
+	for _, tc := range td {
+		t.Run(tc.name, func(t *testing.T) {
+			width, msg := parser.detectOneMsg([]byte(tc.seq), false)
 			if width != len(tc.seq) {
 				t.Errorf("parser did not consume the entire input: got %d, expected %d", width, len(tc.seq))
 			}
@@ -214,6 +379,78 @@ func TestDetectOneMsg(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("defaults", func(t *testing.T) {
+		if newInputParser(nil, false) != defaultInputParser {
+			t.Errorf("expected the default parser without custom sequences")
+		}
+		if _, msg := defaultInputParser.detectOneMsg([]byte("\x1b[99~"), false); !reflect.DeepEqual(msg, unknownCSISequenceMsg("\x1b[99~")) {
+			t.Errorf("expected custom sequences not to leak into the default parser, got %#v", msg)
+		}
+	})
+}
+
+func TestDetectBracketedPaste(t *testing.T) {
+	paste := func(s string) string { return "\x1b[200~" + s + "\x1b[201~" }
+
+	td := []struct {
+		name  string
+		seq   string
+		raw   bool
+		width int
+		text  string
+	}{
+		{"plain", paste("hello"), false, -1, "hello"},
+		{"escape sequence", paste("a\x1b[2Jb"), false, -1, "a[2Jb"},
+		{"c1 controls", paste("a\u009b2Jb\u0085"), false, -1, "a2Jb"},
+		{"line breaks", paste("a\r\nb\rc\n\td"), false, -1, "a\r\nb\rc\n\td"},
+		{"crafted end marker", paste("a\x1b[201~\x1b[2Jb"), false, -1, "a[201~[2Jb"},
+		{"followed by another paste", paste("a") + paste("b"), false, len(paste("a")), "a"},
+		{"raw", paste("a\x1b[2J\r\nb"), true, -1, "a\x1b[2J\r\nb"},
+	}
+
+	for _, tc := range td {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := newInputParser(nil, tc.raw)
+			width, msg := parser.detectOneMsg([]byte(tc.seq), false)
+			if tc.width < 0 {
+				tc.width = len(tc.seq)
+			}
+			if width != tc.width {
+				t.Errorf("expected to consume %d bytes, consumed %d", tc.width, width)
+			}
+			expected := KeyMsg{Type: KeyRunes, Runes: []rune(tc.text), Paste: true}
+			if !reflect.DeepEqual(expected, msg) {
+				t.Errorf("expected %#v, got %#v", expected, msg)
+			}
+		})
+	}
+}
+
+func TestReadInputsKeyReleases(t *testing.T) {
+	for _, active := range []bool{false, true} {
+		t.Run(fmt.Sprint(active), func(t *testing.T) {
+			parser := defaultInputParser.withKeyReleases(func() bool { return active })
+			msgs := make(chan Msg, 2)
+			err := readAnsiInputs(context.Background(), msgs, strings.NewReader("\x1b[97;1:3ua"), parser)
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("unexpected input error: %v", err)
+			}
+			close(msgs)
+
+			var got []string
+			for msg := range msgs {
+				got = append(got, fmt.Sprintf("%T", msg))
+			}
+			expected := []string{"tea.KeyMsg"}
+			if active {
+				expected = []string{"tea.KeyReleaseMsg", "tea.KeyMsg"}
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
 }
 
 func TestReadLongInput(t *testing.T) {
@@ -235,6 +472,205 @@ func TestReadLongInput(t *testing.T) {
 	}
 }
 
+func TestReadLongPaste(t *testing.T) {
+	text := strings.Repeat("0123456789abcdef", 1<<16)
+
+	// The paste arrives in reads of 4KB, and is followed by a key.
+	input := pasteStartSeq + text + pasteEndSeq + "x"
+	var chunks []io.Reader
+	for len(input) > 0 {
+		n := 4096
+		if n > len(input) {
+			n = len(input)
+		}
+		chunks = append(chunks, strings.NewReader(input[:n]))
+		input = input[n:]
+	}
+
+	msgs := testReadInputs(t, io.MultiReader(chunks...))
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	k := msgs[0].(KeyMsg)
+	if !k.Paste || k.PasteTruncated || string(k.Runes) != text {
+		t.Errorf("expected the paste to be intact, got %d runes, truncated: %v", len(k.Runes), k.PasteTruncated)
+	}
+	if k := msgs[1].(KeyMsg); k.String() != "x" {
+		t.Errorf("expected x after the paste, got %v", k)
+	}
+}
+
+func TestReadTruncatedPaste(t *testing.T) {
+	t.Run("too long", func(t *testing.T) {
+		defer func(size int) { maxPasteSize = size }(maxPasteSize)
+		maxPasteSize = 1000
+
+		text := strings.Repeat("a", 3000)
+		msgs := testReadInputs(t, io.MultiReader(
+			strings.NewReader(pasteStartSeq+text+pasteEndSeq),
+			strings.NewReader("x"),
+		))
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(msgs))
+		}
+		if k := msgs[0].(KeyMsg); !k.Paste || !k.PasteTruncated || string(k.Runes) != text[:1000] {
+			t.Errorf("expected the paste to be cut short, got %d runes, truncated: %v", len(k.Runes), k.PasteTruncated)
+		}
+		if k := msgs[1].(KeyMsg); k.String() != "x" {
+			t.Errorf("expected x after the paste, got %v", k)
+		}
+	})
+
+	t.Run("too long with its end", func(t *testing.T) {
+		defer func(size int) { maxPasteSize = size }(maxPasteSize)
+		maxPasteSize = 100
+
+		// The read the paste gets too long in holds its end.
+		text := strings.Repeat("a", 120)
+		msgs := testReadInputs(t, &chunkedReader{chunks: []string{
+			pasteStartSeq + text[:60],
+			text[60:] + pasteEndSeq,
+			"x",
+		}})
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(msgs))
+		}
+		if k := msgs[0].(KeyMsg); !k.Paste || !k.PasteTruncated || string(k.Runes) != text[:100] {
+			t.Errorf("expected the paste to be cut short, got %d runes, truncated: %v", len(k.Runes), k.PasteTruncated)
+		}
+		if k := msgs[1].(KeyMsg); k.String() != "x" {
+			t.Errorf("expected x after the paste, got %v", k)
+		}
+	})
+
+	t.Run("missing end", func(t *testing.T) {
+		defer func(timeout time.Duration) { pasteTimeout = timeout }(pasteTimeout)
+		pasteTimeout = 10 * time.Millisecond
+
+		msgs := testReadInputs(t, &slowReader{
+			chunks: []string{pasteStartSeq + "abc", "x"},
+			delay:  50 * time.Millisecond,
+		})
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(msgs))
+		}
+		if k := msgs[0].(KeyMsg); !k.Paste || !k.PasteTruncated || string(k.Runes) != "abc" {
+			t.Errorf("expected the paste to be cut short, got %#v", k)
+		}
+		if k := msgs[1].(KeyMsg); k.String() != "x" {
+			t.Errorf("expected x after the paste, got %v", k)
+		}
+	})
+}
+
+func TestReadExpiredPaste(t *testing.T) {
+	defer func(timeout time.Duration) { pasteTimeout = timeout }(pasteTimeout)
+	pasteTimeout = 10 * time.Millisecond
+
+	// The paste is delivered without waiting for more input.
+	r, w := io.Pipe()
+	defer w.Close() //nolint:errcheck
+	msgs := make(chan Msg)
+	go func() { _ = readAnsiInputs(context.Background(), msgs, r, defaultInputParser) }()
+	if _, err := w.Write([]byte(pasteStartSeq + "abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-msgs:
+		if k := msg.(KeyMsg); !k.Paste || !k.PasteTruncated || string(k.Runes) != "abc" {
+			t.Errorf("expected the paste to be cut short, got %#v", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the paste")
+	}
+}
+
+func TestReadInjectedPasteEnd(t *testing.T) {
+	// The pasted text holds an end sequence, and the one the terminal sent
+	// comes in the next read.
+	msgs := testReadInputs(t, &chunkedReader{chunks: []string{
+		pasteStartSeq + "a" + pasteEndSeq + "rm -rf ~\r",
+		pasteEndSeq,
+	}})
+	expected := []Msg{KeyMsg{Type: KeyRunes, Runes: []rune("a[201~rm -rf ~\r"), Paste: true}}
+	if !reflect.DeepEqual(expected, msgs) {
+		t.Errorf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+func TestReadPasteFollowedByKeys(t *testing.T) {
+	defer func(timeout time.Duration) { pasteTimeout = timeout }(pasteTimeout)
+	pasteTimeout = 10 * time.Millisecond
+
+	// Keys read along with the end of a paste are parsed once the input
+	// goes quiet.
+	r, w := io.Pipe()
+	defer w.Close() //nolint:errcheck
+	msgs := make(chan Msg)
+	go func() { _ = readAnsiInputs(context.Background(), msgs, r, defaultInputParser) }()
+	if _, err := w.Write([]byte(pasteStartSeq + "abc" + pasteEndSeq + "x")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Msg{
+		KeyMsg{Type: KeyRunes, Runes: []rune("abc"), Paste: true},
+		KeyMsg{Type: KeyRunes, Runes: []rune("x")},
+	}
+	for _, e := range expected {
+		select {
+		case msg := <-msgs:
+			k := msg.(KeyMsg)
+			k.Time = time.Time{}
+			if !reflect.DeepEqual(e, k) {
+				t.Errorf("expected %#v, got %#v", e, k)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the paste")
+		}
+	}
+}
+
+// slowReader returns its chunks one read at a time, waiting before each one
+// but the first.
+type slowReader struct {
+	chunks []string
+	delay  time.Duration
+	reads  int
+}
+
+func (r *slowReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if r.reads > 0 {
+		time.Sleep(r.delay)
+	}
+	r.reads++
+	n := copy(b, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestReadLongMouseInput(t *testing.T) {
+	// Enough mouse events to fill several input buffers, so some of them
+	// will be cut off at the end of a read.
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&input, "\x1b[<35;%d;1M", i+1)
+	}
+	msgs := testReadInputs(t, strings.NewReader(input.String()))
+	if len(msgs) != 100 {
+		t.Fatalf("expected 100 messages, got %d", len(msgs))
+	}
+	for i, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok || m.X != i {
+			t.Fatalf("unexpected message %d: %#v", i, msg)
+		}
+	}
+}
+
 func TestReadInput(t *testing.T) {
 	type test struct {
 		keyname string
@@ -446,13 +882,13 @@ func TestReadInput(t *testing.T) {
 				KeyMsg{Type: KeyRunes, Runes: []rune("o")},
 			},
 		},
-		{"[a\x03\nb]",
+		{"[a\nb]",
 			[]byte{
 				'\x1b', '[', '2', '0', '0', '~',
 				'a', '\x03', '\n', 'b',
 				'\x1b', '[', '2', '0', '1', '~'},
 			[]Msg{
-				KeyMsg{Type: KeyRunes, Runes: []rune("a\x03\nb"), Paste: true},
+				KeyMsg{Type: KeyRunes, Runes: []rune("a\nb"), Paste: true},
 			},
 		},
 	}
@@ -528,11 +964,12 @@ func testReadInputs(t *testing.T, input io.Reader) []Msg {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		inputErr = readAnsiInputs(ctx, msgsC, input)
+		inputErr = readAnsiInputs(ctx, msgsC, input, defaultInputParser)
 		msgsC <- nil
 	}()
 
 	var msgs []Msg
+	var last time.Time
 loop:
 	for {
 		select {
@@ -541,6 +978,18 @@ loop:
 				// end of input marker for the test.
 				break loop
 			}
+			// Check the timestamp and clear it so tests can compare
+			// messages by value.
+			if ts, ok := inputTime(msg); ok {
+				if ts.IsZero() {
+					t.Errorf("expected %#v to have a timestamp", msg)
+				}
+				if ts.Before(last) {
+					t.Errorf("expected timestamps to be non-decreasing, got %v after %v", ts, last)
+				}
+				last = ts
+				msg = stripInputTime(msg)
+			}
 			msgs = append(msgs, msg)
 		case <-time.After(2 * time.Second):
 			t.Errorf("timeout waiting for input event")
@@ -550,6 +999,56 @@ loop:
 	return msgs
 }
 
+// inputTime returns the timestamp of key and mouse messages.
+func inputTime(msg Msg) (time.Time, bool) {
+	switch msg := msg.(type) {
+	case KeyMsg:
+		return msg.Time, true
+	case KeyReleaseMsg:
+		return msg.Time, true
+	case MouseMsg:
+		return msg.Time, true
+	}
+	return time.Time{}, false
+}
+
+// stripInputTime clears the timestamp of key and mouse messages.
+func stripInputTime(msg Msg) Msg {
+	switch msg := msg.(type) {
+	case KeyMsg:
+		msg.Time = time.Time{}
+		return msg
+	case KeyReleaseMsg:
+		msg.Time = time.Time{}
+		return msg
+	case MouseMsg:
+		msg.Time = time.Time{}
+		return msg
+	}
+	return msg
+}
+
+func TestStampInput(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Second)
+
+	if k := stampInput(KeyMsg{Type: KeyEnter}, now).(KeyMsg); !k.Time.Equal(now) {
+		t.Errorf("expected key to be stamped with %v, got %v", now, k.Time)
+	}
+	if k := stampInput(KeyReleaseMsg{Type: KeyEnter}, now).(KeyReleaseMsg); !k.Time.Equal(now) {
+		t.Errorf("expected key release to be stamped with %v, got %v", now, k.Time)
+	}
+	if m := stampInput(MouseMsg{Button: MouseButtonLeft}, now).(MouseMsg); !m.Time.Equal(now) {
+		t.Errorf("expected mouse event to be stamped with %v, got %v", now, m.Time)
+	}
+	if k := stampInput(KeyMsg{Type: KeyEnter, Time: earlier}, now).(KeyMsg); !k.Time.Equal(earlier) {
+		t.Errorf("expected existing timestamp %v to be kept, got %v", earlier, k.Time)
+	}
+	if msg := stampInput(WindowSizeMsg{Width: 1}, now); msg != (WindowSizeMsg{Width: 1}) {
+		t.Errorf("expected other messages to be unchanged, got %#v", msg)
+	}
+}
+
 // randTest defines the test input and expected output for a sequence
 // of interleaved control sequences and control characters.
 type randTest struct {
@@ -638,7 +1137,7 @@ func genRandomDataWithSeed(s int64, length int) randTest {
 // TestDetectRandomSequencesLex checks that the lex-generated sequence
 // detector works over concatenations of random sequences.
 func TestDetectRandomSequencesLex(t *testing.T) {
-	runTestDetectSequence(t, detectSequence)
+	runTestDetectSequence(t, defaultInputParser.detectSequence)
 }
 
 func runTestDetectSequence(
@@ -679,7 +1178,7 @@ func runTestDetectSequence(
 // TestDetectRandomSequencesMap checks that the map-based sequence
 // detector works over concatenations of random sequences.
 func TestDetectRandomSequencesMap(t *testing.T) {
-	runTestDetectSequence(t, detectSequence)
+	runTestDetectSequence(t, defaultInputParser.detectSequence)
 }
 
 // BenchmarkDetectSequenceMap benchmarks the map-based sequence
@@ -688,7 +1187,157 @@ func BenchmarkDetectSequenceMap(b *testing.B) {
 	td := genRandomDataWithSeed(123, 10000)
 	for i := 0; i < b.N; i++ {
 		for j, w := 0, 0; j < len(td.data); j += w {
-			_, w, _ = detectSequence(td.data[j:])
+			_, w, _ = defaultInputParser.detectSequence(td.data[j:])
 		}
 	}
 }
+
+func TestParseSequence(t *testing.T) {
+	td := []struct {
+		name string
+		seq  string
+		rest string // the input left for the next message
+		msg  Msg
+	}{
+		{"runes", "a", "", KeyMsg{Type: KeyRunes, Runes: []rune("a")}},
+		{"one key at a time", "\x1b[A\x1b[B", "\x1b[B", KeyMsg{Type: KeyUp}},
+		{"mouse", "\x1b[<0;10;20M", "", MouseMsg{X: 9, Y: 19, Button: MouseButtonLeft, Action: MouseActionPress, Type: MouseLeft}},
+		{"incomplete paste", "\x1b[200~pas", "\x1b[200~pas", nil},
+		{"empty", "", "", nil},
+		{"clipboard: bel", "\x1b]52;c;aGVsbG8gd29ybGQ=\a", "", ClipboardMsg{Content: "hello world"}},
+		{"clipboard: st", "\x1b]52;c;aGVsbG8gd29ybGQ=\x1b\\", "", ClipboardMsg{Content: "hello world"}},
+		{"clipboard: primary selection", "\x1b]52;p;aGk=\a", "", ClipboardMsg{Content: "hi"}},
+		{"clipboard: empty", "\x1b]52;c;\a", "", ClipboardMsg{}},
+		{"clipboard: denied", "\x1b]52;c;?\a", "", ClipboardMsg{}},
+		{"clipboard: unterminated", "\x1b]52;c;abc", "", nil},
+		{"clipboard: cut off by a key", "\x1b]52;c;abc\x1b[A", "\x1b[A", nil},
+		{"device attributes: xterm", "\x1b[?64;1;2;6;9;15;16;17;18;21;22;28c", "", CapabilitiesMsg{Params: []int{64, 1, 2, 6, 9, 15, 16, 17, 18, 21, 22, 28}, Color: true}},
+		{"device attributes: xterm with sixel", "\x1b[?63;1;2;4;6;9;15;22c", "", CapabilitiesMsg{Params: []int{63, 1, 2, 4, 6, 9, 15, 22}, Sixel: true, Color: true}},
+		{"device attributes: mlterm", "\x1b[?63;1;2;3;4;7;29c", "", CapabilitiesMsg{Params: []int{63, 1, 2, 3, 4, 7, 29}, Sixel: true}},
+		{"device attributes: vt100", "\x1b[?1;2c", "", CapabilitiesMsg{Params: []int{1, 2}}},
+		{"device attributes: secondary", "\x1b[>0;276;0c", "", unknownCSISequenceMsg("\x1b[>0;276;0c")},
+		{"window size: size", "\x1b[8;24;80t", "", WindowSizeMsg{Width: 80, Height: 24}},
+		{"window size: large", "\x1b[8;120;300t", "", WindowSizeMsg{Width: 300, Height: 120}},
+		{"win32: a", "\x1b[65;30;97;1;0;1_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"win32: shift+a", "\x1b[65;30;65;1;16;1_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'A'}}},
+		{"win32: ctrl+a", "\x1b[65;30;1;1;8;1_", "", KeyMsg{Type: KeyCtrlA}},
+		{"win32: ctrl+alt+a", "\x1b[65;30;1;1;10;1_", "", KeyMsg{Type: KeyCtrlA, Alt: true}},
+		{"win32: alt+a", "\x1b[65;30;97;1;2;1_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}, Alt: true}},
+		{"win32: altgr+q", "\x1b[81;16;64;1;9;1_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'@'}}},
+		{"win32: shift+1", "\x1b[49;2;33;1;16;1_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'!'}}},
+		{"win32: a release", "\x1b[65;30;97;0;0;1_", "", KeyReleaseMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"win32: a repeated", "\x1b[65;30;97;1;0;3_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a', 'a', 'a'}}},
+		{"win32: a omitted params", "\x1b[65;;97;1_", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"win32: space", "\x1b[32;57;32;1;0;1_", "", KeyMsg{Type: KeySpace, Runes: []rune{' '}}},
+		{"win32: enter", "\x1b[13;28;13;1;0;1_", "", KeyMsg{Type: KeyEnter}},
+		{"win32: shift+tab", "\x1b[9;15;9;1;16;1_", "", KeyMsg{Type: KeyShiftTab}},
+		{"win32: escape", "\x1b[27;1;27;1;0;1_", "", KeyMsg{Type: KeyEscape}},
+		{"win32: backspace", "\x1b[8;14;8;1;0;1_", "", KeyMsg{Type: KeyBackspace}},
+		{"win32: shift", "\x1b[16;42;0;1;16;1_", "", nil},
+		{"win32: ctrl", "\x1b[17;29;0;1;8;1_", "", nil},
+		{"win32: alt release", "\x1b[18;56;0;0;0;1_", "", nil},
+		{"win32: up", "\x1b[38;72;0;1;0;1_", "", KeyMsg{Type: KeyUp}},
+		{"win32: shift+up", "\x1b[38;72;0;1;16;1_", "", KeyMsg{Type: KeyShiftUp}},
+		{"win32: ctrl+up", "\x1b[38;72;0;1;8;1_", "", KeyMsg{Type: KeyCtrlUp}},
+		{"win32: alt+up", "\x1b[38;72;0;1;2;1_", "", KeyMsg{Type: KeyUp, Alt: true}},
+		{"win32: left release", "\x1b[37;75;0;0;0;1_", "", KeyReleaseMsg{Type: KeyLeft}},
+		{"win32: pgdown", "\x1b[34;81;0;1;0;1_", "", KeyMsg{Type: KeyPgDown}},
+		{"win32: f5", "\x1b[116;63;0;1;0;1_", "", KeyMsg{Type: KeyF5}},
+		{"csi u: a", "\x1b[97u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"csi u: a with modifier", "\x1b[97;1u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"csi u: A", "\x1b[97;2u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'A'}}},
+		{"csi u: ctrl+a", "\x1b[97;5u", "", KeyMsg{Type: KeyCtrlA}},
+		{"csi u: alt+ctrl+a", "\x1b[97;7u", "", KeyMsg{Type: KeyCtrlA, Alt: true}},
+		{"csi u: ctrl+shift+p", "\x1b[112;6u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'p'}, Ctrl: true, Shift: true}},
+		{"csi u: alt+enter", "\x1b[13;3u", "", KeyMsg{Type: KeyEnter, Alt: true}},
+		{"csi u: ctrl+enter", "\x1b[13;5u", "", KeyMsg{Type: KeyEnter, Ctrl: true}},
+		{"csi u: shift+tab", "\x1b[9;2u", "", KeyMsg{Type: KeyShiftTab}},
+		{"csi u: esc", "\x1b[27u", "", KeyMsg{Type: KeyEscape}},
+		{"csi u: alt+backspace", "\x1b[127;3u", "", KeyMsg{Type: KeyBackspace, Alt: true}},
+		{"csi u: ctrl+ ", "\x1b[32;5u", "", KeyMsg{Type: KeySpace, Runes: []rune{' '}, Ctrl: true}},
+		{"csi u: kitty alternates", "\x1b[97:65;2u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'A'}}},
+		{"csi u: kitty text", "\x1b[97;1;97u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"csi u: modifyOtherKeys ctrl+shift+p", "\x1b[27;6;80~", "", KeyMsg{Type: KeyRunes, Runes: []rune{'p'}, Ctrl: true, Shift: true}},
+		{"csi u: modifyOtherKeys alt+enter", "\x1b[27;3;13~", "", KeyMsg{Type: KeyEnter, Alt: true}},
+		{"csi u: modifyOtherKeys a", "\x1b[27;1;97~", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"csi u: private use area", "\x1b[57399u", "", unknownCSISequenceMsg("\x1b[57399u")},
+		{"csi u: invalid modifier", "\x1b[97;0u", "", unknownCSISequenceMsg("\x1b[97;0u")},
+		{"key events: press", "\x1b[97;1:1u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"key events: repeat", "\x1b[97;1:2u", "", KeyMsg{Type: KeyRunes, Runes: []rune{'a'}, Repeat: true}},
+		{"key events: release", "\x1b[97;1:3u", "", KeyReleaseMsg{Type: KeyRunes, Runes: []rune{'a'}}},
+		{"key events: ctrl+a release", "\x1b[97;5:3u", "", KeyReleaseMsg{Type: KeyCtrlA}},
+		{"key events: enter release", "\x1b[13;1:3u", "", KeyReleaseMsg{Type: KeyEnter}},
+		{"key events: up repeat", "\x1b[1;1:2A", "", KeyMsg{Type: KeyUp, Repeat: true}},
+		{"key events: up release", "\x1b[1;1:3A", "", KeyReleaseMsg{Type: KeyUp}},
+		{"key events: ctrl+up release", "\x1b[1;5:3A", "", KeyReleaseMsg{Type: KeyCtrlUp}},
+		{"key events: f1 release", "\x1b[1;1:3P", "", KeyReleaseMsg{Type: KeyF1}},
+		{"key events: delete release", "\x1b[3;1:3~", "", KeyReleaseMsg{Type: KeyDelete}},
+		{"key events: pgup repeat", "\x1b[5;1:2~", "", KeyMsg{Type: KeyPgUp, Repeat: true}},
+		{"keypad: esc Op", "\x1bOp", "", KeyMsg{Type: KeyRunes, Runes: []rune{'0'}}},
+		{"keypad: esc Oq", "\x1bOq", "", KeyMsg{Type: KeyRunes, Runes: []rune{'1'}}},
+		{"keypad: esc Or", "\x1bOr", "", KeyMsg{Type: KeyRunes, Runes: []rune{'2'}}},
+		{"keypad: esc Os", "\x1bOs", "", KeyMsg{Type: KeyRunes, Runes: []rune{'3'}}},
+		{"keypad: esc Ot", "\x1bOt", "", KeyMsg{Type: KeyRunes, Runes: []rune{'4'}}},
+		{"keypad: esc Ou", "\x1bOu", "", KeyMsg{Type: KeyRunes, Runes: []rune{'5'}}},
+		{"keypad: esc Ov", "\x1bOv", "", KeyMsg{Type: KeyRunes, Runes: []rune{'6'}}},
+		{"keypad: esc Ow", "\x1bOw", "", KeyMsg{Type: KeyRunes, Runes: []rune{'7'}}},
+		{"keypad: esc Ox", "\x1bOx", "", KeyMsg{Type: KeyRunes, Runes: []rune{'8'}}},
+		{"keypad: esc Oy", "\x1bOy", "", KeyMsg{Type: KeyRunes, Runes: []rune{'9'}}},
+		{"keypad: esc Oj", "\x1bOj", "", KeyMsg{Type: KeyRunes, Runes: []rune{'*'}}},
+		{"keypad: esc Ok", "\x1bOk", "", KeyMsg{Type: KeyRunes, Runes: []rune{'+'}}},
+		{"keypad: esc Ol", "\x1bOl", "", KeyMsg{Type: KeyRunes, Runes: []rune{','}}},
+		{"keypad: esc Om", "\x1bOm", "", KeyMsg{Type: KeyRunes, Runes: []rune{'-'}}},
+		{"keypad: esc On", "\x1bOn", "", KeyMsg{Type: KeyRunes, Runes: []rune{'.'}}},
+		{"keypad: esc Oo", "\x1bOo", "", KeyMsg{Type: KeyRunes, Runes: []rune{'/'}}},
+		{"keypad: esc OX", "\x1bOX", "", KeyMsg{Type: KeyRunes, Runes: []rune{'='}}},
+		{"keypad: esc OM", "\x1bOM", "", KeyMsg{Type: KeyEnter}},
+		{"cursor position: esc [?18;1R", "\x1b[?18;1R", "", cursorPositionReportMsg{row: 18, col: 1}},
+		{"cursor position: esc [?5;40;1R", "\x1b[?5;40;1R", "", cursorPositionReportMsg{row: 5, col: 40}},
+		{"window size: other report", "\x1b[4;480;640t", "", unknownCSISequenceMsg("\x1b[4;480;640t")},
+		{"window size: cursor position", "\x1b[8;24R", "", unknownCSISequenceMsg("\x1b[8;24R")},
+		{"cursor position: function key", "\x1b[1;2R", "", KeyMsg{Type: KeyF3, Shift: true}},
+		{"mouse pixels: set", "\x1b[?1016;1$y", "", mousePixelsReportMsg{on: true}},
+		{"mouse pixels: not recognized", "\x1b[?1016;0$ya", "a", mousePixelsReportMsg{on: false}},
+	}
+	for _, tc := range td {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, w := ParseSequence([]byte(tc.seq))
+			if expected := len(tc.seq) - len(tc.rest); w != expected {
+				t.Errorf("%q: expected %d bytes to be taken up, got %d", tc.seq, expected, w)
+			}
+			if !reflect.DeepEqual(msg, tc.msg) {
+				t.Errorf("%q: expected %#v, got %#v", tc.seq, tc.msg, msg)
+			}
+		})
+	}
+}
+
+func FuzzParseSequence(f *testing.F) {
+	for seq := range sequences {
+		f.Add([]byte(seq))
+	}
+	for _, seq := range []string{
+		"a", "\x1b", "\x1b\x1b", "\x00", "\x7f", "\xff", "日本",
+		"\x1b[M !!", "\x1b[M", "\x1b[<0;10;20M", "\x1b[<0;10", "\x1b[32;1;1M",
+		"\x1b[200~paste\x1b[201~", "\x1b[200~", "\x1b[97;5u", "\x1b[27;5;97~",
+		"\x1b[8;24;80t", "\x1b[?62;4c", "\x1b]52;c;aGk=\x07", "\x1b]52;c;",
+	} {
+		f.Add([]byte(seq))
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, w := ParseSequence(b)
+		if w < 0 || w > len(b) {
+			t.Fatalf("%q: took up %d bytes", b, w)
+		}
+		if w == 0 && len(b) > 0 && !bytes.HasPrefix(b, []byte("\x1b")) {
+			t.Fatalf("%q: took up no bytes, but doesn't start a sequence", b)
+		}
+
+		// The input loop reads until it has a whole event.
+		w, _ = defaultInputParser.detectOneMsg(b, true)
+		if w < 0 || w > len(b) {
+			t.Fatalf("%q: took up %d bytes with more data to come", b, w)
+		}
+	})
+}
@@ -7,18 +7,19 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/erikgeiser/coninput"
 	localereader "github.com/mattn/go-localereader"
 	"golang.org/x/sys/windows"
 )
 
-func readInputs(ctx context.Context, msgs chan<- Msg, input io.Reader) error {
+func readInputs(ctx context.Context, msgs chan<- Msg, input io.Reader, parser *inputParser) error {
 	if coninReader, ok := input.(*conInputReader); ok {
 		return readConInputs(ctx, msgs, coninReader.conin)
 	}
 
-	return readAnsiInputs(ctx, msgs, localereader.NewReader(input))
+	return readAnsiInputs(ctx, msgs, localereader.NewReader(input), parser)
 }
 
 func readConInputs(ctx context.Context, msgsch chan<- Msg, con windows.Handle) error {
@@ -62,9 +63,10 @@ func readConInputs(ctx context.Context, msgsch chan<- Msg, con windows.Handle) e
 			}
 
 			// Send all messages to the channel
+			now := time.Now()
 			for _, msg := range msgs {
 				select {
-				case msgsch <- msg:
+				case msgsch <- stampInput(msg, now):
 				case <-ctx.Done():
 					err := ctx.Err()
 					if err != nil {
@@ -0,0 +1,67 @@
+package tea
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineMsg contains a line of input, without its line ending. Lines are only
+// delivered instead of key presses when the program was started with
+// WithLineInput and its input isn't a terminal.
+type LineMsg struct {
+	Text string
+}
+
+// InputEOFMsg is sent when the program's input has been read to the end, such
+// as when input is piped in from a command that finished, or an SSH channel
+// was closed. No further input will arrive. Use WithQuitOnInputEOF to quit
+// when this happens.
+//
+// It's also sent when an input added with WithAdditionalInput reaches its
+// end, with Input set to it.
+//
+// Errors reading the input, other than reaching its end, make [Program.Run]
+// return the error.
+type InputEOFMsg struct {
+	// Input is the additional input that was read to the end, or nil for
+	// the program's input.
+	Input io.Reader
+}
+
+// readLines reads input a line at a time, sending each line as a LineMsg. It
+// returns io.EOF once the input has been exhausted.
+func readLines(ctx context.Context, msgs chan<- Msg, input io.Reader) error {
+	send := func(msg Msg) error {
+		select {
+		case msgs <- msg:
+			return nil
+		case <-ctx.Done():
+			err := ctx.Err()
+			if err != nil {
+				err = fmt.Errorf("found context error while reading input: %w", err)
+			}
+			return err
+		}
+	}
+
+	r := bufio.NewReader(input)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if err := send(LineMsg{Text: line}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+	}
+}
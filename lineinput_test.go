@@ -0,0 +1,52 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type lineModel struct {
+	msgs []Msg
+}
+
+func (m *lineModel) Init() Cmd {
+	return nil
+}
+
+func (m *lineModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case LineMsg:
+		m.msgs = append(m.msgs, msg)
+	case InputEOFMsg:
+		m.msgs = append(m.msgs, msg)
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m *lineModel) View() string {
+	return ""
+}
+
+func TestLineInput(t *testing.T) {
+	var buf bytes.Buffer
+	in := bytes.NewBufferString("one\r\n\x1b[Atwo\n\nthree")
+
+	m := &lineModel{}
+	p := NewProgram(m, WithInput(in), WithOutput(&buf), WithLineInput())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Msg{
+		LineMsg{Text: "one"},
+		LineMsg{Text: "\x1b[Atwo"},
+		LineMsg{Text: ""},
+		LineMsg{Text: "three"},
+		InputEOFMsg{},
+	}
+	if !reflect.DeepEqual(m.msgs, expected) {
+		t.Errorf("expected messages %#v, got %#v", expected, m.msgs)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
 	"unicode"
 )
 
@@ -20,6 +21,9 @@ import (
 //			os.Exit(1)
 //	  }
 //	  defer f.Close()
+//
+// Log output goes only to the file, never to the program's output, so it's
+// safe to log from Update and View while the program is running.
 func LogToFile(path string, prefix string) (*os.File, error) {
 	return LogToFileWith(path, prefix, log.Default())
 }
@@ -51,3 +55,105 @@ func LogToFileWith(path string, prefix string, log LogOptionsSetter) (*os.File,
 
 	return f, nil
 }
+
+// LogOptions configures logging with LogToFileWithOptions.
+type LogOptions struct {
+	// Prefix is written at the start of each log line.
+	Prefix string
+
+	// Timestamps adds the date and time to each log line.
+	Timestamps bool
+
+	// MaxSize is the size in bytes the log file may grow to. When a write
+	// would take it past this size, the file is moved to the same path with
+	// ".1" appended, replacing any previous one, and a new file is started.
+	// Zero means no limit.
+	MaxSize int64
+}
+
+// LogToFileWithOptions is like LogToFile, but allows adding timestamps and
+// limiting the size of the log file.
+//
+// Don't forget to close the returned writer when you're done with it.
+//
+//	w, err := LogToFileWithOptions("debug.log", LogOptions{
+//	    Prefix:     "debug",
+//	    Timestamps: true,
+//	    MaxSize:    1 << 20,
+//	})
+//	if err != nil {
+//	    fmt.Println("fatal:", err)
+//	    os.Exit(1)
+//	}
+//	defer w.Close()
+func LogToFileWithOptions(path string, opts LogOptions) (io.WriteCloser, error) {
+	f, err := LogToFile(path, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := log.Lmsgprefix
+	if opts.Timestamps {
+		flags |= log.LstdFlags
+	}
+	log.SetFlags(flags)
+
+	if opts.MaxSize <= 0 {
+		return f, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error opening file for logging: %w", err)
+	}
+	w := &rotatingFile{path: path, maxSize: opts.MaxSize, file: f, size: info.Size()}
+	log.SetOutput(w)
+	return w, nil
+}
+
+// rotatingFile is a log file that's moved aside when it grows too large.
+type rotatingFile struct {
+	mtx     sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err //nolint:wrapcheck
+}
+
+// rotate moves the current log file aside and starts a new one.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("error rotating log file: %w", err)
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("error rotating log file: %w", err)
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("error rotating log file: %w", err)
+	}
+	r.file, r.size = f, 0
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.file.Close() //nolint:wrapcheck
+}
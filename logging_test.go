@@ -1,9 +1,12 @@
 package tea
 
 import (
+	"bytes"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +30,103 @@ func TestLogToFile(t *testing.T) {
 		t.Fatalf("wrong log msg: %q", string(out))
 	}
 }
+
+type loggingModel struct{}
+
+func (m loggingModel) Init() Cmd { return nil }
+
+func (m loggingModel) Update(msg Msg) (Model, Cmd) {
+	if k, ok := msg.(KeyMsg); ok {
+		log.Printf("got key %s", k)
+		return m, Quit
+	}
+	log.Printf("got %T", msg)
+	return m, nil
+}
+
+func (m loggingModel) View() string { return "view\n" }
+
+func resetLog() {
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("")
+	log.SetFlags(log.LstdFlags)
+}
+
+func TestLogToFileDuringRun(t *testing.T) {
+	defer resetLog()
+
+	path := filepath.Join(t.TempDir(), "log.txt")
+	f, err := LogToFile(path, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.SetFlags(log.Lmsgprefix)
+
+	var buf bytes.Buffer
+	in := bytes.NewBufferString("q")
+	if _, err := NewProgram(loggingModel{}, WithInput(in), WithOutput(&buf)).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "got key") {
+		t.Errorf("expected no log output in the program output, got %q", buf.String())
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "debug got key q\n"; !strings.Contains(string(out), expected) {
+		t.Errorf("expected log to contain %q, got %q", expected, out)
+	}
+}
+
+func TestLogToFileWithOptions(t *testing.T) {
+	defer resetLog()
+
+	t.Run("timestamps", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.txt")
+		w, err := LogToFileWithOptions(path, LogOptions{Prefix: "debug", Timestamps: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Println("hello")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d debug hello\n$`).Match(out) {
+			t.Errorf("expected a timestamped log line, got %q", out)
+		}
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.txt")
+		w, err := LogToFileWithOptions(path, LogOptions{MaxSize: 12})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []string{"one", "two", "three", "four"} {
+			log.Println(s)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for p, expected := range map[string]string{path + ".1": "one\ntwo\n", path: "three\nfour\n"} {
+			out, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != expected {
+				t.Errorf("expected %s to contain %q, got %q", p, expected, out)
+			}
+		}
+	})
+}
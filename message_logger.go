@@ -0,0 +1,117 @@
+package tea
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// msgLogBufferSize is the number of log entries that can be waiting to
+	// be written before entries are dropped.
+	msgLogBufferSize = 1024
+
+	// msgLogValueWidth is the width message values are shortened to.
+	msgLogValueWidth = 60
+)
+
+// msgLogger writes a trace of the messages delivered to Update, and of calls
+// to Init and View, for debugging. Entries are written from a separate
+// goroutine so that a slow writer never holds up the event loop; if the
+// writer falls too far behind, entries are dropped and the number dropped is
+// logged instead.
+type msgLogger struct {
+	out     io.Writer
+	verbose bool
+	entries chan string
+	dropped int
+	done    chan struct{}
+}
+
+func newMsgLogger(out io.Writer, verbose bool) *msgLogger {
+	return &msgLogger{
+		out:     out,
+		verbose: verbose,
+		entries: make(chan string, msgLogBufferSize),
+	}
+}
+
+// start starts writing log entries.
+func (l *msgLogger) start() {
+	if l == nil {
+		return
+	}
+	if l.done != nil {
+		// The entries of the last run were closed when it stopped.
+		l.entries = make(chan string, msgLogBufferSize)
+	}
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		for entry := range l.entries {
+			_, _ = io.WriteString(l.out, entry)
+		}
+	}()
+}
+
+// stop writes any remaining log entries and stops the logger.
+func (l *msgLogger) stop() {
+	if l == nil || l.done == nil {
+		return
+	}
+	close(l.entries)
+	<-l.done
+}
+
+// logMsg logs a message about to be delivered to Update. Messages the
+// program uses internally, such as the ones that control the renderer, are
+// only logged in verbose mode.
+func (l *msgLogger) logMsg(msg Msg) {
+	if l == nil {
+		return
+	}
+	if _, ok := msg.(internalMsg); ok && !l.verbose {
+		return
+	}
+	l.log(fmt.Sprintf("Update %T %s", msg, formatMsgValue(msg)))
+}
+
+// log logs an event, such as a call to Init or View.
+func (l *msgLogger) log(event string) {
+	if l == nil {
+		return
+	}
+	now := time.Now().Format("15:04:05.000000")
+	if l.dropped > 0 {
+		if !l.send(fmt.Sprintf("%s (%d entries dropped)\n", now, l.dropped)) {
+			l.dropped++
+			return
+		}
+		l.dropped = 0
+	}
+	if !l.send(now + " " + event + "\n") {
+		l.dropped++
+	}
+}
+
+// send queues an entry to be written, reporting false if the queue is full.
+func (l *msgLogger) send(entry string) bool {
+	select {
+	case l.entries <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatMsgValue renders a message on a single line, shortened to a
+// reasonable width.
+func formatMsgValue(msg Msg) string {
+	s := fmt.Sprintf("%+v", msg)
+	s = strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+	if r := []rune(s); len(r) > msgLogValueWidth {
+		s = string(r[:msgLogValueWidth-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type traceModel struct{}
+
+func (m traceModel) Init() Cmd {
+	return func() Msg { return KeyMsg{Type: KeyEnter} }
+}
+
+func (m traceModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case KeyMsg:
+		return m, ClearScreen
+	case clearScreenMsg:
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m traceModel) View() string { return "trace\n" }
+
+func TestMessageLogger(t *testing.T) {
+	for name, tc := range map[string]struct {
+		verbose  bool
+		expected []string
+	}{
+		"default": {
+			expected: []string{
+				"Init", "View",
+				"Update tea.KeyMsg enter", "View",
+				"View",
+				"Quit", "View",
+			},
+		},
+		"verbose": {
+			verbose: true,
+			expected: []string{
+				"Init", "View",
+				"Update tea.KeyMsg enter", "View",
+				"Update tea.clearScreenMsg {}", "View",
+				"Quit", "View",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf, trace bytes.Buffer
+			p := NewProgram(traceModel{},
+				WithInput(nil),
+				WithOutput(&buf),
+				WithMessageLogger(&trace, tc.verbose))
+			if _, err := p.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Strip the timestamps.
+			var events []string
+			for _, line := range strings.Split(strings.TrimSuffix(trace.String(), "\n"), "\n") {
+				_, event, _ := strings.Cut(line, " ")
+				events = append(events, event)
+			}
+			if !reflect.DeepEqual(events, tc.expected) {
+				t.Errorf("expected trace %q, got %q", tc.expected, events)
+			}
+		})
+	}
+}
+
+func TestMessageLoggerDropsEntries(t *testing.T) {
+	l := newMsgLogger(&bytes.Buffer{}, false)
+	for i := 0; i < msgLogBufferSize+2; i++ {
+		l.log("View")
+	}
+	if l.dropped != 2 {
+		t.Errorf("expected 2 dropped entries, got %d", l.dropped)
+	}
+
+	// Make room, so the dropped entries are reported.
+	<-l.entries
+	<-l.entries
+	l.log("View")
+	if l.dropped != 0 {
+		t.Errorf("expected dropped entries to be reported, got %d", l.dropped)
+	}
+	var last string
+	for len(l.entries) > 0 {
+		last = <-l.entries
+	}
+	if !strings.HasSuffix(last, " View\n") {
+		t.Errorf("expected the last entry to be logged, got %q", last)
+	}
+}
+
+func TestFormatMsgValue(t *testing.T) {
+	if s := formatMsgValue("a\nb"); s != `a\nb` {
+		t.Errorf("expected newlines to be escaped, got %q", s)
+	}
+	if s := formatMsgValue(strings.Repeat("x", 100)); len([]rune(s)) != msgLogValueWidth {
+		t.Errorf("expected value to be shortened to %d runes, got %q", msgLogValueWidth, s)
+	}
+}
@@ -1,6 +1,12 @@
 package tea
 
-import "strconv"
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
 
 // MouseMsg contains information about a mouse event and are sent to a programs
 // update function when mouse activity occurs. Note that the mouse must first
@@ -23,6 +29,31 @@ type MouseEvent struct {
 	Action MouseAction
 	Button MouseButton
 
+	// PixelX and PixelY hold the position of the mouse in pixels. They're
+	// only set when pixel mouse mode has been enabled with the
+	// EnableMousePixelMotion command, and the terminal confirmed it's in
+	// it. In that mode X and Y are derived from
+	// the size of a terminal cell, or set to -1 if the terminal doesn't
+	// report its cell size.
+	PixelX int
+	PixelY int
+
+	// ViewX and ViewY hold the position of the mouse relative to the top
+	// left of the view, which is where the program is drawn in the terminal
+	// when it doesn't use the alternate screen, and is only known then with
+	// WithMouseViewPosition. InView reports whether the event is on one of
+	// the lines of the view. Events above or below it aren't, nor are events
+	// that arrive before the terminal has told us where the view is, in
+	// which case ViewX and ViewY are 0. They're set on events read from the
+	// terminal, not on ones passed to Program.Send.
+	ViewX  int
+	ViewY  int
+	InView bool
+
+	// Time is when the event was read from the input, or when it was passed
+	// to Program.Send. It holds a monotonic clock reading.
+	Time time.Time
+
 	// Deprecated: Use MouseAction & MouseButton instead.
 	Type MouseEventType
 }
@@ -53,6 +84,9 @@ func (m MouseEvent) String() (s string) {
 		}
 	} else if m.IsWheel() {
 		s += mouseButtons[m.Button]
+		if m.Action == MouseActionRelease {
+			s += " " + mouseActions[m.Action]
+		}
 	} else {
 		btn := mouseButtons[m.Button]
 		if btn != "" {
@@ -183,9 +217,11 @@ func parseSGRMouseEvent(buf []byte) MouseEvent {
 	release := matches[4] == "m"
 	m := parseMouseButton(b, true)
 
-	// Wheel buttons don't have release events
+	// The button is kept on release so presses and releases can be paired.
+	// Some terminals also report releases for wheel buttons; these are
+	// reported as releases rather than as another scroll.
 	// Motion can be reported as a release event in some terminals (Windows Terminal)
-	if m.Action != MouseActionMotion && !m.IsWheel() && release {
+	if m.Action != MouseActionMotion && release {
 		m.Action = MouseActionRelease
 		m.Type = MouseRelease
 	}
@@ -202,6 +238,34 @@ func parseSGRMouseEvent(buf []byte) MouseEvent {
 
 const x10MouseByteOffset = 32
 
+// Parse urxvt-encoded (1015) mouse events. These use the same button encoding
+// as X10 but report coordinates as decimal numbers, so they aren't limited to
+// 223 columns or rows. urxvt doesn't support the SGR encoding.
+//
+// urxvt mouse events look like:
+//
+//	ESC [ Cb ; Cx ; Cy M
+//
+// See: https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Extended-coordinates
+func parseURXVTMouseEvent(buf []byte) MouseEvent {
+	matches := mouseURXVTRegex.FindSubmatch(buf)
+	if len(matches) != 4 {
+		// Unreachable, we already checked the regex in `detectOneMsg`.
+		panic("invalid mouse event")
+	}
+
+	b, _ := strconv.Atoi(string(matches[1]))
+	x, _ := strconv.Atoi(string(matches[2]))
+	y, _ := strconv.Atoi(string(matches[3]))
+	m := parseMouseButton(b, false)
+
+	// (1,1) is the upper left. We subtract 1 to normalize it to (0,0).
+	m.X = x - 1
+	m.Y = y - 1
+
+	return m
+}
+
 // Parse X10-encoded mouse events; the simplest kind. The last release of X10
 // was December 1986, by the way. The original X10 mouse protocol limits the Cx
 // and Cy coordinates to 223 (=255-032).
@@ -306,3 +370,182 @@ func parseMouseButton(b int, isSGR bool) MouseEvent {
 
 	return m
 }
+
+// pixelMouseEvent translates a mouse event reported in pixel mode, where the
+// coordinates are in pixels, into one with both pixel and cell coordinates.
+// cellWidth and cellHeight are the size of a terminal cell in pixels, or zero
+// if unknown.
+func pixelMouseEvent(m MouseEvent, cellWidth, cellHeight int) MouseEvent {
+	m.PixelX, m.PixelY = m.X, m.Y
+	if cellWidth > 0 && cellHeight > 0 {
+		m.X, m.Y = m.PixelX/cellWidth, m.PixelY/cellHeight
+	} else {
+		m.X, m.Y = -1, -1
+	}
+	return m
+}
+
+// requestMousePixelsSeq asks the terminal whether it reports mouse events in
+// pixels (DECRQM for mode 1016).
+const requestMousePixelsSeq = "?1016$p"
+
+// mousePixelsReportRe matches the terminal's answer to
+// requestMousePixelsSeq:
+//
+//	CSI ? 1016 ; Ps $ y
+//
+// where Ps is 1 or 3 when the mode is set.
+var mousePixelsReportRe = regexp.MustCompile(`^\x1b\[\?1016;(\d)\$y`)
+
+// mousePixelsReportMsg is the terminal's answer to a request for whether it
+// reports mouse events in pixels.
+type mousePixelsReportMsg struct {
+	on bool
+}
+
+func (mousePixelsReportMsg) internalMsg() {}
+
+// detectMousePixelsReport detects the terminal's answer to a request for
+// whether it reports mouse events in pixels.
+func detectMousePixelsReport(input []byte) (hasReport bool, width int, msg Msg) {
+	m := mousePixelsReportRe.FindSubmatch(input)
+	if m == nil {
+		return false, 0, nil
+	}
+	on := m[1][0] == '1' || m[1][0] == '3'
+	return true, len(m[0]), mousePixelsReportMsg{on: on}
+}
+
+// pixelMouse translates the mouse events read from the terminal after it
+// confirmed reporting them in pixels. It's shared by the event loop, which
+// turns pixel mode on and off, and the goroutine reading the input.
+type pixelMouse struct {
+	mtx sync.Mutex
+
+	// requested is whether pixel mode was turned on, and confirmed whether
+	// the terminal answered that it's in it since.
+	requested bool
+	confirmed bool
+
+	// the size of a cell in pixels, if known
+	cellWidth  int
+	cellHeight int
+}
+
+// request records that pixel mode was turned on, and that the terminal is yet
+// to confirm it.
+func (m *pixelMouse) request() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.requested, m.confirmed = true, false
+}
+
+// reset records that pixel mode was turned off.
+func (m *pixelMouse) reset() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.requested, m.confirmed = false, false
+}
+
+// setCellSize records the size of a cell in pixels, or zero if unknown.
+func (m *pixelMouse) setCellSize(width, height int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.cellWidth, m.cellHeight = width, height
+}
+
+// confirm records the terminal's answer to whether it's in pixel mode.
+// Answers that come in when it wasn't asked to be are ignored.
+func (m *pixelMouse) confirm(on bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.confirmed = m.requested && on
+}
+
+// translate translates a mouse event read from the terminal while it reports
+// them in pixels. Other events are returned unchanged.
+func (m *pixelMouse) translate(ev MouseEvent) MouseEvent {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if !m.confirmed {
+		return ev
+	}
+	return pixelMouseEvent(ev, m.cellWidth, m.cellHeight)
+}
+
+// coalesceMouseMotion forwards messages from in to out. While out isn't ready
+// to receive, consecutive motion events with the same buttons and modifiers
+// are collapsed into the latest one. Any other message first flushes the
+// pending motion event so ordering is preserved. It returns when in is closed
+// or the context is done.
+func coalesceMouseMotion(ctx context.Context, in <-chan Msg, out chan<- Msg) {
+	var pending *MouseMsg
+
+	send := func(msg Msg) bool {
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		// Only try to deliver when there's something pending.
+		var pendingOut chan<- Msg
+		var pendingMsg Msg
+		if pending != nil {
+			pendingOut = out
+			pendingMsg = *pending
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case pendingOut <- pendingMsg:
+			pending = nil
+
+		case msg, ok := <-in:
+			if !ok {
+				if pending != nil {
+					send(*pending)
+				}
+				return
+			}
+
+			m, isMotion := msg.(MouseMsg)
+			isMotion = isMotion && m.Action == MouseActionMotion
+			if isMotion && pending != nil && sameMotion(*pending, m) {
+				pending = &m
+				continue
+			}
+			if pending != nil {
+				if !send(*pending) {
+					return
+				}
+				pending = nil
+			}
+			if isMotion {
+				pending = &m
+				continue
+			}
+			if !send(msg) {
+				return
+			}
+		}
+	}
+}
+
+// sameMotion reports whether two motion events can be collapsed into one.
+func sameMotion(a, b MouseMsg) bool {
+	return a.Button == b.Button &&
+		a.Shift == b.Shift &&
+		a.Alt == b.Alt &&
+		a.Ctrl == b.Ctrl
+}
@@ -1,8 +1,12 @@
 package tea
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMouseEvent_String(t *testing.T) {
@@ -788,6 +792,28 @@ func TestParseSGRMouseEvent(t *testing.T) {
 				Button: MouseButtonWheelRight,
 			},
 		},
+		{
+			name: "wheel up release",
+			buf:  encode(64, 32, 16, true),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Type:   MouseRelease,
+				Action: MouseActionRelease,
+				Button: MouseButtonWheelUp,
+			},
+		},
+		{
+			name: "wheel down release",
+			buf:  encode(65, 32, 16, true),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Type:   MouseRelease,
+				Action: MouseActionRelease,
+				Button: MouseButtonWheelDown,
+			},
+		},
 		{
 			name: "backward",
 			buf:  encode(128, 32, 16, false),
@@ -907,6 +933,77 @@ func TestParseSGRMouseEvent(t *testing.T) {
 				Button: MouseButtonWheelDown,
 			},
 		},
+		{
+			name: "shift+wheel up",
+			buf:  encode(68, 32, 16, false),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Shift:  true,
+				Type:   MouseWheelUp,
+				Action: MouseActionPress,
+				Button: MouseButtonWheelUp,
+			},
+		},
+		{
+			name: "shift+wheel down",
+			buf:  encode(69, 32, 16, false),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Shift:  true,
+				Type:   MouseWheelDown,
+				Action: MouseActionPress,
+				Button: MouseButtonWheelDown,
+			},
+		},
+		{
+			name: "shift+wheel left",
+			buf:  encode(70, 32, 16, false),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Shift:  true,
+				Type:   MouseWheelLeft,
+				Action: MouseActionPress,
+				Button: MouseButtonWheelLeft,
+			},
+		},
+		{
+			name: "shift+wheel right",
+			buf:  encode(71, 32, 16, false),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Shift:  true,
+				Type:   MouseWheelRight,
+				Action: MouseActionPress,
+				Button: MouseButtonWheelRight,
+			},
+		},
+		{
+			name: "wheel left with motion bit",
+			buf:  encode(98, 32, 16, false),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Type:   MouseWheelLeft,
+				Action: MouseActionPress,
+				Button: MouseButtonWheelLeft,
+			},
+		},
+		{
+			name: "ctrl+wheel right with motion bit",
+			buf:  encode(115, 32, 16, false),
+			expected: MouseEvent{
+				X:      32,
+				Y:      16,
+				Ctrl:   true,
+				Type:   MouseWheelRight,
+				Action: MouseActionPress,
+				Button: MouseButtonWheelRight,
+			},
+		},
 		{
 			name: "ctrl+alt+shift+wheel press",
 			buf:  encode(93, 32, 16, false),
@@ -937,3 +1034,389 @@ func TestParseSGRMouseEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestPixelMouseEvent(t *testing.T) {
+	tt := []struct {
+		name       string
+		buf        []byte
+		cellWidth  int
+		cellHeight int
+		expected   MouseEvent
+	}{
+		{
+			name:       "press",
+			buf:        []byte("\x1b[<0;101;201M"),
+			cellWidth:  10,
+			cellHeight: 20,
+			expected: MouseEvent{
+				X:      10,
+				Y:      10,
+				PixelX: 100,
+				PixelY: 200,
+				Type:   MouseLeft,
+				Action: MouseActionPress,
+				Button: MouseButtonLeft,
+			},
+		},
+		{
+			name:       "motion",
+			buf:        []byte("\x1b[<35;96;39M"),
+			cellWidth:  8,
+			cellHeight: 16,
+			expected: MouseEvent{
+				X:      11,
+				Y:      2,
+				PixelX: 95,
+				PixelY: 38,
+				Type:   MouseMotion,
+				Action: MouseActionMotion,
+				Button: MouseButtonNone,
+			},
+		},
+		{
+			name:       "release",
+			buf:        []byte("\x1b[<0;8;16m"),
+			cellWidth:  8,
+			cellHeight: 16,
+			expected: MouseEvent{
+				X:      0,
+				Y:      0,
+				PixelX: 7,
+				PixelY: 15,
+				Type:   MouseRelease,
+				Action: MouseActionRelease,
+				Button: MouseButtonLeft,
+			},
+		},
+		{
+			name: "unknown cell size",
+			buf:  []byte("\x1b[<0;101;201M"),
+			expected: MouseEvent{
+				X:      -1,
+				Y:      -1,
+				PixelX: 100,
+				PixelY: 200,
+				Type:   MouseLeft,
+				Action: MouseActionPress,
+				Button: MouseButtonLeft,
+			},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			actual := pixelMouseEvent(parseSGRMouseEvent(tc.buf), tc.cellWidth, tc.cellHeight)
+			if tc.expected != actual {
+				t.Fatalf("expected %#v but got %#v",
+					tc.expected,
+					actual,
+				)
+			}
+		})
+	}
+}
+
+func TestCoalesceMouseMotion(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&input, "\x1b[<35;%d;1M", i+1)
+	}
+	input.WriteString("\x1b[<0;50;1M")
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&input, "\x1b[<32;%d;1M", i+51)
+	}
+
+	in := make(chan Msg)
+	out := make(chan Msg)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		defer close(in)
+		_ = readAnsiInputs(ctx, in, &input, defaultInputParser)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		coalesceMouseMotion(ctx, in, out)
+	}()
+
+	// Act like a slow Update, so the burst arrives while we're busy.
+	var msgs []MouseMsg
+loop:
+	for {
+		select {
+		case msg := <-out:
+			msgs = append(msgs, msg.(MouseMsg))
+			time.Sleep(10 * time.Millisecond)
+		case <-done:
+			break loop
+		}
+	}
+
+	if len(msgs) >= 10 {
+		t.Fatalf("expected motion events to be coalesced, got %d messages", len(msgs))
+	}
+
+	var press int
+	for i, m := range msgs {
+		if m.Action == MouseActionPress {
+			press = i
+		}
+	}
+	if m := msgs[press]; m.Button != MouseButtonLeft || m.X != 49 {
+		t.Fatalf("expected press to be delivered, got %v", msgs)
+	}
+	if m := msgs[press-1]; m.Action != MouseActionMotion || m.X != 49 {
+		t.Errorf("expected last motion before press at x=49, got %v", m)
+	}
+	if m := msgs[len(msgs)-1]; m.Action != MouseActionMotion || m.Button != MouseButtonLeft || m.X != 54 {
+		t.Errorf("expected final motion at x=54, got %v", m)
+	}
+}
+
+func TestParseURXVTMouseEvent(t *testing.T) {
+	encodeURXVT := func(b, x, y int) []byte {
+		return []byte(fmt.Sprintf("\x1b[%d;%d;%dM", b+32, x+1, y+1))
+	}
+	encodeSGR := func(b, x, y int, r bool) []byte {
+		re := 'M'
+		if r {
+			re = 'm'
+		}
+		return []byte(fmt.Sprintf("\x1b[<%d;%d;%d%c", b, x+1, y+1, re))
+	}
+
+	tt := []struct {
+		name  string
+		urxvt []byte
+		sgr   []byte
+	}{
+		{
+			name:  "left press",
+			urxvt: encodeURXVT(0, 32, 16),
+			sgr:   encodeSGR(0, 32, 16, false),
+		},
+		{
+			name:  "right press beyond X10 range",
+			urxvt: encodeURXVT(2, 300, 250),
+			sgr:   encodeSGR(2, 300, 250, false),
+		},
+		{
+			name:  "release",
+			urxvt: encodeURXVT(3, 300, 250),
+			sgr:   encodeSGR(3, 300, 250, true),
+		},
+		{
+			name:  "wheel up",
+			urxvt: encodeURXVT(64, 32, 16),
+			sgr:   encodeSGR(64, 32, 16, false),
+		},
+		{
+			name:  "ctrl+wheel down",
+			urxvt: encodeURXVT(81, 32, 16),
+			sgr:   encodeSGR(81, 32, 16, false),
+		},
+		{
+			name:  "left drag motion",
+			urxvt: encodeURXVT(32, 400, 16),
+			sgr:   encodeSGR(32, 400, 16, false),
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			w, msg := defaultInputParser.detectOneMsg(tc.urxvt, false)
+			if w != len(tc.urxvt) {
+				t.Fatalf("expected to consume %d bytes, consumed %d", len(tc.urxvt), w)
+			}
+			expected := MouseMsg(parseSGRMouseEvent(tc.sgr))
+			if msg != expected {
+				t.Fatalf("expected %#v but got %#v",
+					expected,
+					msg,
+				)
+			}
+		})
+	}
+}
+
+func TestParseMouseModifiers(t *testing.T) {
+	modifiers := []struct {
+		name             string
+		bits             int
+		shift, alt, ctrl bool
+	}{
+		{"", 0, false, false, false},
+		{"shift+", 4, true, false, false},
+		{"alt+", 8, false, true, false},
+		{"ctrl+", 16, false, false, true},
+		{"ctrl+alt+shift+", 28, true, true, true},
+	}
+	events := []struct {
+		name    string
+		button  int
+		release bool
+		event   MouseEvent
+	}{
+		{"left press", 0, false, MouseEvent{Type: MouseLeft, Action: MouseActionPress, Button: MouseButtonLeft}},
+		{"left release", 0, true, MouseEvent{Type: MouseRelease, Action: MouseActionRelease, Button: MouseButtonLeft}},
+		{"left motion", 32, false, MouseEvent{Type: MouseLeft, Action: MouseActionMotion, Button: MouseButtonLeft}},
+		{"motion", 35, false, MouseEvent{Type: MouseMotion, Action: MouseActionMotion, Button: MouseButtonNone}},
+		{"wheel up", 64, false, MouseEvent{Type: MouseWheelUp, Action: MouseActionPress, Button: MouseButtonWheelUp}},
+		{"wheel up release", 64, true, MouseEvent{Type: MouseRelease, Action: MouseActionRelease, Button: MouseButtonWheelUp}},
+	}
+
+	for _, mod := range modifiers {
+		for _, ev := range events {
+			name := mod.name + ev.name
+			t.Run(name, func(t *testing.T) {
+				final := 'M'
+				if ev.release {
+					final = 'm'
+				}
+				buf := []byte(fmt.Sprintf("\x1b[<%d;11;21%c", ev.button+mod.bits, final))
+
+				expected := ev.event
+				expected.X, expected.Y = 10, 20
+				expected.Shift, expected.Alt, expected.Ctrl = mod.shift, mod.alt, mod.ctrl
+
+				actual := parseSGRMouseEvent(buf)
+				if expected != actual {
+					t.Fatalf("expected %#v but got %#v", expected, actual)
+				}
+				if s := actual.String(); s != name {
+					t.Errorf("expected string %q, got %q", name, s)
+				}
+			})
+		}
+	}
+}
+
+func TestReadMouseDrag(t *testing.T) {
+	td := []struct {
+		name   string
+		button int
+		want   MouseButton
+	}{
+		{"left", 0, MouseButtonLeft},
+		{"middle", 1, MouseButtonMiddle},
+		{"right", 2, MouseButtonRight},
+	}
+
+	for _, tc := range td {
+		t.Run(tc.name, func(t *testing.T) {
+			// Press, drag across two cells, then release.
+			input := fmt.Sprintf("\x1b[<%d;1;1M\x1b[<%d;2;1M\x1b[<%d;3;1M\x1b[<%d;3;1m",
+				tc.button, tc.button+32, tc.button+32, tc.button)
+			msgs := testReadInputs(t, strings.NewReader(input))
+
+			expected := []MouseAction{MouseActionPress, MouseActionMotion, MouseActionMotion, MouseActionRelease}
+			if len(msgs) != len(expected) {
+				t.Fatalf("expected %d events, got %#v", len(expected), msgs)
+			}
+			for i, msg := range msgs {
+				m, ok := msg.(MouseMsg)
+				if !ok {
+					t.Fatalf("expected a mouse event, got %#v (%T)", msg, msg)
+				}
+				if m.Button != tc.want || m.Action != expected[i] {
+					t.Errorf("event %d: expected %v %v, got %v %v", i, tc.want, expected[i], m.Button, m.Action)
+				}
+			}
+			if last := msgs[len(msgs)-1].(MouseMsg); last.X != 2 || last.Y != 0 {
+				t.Errorf("expected release at (2, 0), got (%d, %d)", last.X, last.Y)
+			}
+		})
+	}
+}
+
+func TestPixelMouseConfirmed(t *testing.T) {
+	var pm pixelMouse
+	parser := defaultInputParser.withPixelMouse(&pm)
+	pm.setCellSize(10, 20)
+	press := MouseMsg(parseSGRMouseEvent([]byte("\x1b[<0;101;201M")))
+	deliver := func(msg Msg) MouseEvent {
+		t.Helper()
+		msg, ok := parser.deliver(msg)
+		if !ok {
+			t.Fatalf("expected %#v to be delivered", msg)
+		}
+		return MouseEvent(msg.(MouseMsg))
+	}
+
+	// Events are in cells until the terminal confirms pixel mode.
+	pm.request()
+	if m := deliver(press); m.X != 100 || m.PixelX != 0 {
+		t.Errorf("expected the event in cells before pixel mode was confirmed, got %#v", m)
+	}
+	if _, ok := parser.deliver(mousePixelsReportMsg{on: true}); ok {
+		t.Errorf("expected the report not to be delivered")
+	}
+	if m := deliver(press); m.X != 10 || m.PixelX != 100 {
+		t.Errorf("expected the event in pixels once pixel mode was confirmed, got %#v", m)
+	}
+
+	// Terminals that don't support it keep reporting cells.
+	pm.request()
+	parser.deliver(mousePixelsReportMsg{on: false})
+	if m := deliver(press); m.X != 100 || m.PixelX != 0 {
+		t.Errorf("expected the event in cells when pixel mode isn't supported, got %#v", m)
+	}
+
+	// Reports that come in after pixel mode was turned off are ignored.
+	pm.reset()
+	parser.deliver(mousePixelsReportMsg{on: true})
+	if m := deliver(press); m.X != 100 || m.PixelX != 0 {
+		t.Errorf("expected the event in cells after pixel mode was turned off, got %#v", m)
+	}
+}
+
+type pixelMouseModel struct {
+	mouse chan MouseMsg
+}
+
+func (m pixelMouseModel) Init() Cmd { return EnableMousePixelMotion }
+
+func (m pixelMouseModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(MouseMsg); ok {
+		m.mouse <- msg
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m pixelMouseModel) View() string { return "" }
+
+func TestPixelMouseSend(t *testing.T) {
+	var buf bytes.Buffer
+	m := pixelMouseModel{mouse: make(chan MouseMsg, 1)}
+	p := NewProgram(m, WithInput(nil), WithOutput(&buf))
+
+	// Mouse events sent to the program are delivered as they are, even in
+	// pixel mode.
+	sent := MouseMsg{X: 3, Y: 4, Type: MouseLeft, Action: MouseActionPress, Button: MouseButtonLeft}
+	go func() {
+		requested := func() bool {
+			p.pixelMouse.mtx.Lock()
+			defer p.pixelMouse.mtx.Unlock()
+			return p.pixelMouse.requested
+		}
+		for !requested() {
+			time.Sleep(time.Millisecond)
+		}
+		p.pixelMouse.confirm(true)
+		p.Send(sent)
+	}()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-m.mouse; got.X != 3 || got.Y != 4 || got.PixelX != 0 {
+		t.Errorf("expected %#v, got %#v", sent, got)
+	}
+}
@@ -21,4 +21,19 @@ func (n nilRenderer) enableBracketedPaste()      {}
 func (n nilRenderer) disableBracketedPaste()     {}
 func (n nilRenderer) enableMouseSGRMode()        {}
 func (n nilRenderer) disableMouseSGRMode()       {}
+func (n nilRenderer) enableMousePixelsMode()     {}
+func (n nilRenderer) disableMousePixelsMode()    {}
 func (n nilRenderer) bracketedPasteActive() bool { return false }
+func (n nilRenderer) enableKeyReleases()         {}
+func (n nilRenderer) disableKeyReleases()        {}
+func (n nilRenderer) keyReleasesActive() bool    { return false }
+func (n nilRenderer) enableWin32InputMode()      {}
+func (n nilRenderer) disableWin32InputMode()     {}
+func (n nilRenderer) requestTerminalAttributes() {}
+func (n nilRenderer) readClipboard()             {}
+func (n nilRenderer) queryWindowSize()           {}
+
+func (n nilRenderer) setProgress(ProgressState, int) {}
+
+func (n nilRenderer) screenState() ScreenStateMsg          { return ScreenStateMsg{} }
+func (n nilRenderer) currentFrame(bool) (string, int, int) { return "", 0, 0 }
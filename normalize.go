@@ -0,0 +1,144 @@
+package tea
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// CursorSequenceMsg is sent with WithStrictView when the view contains an
+// escape sequence that moves the cursor or erases part of the screen, such as
+// "\x1b[2A", which would throw off the renderer's idea of where the cursor
+// is. Such sequences are removed from the view whether or not this option is
+// set; the message is there to help find where they come from.
+type CursorSequenceMsg struct {
+	// Line is the line of the view the sequence is on, counting from 0.
+	Line int
+
+	// Sequence is the escape sequence.
+	Sequence string
+}
+
+// defaultTabWidth is how many columns apart tab stops are by default, as in
+// most terminals.
+const defaultTabWidth = 8
+
+// normalizeFrame makes the line breaks and whitespace of a frame something
+// the renderer can diff and measure: CRLF line endings become LF, stray
+// carriage returns are removed, and tabs are expanded to spaces up to the
+// next tab stop, tabWidth columns apart.
+func normalizeFrame(s string, tabWidth int, cond *runewidth.Condition) string {
+	if strings.ContainsRune(s, '\r') {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		s = strings.ReplaceAll(s, "\r", "")
+	}
+	if !strings.ContainsRune(s, '\t') {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line, tabWidth, cond)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expandTabs replaces the tabs in a line with spaces up to the next tab stop,
+// measuring the cells the text before them takes up with cond, ignoring ANSI
+// escape sequences.
+func expandTabs(s string, tabWidth int, cond *runewidth.Condition) string {
+	if !strings.ContainsRune(s, '\t') {
+		return s
+	}
+	if tabWidth < 1 {
+		tabWidth = defaultTabWidth
+	}
+
+	var (
+		b     strings.Builder
+		cells int
+	)
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '\x1b':
+			n := ansi.SequenceLen(s[i:])
+			b.WriteString(s[i : i+n])
+			i += n
+		case '\t':
+			n := tabWidth - cells%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			cells += n
+			i++
+		default:
+			c, n := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+n])
+			cells += cond.RuneWidth(c)
+			i += n
+		}
+	}
+	return b.String()
+}
+
+// stripCursorSequences removes the escape sequences that move the cursor or
+// erase part of the screen from a frame, leaving others, such as SGR and OSC
+// sequences, alone. It returns the first one removed, if any.
+func stripCursorSequences(s string) (string, *CursorSequenceMsg) {
+	// Most frames have none, and are returned as they are.
+	i := strings.IndexByte(s, '\x1b')
+	for i >= 0 && !isCursorSequence(s[i:i+ansi.SequenceLen(s[i:])]) {
+		j := strings.IndexByte(s[i+1:], '\x1b')
+		if j < 0 {
+			return s, nil
+		}
+		i += 1 + j
+	}
+	if i < 0 {
+		return s, nil
+	}
+
+	var (
+		b     strings.Builder
+		first *CursorSequenceMsg
+	)
+	b.Grow(len(s))
+	for {
+		i := strings.IndexByte(s, '\x1b')
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		n := ansi.SequenceLen(s[i:])
+		seq := s[i : i+n]
+		b.WriteString(s[:i])
+		if isCursorSequence(seq) {
+			if first == nil {
+				first = &CursorSequenceMsg{
+					Line:     strings.Count(b.String(), "\n"),
+					Sequence: seq,
+				}
+			}
+		} else {
+			b.WriteString(seq)
+		}
+		s = s[i+n:]
+	}
+	return b.String(), first
+}
+
+// isCursorSequence reports whether seq moves the cursor or erases part of the
+// screen.
+func isCursorSequence(seq string) bool {
+	if len(seq) == 2 {
+		// DECSC, DECRC, IND, NEL and RI.
+		return strings.IndexByte("78DEM", seq[1]) >= 0
+	}
+	if len(seq) < 3 || seq[1] != '[' {
+		return false
+	}
+	// CUU, CUD, CUF, CUB, CNL, CPL, CHA, CUP, ED, EL, IL, DL, SU, SD, VPA,
+	// HVP, SCOSC and SCORC.
+	return strings.IndexByte("ABCDEFGHJKLMSTdfsu", seq[len(seq)-1]) >= 0
+}
@@ -0,0 +1,143 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestNormalizeFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		tabWidth int
+		expected string
+	}{
+		{"plain", "a\nb", 8, "a\nb"},
+		{"crlf", "a\r\nb\r\n", 8, "a\nb\n"},
+		{"stray cr", "a\rb\n\rc", 8, "ab\nc"},
+		{"tab", "\ta\tb", 8, "        a       b"},
+		{"tab width", "ab\tc", 4, "ab  c"},
+		{"tab per line", "abc\t|\n\t|", 4, "abc |\n    |"},
+		{"styled tab", "\x1b[1mab\x1b[0m\tc", 4, "\x1b[1mab\x1b[0m  c"},
+		{"wide tab", "日本\tc", 8, "日本    c"},
+		{"tab after hyperlink", "\x1b]8;;http://x.com\x1b\\a\x1b]8;;\x1b\\\tb", 4, "\x1b]8;;http://x.com\x1b\\a\x1b]8;;\x1b\\   b"},
+		{"tab after title", "\x1b]2;a title\aab\tc", 4, "\x1b]2;a title\aab  c"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := normalizeFrame(test.frame, test.tabWidth, newWidthCondition(false))
+			if got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRendererNormalizesFrames(t *testing.T) {
+	const frame = "name\tvalue\r\none\t1\r\ntwo\t2"
+
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write(frame)
+	r.flush()
+	if expected := "name    value\r\none     1\r\ntwo     2\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// Rendering the same view again, bypassing the check for an unchanged
+	// frame, skips every line but the first, which is always rendered, as
+	// they match the ones rendered before.
+	buf.Reset()
+	r.lastRender = ""
+	r.write(frame)
+	r.flush()
+	if expected := "\x1b[2A\x1b[0D\x1b[2Kname    value\r\n\x1b[1B\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestStripCursorSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		expected string
+		found    *CursorSequenceMsg
+	}{
+		{"plain", "a\nb", "a\nb", nil},
+		{"sgr", "\x1b[1;31ma\x1b[0m", "\x1b[1;31ma\x1b[0m", nil},
+		{"osc", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\a", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\a", nil},
+		{"cursor up", "a\nb\x1b[2Ac", "a\nbc", &CursorSequenceMsg{Line: 1, Sequence: "\x1b[2A"}},
+		{"position", "\x1b[5;1Ha", "a", &CursorSequenceMsg{Line: 0, Sequence: "\x1b[5;1H"}},
+		{"erase", "a\x1b[K\nb\x1b[2J", "a\nb", &CursorSequenceMsg{Line: 0, Sequence: "\x1b[K"}},
+		{"save and restore", "\x1b7a\x1b8b", "ab", &CursorSequenceMsg{Line: 0, Sequence: "\x1b7"}},
+		{"cut short", "a\x1b[2", "a\x1b[2", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := stripCursorSequences(test.frame)
+			if got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+			if (found == nil) != (test.found == nil) || found != nil && *found != *test.found {
+				t.Errorf("expected %+v to be found, got %+v", test.found, found)
+			}
+		})
+	}
+}
+
+func TestRendererStripsCursorSequences(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("a\nb\nc")
+	r.flush()
+	buf.Reset()
+
+	// The sequence is removed, so the diff still lines up with the screen.
+	r.write("a\nb\x1b[2A\nX")
+	r.flush()
+	if expected := "\x1b[0D\x1b[2K\x1b[2A\x1b[0D\x1b[2Ka\r\n\x1b[1BX\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+// strictModel shows a view with a sequence that moves the cursor, and
+// records the CursorSequenceMsgs it gets.
+type strictModel struct {
+	msgs []CursorSequenceMsg
+}
+
+func (m strictModel) Init() Cmd { return nil }
+
+func (m strictModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(CursorSequenceMsg); ok {
+		m.msgs = append(m.msgs, msg)
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m strictModel) View() string {
+	return "first\nsecond\x1b[1A\n"
+}
+
+func TestStrictView(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(strictModel{}, WithInput(nil), WithOutput(&buf), WithStrictView())
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := CursorSequenceMsg{Line: 1, Sequence: "\x1b[1A"}
+	if msgs := m.(strictModel).msgs; len(msgs) != 1 || msgs[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, msgs)
+	}
+	if strings.Contains(buf.String(), "second\x1b[1A") {
+		t.Errorf("expected the sequence to be removed, got %q", buf.String())
+	}
+}
@@ -3,7 +3,9 @@ package tea
 import (
 	"context"
 	"io"
+	"os"
 	"sync/atomic"
+	"time"
 
 	"github.com/muesli/termenv"
 )
@@ -27,12 +29,22 @@ func WithContext(ctx context.Context) ProgramOption {
 
 // WithOutput sets the output which, by default, is stdout. In most cases you
 // won't need to use this.
+//
+// Pass a *termenv.Output to control how the terminal is detected, such as
+// when the terminal is a pty managed by an SSH server. Its color profile is
+// honored by the renderer, which downgrades colors in frames to ones the
+// profile supports. For other writers that aren't files there's no terminal
+// to detect, so colors are written as they are.
 func WithOutput(output io.Writer) ProgramOption {
 	return func(p *Program) {
-		if o, ok := output.(*termenv.Output); ok {
+		p.outputWriter = output
+		switch o := output.(type) {
+		case *termenv.Output:
 			p.output = o
-		} else {
-			p.output = termenv.NewOutput(output, termenv.WithColorCache(true))
+		case *os.File:
+			p.output = termenv.NewOutput(o, termenv.WithColorCache(true))
+		default:
+			p.output = termenv.NewOutput(o, termenv.WithProfile(termenv.TrueColor), termenv.WithColorCache(true))
 		}
 	}
 }
@@ -49,6 +61,9 @@ func WithInput(input io.Reader) ProgramOption {
 }
 
 // WithInputTTY opens a new TTY for input (or console input device on Windows).
+// Use it to read keyboard input from the terminal even if standard input was
+// piped in or redirected. The TTY is closed when the program exits, and
+// running the program fails if there is no controlling terminal.
 func WithInputTTY() ProgramOption {
 	return func(p *Program) {
 		p.inputType = ttyInput
@@ -56,7 +71,8 @@ func WithInputTTY() ProgramOption {
 }
 
 // WithoutSignalHandler disables the signal handler that Bubble Tea sets up for
-// Programs. This is useful if you want to handle signals yourself.
+// Programs, which quits on SIGINT, SIGTERM and SIGHUP. This is useful if you
+// want to handle signals yourself.
 func WithoutSignalHandler() ProgramOption {
 	return func(p *Program) {
 		p.startupOptions |= withoutSignalHandler
@@ -108,6 +124,21 @@ func WithoutBracketedPaste() ProgramOption {
 	}
 }
 
+// WithKeyReleases starts the program with key release reporting enabled. Key
+// releases are delivered as KeyReleaseMsgs, and keys being held down are
+// reported with the Repeat flag set on KeyMsg.
+//
+// This relies on the kitty keyboard protocol. On terminals that don't support
+// it nothing changes: no release events are sent.
+//
+// To enable key releases once the program has already started running use the
+// EnableKeyReleases command.
+func WithKeyReleases() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withKeyReleases
+	}
+}
+
 // WithMouseCellMotion starts the program with the mouse enabled in "cell
 // motion" mode.
 //
@@ -155,6 +186,113 @@ func WithMouseAllMotion() ProgramOption {
 	}
 }
 
+// WithMouseMotionCoalescing collapses consecutive mouse motion events into the
+// most recent one whenever the program falls behind the input, so that
+// dragging or hovering doesn't queue up an Update and View for every sample
+// the terminal reports.
+//
+// Only motion events with the same buttons and modifiers are collapsed. Mouse
+// presses, releases, and wheel events, as well as any other messages, are
+// never dropped and are delivered in the order they were received.
+func WithMouseMotionCoalescing() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withMouseMotionCoalescing
+	}
+}
+
+// WithWin32InputMode asks Windows Terminal to report keys in win32-input-mode,
+// which delivers complete key down and key up events with virtual key codes
+// and avoids the ambiguities of parsing escape sequences. Key up events are
+// only delivered, as KeyReleaseMsg, when key releases are enabled.
+//
+// Terminals that don't support win32-input-mode ignore the request and keep
+// sending regular escape sequences. The option has no effect when input is
+// read from the Windows console directly.
+func WithWin32InputMode() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withWin32InputMode
+	}
+}
+
+// WithResizeDebounce sets how long the terminal size has to stay the same
+// after a burst of resizes, such as while dragging the corner of the window,
+// before the final size is reported. The first resize of a burst is always
+// reported right away. The default is 50ms; zero reports every resize.
+//
+// This only applies to platforms that signal resizes (SIGWINCH).
+func WithResizeDebounce(d time.Duration) ProgramOption {
+	return func(p *Program) {
+		p.resizeDebounce = d
+	}
+}
+
+// WithLineInput reads input a line at a time when the program's input isn't a
+// terminal, such as when it is driven by a script through a pipe or a file.
+// Each line is delivered as a LineMsg, followed by an InputEOFMsg once the
+// input is exhausted. Escape sequences aren't interpreted in this mode, so
+// there are no key, mouse or paste messages.
+//
+// When standard input isn't a terminal, this also keeps the program from
+// opening the terminal for input instead. Input from a terminal is read as
+// usual.
+func WithLineInput() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withLineInput
+	}
+}
+
+// WithAdditionalInput adds a source of messages that is read alongside the
+// program's main input, such as a socket receiving commands. The source is
+// read on its own goroutine until it reaches EOF or fails, or the program
+// exits. Data from each read is passed to parse, and the Msg it returns, if
+// not nil, is sent to the program. Messages from the same source arrive in the
+// order they were read.
+//
+// When a source is exhausted, an InputEOFMsg with its Input set to the source
+// is sent. When a source is exhausted or fails, the program and any other
+// sources keep running.
+func WithAdditionalInput(input io.Reader, parse func([]byte) Msg) ProgramOption {
+	return func(p *Program) {
+		p.additionalInputs = append(p.additionalInputs, &additionalInput{
+			input: input,
+			parse: parse,
+		})
+	}
+}
+
+// WithKeySequences adds escape sequences to recognize as keys, such as the
+// function keys of terminals the built-in tables don't cover. Sequences that
+// are also recognized by Bubble Tea are reported as the given keys instead. A
+// sequence that is a prefix of a longer built-in sequence only applies when
+// the input doesn't contain the longer sequence.
+//
+// Like built-in sequences, sequences prefixed with an escape are reported with
+// the Alt modifier.
+//
+//	p := tea.NewProgram(model, tea.WithKeySequences(map[string]tea.Key{
+//		"\x1b[99~": {Type: tea.KeyF20},
+//	}))
+func WithKeySequences(seqs map[string]Key) ProgramOption {
+	return func(p *Program) {
+		if p.keySequences == nil {
+			p.keySequences = make(map[string]Key, len(seqs))
+		}
+		for seq, key := range seqs {
+			p.keySequences[seq] = key
+		}
+	}
+}
+
+// WithRawPaste delivers pasted text exactly as it was received. By default,
+// control characters other than line breaks and tabs are removed from pasted
+// text, so that escape sequences in it can't disturb the terminal when the
+// text is displayed.
+func WithRawPaste() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withRawPaste
+	}
+}
+
 // WithoutRenderer disables the renderer. When this is set output and log
 // statements will be plainly sent to stdout (or another output if one is set)
 // without any rendering and redrawing logic. In other words, printing and
@@ -163,12 +301,26 @@ func WithMouseAllMotion() ProgramOption {
 // application, or to provide an additional non-TUI mode to your Bubble Tea
 // programs. For example, your program could behave like a daemon if output is
 // not a TTY.
+//
+// Without a renderer the program leaves the terminal alone: it doesn't enter
+// raw mode, open the controlling terminal for input, or toggle any terminal
+// modes, and commands such as EnterAltScreen and SetWindowTitle do nothing.
 func WithoutRenderer() ProgramOption {
 	return func(p *Program) {
 		p.renderer = &nilRenderer{}
 	}
 }
 
+// WithRenderer has the program draw its views with a TestRenderer, rather
+// than picking a renderer for its output. The program leaves the size of the
+// TestRenderer's screen alone, rather than measuring the terminal it runs in,
+// so it only changes with the WindowSizeMsgs the test sends.
+func WithRenderer(r *TestRenderer) ProgramOption {
+	return func(p *Program) {
+		p.renderer = r
+	}
+}
+
 // WithANSICompressor removes redundant ANSI sequences to produce potentially
 // smaller output, at the cost of some processing overhead.
 //
@@ -180,6 +332,20 @@ func WithANSICompressor() ProgramOption {
 	}
 }
 
+// WithANSICompressorThreshold removes redundant ANSI sequences like
+// WithANSICompressor, but only from what's written to the terminal for a
+// frame when it's larger than the given number of bytes. Compressing large
+// frames pays off, while for small ones it only adds latency. It takes
+// precedence over WithANSICompressor.
+//
+// This feature is provisional, and may be changed or removed in a future version
+// of this package.
+func WithANSICompressorThreshold(bytes int) ProgramOption {
+	return func(p *Program) {
+		p.compressorThreshold = bytes
+	}
+}
+
 // WithFilter supplies an event filter that will be invoked before Bubble Tea
 // processes a tea.Msg. The event filter can return any tea.Msg which will then
 // get handled by Bubble Tea instead of the original event. If the event filter
@@ -223,3 +389,372 @@ func WithFPS(fps int) ProgramOption {
 		p.fps = fps
 	}
 }
+
+// WithEastAsianWidth sets whether characters of ambiguous width, such as "α"
+// or "☆", take up two cells rather than one. CJK terminals usually render
+// them as double width, and the renderer needs to know to truncate lines
+// correctly.
+//
+// By default this is detected from the RUNEWIDTH_EASTASIAN environment
+// variable and, failing that, the locale. To change it while the program is
+// running use the SetEastAsianWidth command.
+func WithEastAsianWidth(enabled bool) ProgramOption {
+	return func(p *Program) {
+		p.eastAsianWidth = enabled
+	}
+}
+
+// WithMessageQueueSize queues up to size messages sent with Program.Send
+// while the program is busy, instead of blocking the sender until the program
+// gets to them. The policy determines what happens when the queue is full:
+// the sender can be blocked until there's room, or either the oldest queued
+// message or the one being sent can be dropped. Dropped messages are reported
+// with a QueueOverflowMsg.
+//
+// Messages that control the program, such as QuitMsg and WindowSizeMsg, are
+// never dropped.
+func WithMessageQueueSize(size int, policy QueuePolicy) ProgramOption {
+	return func(p *Program) {
+		if size < 1 {
+			p.queue = nil
+			return
+		}
+		p.queue = newMsgQueue(size, policy)
+	}
+}
+
+// WithPlainOutput renders frames as plain text, without moving the cursor or
+// changing any terminal modes. Each frame that differs from the previous one
+// is written in full, followed by a newline. Since there's no terminal to ask,
+// the program receives a WindowSizeMsg of 80x24.
+//
+// This happens automatically when the output is a file or a pipe, such as
+// when running "mytool > out.txt". Use this option to get the same output
+// when writing to another kind of io.Writer, or to a terminal.
+func WithPlainOutput() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withPlainOutput
+	}
+}
+
+// WithAccessibleOutput renders for screen readers, which cope badly with
+// repainted screens and a cursor that jumps around. Rather than drawing each
+// frame, the lines of the frame that weren't in the previous one are written
+// as plain text, one after the other, without escape sequences. The alternate
+// screen and the mouse aren't used.
+//
+// The program receives an AccessibleModeMsg right after Init, so it can
+// adjust its view, such as by leaving out decorations. This also happens when
+// the ACCESSIBLE environment variable is set.
+func WithAccessibleOutput() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withAccessibleOutput
+	}
+}
+
+// WithPlainOutputFinalFrame writes only the final frame when rendering plain
+// output, rather than every frame that changed. It has no effect when
+// rendering to a terminal. See WithPlainOutput.
+func WithPlainOutputFinalFrame() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withPlainOutputFinalFrame
+	}
+}
+
+// WithMessageLogger writes a trace of the program's messages to w, for
+// debugging. Each message delivered to Update is logged with a timestamp, its
+// type and its value, along with calls to Init and View, and quitting:
+//
+//	15:04:05.000000 Init
+//	15:04:05.000042 View
+//	15:04:05.001337 Update tea.KeyMsg enter
+//
+// Messages the program uses internally, such as the ones controlling the
+// renderer, are only logged if verbose is set. Entries are written in the
+// background, so a slow writer doesn't slow down the program.
+func WithMessageLogger(w io.Writer, verbose bool) ProgramOption {
+	return func(p *Program) {
+		p.msgLog = newMsgLogger(w, verbose)
+	}
+}
+
+// WithRecording records the session to w in the asciicast v2 format, which
+// can be played back with asciinema. Everything the renderer writes to the
+// terminal is recorded, along with the time it was written. The recording
+// starts with the terminal size the program was first told about, and is
+// complete when the program exits.
+//
+// To record input too, use WithRecordingInput.
+func WithRecording(w io.Writer) ProgramOption {
+	return func(p *Program) {
+		p.recording = newRecorder(w)
+	}
+}
+
+// WithRecordingInput records what's read from the input alongside the output
+// when recording a session with WithRecording, as asciicast input events. To
+// record input on its own, to be played back with a ReplayReader, use
+// WithInputRecorder instead.
+func WithRecordingInput() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withRecordingInput
+	}
+}
+
+// WithInputRecorder records everything read from the input to w, along with
+// when it was read, so that it can be played back later with a ReplayReader.
+// This is useful for reproducing bugs that depend on the exact input and its
+// timing.
+//
+// The recording has a line for each read from the input, holding a JSON
+// object with the time of the read in seconds since the program started and
+// the base64 encoded bytes that were read.
+func WithInputRecorder(w io.Writer) ProgramOption {
+	return func(p *Program) {
+		p.inputRecorder = newInputRecorder(w)
+	}
+}
+
+// WithInitialWindowSize sets the window size reported to the program when the
+// output isn't a terminal, such as when it's written to a file or a buffer in
+// a test. Without a terminal, there's no window size to report, and models
+// that lay themselves out when they get a WindowSizeMsg would never be laid
+// out.
+//
+// The WindowSizeMsg is delivered right after Init, before any other message,
+// and the renderer uses the size right away. When the output is a terminal,
+// its actual size is reported instead.
+func WithInitialWindowSize(width, height int) ProgramOption {
+	return func(p *Program) {
+		p.initialSize = &WindowSizeMsg{Width: width, Height: height}
+	}
+}
+
+// WithDroppedFrameReports reports when the renderer falls behind, because
+// writing frames to the terminal takes longer than the time between them,
+// with a DroppedFramesMsg at most once a second. Programs can use it to lower
+// their frame rate, or simplify their view, when the terminal or the network
+// can't keep up.
+func WithDroppedFrameReports() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withDroppedFrameReports
+	}
+}
+
+// WithSharedOutput lets the program run with an output another program is
+// already running with, such as when a program is started from within
+// another one, rather than failing with ErrOutputInUse. The other program
+// stops rendering while this one runs. When this one exits, it leaves the
+// terminal modes it set the same way as the other program, such as the
+// alternate screen, as they are, and turns off the others; the other program
+// then turns its modes on again and repaints its view.
+func WithSharedOutput() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withSharedOutput
+	}
+}
+
+// WithSlowHandlerThreshold reports calls to Update or View that take longer
+// than the given threshold, which freeze the program while they run. Each
+// slow call is logged with the message logger, if there is one, as soon as it
+// passes the threshold, even if it never returns, and reported once with a
+// SlowHandlerMsg. Time spent in commands isn't counted, as they don't hold up
+// the program.
+func WithSlowHandlerThreshold(d time.Duration) ProgramOption {
+	return func(p *Program) {
+		p.slowThreshold = d
+	}
+}
+
+// WithInterruptMsg delivers SIGINT to the program as an InterruptMsg, rather
+// than quitting right away, so that the model can decide what to do, such as
+// asking whether to save changes before returning Quit.
+//
+// In most cases ^C doesn't send SIGINT, as the terminal is in raw mode and it
+// arrives as a KeyMsg instead. SIGINT is sent when input isn't a terminal, or
+// by other processes, such as with kill -INT.
+//
+// As a safety hatch, a second SIGINT within a few seconds of the first kills
+// the program, in case it's stuck or doesn't handle InterruptMsg.
+// [Program.ExitCode] reports ExitInterrupted in that case.
+func WithInterruptMsg() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withInterruptMsg
+	}
+}
+
+// WithQuitOnInputEOF quits the program when the end of its input is reached,
+// right after sending an InputEOFMsg. Additional inputs reaching their end
+// don't quit the program. This is handy for simple tools driven
+// by piped input.
+func WithQuitOnInputEOF() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withQuitOnInputEOF
+	}
+}
+
+// WithEnviron sets the environment of the terminal the program renders to,
+// in the "key=value" form of os.Environ, which is used instead of the
+// process's environment to detect the terminal's color profile. This is
+// useful when serving programs over SSH, where the client sends its TERM and
+// other variables with the session:
+//
+//	p := tea.NewProgram(model,
+//	    tea.WithInput(session),
+//	    tea.WithOutput(session),
+//	    tea.WithEnviron(session.Environ()),
+//	)
+//
+// The environment is taken to describe the program's output, even if it's
+// not a terminal the program can see. Models are told about the detected
+// profile with a ColorProfileMsg.
+func WithEnviron(env []string) ProgramOption {
+	return func(p *Program) {
+		p.environ = environ(env)
+	}
+}
+
+// WithColorProfile sets the color profile of the terminal the program
+// renders to, rather than detecting it. It takes precedence over WithEnviron.
+// Models are told about the profile with a ColorProfileMsg.
+func WithColorProfile(profile termenv.Profile) ProgramOption {
+	return func(p *Program) {
+		p.colorProfile = &profile
+	}
+}
+
+// WithFrameTransform transforms every frame before it's rendered, such as to
+// overlay a ruler or watermark a recording. Transforms can be set more than
+// once, and are applied in order. They're applied by the standard renderer
+// only, to the view as it's rendered, before it's compared to the previous
+// frame to work out what changed.
+//
+// A transform is called with each frame and should return the transformed
+// frame without side effects. If it panics, the frame is rendered without it
+// and the panic is reported with a FrameTransformErrorMsg.
+func WithFrameTransform(fn func(frame string) string) ProgramOption {
+	return func(p *Program) {
+		p.frameTransforms = append(p.frameTransforms, fn)
+	}
+}
+
+// WithBottomAnchor keeps the last line of the view on the same row when the
+// view gets shorter or taller, for inline programs with a prompt at the
+// bottom, such as a REPL. When the view shrinks, the rows above it are cleared
+// and it moves down. When it grows, it takes back the rows it cleared, and
+// otherwise scrolls the terminal as usual.
+//
+// It has no effect in the alternate screen, or while lines are ignored by the
+// renderer.
+func WithBottomAnchor() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withBottomAnchor
+	}
+}
+
+// WithKeepFinalFrame leaves the final frame on screen as it is when the
+// program exits. By default, the line the cursor is on is cleared, which is
+// the last line of the view, and is usually empty, as most views end with a
+// newline. With this option, a view that doesn't end with a newline keeps its
+// last line, and a newline is written after it so the shell prompt starts on
+// a line of its own.
+//
+// It has no effect in the alternate screen, which is exited on quit.
+func WithKeepFinalFrame() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withKeepFinalFrame
+	}
+}
+
+// WithClearOnQuit clears the program's view from the terminal when it quits,
+// leaving the cursor where the view started, as if the program never ran.
+// This suits short-lived programs like pickers. Lines printed above the view
+// with Println and Printf are left alone. It takes precedence over
+// WithKeepFinalFrame.
+//
+// In the alternate screen this is what happens anyway, as it's exited on
+// quit.
+func WithClearOnQuit() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withClearOnQuit
+	}
+}
+
+// WithStrictView reports escape sequences in the view that move the cursor
+// or erase part of the screen with a CursorSequenceMsg, naming the line they're
+// on. The renderer keeps track of where the cursor is to redraw only what
+// changed, so such sequences are always removed from the view; this option
+// helps track down where they come from, such as captured terminal output.
+func WithStrictView() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withStrictView
+	}
+}
+
+// WithSCOCursorSave makes SaveCursorPosition and RestoreCursorPosition use
+// CSI s and CSI u, rather than ESC 7 and ESC 8, for terminals and tools that
+// only understand those.
+func WithSCOCursorSave() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withSCOCursorSave
+	}
+}
+
+// WithWindowSizeQuery asks the terminal for its size at startup when the
+// program can't find it out itself, because its output isn't a terminal it
+// can measure, such as an SSH channel without a PTY. Terminals that support
+// it answer with a WindowSizeMsg. Use the QueryWindowSize command to ask
+// again later, as changes in size aren't signaled either.
+//
+// As the output leads to a terminal, it's rendered to as one even when it's a
+// pipe or a serial line, which would otherwise get plain output.
+func WithWindowSizeQuery() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withWindowSizeQuery
+	}
+}
+
+// WithRestoreInlineFrame repaints the frame that was on screen before
+// entering the alternate screen as soon as it's exited, rather than with the
+// next frame the program renders. This matters on terminals without an
+// alternate screen, such as GNU screen by default, where entering it clears
+// the inline view, which would otherwise stay gone until the program renders
+// again.
+func WithRestoreInlineFrame() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withRestoreInlineFrame
+	}
+}
+
+// WithHyperlinkFallback is for terminals that don't support OSC 8
+// hyperlinks, where links made with Hyperlink would be plain text. The
+// renderer leaves out their OSC 8 sequences and makes their text clickable
+// instead, sending an OpenLinkMsg with the URL when it's clicked, which
+// requires the mouse to be enabled. Inline, the terminal is asked where the
+// view is, as with WithMouseViewPosition.
+func WithHyperlinkFallback() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withHyperlinkFallback
+	}
+}
+
+// WithMouseViewPosition has mouse events carry where they are in the view,
+// in the ViewX, ViewY and InView fields of MouseMsg, when the program doesn't
+// use the alternate screen. The inline view doesn't start at the top of the
+// screen, so the terminal is asked where the cursor is, with ESC[?6n, when the
+// mouse is enabled and after the terminal is resized. In the alternate
+// screen, mouse events carry where they are in the view regardless.
+func WithMouseViewPosition() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withMouseViewPosition
+	}
+}
+
+// WithTabWidth sets how many columns apart tab stops are when tabs in the
+// view are expanded to spaces, which the renderer does so it can tell how
+// wide lines are. The default is 8, as in most terminals.
+func WithTabWidth(width int) ProgramOption {
+	return func(p *Program) {
+		p.tabWidth = width
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestOptions(t *testing.T) {
@@ -50,6 +51,103 @@ func TestOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("resize debounce", func(t *testing.T) {
+		p := NewProgram(nil)
+		if p.resizeDebounce != defaultResizeDebounce {
+			t.Errorf("expected default resize debounce of %v, got %v", defaultResizeDebounce, p.resizeDebounce)
+		}
+		p = NewProgram(nil, WithResizeDebounce(0))
+		if p.resizeDebounce != 0 {
+			t.Errorf("expected resize debounce to be disabled, got %v", p.resizeDebounce)
+		}
+	})
+
+	t.Run("key sequences", func(t *testing.T) {
+		p := NewProgram(nil,
+			WithKeySequences(map[string]Key{"\x1b[99~": {Type: KeyF20}}),
+			WithKeySequences(map[string]Key{"\x1b[98~": {Type: KeyF19}}),
+		)
+		if len(p.keySequences) != 2 {
+			t.Errorf("expected 2 key sequences, got %v", p.keySequences)
+		}
+	})
+
+	t.Run("message queue size", func(t *testing.T) {
+		p := NewProgram(nil, WithMessageQueueSize(10, QueueDropOldest))
+		if p.queue == nil || p.queue.size != 10 || p.queue.policy != QueueDropOldest {
+			t.Errorf("expected a queue of 10 dropping the oldest messages, got %+v", p.queue)
+		}
+		if p := NewProgram(nil); p.queue != nil {
+			t.Errorf("expected no queue by default")
+		}
+	})
+
+	t.Run("message logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(nil, WithMessageLogger(&buf, true))
+		if p.msgLog == nil || p.msgLog.out != &buf || !p.msgLog.verbose {
+			t.Errorf("expected a verbose message logger writing to the buffer")
+		}
+		if p := NewProgram(nil); p.msgLog != nil {
+			t.Errorf("expected no message logger by default")
+		}
+	})
+
+	t.Run("recording", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(nil, WithRecording(&buf))
+		if p.recording == nil || p.recording.w != &buf {
+			t.Errorf("expected a recording written to the buffer")
+		}
+	})
+
+	t.Run("initial window size", func(t *testing.T) {
+		p := NewProgram(nil, WithInitialWindowSize(100, 40))
+		if p.initialSize == nil || *p.initialSize != (WindowSizeMsg{Width: 100, Height: 40}) {
+			t.Errorf("expected an initial window size of 100x40, got %v", p.initialSize)
+		}
+	})
+
+	t.Run("slow handler threshold", func(t *testing.T) {
+		p := NewProgram(nil, WithSlowHandlerThreshold(time.Second))
+		if p.slowThreshold != time.Second {
+			t.Errorf("expected a slow handler threshold of 1s, got %v", p.slowThreshold)
+		}
+	})
+
+	t.Run("input recorder", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(nil, WithInputRecorder(&buf))
+		if p.inputRecorder == nil || p.inputRecorder.w != &buf {
+			t.Errorf("expected input to be recorded to the buffer")
+		}
+	})
+
+	t.Run("east asian width", func(t *testing.T) {
+		p := NewProgram(nil, WithEastAsianWidth(true))
+		if !p.eastAsianWidth {
+			t.Errorf("expected east asian width to be set")
+		}
+		p = NewProgram(nil, WithEastAsianWidth(false))
+		if p.eastAsianWidth {
+			t.Errorf("expected east asian width to be unset")
+		}
+	})
+
+	t.Run("ansi compressor threshold", func(t *testing.T) {
+		p := NewProgram(nil, WithANSICompressorThreshold(1024))
+		if p.compressorThreshold != 1024 {
+			t.Errorf("expected compressor threshold 1024, got %d", p.compressorThreshold)
+		}
+	})
+
+	t.Run("tab width", func(t *testing.T) {
+		p := NewProgram(nil, WithTabWidth(4))
+		if p.tabWidth != 4 {
+			t.Errorf("expected tab width 4, got %d", p.tabWidth)
+		}
+	})
+
 	t.Run("input options", func(t *testing.T) {
 		exercise := func(t *testing.T, opt ProgramOption, expect inputType) {
 			p := NewProgram(nil, opt)
@@ -89,6 +187,94 @@ func TestOptions(t *testing.T) {
 			exercise(t, WithANSICompressor(), withANSICompressor)
 		})
 
+		t.Run("key releases", func(t *testing.T) {
+			exercise(t, WithKeyReleases(), withKeyReleases)
+		})
+
+		t.Run("mouse motion coalescing", func(t *testing.T) {
+			exercise(t, WithMouseMotionCoalescing(), withMouseMotionCoalescing)
+		})
+
+		t.Run("win32 input mode", func(t *testing.T) {
+			exercise(t, WithWin32InputMode(), withWin32InputMode)
+		})
+
+		t.Run("line input", func(t *testing.T) {
+			exercise(t, WithLineInput(), withLineInput)
+		})
+
+		t.Run("raw paste", func(t *testing.T) {
+			exercise(t, WithRawPaste(), withRawPaste)
+		})
+
+		t.Run("plain output", func(t *testing.T) {
+			exercise(t, WithPlainOutput(), withPlainOutput)
+		})
+
+		t.Run("quit on input eof", func(t *testing.T) {
+			exercise(t, WithQuitOnInputEOF(), withQuitOnInputEOF)
+		})
+
+		t.Run("interrupt msg", func(t *testing.T) {
+			exercise(t, WithInterruptMsg(), withInterruptMsg)
+		})
+
+		t.Run("input recording", func(t *testing.T) {
+			exercise(t, WithRecordingInput(), withRecordingInput)
+		})
+
+		t.Run("bottom anchor", func(t *testing.T) {
+			exercise(t, WithBottomAnchor(), withBottomAnchor)
+		})
+
+		t.Run("keep final frame", func(t *testing.T) {
+			exercise(t, WithKeepFinalFrame(), withKeepFinalFrame)
+		})
+
+		t.Run("clear on quit", func(t *testing.T) {
+			exercise(t, WithClearOnQuit(), withClearOnQuit)
+		})
+
+		t.Run("strict view", func(t *testing.T) {
+			exercise(t, WithStrictView(), withStrictView)
+		})
+
+		t.Run("sco cursor save", func(t *testing.T) {
+			exercise(t, WithSCOCursorSave(), withSCOCursorSave)
+		})
+
+		t.Run("window size query", func(t *testing.T) {
+			exercise(t, WithWindowSizeQuery(), withWindowSizeQuery)
+		})
+
+		t.Run("restore inline frame", func(t *testing.T) {
+			exercise(t, WithRestoreInlineFrame(), withRestoreInlineFrame)
+		})
+
+		t.Run("hyperlink fallback", func(t *testing.T) {
+			exercise(t, WithHyperlinkFallback(), withHyperlinkFallback)
+		})
+
+		t.Run("mouse view position", func(t *testing.T) {
+			exercise(t, WithMouseViewPosition(), withMouseViewPosition)
+		})
+
+		t.Run("accessible output", func(t *testing.T) {
+			exercise(t, WithAccessibleOutput(), withAccessibleOutput)
+		})
+
+		t.Run("dropped frame reports", func(t *testing.T) {
+			exercise(t, WithDroppedFrameReports(), withDroppedFrameReports)
+		})
+
+		t.Run("shared output", func(t *testing.T) {
+			exercise(t, WithSharedOutput(), withSharedOutput)
+		})
+
+		t.Run("plain output final frame", func(t *testing.T) {
+			exercise(t, WithPlainOutputFinalFrame(), withPlainOutputFinalFrame)
+		})
+
 		t.Run("without catch panics", func(t *testing.T) {
 			exercise(t, WithoutCatchPanics(), withoutCatchPanics)
 		})
@@ -0,0 +1,121 @@
+package tea
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// ErrOutputInUse is returned by [Program.Run] when another program is already
+// running with the same output, as their output would be mixed up. Programs
+// that are meant to share it, such as one started from within another, can
+// be run with [WithSharedOutput].
+var ErrOutputInUse = errors.New("output is in use by another program")
+
+// outputClaim is held by the program running with an output.
+type outputClaim struct {
+	// renderer is the renderer of the program holding the claim, once it has
+	// one, and shared the number of programs running with WithSharedOutput
+	// alongside it. They're guarded by outputs.
+	renderer renderer
+	shared   int
+}
+
+// outputs holds the claims of running programs on the outputs they write to,
+// keyed by the file descriptor of outputs that are files, such as
+// terminals, and by the writer itself otherwise.
+var outputs = struct {
+	sync.Mutex
+	claims map[interface{}]*outputClaim
+}{claims: map[interface{}]*outputClaim{}}
+
+// outputKey returns the key the program's output is claimed under, or false
+// if it can't be told apart from other outputs.
+func (p *Program) outputKey() (interface{}, bool) {
+	if f, ok := p.output.TTY().(*os.File); ok {
+		return f.Fd(), true
+	}
+	w := p.outputWriter
+	if w == nil || reflect.ValueOf(w).Kind() != reflect.Ptr {
+		return nil, false
+	}
+	return w, true
+}
+
+// lockOutput claims the program's output for it. It returns a function that
+// releases it again, or ErrOutputInUse if another program is running with the
+// same output. Programs run with WithSharedOutput don't claim an output
+// that's already claimed. While they run, the renderer of the program that
+// did is paused, and once they exit, it's resumed, repainting its view.
+func (p *Program) lockOutput() (func(), error) {
+	p.outputClaim, p.sharedClaim = nil, nil
+	key, ok := p.outputKey()
+	if !ok || p.headless() {
+		return func() {}, nil
+	}
+
+	outputs.Lock()
+	defer outputs.Unlock()
+	if claim, ok := outputs.claims[key]; ok {
+		if p.startupOptions.has(withSharedOutput) {
+			p.sharedClaim = claim
+			claim.shared++
+			if r, ok := claim.renderer.(*standardRenderer); ok && claim.shared == 1 {
+				r.pause()
+			}
+			return func() {
+				outputs.Lock()
+				defer outputs.Unlock()
+				claim.shared--
+				if r, ok := claim.renderer.(*standardRenderer); ok && claim.shared == 0 {
+					r.resume()
+				}
+			}, nil
+		}
+		if fd, ok := key.(uintptr); ok {
+			return nil, fmt.Errorf("%w: file descriptor %d", ErrOutputInUse, fd)
+		}
+		return nil, fmt.Errorf("%w: %T %p", ErrOutputInUse, key, key)
+	}
+	claim := &outputClaim{}
+	outputs.claims[key] = claim
+	p.outputClaim = claim
+
+	return func() {
+		outputs.Lock()
+		defer outputs.Unlock()
+		delete(outputs.claims, key)
+		// Programs still sharing the output don't resume the renderer.
+		claim.renderer = nil
+	}, nil
+}
+
+// claimRenderer records the program's renderer on its claim on the output,
+// for programs sharing it to see the terminal modes it's in.
+func (p *Program) claimRenderer() {
+	if p.outputClaim == nil {
+		return
+	}
+	outputs.Lock()
+	defer outputs.Unlock()
+	p.outputClaim.renderer = p.renderer
+}
+
+// sharedModes returns the terminal modes the program the output is shared
+// with is in. When the program exits, it leaves the modes it set the same way
+// as they are, and turns off the others. They're all off when the output
+// isn't shared.
+func (p *Program) sharedModes() ScreenStateMsg {
+	if p.sharedClaim == nil {
+		return ScreenStateMsg{}
+	}
+	outputs.Lock()
+	r := p.sharedClaim.renderer
+	outputs.Unlock()
+	if r == nil {
+		return ScreenStateMsg{}
+	}
+	return r.screenState()
+}
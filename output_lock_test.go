@@ -0,0 +1,292 @@
+package tea
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTeaSequentialPrograms(t *testing.T) {
+	run := func(out io.Writer) {
+		p := NewProgram(&testModel{},
+			WithInput(nil),
+			WithOutput(out),
+			WithAltScreen(),
+			WithMouseAllMotion(),
+			WithKeyReleases())
+		go p.Send(Sequence(EnableMousePixelMotion, HideCursor, Quit)())
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var first, both bytes.Buffer
+	run(&first)
+	run(&both)
+	run(&both)
+
+	// The second program starts from the same state as the first one.
+	if expected := first.String() + first.String(); both.String() != expected {
+		t.Errorf("expected the programs to write the same output:\n%q\ngot:\n%q", expected, both.String())
+	}
+
+	// Every mode the first program enabled was disabled when it exited.
+	out := first.String()
+	for enable, disable := range map[string]string{
+		"\x1b[?25l":   "\x1b[?25h",
+		"\x1b[?1049h": "\x1b[?1049l",
+		"\x1b[?2004h": "\x1b[?2004l",
+		"\x1b[?1003h": "\x1b[?1003l",
+		"\x1b[?1006h": "\x1b[?1006l",
+		"\x1b[?1015h": "\x1b[?1015l",
+		"\x1b[?1016h": "\x1b[?1016l",
+		"\x1b[>11u":   "\x1b[<u",
+	} {
+		if i := strings.LastIndex(out, enable); i < 0 || strings.LastIndex(out, disable) < i {
+			t.Errorf("expected %q to be followed by %q, got %q", enable, disable, out)
+		}
+	}
+}
+
+type blockingModel struct {
+	started chan struct{}
+}
+
+func (m blockingModel) Init() Cmd {
+	return func() Msg {
+		close(m.started)
+		return nil
+	}
+}
+
+func (m blockingModel) Update(msg Msg) (Model, Cmd) { return m, nil }
+
+func (m blockingModel) View() string { return "" }
+
+func TestTeaOutputInUse(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close() //nolint:errcheck
+	defer w.Close() //nolint:errcheck
+	go func() { _, _ = io.Copy(io.Discard, r) }()
+
+	m := blockingModel{started: make(chan struct{})}
+	first := NewProgram(m, WithInput(nil), WithOutput(w))
+	done := make(chan error)
+	go func() {
+		_, err := first.Run()
+		done <- err
+	}()
+	<-m.started
+
+	// Another program can't use the same output at the same time.
+	second := NewProgram(&testModel{}, WithInput(nil), WithOutput(w))
+	if _, err := second.Run(); !errors.Is(err, ErrOutputInUse) {
+		t.Fatalf("expected ErrOutputInUse, got %v", err)
+	}
+
+	first.Quit()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the first program is done, it can.
+	third := NewProgram(&testModel{}, WithInput(nil), WithOutput(w))
+	go third.Quit()
+	if _, err := third.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lockedBuffer stands in for a terminal that several programs write to.
+type lockedBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
+
+func TestTeaOutputInUseWriter(t *testing.T) {
+	out := &lockedBuffer{}
+	m := blockingModel{started: make(chan struct{})}
+	first := NewProgram(m, WithInput(nil), WithOutput(out))
+	done := make(chan error)
+	go func() {
+		_, err := first.Run()
+		done <- err
+	}()
+	<-m.started
+
+	// None of the programs started alongside it get to use its output.
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := NewProgram(&testModel{}, WithInput(nil), WithOutput(out))
+			_, errs[i] = p.Run()
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if !errors.Is(err, ErrOutputInUse) {
+			t.Errorf("program %d: expected ErrOutputInUse, got %v", i, err)
+		}
+	}
+
+	first.Quit()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeaSharedOutput(t *testing.T) {
+	out := &lockedBuffer{}
+	m := blockingModel{started: make(chan struct{})}
+	outer := NewProgram(m,
+		WithInput(nil),
+		WithOutput(out),
+		WithAltScreen(),
+		WithMouseCellMotion())
+	done := make(chan error)
+	go func() {
+		_, err := outer.Run()
+		done <- err
+	}()
+	<-m.started
+
+	// A program run from within the other one, sharing its output.
+	before := len(out.String())
+	nested := NewProgram(cmdModel{cmd: Quit},
+		WithInput(nil),
+		WithOutput(out),
+		WithAltScreen(),
+		WithMouseCellMotion(),
+		WithSharedOutput())
+	if _, err := nested.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// It left the modes the outer program is in alone.
+	resets := []string{"\x1b[?1049l", "\x1b[?1002l", "\x1b[?2004l", "\x1b[?25h"}
+	nestedOut := out.String()[before:]
+	for _, seq := range resets {
+		if strings.Contains(nestedOut, seq) {
+			t.Errorf("expected the nested program not to write %q, got %q", seq, nestedOut)
+		}
+	}
+
+	outer.Quit()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The outer program reset them when it exited.
+	outerOut := out.String()[before+len(nestedOut):]
+	for _, seq := range resets {
+		if !strings.Contains(outerOut, seq) {
+			t.Errorf("expected the outer program to write %q when it exited, got %q", seq, outerOut)
+		}
+	}
+}
+
+type viewMsg string
+
+// sharedViewModel shows the view it's sent, telling updated once it has.
+type sharedViewModel struct {
+	view    string
+	updated chan struct{}
+}
+
+func (m sharedViewModel) Init() Cmd { return nil }
+
+func (m sharedViewModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(viewMsg); ok {
+		m.view = string(msg)
+		m.updated <- struct{}{}
+	}
+	return m, nil
+}
+
+func (m sharedViewModel) View() string { return m.view }
+
+// waitForOutput waits for out to contain s after the first n bytes.
+func waitForOutput(t *testing.T, out *lockedBuffer, n int, s string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(out.String()[n:], s) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %q, got %q", s, out.String()[n:])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTeaSharedOutputPaused(t *testing.T) {
+	out := &lockedBuffer{}
+	m := sharedViewModel{view: "outer view", updated: make(chan struct{})}
+	outer := NewProgram(m,
+		WithInput(nil),
+		WithOutput(out),
+		WithMouseCellMotion())
+	done := make(chan error)
+	go func() {
+		_, err := outer.Run()
+		done <- err
+	}()
+	waitForOutput(t, out, 0, "outer view")
+
+	// The nested program uses another mouse mode than the outer one, and the
+	// outer one's view changes while it runs.
+	before := len(out.String())
+	var during string
+	nested := NewProgram(cmdModel{cmd: func() Msg {
+		outer.Send(viewMsg("changed view"))
+		<-m.updated
+		time.Sleep(50 * time.Millisecond)
+		during = out.String()[before:]
+		return QuitMsg{}
+	}},
+		WithInput(nil),
+		WithOutput(out),
+		WithMouseAllMotion(),
+		WithSharedOutput())
+	if _, err := nested.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The outer program didn't render while the nested one ran.
+	if strings.Contains(during, "changed view") {
+		t.Errorf("expected the outer program not to render while the nested one ran, got %q", during)
+	}
+
+	// The nested program turned off its own mouse mode, and the outer
+	// program turned its own on again and repainted its view.
+	waitForOutput(t, out, before, "\x1b[?1003l")
+	after := before + strings.Index(out.String()[before:], "\x1b[?1003l")
+	waitForOutput(t, out, after, "\x1b[?1002h")
+	waitForOutput(t, out, after, "changed view")
+
+	outer.Quit()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,167 @@
+package tea
+
+import (
+	"bytes"
+	"time"
+)
+
+// Sequences the terminal puts around pasted text in bracketed paste mode.
+const (
+	pasteStartSeq = "\x1b[200~"
+	pasteEndSeq   = "\x1b[201~"
+)
+
+var (
+	// maxPasteSize is how many bytes of a paste are kept. A longer one is
+	// delivered cut short, and the rest of it is dropped.
+	maxPasteSize = 4 << 20
+
+	// pasteTimeout is how long the input can go quiet in the middle of a
+	// paste before we stop waiting for its end, in case the terminal never
+	// marks it. The paste is delivered cut short once it has.
+	pasteTimeout = 2 * time.Second
+)
+
+// findPasteEnd finds the end sequence that ends a paste in data, which
+// follows the paste's start sequence, looking from index from on. last is the
+// last end sequence found before from, or -1 if there's none. It returns the
+// end sequence that ends the paste, or -1 if its end isn't in yet, and the
+// last end sequence found.
+//
+// The pasted text itself may contain end sequences meant to end the paste
+// early, so that the rest is interpreted as keys. The terminal sends its own
+// once the paste is done, so an end sequence only ends the paste if nothing
+// was read after it, or if another paste starts after it before the next end
+// sequence.
+func findPasteEnd(data []byte, from, last int) (end, lastEnd int) {
+	for {
+		e := bytes.Index(data[from:], []byte(pasteEndSeq))
+		s := bytes.Index(data[from:], []byte(pasteStartSeq))
+		if last >= 0 && s != -1 && (e == -1 || s < e) {
+			return last, last
+		}
+		if e == -1 {
+			break
+		}
+		last = from + e
+		from = last + len(pasteEndSeq)
+	}
+	if last >= 0 && last+len(pasteEndSeq) == len(data) {
+		return last, last
+	}
+	return -1, last
+}
+
+// pendingPaste collects a paste that spans several reads from the input, so
+// it's delivered as one message without rereading what came before each time.
+type pendingPaste struct {
+	// data is what was pasted so far, without the start sequence.
+	data []byte
+
+	// lastEnd is where the last end sequence in data is, or -1 if there's
+	// none. It only ends the paste if the input goes quiet after it.
+	lastEnd int
+
+	// lastRead is when the last part of the paste was read.
+	lastRead time.Time
+
+	// dropping is set once the paste got too long, after which the rest
+	// of it is dropped until its end.
+	dropping bool
+}
+
+// newPendingPaste starts collecting a paste from input, which starts with
+// the paste's start sequence but doesn't hold its end.
+func newPendingPaste(input []byte, now time.Time) *pendingPaste {
+	data := make([]byte, 0, 2*len(input))
+	data = append(data, input[len(pasteStartSeq):]...)
+	_, lastEnd := findPasteEnd(data, 0, -1)
+	return &pendingPaste{
+		data:     data,
+		lastEnd:  lastEnd,
+		lastRead: now,
+	}
+}
+
+// timedOut reports whether the input has been quiet for too long for the
+// paste to go on.
+func (p *pendingPaste) timedOut(now time.Time) bool {
+	return now.Sub(p.lastRead) >= pasteTimeout
+}
+
+// add adds input read in the middle of the paste. Once the paste's end is
+// in, it returns the input to parse from there on and true: the whole paste
+// with its start and end sequences followed by whatever was read after it, or
+// just what was read after it if the paste was too long, in which case
+// truncate cuts it short.
+func (p *pendingPaste) add(input []byte, now time.Time) ([]byte, bool) {
+	p.lastRead = now
+
+	// The sequences may have been cut in two by the previous read.
+	from := len(p.data) - len(pasteEndSeq) + 1
+	if from < 0 {
+		from = 0
+	}
+	p.data = append(p.data, input...)
+	var end int
+	end, p.lastEnd = findPasteEnd(p.data, from, p.lastEnd)
+	if end < 0 {
+		if p.dropping {
+			p.keepTail()
+		}
+		return nil, false
+	}
+
+	if p.dropping || end > maxPasteSize {
+		rest := p.data[end+len(pasteEndSeq):]
+		p.data = p.data[:end]
+		return rest, true
+	}
+	return append([]byte(pasteStartSeq), p.data...), true
+}
+
+// endAtLast ends the paste at the last end sequence in it, for when the input
+// went quiet after it. It returns what was pasted, cut off at maxPasteSize,
+// and what was read after it, or false if there's no end sequence.
+func (p *pendingPaste) endAtLast() (paste, rest []byte, ok bool) {
+	if p.lastEnd < 0 {
+		return nil, nil, false
+	}
+	paste, rest = p.data[:p.lastEnd], p.data[p.lastEnd+len(pasteEndSeq):]
+	if len(paste) > maxPasteSize {
+		paste = paste[:maxPasteSize]
+	}
+	return paste, rest, true
+}
+
+// truncate returns what's been pasted so far, cut off at maxPasteSize, if the
+// paste has gotten longer than that, dropping what's been collected. It
+// returns nil otherwise.
+func (p *pendingPaste) truncate() []byte {
+	if p.dropping || len(p.data) <= maxPasteSize {
+		return nil
+	}
+	paste := p.data[:maxPasteSize]
+	p.data = append([]byte(nil), p.data[maxPasteSize:]...)
+	if p.lastEnd -= maxPasteSize; p.lastEnd < 0 {
+		p.lastEnd = -1
+	}
+	p.dropping = true
+	p.keepTail()
+	return paste
+}
+
+// keepTail drops what's been collected while dropping the rest of a paste,
+// except for what comes after the last end sequence, which another paste
+// could start after, or otherwise what could be the start of an end sequence.
+func (p *pendingPaste) keepTail() {
+	if p.lastEnd >= 0 && len(p.data)-p.lastEnd <= maxPasteSize {
+		p.data = append(p.data[:0], p.data[p.lastEnd:]...)
+		p.lastEnd = 0
+		return
+	}
+	p.lastEnd = -1
+	if n := len(pasteEndSeq) - 1; len(p.data) > n {
+		p.data = append(p.data[:0], p.data[len(p.data)-n:]...)
+	}
+}
@@ -0,0 +1,167 @@
+package tea
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+)
+
+// Size reported to programs rendering plain output, where there's no
+// terminal to ask.
+const (
+	plainOutputWidth  = 80
+	plainOutputHeight = 24
+)
+
+// plainRenderer writes frames as plain text, for output that isn't a
+// terminal such as a file or a pipe, or a terminal that doesn't understand
+// escape sequences. It never moves the cursor or toggles terminal modes. Each
+// frame that differs from the previous one is written in full, followed by a
+// newline, or only the final frame if finalOnly is set.
+type plainRenderer struct {
+	mtx       sync.Mutex
+	out       io.Writer
+	finalOnly bool
+	lastFrame string
+
+	// stripEscapes removes escape sequences, such as colors, from frames.
+	stripEscapes bool
+
+	// changedLines writes only the lines of each frame that weren't in the
+	// previous one, rather than the whole frame, for screen readers.
+	changedLines bool
+
+	// crlf ends lines with "\r\n", for terminals, which don't turn "\n"
+	// into a new line of their own in raw mode.
+	crlf bool
+}
+
+// AccessibleModeMsg is sent right after Init when the program renders for
+// screen readers, with WithAccessibleOutput or because the ACCESSIBLE
+// environment variable is set, so it can adjust its view. Only the lines of
+// each frame that weren't in the previous one are written, so views work best
+// when what changes is on lines of its own, such as a status line.
+type AccessibleModeMsg struct{}
+
+func newPlainRenderer(out io.Writer, finalOnly, stripEscapes bool) *plainRenderer {
+	return &plainRenderer{out: out, finalOnly: finalOnly, stripEscapes: stripEscapes}
+}
+
+func (r *plainRenderer) write(s string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	s = strings.TrimSuffix(s, "\n")
+	if r.stripEscapes {
+		s = ansi.Strip(s)
+	}
+	if s == r.lastFrame {
+		return
+	}
+	if r.changedLines {
+		r.writeChangedLines(r.lastFrame, s)
+		r.lastFrame = s
+		return
+	}
+	r.lastFrame = s
+	if !r.finalOnly {
+		r.writeFrame()
+	}
+}
+
+// writeChangedLines writes the lines of a frame that weren't in the previous
+// one, in order. Lines that moved, as when a list scrolls, aren't new, and
+// neither are blank lines.
+func (r *plainRenderer) writeChangedLines(prev, frame string) {
+	seen := make(map[string]int)
+	for _, line := range strings.Split(prev, "\n") {
+		seen[strings.TrimRight(line, " ")]++
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(frame, "\n") {
+		line = strings.TrimRight(line, " ")
+		if seen[line] > 0 {
+			seen[line]--
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString(r.newline())
+	}
+	_, _ = io.WriteString(r.out, b.String())
+}
+
+// newline returns what lines are ended with.
+func (r *plainRenderer) newline() string {
+	if r.crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// stop writes the final frame, if only the final frame is written.
+func (r *plainRenderer) stop() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.finalOnly {
+		r.writeFrame()
+	}
+}
+
+func (r *plainRenderer) writeFrame() {
+	if r.lastFrame == "" {
+		return
+	}
+	frame := r.lastFrame
+	if r.crlf {
+		frame = strings.ReplaceAll(frame, "\n", "\r\n")
+	}
+	_, _ = io.WriteString(r.out, frame+r.newline())
+}
+
+func (r *plainRenderer) start()                     {}
+func (r *plainRenderer) kill()                      {}
+func (r *plainRenderer) repaint()                   {}
+func (r *plainRenderer) clearScreen()               {}
+func (r *plainRenderer) altScreen() bool            { return false }
+func (r *plainRenderer) enterAltScreen()            {}
+func (r *plainRenderer) exitAltScreen()             {}
+func (r *plainRenderer) showCursor()                {}
+func (r *plainRenderer) hideCursor()                {}
+func (r *plainRenderer) enableMouseCellMotion()     {}
+func (r *plainRenderer) disableMouseCellMotion()    {}
+func (r *plainRenderer) enableMouseAllMotion()      {}
+func (r *plainRenderer) disableMouseAllMotion()     {}
+func (r *plainRenderer) enableBracketedPaste()      {}
+func (r *plainRenderer) disableBracketedPaste()     {}
+func (r *plainRenderer) enableMouseSGRMode()        {}
+func (r *plainRenderer) disableMouseSGRMode()       {}
+func (r *plainRenderer) enableMousePixelsMode()     {}
+func (r *plainRenderer) disableMousePixelsMode()    {}
+func (r *plainRenderer) bracketedPasteActive() bool { return false }
+func (r *plainRenderer) enableKeyReleases()         {}
+func (r *plainRenderer) disableKeyReleases()        {}
+func (r *plainRenderer) keyReleasesActive() bool    { return false }
+func (r *plainRenderer) enableWin32InputMode()      {}
+func (r *plainRenderer) disableWin32InputMode()     {}
+func (r *plainRenderer) requestTerminalAttributes() {}
+func (r *plainRenderer) readClipboard()             {}
+func (r *plainRenderer) queryWindowSize()           {}
+
+func (r *plainRenderer) setProgress(ProgressState, int) {}
+
+func (r *plainRenderer) screenState() ScreenStateMsg {
+	return ScreenStateMsg{}
+}
+
+func (r *plainRenderer) currentFrame(bool) (string, int, int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.lastFrame, 0, 0
+}
@@ -0,0 +1,230 @@
+package tea
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+type plainModel struct {
+	size  WindowSizeMsg
+	count int
+}
+
+func (m plainModel) Init() Cmd { return SetWindowTitle("title") }
+
+func (m plainModel) Update(msg Msg) (Model, Cmd) {
+	switch msg := msg.(type) {
+	case WindowSizeMsg:
+		m.size = msg
+		return m, func() Msg { return incrementMsg{} }
+	case incrementMsg:
+		m.count++
+		if m.count == 3 {
+			return m, Quit
+		}
+		return m, func() Msg { return incrementMsg{} }
+	}
+	return m, nil
+}
+
+func (m plainModel) View() string {
+	return strings.Repeat("=", m.size.Width/10) + "\ncount: " + string(rune('0'+m.count)) + "\n"
+}
+
+func TestPlainOutput(t *testing.T) {
+	run := func(t *testing.T, opts ...ProgramOption) (string, plainModel) {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+		opts = append([]ProgramOption{WithInput(&in), WithOutput(&buf), WithAltScreen(), WithMouseAllMotion()}, opts...)
+		p := NewProgram(plainModel{}, opts...)
+		m, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if strings.Contains(out, "\x1b") {
+			t.Errorf("expected no escape sequences, got %q", out)
+		}
+		return out, m.(plainModel)
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		out, m := run(t, WithPlainOutput())
+		if m.size != (WindowSizeMsg{Width: plainOutputWidth, Height: plainOutputHeight}) {
+			t.Errorf("expected a window size of %dx%d, got %v", plainOutputWidth, plainOutputHeight, m.size)
+		}
+		expected := "\ncount: 0\n" +
+			"========\ncount: 0\n" +
+			"========\ncount: 1\n" +
+			"========\ncount: 2\n" +
+			"========\ncount: 3\n"
+		if out != expected {
+			t.Errorf("expected:\n%q\ngot:\n%q", expected, out)
+		}
+	})
+
+	t.Run("final frame", func(t *testing.T) {
+		out, _ := run(t, WithPlainOutput(), WithPlainOutputFinalFrame())
+		if expected := "========\ncount: 3\n"; out != expected {
+			t.Errorf("expected %q, got %q", expected, out)
+		}
+	})
+}
+
+// keyModel shows the keys it gets in color, quitting on q.
+type keyModel struct {
+	keys string
+}
+
+func (m keyModel) Init() Cmd { return nil }
+
+func (m keyModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(KeyMsg); ok {
+		m.keys += string(msg.Runes)
+		if strings.HasSuffix(m.keys, "q") {
+			m.keys = strings.TrimSuffix(m.keys, "q")
+			return m, Quit
+		}
+	}
+	return m, nil
+}
+
+func (m keyModel) View() string {
+	return "\x1b[1;31mkeys:\x1b[0m \x1b]8;;https://example.com\x1b\\" + m.keys + "\x1b]8;;\x1b\\\n"
+}
+
+func TestDumbTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(keyModel{},
+		WithInput(strings.NewReader("abq")),
+		WithOutput(&buf),
+		WithAltScreen(),
+		WithMouseCellMotion(),
+		WithEnviron([]string{"TERM=dumb"}))
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := m.(keyModel).keys; keys != "ab" {
+		t.Errorf("expected the model to get input, got %q", keys)
+	}
+	out := buf.String()
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("expected no escape sequences, got %q", out)
+	}
+	if !strings.HasSuffix(out, "keys: ab\n") {
+		t.Errorf("expected the frames as plain text, got %q", out)
+	}
+}
+
+func TestPlainOutputToFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var in bytes.Buffer
+	p := NewProgram(plainModel{}, WithInput(&in), WithOutput(f))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.renderer.(*plainRenderer); !ok {
+		t.Fatalf("expected the plain renderer when writing to a file, got %T", p.renderer)
+	}
+
+	out, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "\x1b") || !strings.HasSuffix(string(out), "count: 3\n") {
+		t.Errorf("expected plain frames, got %q", out)
+	}
+}
+
+func TestAccessibleOutput(t *testing.T) {
+	var buf bytes.Buffer
+	r := newPlainRenderer(&buf, false, true)
+	r.changedLines = true
+
+	frames := []struct {
+		frame    string
+		expected string
+	}{
+		{"\x1b[1mTodo\x1b[0m\n  a\n  b\n\nstatus: 2 items\n", "Todo\n  a\n  b\nstatus: 2 items\n"},
+		{"\x1b[1mTodo\x1b[0m\n  a\n  b\n  c\n\nstatus: 3 items\n", "  c\nstatus: 3 items\n"},
+		{"\x1b[1mTodo\x1b[0m\n  a\n  b\n  c\n\nstatus: 3 items\n", ""},
+		{"\x1b[1mTodo\x1b[0m\n  b\n  c\n\nstatus: 2 items\n", "status: 2 items\n"},
+	}
+	for i, f := range frames {
+		buf.Reset()
+		r.write(f.frame)
+		if buf.String() != f.expected {
+			t.Errorf("frame %d: expected %q, got %q", i, f.expected, buf.String())
+		}
+	}
+}
+
+func TestPlainOutputToTerminal(t *testing.T) {
+	// Terminals in raw mode don't return to the start of the line on "\n".
+	var buf bytes.Buffer
+	r := newPlainRenderer(&buf, false, true)
+	r.crlf = true
+	r.write("title\nstatus 0\n")
+	if expected := "title\r\nstatus 0\r\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	r.changedLines = true
+	r.write("title\nstatus 1\nfooter\n")
+	if expected := "status 1\r\nfooter\r\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+// accessibleModel records whether it was told about accessible mode.
+type accessibleModel struct {
+	keyModel
+	accessible bool
+}
+
+func (m accessibleModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(AccessibleModeMsg); ok {
+		m.accessible = true
+		return m, nil
+	}
+	km, cmd := m.keyModel.Update(msg)
+	m.keyModel = km.(keyModel)
+	return m, cmd
+}
+
+func TestAccessibleMode(t *testing.T) {
+	for _, opt := range []ProgramOption{WithAccessibleOutput(), WithEnviron([]string{"ACCESSIBLE=1"})} {
+		var buf bytes.Buffer
+		p := NewProgram(accessibleModel{},
+			WithInput(strings.NewReader("abq")),
+			WithOutput(&buf),
+			WithAltScreen(),
+			WithMouseCellMotion(),
+			opt)
+		m, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !m.(accessibleModel).accessible {
+			t.Error("expected the model to be told about accessible mode")
+		}
+		out := buf.String()
+		if strings.Contains(out, "\x1b") {
+			t.Errorf("expected no escape sequences, got %q", out)
+		}
+		if !strings.HasSuffix(out, "keys: ab\n") || strings.Count(out, "keys:") != strings.Count(out, "\n") {
+			t.Errorf("expected only the changed lines, got %q", out)
+		}
+	}
+}
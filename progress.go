@@ -0,0 +1,80 @@
+package tea
+
+import (
+	"fmt"
+
+	"github.com/muesli/termenv"
+)
+
+// ProgressState is the state of the progress bar some terminals, such as
+// Windows Terminal and ConEmu, show in the taskbar. See SetProgress.
+type ProgressState int
+
+// Progress states, in the order OSC 9;4 numbers them.
+const (
+	// ProgressNone removes the progress bar.
+	ProgressNone ProgressState = iota
+
+	// ProgressNormal shows the progress made.
+	ProgressNormal
+
+	// ProgressError shows the progress made, in a color that signals an
+	// error.
+	ProgressError
+
+	// ProgressIndeterminate shows that something is in progress, without
+	// saying how far along it is.
+	ProgressIndeterminate
+
+	// ProgressPaused shows the progress made, in a color that signals it's
+	// paused.
+	ProgressPaused
+)
+
+// SetProgress is a command that sets the progress bar some terminals, such as
+// Windows Terminal and ConEmu, show in the taskbar, with OSC 9;4. The percent
+// is from 0 to 100, and is ignored for ProgressNone and
+// ProgressIndeterminate. Other terminals ignore it.
+//
+// The progress bar is removed when the program exits.
+func SetProgress(state ProgressState, percent int) Cmd {
+	return func() Msg {
+		return setProgressMsg{state: state, percent: percent}
+	}
+}
+
+// setProgressMsg is an internal message that sets the taskbar progress bar.
+// To send a setProgressMsg, use the SetProgress command.
+type setProgressMsg struct {
+	state   ProgressState
+	percent int
+}
+
+func (setProgressMsg) internalMsg() {}
+
+// progressSeq returns the sequence that sets the progress bar.
+func progressSeq(state ProgressState, percent int) string {
+	if state < ProgressNone || state > ProgressPaused {
+		state = ProgressNormal
+	}
+	switch {
+	case state == ProgressNone || state == ProgressIndeterminate || percent < 0:
+		percent = 0
+	case percent > 100: //nolint:gomnd
+		percent = 100
+	}
+	return fmt.Sprintf(termenv.OSC+"9;4;%d;%d"+termenv.ST, state, percent)
+}
+
+// setProgress sets the progress bar. Removing it is a no-op unless it was set,
+// so that programs that don't use it don't write the sequence on exit.
+func (r *standardRenderer) setProgress(state ProgressState, percent int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if state == ProgressNone && !r.progressActive {
+		return
+	}
+	_, _ = r.out.WriteString(progressSeq(state, percent))
+	r.progressActive = state != ProgressNone
+}
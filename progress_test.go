@@ -0,0 +1,68 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestSetProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    ProgressState
+		percent  int
+		expected string
+	}{
+		{"normal", ProgressNormal, 73, "\x1b]9;4;1;73\x1b\\"},
+		{"error", ProgressError, 40, "\x1b]9;4;2;40\x1b\\"},
+		{"indeterminate", ProgressIndeterminate, 40, "\x1b]9;4;3;0\x1b\\"},
+		{"paused", ProgressPaused, 10, "\x1b]9;4;4;10\x1b\\"},
+		{"over", ProgressNormal, 150, "\x1b]9;4;1;100\x1b\\"},
+		{"under", ProgressNormal, -5, "\x1b]9;4;1;0\x1b\\"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+			r.setProgress(test.state, test.percent)
+			if buf.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, buf.String())
+			}
+
+			buf.Reset()
+			r.setProgress(ProgressNone, 50)
+			if expected := "\x1b]9;4;0;0\x1b\\"; buf.String() != expected {
+				t.Errorf("expected %q, got %q", expected, buf.String())
+			}
+
+			// It's already removed.
+			buf.Reset()
+			r.setProgress(ProgressNone, 0)
+			if buf.Len() != 0 {
+				t.Errorf("expected nothing, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestProgressClearedOnExit(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(viewModel("installing"), WithInput(nil), WithOutput(&buf))
+	go p.Send(sequenceMsg{SetProgress(ProgressNormal, 73), Quit})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	set := strings.Index(out, "\x1b]9;4;1;73\x1b\\")
+	removed := strings.LastIndex(out, "\x1b]9;4;0;0\x1b\\")
+	if set < 0 || removed < set {
+		t.Errorf("expected the progress to be set and then removed, got %q", out)
+	}
+	if strings.Count(out, "\x1b]9;4;0;0\x1b\\") != 1 {
+		t.Errorf("expected the progress to be removed once, got %q", out)
+	}
+}
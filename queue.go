@@ -0,0 +1,188 @@
+package tea
+
+import (
+	"context"
+	"sync"
+)
+
+// QueuePolicy determines what happens when a message is sent while the
+// message queue is full. See WithMessageQueueSize.
+type QueuePolicy int
+
+// Available queue policies.
+const (
+	// QueueBlock blocks the sender until there's room in the queue.
+	QueueBlock QueuePolicy = iota
+
+	// QueueDropOldest drops the oldest message in the queue to make room.
+	QueueDropOldest
+
+	// QueueDropNewest drops the message being sent.
+	QueueDropNewest
+)
+
+// QueueOverflowMsg is sent when messages were dropped because the message
+// queue was full. Dropped messages are counted until the program catches up
+// with the queue, and are then reported in a single QueueOverflowMsg.
+type QueueOverflowMsg struct {
+	// Dropped is the number of messages dropped since the last
+	// QueueOverflowMsg.
+	Dropped int
+}
+
+// msgQueue is a bounded queue of messages sent with Program.Send, waiting to
+// be delivered to the event loop.
+type msgQueue struct {
+	mtx     sync.Mutex
+	msgs    []Msg
+	size    int
+	policy  QueuePolicy
+	dropped int
+
+	// pushed and popped are signaled when a message is added to or removed
+	// from the queue.
+	pushed chan struct{}
+	popped chan struct{}
+}
+
+func newMsgQueue(size int, policy QueuePolicy) *msgQueue {
+	return &msgQueue{
+		size:   size,
+		policy: policy,
+		pushed: make(chan struct{}, 1),
+		popped: make(chan struct{}, 1),
+	}
+}
+
+// push adds a message to the queue, applying the queue policy if it's full.
+// Internal messages are never dropped and don't wait for room. It returns
+// early if the context is done.
+func (q *msgQueue) push(ctx context.Context, msg Msg) {
+	for {
+		q.mtx.Lock()
+		if len(q.msgs) < q.size || isInternalMsg(msg) {
+			q.msgs = append(q.msgs, msg)
+			q.mtx.Unlock()
+			notify(q.pushed)
+			return
+		}
+
+		switch q.policy {
+		case QueueDropNewest:
+			q.dropped++
+			q.mtx.Unlock()
+			return
+
+		case QueueDropOldest:
+			q.dropped++
+			for i, m := range q.msgs {
+				if !isInternalMsg(m) {
+					q.msgs = append(q.msgs[:i], q.msgs[i+1:]...)
+					q.msgs = append(q.msgs, msg)
+					q.mtx.Unlock()
+					notify(q.pushed)
+					return
+				}
+			}
+			// The queue is full of internal messages, which aren't
+			// dropped, so the message being sent is.
+			q.mtx.Unlock()
+			return
+		}
+
+		q.mtx.Unlock()
+		select {
+		case <-q.popped:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tryPush adds a message to the queue if there's room, reporting whether it
+// was added. Internal messages are always added.
+func (q *msgQueue) tryPush(msg Msg) bool {
+	q.mtx.Lock()
+	if len(q.msgs) >= q.size && !isInternalMsg(msg) {
+		q.mtx.Unlock()
+		return false
+	}
+	q.msgs = append(q.msgs, msg)
+	q.mtx.Unlock()
+	notify(q.pushed)
+	return true
+}
+
+// pop removes the next message from the queue, waiting for one if it's
+// empty. If messages were dropped, a QueueOverflowMsg is returned first. It
+// reports false if the context is done.
+func (q *msgQueue) pop(ctx context.Context) (Msg, bool) {
+	for {
+		q.mtx.Lock()
+		if q.dropped > 0 {
+			msg := QueueOverflowMsg{Dropped: q.dropped}
+			q.dropped = 0
+			q.mtx.Unlock()
+			return msg, true
+		}
+		if len(q.msgs) > 0 {
+			msg := q.msgs[0]
+			q.msgs[0] = nil
+			q.msgs = q.msgs[1:]
+			q.mtx.Unlock()
+			notify(q.popped)
+			return msg, true
+		}
+		q.mtx.Unlock()
+
+		select {
+		case <-q.pushed:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// forwardQueue delivers the messages in the queue to the event loop until
+// the program shuts down.
+func (p *Program) forwardQueue() chan struct{} {
+	ch := make(chan struct{})
+
+	go func() {
+		defer close(ch)
+
+		for {
+			msg, ok := p.queue.pop(p.ctx)
+			if !ok {
+				return
+			}
+			select {
+			case p.msgs <- msg:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// notify notifies a waiter on ch, if there isn't a notification pending
+// already.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// isInternalMsg reports whether a message controls the program itself, such
+// as quitting or changing terminal modes, rather than being meant for the
+// model. These messages are never dropped from the message queue.
+func isInternalMsg(msg Msg) bool {
+	switch msg.(type) {
+	case QuitMsg, WindowSizeMsg, BatchMsg, internalMsg:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package tea
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func drainQueue(t *testing.T, q *msgQueue) []Msg {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var msgs []Msg
+	for {
+		q.mtx.Lock()
+		empty := len(q.msgs) == 0 && q.dropped == 0
+		q.mtx.Unlock()
+		if empty {
+			return msgs
+		}
+		msg, ok := q.pop(ctx)
+		if !ok {
+			t.Fatal("expected a message")
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestMsgQueuePolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drop newest", func(t *testing.T) {
+		q := newMsgQueue(2, QueueDropNewest)
+		for i := 0; i < 5; i++ {
+			q.push(ctx, i)
+		}
+		expected := []Msg{QueueOverflowMsg{Dropped: 3}, 0, 1}
+		if msgs := drainQueue(t, q); !reflect.DeepEqual(msgs, expected) {
+			t.Errorf("expected %v, got %v", expected, msgs)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		q := newMsgQueue(2, QueueDropOldest)
+		for i := 0; i < 5; i++ {
+			q.push(ctx, i)
+		}
+		expected := []Msg{QueueOverflowMsg{Dropped: 3}, 3, 4}
+		if msgs := drainQueue(t, q); !reflect.DeepEqual(msgs, expected) {
+			t.Errorf("expected %v, got %v", expected, msgs)
+		}
+	})
+
+	t.Run("drop oldest with only internal messages", func(t *testing.T) {
+		q := newMsgQueue(2, QueueDropOldest)
+		q.push(ctx, QuitMsg{})
+		q.push(ctx, WindowSizeMsg{Width: 80})
+		q.push(ctx, 0)
+		q.push(ctx, 1)
+
+		expected := []Msg{QueueOverflowMsg{Dropped: 2}, QuitMsg{}, WindowSizeMsg{Width: 80}}
+		if msgs := drainQueue(t, q); !reflect.DeepEqual(msgs, expected) {
+			t.Errorf("expected %v, got %v", expected, msgs)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		q := newMsgQueue(2, QueueBlock)
+		q.push(ctx, 0)
+		q.push(ctx, 1)
+
+		done := make(chan struct{})
+		go func() {
+			q.push(ctx, 2)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("expected the sender to block while the queue is full")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		if msg, _ := q.pop(ctx); msg != 0 {
+			t.Errorf("expected the first message, got %v", msg)
+		}
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected the sender to be unblocked")
+		}
+
+		expected := []Msg{1, 2}
+		if msgs := drainQueue(t, q); !reflect.DeepEqual(msgs, expected) {
+			t.Errorf("expected %v, got %v", expected, msgs)
+		}
+	})
+
+	t.Run("block until done", func(t *testing.T) {
+		q := newMsgQueue(1, QueueBlock)
+		q.push(ctx, 0)
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		q.push(ctx, 1)
+
+		expected := []Msg{0}
+		if msgs := drainQueue(t, q); !reflect.DeepEqual(msgs, expected) {
+			t.Errorf("expected %v, got %v", expected, msgs)
+		}
+	})
+
+	t.Run("internal messages", func(t *testing.T) {
+		for _, policy := range []QueuePolicy{QueueDropNewest, QueueDropOldest, QueueBlock} {
+			q := newMsgQueue(1, policy)
+			q.push(ctx, QuitMsg{})
+			q.push(ctx, WindowSizeMsg{Width: 80})
+			q.push(ctx, enterAltScreenMsg{})
+			q.push(ctx, setWindowTitleMsg("title"))
+
+			expected := []Msg{QuitMsg{}, WindowSizeMsg{Width: 80}, enterAltScreenMsg{}, setWindowTitleMsg("title")}
+			if msgs := drainQueue(t, q); !reflect.DeepEqual(msgs, expected) {
+				t.Errorf("policy %d: expected %v, got %v", policy, expected, msgs)
+			}
+		}
+	})
+}
+
+type overflowModel struct {
+	stalled  chan struct{}
+	gate     chan struct{}
+	overflow chan QueueOverflowMsg
+}
+
+func (m overflowModel) Init() Cmd { return nil }
+
+func (m overflowModel) Update(msg Msg) (Model, Cmd) {
+	switch msg := msg.(type) {
+	case int:
+		if msg == 0 {
+			close(m.stalled)
+			<-m.gate
+		}
+	case QueueOverflowMsg:
+		m.overflow <- msg
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m overflowModel) View() string { return "" }
+
+func TestTeaMessageQueueOverflow(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	m := overflowModel{stalled: make(chan struct{}), gate: make(chan struct{}), overflow: make(chan QueueOverflowMsg, 1)}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithMessageQueueSize(10, QueueDropNewest))
+
+	go func() {
+		// Update stalls on the first message, and the forwarder holds the
+		// second. The queue then fills up with the next ten.
+		p.Send(0)
+		<-m.stalled
+		p.Send(1)
+		for {
+			p.queue.mtx.Lock()
+			empty := len(p.queue.msgs) == 0
+			p.queue.mtx.Unlock()
+			if empty {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		for i := 2; i < 102; i++ {
+			p.Send(i)
+		}
+		close(m.gate)
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := <-m.overflow; msg.Dropped != 90 {
+		t.Errorf("expected 90 dropped messages, got %d", msg.Dropped)
+	}
+}
+
+func TestTeaTrySend(t *testing.T) {
+	t.Run("without queue", func(t *testing.T) {
+		p := NewProgram(nil)
+		if p.TrySend(0) {
+			t.Error("expected TrySend to fail before the program is running")
+		}
+	})
+
+	t.Run("stalled update", func(t *testing.T) {
+		m := overflowModel{stalled: make(chan struct{}), gate: make(chan struct{}), overflow: make(chan QueueOverflowMsg, 1)}
+		p := NewProgram(m, WithInput(nil), WithOutput(&bytes.Buffer{}), WithMessageQueueSize(2, QueueBlock))
+
+		// The queue takes messages before the program starts.
+		if !p.TrySend(0) || !p.TrySend(1) {
+			t.Fatal("expected TrySend to queue messages before the program starts")
+		}
+		if p.TrySend(2) {
+			t.Fatal("expected TrySend to fail with a full queue")
+		}
+
+		done := make(chan error)
+		go func() {
+			_, err := p.Run()
+			done <- err
+		}()
+
+		// Update stalls on the first message. Fill the queue back up.
+		<-m.stalled
+		start := time.Now()
+		sent := 0
+		for p.TrySend(2 + sent) {
+			sent++
+			if sent > 3 {
+				t.Fatal("expected TrySend to fail once the queue is full")
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("expected TrySend not to block, took %v", elapsed)
+		}
+
+		close(m.gate)
+		p.Quit()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if p.TrySend(0) {
+			t.Error("expected TrySend to fail after the program exited")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package tea
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// recorder records a session in the asciicast v2 format, as used by
+// asciinema: a header line with the terminal size, followed by a line for
+// each write to the terminal and, optionally, each read from the input.
+//
+// The header needs the terminal size, which isn't known until the first
+// WindowSizeMsg, so events are held back until then.
+type recorder struct {
+	mtx     sync.Mutex
+	w       io.Writer
+	start   time.Time
+	header  bool
+	pending [][]byte
+}
+
+// asciicastHeader is the first line of an asciicast v2 recording.
+type asciicastHeader struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Asciicast event types.
+const (
+	asciicastOutput = "o"
+	asciicastInput  = "i"
+)
+
+func newRecorder(w io.Writer) *recorder {
+	return &recorder{w: w}
+}
+
+// begin sets the time events are recorded relative to.
+func (r *recorder) begin() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.start = time.Now()
+}
+
+// setSize writes the header with the given terminal size, followed by any
+// events held back, if the header hasn't been written yet.
+func (r *recorder) setSize(width, height int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.header {
+		return
+	}
+	r.header = true
+	b, _ := json.Marshal(asciicastHeader{
+		Version:   2, //nolint:gomnd
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+	})
+	r.writeLine(b)
+	for _, line := range r.pending {
+		r.writeLine(line)
+	}
+	r.pending = nil
+}
+
+// finish completes the recording. If the terminal size was never reported,
+// the header is written with a default size.
+func (r *recorder) finish() {
+	r.setSize(plainOutputWidth, plainOutputHeight)
+}
+
+// event records data written to or read from the terminal.
+func (r *recorder) event(typ string, data []byte) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	line := asciicastEvent(time.Since(r.start), typ, data)
+	if !r.header {
+		r.pending = append(r.pending, line)
+		return
+	}
+	r.writeLine(line)
+}
+
+// asciicastEvent encodes an event that happened at the given time into the
+// recording.
+func asciicastEvent(t time.Duration, typ string, data []byte) []byte {
+	secs := float64(t.Microseconds()) / float64(time.Second/time.Microsecond)
+	line, _ := json.Marshal([]interface{}{secs, typ, string(data)})
+	return line
+}
+
+func (r *recorder) writeLine(b []byte) {
+	_, _ = r.w.Write(append(b, '\n'))
+}
+
+// output returns a writer that records everything written to it as output
+// before forwarding it to w.
+func (r *recorder) output(w io.Writer) io.Writer {
+	return recorderWriter{r: r, typ: asciicastOutput, forward: w}
+}
+
+// input returns a writer that records everything written to it as input.
+func (r *recorder) input() io.Writer {
+	return recorderWriter{r: r, typ: asciicastInput, forward: io.Discard}
+}
+
+type recorderWriter struct {
+	r       *recorder
+	typ     string
+	forward io.Writer
+}
+
+func (w recorderWriter) Write(b []byte) (int, error) {
+	n, err := w.forward.Write(b)
+	if n > 0 {
+		w.r.event(w.typ, b[:n])
+	}
+	return n, err //nolint:wrapcheck
+}
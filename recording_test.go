@@ -0,0 +1,106 @@
+package tea
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type recordingModel struct {
+	init Cmd
+}
+
+func (m recordingModel) Init() Cmd { return m.init }
+
+func (m recordingModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case WindowSizeMsg, KeyMsg:
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m recordingModel) View() string { return "recording\n" }
+
+// readRecording parses an asciicast recording, returning its header and the
+// data of its output and input events.
+func readRecording(t *testing.T, rec string) (asciicastHeader, string, string) {
+	t.Helper()
+
+	lines := strings.Split(strings.TrimSuffix(rec, "\n"), "\n")
+	var header asciicastHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatalf("invalid header %q: %v", lines[0], err)
+	}
+
+	var out, in strings.Builder
+	var last float64
+	for _, line := range lines[1:] {
+		var event []interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if len(event) != 3 {
+			t.Fatalf("expected an event to have 3 fields, got %q", line)
+		}
+		ts, ok := event[0].(float64)
+		if !ok || ts < last {
+			t.Fatalf("expected increasing event times, got %q", line)
+		}
+		last = ts
+		data, _ := event[2].(string)
+		switch event[1] {
+		case asciicastOutput:
+			out.WriteString(data)
+		case asciicastInput:
+			in.WriteString(data)
+		default:
+			t.Fatalf("unexpected event type in %q", line)
+		}
+	}
+	return header, out.String(), in.String()
+}
+
+func TestRecording(t *testing.T) {
+	var buf, rec bytes.Buffer
+	m := recordingModel{init: func() Msg { return WindowSizeMsg{Width: 100, Height: 30} }}
+	p := NewProgram(m, WithInput(nil), WithOutput(&buf), WithRecording(&rec))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	header, out, in := readRecording(t, rec.String())
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 {
+		t.Errorf("expected a version 2 header for 100x30, got %+v", header)
+	}
+	if out != buf.String() {
+		t.Errorf("expected recorded output %q, got %q", buf.String(), out)
+	}
+	if in != "" {
+		t.Errorf("expected no recorded input, got %q", in)
+	}
+}
+
+func TestRecordingInput(t *testing.T) {
+	var buf, rec bytes.Buffer
+	p := NewProgram(recordingModel{},
+		WithInput(bytes.NewBufferString("q")),
+		WithOutput(&buf),
+		WithRecording(&rec),
+		WithRecordingInput())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	header, out, in := readRecording(t, rec.String())
+	if header.Width != plainOutputWidth || header.Height != plainOutputHeight {
+		t.Errorf("expected the default size without a WindowSizeMsg, got %+v", header)
+	}
+	if out != buf.String() {
+		t.Errorf("expected recorded output %q, got %q", buf.String(), out)
+	}
+	if in != "q" {
+		t.Errorf("expected recorded input %q, got %q", "q", in)
+	}
+}
@@ -0,0 +1,78 @@
+package tea
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxWriteRetries is how many times a write to the terminal that failed
+	// with a transient error is retried before giving up.
+	maxWriteRetries = 3
+
+	// writeRetryDelay is how long to wait before retrying a write.
+	writeRetryDelay = 10 * time.Millisecond
+)
+
+// RenderErrorMsg is sent when writing to the terminal fails, such as when the
+// output is a closed SSH channel or a pipe nobody reads from. Once this
+// happens the renderer stops writing, so most programs will want to quit.
+type RenderErrorMsg struct {
+	Err error
+}
+
+// failingWriter forwards writes to the terminal until one fails. Writes that
+// fail with a transient error are retried a few times first. After a write
+// has failed, further writes fail right away without being attempted.
+type failingWriter struct {
+	mtx     sync.Mutex
+	forward io.Writer
+	err     error
+
+	// onError is called with the error when a write first fails.
+	onError func(error)
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	var written int
+	for retries := 0; ; retries++ {
+		n, err := w.forward.Write(b[written:])
+		written += n
+		if err == nil {
+			return written, nil
+		}
+		if retries < maxWriteRetries && isTransientWriteError(err) {
+			time.Sleep(writeRetryDelay)
+			continue
+		}
+
+		w.err = err
+		if w.onError != nil {
+			w.onError(err)
+		}
+		return written, err //nolint:wrapcheck
+	}
+}
+
+// failed reports whether a write has failed.
+func (w *failingWriter) failed() bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.err != nil
+}
+
+// isTransientWriteError reports whether a failed write may succeed if tried
+// again, such as when a non-blocking pipe is full.
+func isTransientWriteError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR)
+}
@@ -0,0 +1,119 @@
+package tea
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// brokenWriter fails all writes after the first few, counting the attempts.
+type brokenWriter struct {
+	mtx      sync.Mutex
+	ok       int
+	err      error
+	attempts int
+}
+
+func (w *brokenWriter) Write(b []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.attempts++
+	if w.attempts <= w.ok {
+		return len(b), nil
+	}
+	return 0, w.err
+}
+
+func (w *brokenWriter) Attempts() int {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.attempts
+}
+
+// flakyWriter fails the first few writes with a transient error.
+type flakyWriter struct {
+	failures int
+	attempts int
+}
+
+func (w *flakyWriter) Write(b []byte) (int, error) {
+	w.attempts++
+	if w.attempts <= w.failures {
+		return 0, syscall.EAGAIN
+	}
+	return len(b), nil
+}
+
+func TestFailingWriter(t *testing.T) {
+	t.Run("transient", func(t *testing.T) {
+		fw := &flakyWriter{failures: maxWriteRetries}
+		w := &failingWriter{forward: fw}
+		if n, err := w.Write([]byte("hello")); n != 5 || err != nil {
+			t.Errorf("expected the write to be retried until it succeeds, got %d, %v", n, err)
+		}
+		if fw.attempts != maxWriteRetries+1 {
+			t.Errorf("expected %d attempts, got %d", maxWriteRetries+1, fw.attempts)
+		}
+	})
+
+	t.Run("too many transient errors", func(t *testing.T) {
+		fw := &flakyWriter{failures: maxWriteRetries + 1}
+		w := &failingWriter{forward: fw}
+		if _, err := w.Write([]byte("hello")); !errors.Is(err, syscall.EAGAIN) {
+			t.Errorf("expected the write to fail after %d retries, got %v", maxWriteRetries, err)
+		}
+	})
+
+	t.Run("persistent", func(t *testing.T) {
+		closed := errors.New("closed")
+		bw := &brokenWriter{err: closed}
+		var reported []error
+		w := &failingWriter{forward: bw, onError: func(err error) {
+			reported = append(reported, err)
+		}}
+		for i := 0; i < 3; i++ {
+			if _, err := w.Write([]byte("hello")); !errors.Is(err, closed) {
+				t.Errorf("expected write to fail, got %v", err)
+			}
+		}
+		if bw.attempts != 1 {
+			t.Errorf("expected writes to stop after the first failure, got %d attempts", bw.attempts)
+		}
+		if len(reported) != 1 || !errors.Is(reported[0], closed) {
+			t.Errorf("expected the error to be reported once, got %v", reported)
+		}
+	})
+}
+
+type renderErrorModel struct {
+	err error
+}
+
+func (m renderErrorModel) Init() Cmd { return nil }
+
+func (m renderErrorModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(RenderErrorMsg); ok {
+		m.err = msg.Err
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m renderErrorModel) View() string { return "view\n" }
+
+func TestTeaRenderError(t *testing.T) {
+	closed := errors.New("closed")
+	w := &brokenWriter{ok: 1, err: closed}
+	m, err := NewProgram(renderErrorModel{}, WithInput(nil), WithOutput(w)).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(m.(renderErrorModel).err, closed) {
+		t.Errorf("expected a RenderErrorMsg with the write error, got %v", m.(renderErrorModel).err)
+	}
+	if n := w.Attempts(); n != 2 {
+		t.Errorf("expected the renderer to stop writing after the failure, got %d attempts", n)
+	}
+}
@@ -57,6 +57,12 @@ type renderer interface {
 	// disableMouseSGRMode disables mouse extended mode (SGR).
 	disableMouseSGRMode()
 
+	// enableMousePixelsMode enables mouse pixel mode (SGR-Pixels).
+	enableMousePixelsMode()
+
+	// disableMousePixelsMode disables mouse pixel mode (SGR-Pixels).
+	disableMousePixelsMode()
+
 	// enableBracketedPaste enables bracketed paste, where characters
 	// inside the input are not interpreted when pasted as a whole.
 	enableBracketedPaste()
@@ -67,7 +73,52 @@ type renderer interface {
 	// bracketedPasteActive reports whether bracketed paste mode is
 	// currently enabled.
 	bracketedPasteActive() bool
+
+	// enableKeyReleases enables the kitty keyboard protocol enhancements
+	// needed to receive key release and repeat events.
+	enableKeyReleases()
+
+	// disableKeyReleases restores the keyboard protocol in effect before
+	// enableKeyReleases was called.
+	disableKeyReleases()
+
+	// keyReleasesActive reports whether key release events are currently
+	// enabled.
+	keyReleasesActive() bool
+
+	// enableWin32InputMode asks the terminal to report keys in
+	// win32-input-mode.
+	enableWin32InputMode()
+
+	// disableWin32InputMode stops reporting keys in win32-input-mode. It
+	// only writes to the terminal if enableWin32InputMode was called.
+	disableWin32InputMode()
+
+	// requestTerminalAttributes asks the terminal for its primary device
+	// attributes.
+	requestTerminalAttributes()
+
+	// readClipboard asks the terminal for the contents of the clipboard.
+	readClipboard()
+
+	// queryWindowSize asks the terminal for its size.
+	queryWindowSize()
+
+	// setProgress sets the progress bar shown in the taskbar, or removes it
+	// with ProgressNone, which does nothing unless it's set.
+	setProgress(state ProgressState, percent int)
+
+	// screenState returns the state of the terminal as far as the renderer
+	// knows it. Mouse modes are left to the program.
+	screenState() ScreenStateMsg
+
+	// currentFrame returns the frame on screen, rendering a pending one first
+	// if render is set, and the size of the screen, or zero if it's not
+	// known.
+	currentFrame(render bool) (frame string, width, height int)
 }
 
 // repaintMsg forces a full repaint.
 type repaintMsg struct{}
+
+func (repaintMsg) internalMsg() {}
@@ -0,0 +1,144 @@
+package tea
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// inputRecord is a line of an input recording: the bytes returned by a single
+// read from the input, and when they were read relative to the start of the
+// program.
+type inputRecord struct {
+	Time float64 `json:"time"`
+	Data []byte  `json:"data"`
+}
+
+// inputRecorder writes an input recording. Each write is recorded as a
+// separate read.
+type inputRecorder struct {
+	mtx   sync.Mutex
+	w     io.Writer
+	start time.Time
+}
+
+func newInputRecorder(w io.Writer) *inputRecorder {
+	return &inputRecorder{w: w}
+}
+
+// begin sets the time reads are recorded relative to.
+func (r *inputRecorder) begin() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.start = time.Now()
+}
+
+func (r *inputRecorder) Write(b []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	t := time.Since(r.start).Seconds()
+	line, err := json.Marshal(inputRecord{Time: t, Data: b})
+	if err != nil {
+		return 0, fmt.Errorf("error recording input: %w", err)
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return 0, fmt.Errorf("error recording input: %w", err)
+	}
+	return len(b), nil
+}
+
+// ReplayOptions configures how an input recording is played back by a
+// ReplayReader.
+type ReplayOptions struct {
+	// Speed speeds up playback by the given factor: 2 plays the recording
+	// back twice as fast. Zero means the original speed.
+	Speed float64
+
+	// MaxDelay is the longest to wait between reads, to skip over long
+	// pauses. Zero means no limit.
+	MaxDelay time.Duration
+}
+
+// ReplayReader plays back input recorded with WithInputRecorder. Pass it to
+// WithInput to feed the recording to a program:
+//
+//	r, err := tea.NewReplayReader(f, tea.ReplayOptions{MaxDelay: time.Second})
+//	if err != nil {
+//	    return err
+//	}
+//	p := tea.NewProgram(model, tea.WithInput(r))
+//
+// Each read returns the bytes of a single recorded read, after the delay
+// that preceded it when it was recorded. Reads are never combined, so escape
+// sequences are split up the same way they were when the input was
+// recorded.
+type ReplayReader struct {
+	records []inputRecord
+	opts    ReplayOptions
+
+	// last is when the previous read was returned, and lastTime when it was
+	// recorded.
+	last     time.Time
+	lastTime float64
+}
+
+// NewReplayReader reads an input recording, returning a reader that plays it
+// back.
+func NewReplayReader(recording io.Reader, opts ReplayOptions) (*ReplayReader, error) {
+	r := &ReplayReader{opts: opts}
+	s := bufio.NewScanner(recording)
+	s.Buffer(nil, 1<<20) //nolint:gomnd
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var rec inputRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("error reading input recording: %w", err)
+		}
+		r.records = append(r.records, rec)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input recording: %w", err)
+	}
+	return r, nil
+}
+
+// Read waits for the next recorded read, and returns its bytes. If b is too
+// small for them, the rest are returned by the next read without waiting.
+func (r *ReplayReader) Read(b []byte) (int, error) {
+	if len(r.records) == 0 {
+		return 0, io.EOF
+	}
+	rec := &r.records[0]
+
+	if r.last.IsZero() {
+		r.last = time.Now()
+	}
+	time.Sleep(time.Until(r.last.Add(r.delay(rec.Time - r.lastTime))))
+	r.last, r.lastTime = time.Now(), rec.Time
+
+	n := copy(b, rec.Data)
+	rec.Data = rec.Data[n:]
+	if len(rec.Data) == 0 {
+		r.records = r.records[1:]
+	}
+	return n, nil
+}
+
+// delay returns how long to wait for a gap of the given number of seconds
+// in the recording.
+func (r *ReplayReader) delay(secs float64) time.Duration {
+	if r.opts.Speed > 0 {
+		secs /= r.opts.Speed
+	}
+	d := time.Duration(secs * float64(time.Second))
+	if r.opts.MaxDelay > 0 && d > r.opts.MaxDelay {
+		d = r.opts.MaxDelay
+	}
+	return d
+}
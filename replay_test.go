@@ -0,0 +1,124 @@
+package tea
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type replayModel struct {
+	keys *[]string
+}
+
+func (m replayModel) Init() Cmd { return nil }
+
+func (m replayModel) Update(msg Msg) (Model, Cmd) {
+	if k, ok := msg.(KeyMsg); ok {
+		*m.keys = append(*m.keys, k.String())
+		if k.String() == "q" {
+			return m, Quit
+		}
+	}
+	return m, nil
+}
+
+func (m replayModel) View() string { return "" }
+
+func TestInputRecordAndReplay(t *testing.T) {
+	// An escape on its own and the start of a sequence split across two
+	// reads are only parsed the same way if the reads are replayed as they
+	// were recorded.
+	chunks := []string{"a", "\x1b", "[A", "\x1b[", "B", "\x1b[C", "q"}
+
+	var rec bytes.Buffer
+	pr, pw := io.Pipe()
+	go func() {
+		for _, c := range chunks {
+			_, _ = pw.Write([]byte(c))
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	var live []string
+	p := NewProgram(replayModel{keys: &live},
+		WithInput(pr),
+		WithOutput(&bytes.Buffer{}),
+		WithInputRecorder(&rec))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	_ = pw.Close()
+
+	if n := strings.Count(rec.String(), "\n"); n != len(chunks) {
+		t.Fatalf("expected %d recorded reads, got %d: %q", len(chunks), n, rec.String())
+	}
+
+	r, err := NewReplayReader(&rec, ReplayOptions{Speed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayed []string
+	p = NewProgram(replayModel{keys: &replayed}, WithInput(r), WithOutput(&bytes.Buffer{}))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(live, replayed) {
+		t.Errorf("expected replayed keys %q to match live keys %q", replayed, live)
+	}
+}
+
+func TestReplayReader(t *testing.T) {
+	recording := `{"time":0,"data":"YWJj"}
+{"time":10,"data":"ZA=="}
+`
+	r, err := NewReplayReader(strings.NewReader(recording), ReplayOptions{MaxDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	var reads []string
+	buf := make([]byte, 2)
+	for {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reads = append(reads, string(buf[:n]))
+	}
+	if expected := []string{"ab", "c", "d"}; !reflect.DeepEqual(reads, expected) {
+		t.Errorf("expected reads %q, got %q", expected, reads)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected the delay to be capped, took %v", d)
+	}
+
+	if _, err := NewReplayReader(strings.NewReader("nope\n"), ReplayOptions{}); err == nil {
+		t.Error("expected an error for an invalid recording")
+	}
+}
+
+func TestReplayReaderDelay(t *testing.T) {
+	for _, tc := range []struct {
+		opts     ReplayOptions
+		secs     float64
+		expected time.Duration
+	}{
+		{ReplayOptions{}, 1.5, 1500 * time.Millisecond},
+		{ReplayOptions{Speed: 2}, 1, 500 * time.Millisecond},
+		{ReplayOptions{MaxDelay: time.Second}, 3, time.Second},
+		{ReplayOptions{Speed: 4, MaxDelay: time.Second}, 2, 500 * time.Millisecond},
+	} {
+		r := &ReplayReader{opts: tc.opts}
+		if d := r.delay(tc.secs); d != tc.expected {
+			t.Errorf("expected a delay of %v for %vs with %+v, got %v", tc.expected, tc.secs, tc.opts, d)
+		}
+	}
+}
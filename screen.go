@@ -23,6 +23,8 @@ func ClearScreen() Msg {
 // You can send a clearScreenMsg with ClearScreen.
 type clearScreenMsg struct{}
 
+func (clearScreenMsg) internalMsg() {}
+
 // EnterAltScreen is a special command that tells the Bubble Tea program to
 // enter the alternate screen buffer.
 //
@@ -38,6 +40,8 @@ func EnterAltScreen() Msg {
 // EnterAltScreen.
 type enterAltScreenMsg struct{}
 
+func (enterAltScreenMsg) internalMsg() {}
+
 // ExitAltScreen is a special command that tells the Bubble Tea program to exit
 // the alternate screen buffer. This command should be used to exit the
 // alternate screen buffer while the program is running.
@@ -52,6 +56,8 @@ func ExitAltScreen() Msg {
 // alternate screen buffer. You can send a exitAltScreenMsg with ExitAltScreen.
 type exitAltScreenMsg struct{}
 
+func (exitAltScreenMsg) internalMsg() {}
+
 // EnableMouseCellMotion is a special command that enables mouse click,
 // release, and wheel events. Mouse movement events are also captured if
 // a mouse button is pressed (i.e., drag events).
@@ -67,6 +73,8 @@ func EnableMouseCellMotion() Msg {
 // enableMouseCellMotionMsg, use the EnableMouseCellMotion command.
 type enableMouseCellMotionMsg struct{}
 
+func (enableMouseCellMotionMsg) internalMsg() {}
+
 // EnableMouseAllMotion is a special command that enables mouse click, release,
 // wheel, and motion events, which are delivered regardless of whether a mouse
 // button is pressed, effectively enabling support for hover interactions.
@@ -85,6 +93,43 @@ func EnableMouseAllMotion() Msg {
 // enableMouseAllMotionMsg, use the EnableMouseAllMotion command.
 type enableMouseAllMotionMsg struct{}
 
+func (enableMouseAllMotionMsg) internalMsg() {}
+
+// EnableMousePixelMotion is a special command that enables mouse click,
+// release, wheel, and motion events like EnableMouseAllMotion, but has the
+// terminal report the position of the mouse in pixels rather than cells
+// (SGR pixel mode, 1016). The pixel position is delivered in the PixelX and
+// PixelY fields of MouseMsg, and X and Y are derived from the size of a
+// terminal cell where the terminal reports it.
+//
+// The terminal is asked whether it's in pixel mode, and mouse events are only
+// treated as reported in pixels once it says so. Terminals that don't support
+// it keep reporting cells, in which case PixelX and PixelY stay zero.
+func EnableMousePixelMotion() Msg {
+	return enableMousePixelMotionMsg{}
+}
+
+// enableMousePixelMotionMsg is a special command that signals to start
+// listening for mouse events reported in pixels (ESC[?1016h). To send an
+// enableMousePixelMotionMsg, use the EnableMousePixelMotion command.
+type enableMousePixelMotionMsg struct{}
+
+func (enableMousePixelMotionMsg) internalMsg() {}
+
+// DisableMousePixelMotion is a special command that returns to reporting
+// mouse events in cells. Mouse tracking itself remains enabled; use
+// DisableMouse to stop listening for mouse events altogether.
+func DisableMousePixelMotion() Msg {
+	return disableMousePixelMotionMsg{}
+}
+
+// disableMousePixelMotionMsg is an internal message that signals to stop
+// reporting mouse events in pixels. To send a disableMousePixelMotionMsg, use
+// the DisableMousePixelMotion command.
+type disableMousePixelMotionMsg struct{}
+
+func (disableMousePixelMotionMsg) internalMsg() {}
+
 // DisableMouse is a special command that stops listening for mouse events.
 func DisableMouse() Msg {
 	return disableMouseMsg{}
@@ -94,6 +139,8 @@ func DisableMouse() Msg {
 // for mouse events. To send a disableMouseMsg, use the DisableMouse command.
 type disableMouseMsg struct{}
 
+func (disableMouseMsg) internalMsg() {}
+
 // HideCursor is a special command for manually instructing Bubble Tea to hide
 // the cursor. In some rare cases, certain operations will cause the terminal
 // to show the cursor, which is normally hidden for the duration of a Bubble
@@ -106,6 +153,8 @@ func HideCursor() Msg {
 // this message with HideCursor.
 type hideCursorMsg struct{}
 
+func (hideCursorMsg) internalMsg() {}
+
 // ShowCursor is a special command for manually instructing Bubble Tea to show
 // the cursor.
 func ShowCursor() Msg {
@@ -116,6 +165,38 @@ func ShowCursor() Msg {
 // this message with ShowCursor.
 type showCursorMsg struct{}
 
+func (showCursorMsg) internalMsg() {}
+
+// SaveCursorPosition is a special command that saves the position of the
+// cursor in the terminal, for use with tools that draw at the cursor. Restore
+// it with RestoreCursorPosition.
+//
+// It's saved with ESC 7 (DECSC), or CSI s with WithSCOCursorSave.
+func SaveCursorPosition() Msg {
+	return saveCursorMsg{}
+}
+
+// saveCursorMsg is an internal command used to save the cursor position. You
+// can send a saveCursorMsg with SaveCursorPosition.
+type saveCursorMsg struct{}
+
+func (saveCursorMsg) internalMsg() {}
+
+// RestoreCursorPosition is a special command that moves the cursor back to
+// where it was saved with SaveCursorPosition. As it may move the cursor, the
+// view is repainted in full on the next render.
+//
+// It's restored with ESC 8 (DECRC), or CSI u with WithSCOCursorSave.
+func RestoreCursorPosition() Msg {
+	return restoreCursorMsg{}
+}
+
+// restoreCursorMsg is an internal command used to restore the cursor
+// position. You can send a restoreCursorMsg with RestoreCursorPosition.
+type restoreCursorMsg struct{}
+
+func (restoreCursorMsg) internalMsg() {}
+
 // EnableBracketedPaste is a special command that tells the Bubble Tea program
 // to accept bracketed paste input.
 //
@@ -130,6 +211,8 @@ func EnableBracketedPaste() Msg {
 // enableBracketedPasteMsg with EnableBracketedPaste.
 type enableBracketedPasteMsg struct{}
 
+func (enableBracketedPasteMsg) internalMsg() {}
+
 // DisableBracketedPaste is a special command that tells the Bubble Tea program
 // to accept bracketed paste input.
 //
@@ -144,6 +227,59 @@ func DisableBracketedPaste() Msg {
 // disableBracketedPasteMsg with DisableBracketedPaste.
 type disableBracketedPasteMsg struct{}
 
+func (disableBracketedPasteMsg) internalMsg() {}
+
+// EnableKeyReleases is a special command that asks the terminal to report key
+// releases, which are then delivered as KeyReleaseMsgs. Keys held down will
+// also be reported with the Repeat flag set on KeyMsg.
+//
+// This relies on the kitty keyboard protocol. Terminals that don't support it
+// will ignore the request, and no release events will be sent.
+//
+// Note that key releases will be automatically disabled when the program
+// quits.
+func EnableKeyReleases() Msg {
+	return enableKeyReleasesMsg{}
+}
+
+// enableKeyReleasesMsg is an internal message that signals that key releases
+// should be reported. You can send an enableKeyReleasesMsg with
+// EnableKeyReleases.
+type enableKeyReleasesMsg struct{}
+
+func (enableKeyReleasesMsg) internalMsg() {}
+
+// DisableKeyReleases is a special command that stops the terminal from
+// reporting key releases.
+func DisableKeyReleases() Msg {
+	return disableKeyReleasesMsg{}
+}
+
+// disableKeyReleasesMsg is an internal message that signals that key
+// releases should no longer be reported. You can send a
+// disableKeyReleasesMsg with DisableKeyReleases.
+type disableKeyReleasesMsg struct{}
+
+func (disableKeyReleasesMsg) internalMsg() {}
+
+// SetEastAsianWidth is a command that sets whether characters of ambiguous
+// width take up two cells rather than one. If the setting changes, the screen
+// is repainted.
+//
+// See WithEastAsianWidth for details.
+func SetEastAsianWidth(enabled bool) Cmd {
+	return func() Msg {
+		return setEastAsianWidthMsg(enabled)
+	}
+}
+
+// setEastAsianWidthMsg is an internal message that changes how the width of
+// ambiguous width characters is measured. You can send a
+// setEastAsianWidthMsg with SetEastAsianWidth.
+type setEastAsianWidthMsg bool
+
+func (setEastAsianWidthMsg) internalMsg() {}
+
 // EnterAltScreen enters the alternate screen buffer, which consumes the entire
 // terminal window. ExitAltScreen will return the terminal to its former state.
 //
@@ -198,5 +334,10 @@ func (p *Program) DisableMouseAllMotion() {
 
 // SetWindowTitle sets the terminal window title.
 func (p *Program) SetWindowTitle(title string) {
+	switch p.renderer.(type) {
+	case *plainRenderer, nilRenderer, *nilRenderer:
+		// There's no terminal user interface to title.
+		return
+	}
 	p.output.SetWindowTitle(title)
 }
@@ -2,7 +2,14 @@ package tea
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/muesli/termenv"
 )
 
 func TestClearMsg(t *testing.T) {
@@ -14,47 +21,77 @@ func TestClearMsg(t *testing.T) {
 		{
 			name:     "clear_screen",
 			cmds:     []Cmd{ClearScreen},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[2J\x1b[1;1H\x1b[1;1Hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[2J\x1b[0Dsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
 		},
 		{
 			name:     "altscreen",
 			cmds:     []Cmd{EnterAltScreen, ExitAltScreen},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25l\x1b[?1049l\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b7\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25l\x1b[?1049l\x1b8\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
 		},
 		{
 			name:     "altscreen_autoexit",
 			cmds:     []Cmd{EnterAltScreen},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25lsuccess\r\n\x1b[2;0H\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?1049l\x1b[?25h",
+			expected: "\x1b[?25l\x1b[?2004h\x1b7\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25lsuccess\r\n\x1b[2;0H\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1049l\x1b8\x1b[?25h",
 		},
 		{
 			name:     "mouse_cellmotion",
 			cmds:     []Cmd{EnableMouseCellMotion},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?1002h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?1002h\x1b[?1015h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1006l\x1b[?1015l",
 		},
 		{
 			name:     "mouse_allmotion",
 			cmds:     []Cmd{EnableMouseAllMotion},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1015h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1003l\x1b[?1006l\x1b[?1015l",
 		},
 		{
 			name:     "mouse_disable",
 			cmds:     []Cmd{EnableMouseAllMotion, DisableMouse},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1006h\x1b[?1002l\x1b[?1003l\x1b[?1006lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1015h\x1b[?1006h\x1b[?1003l\x1b[?1006l\x1b[?1015lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
+		},
+		{
+			name:     "mouse_pixels",
+			cmds:     []Cmd{EnableMousePixelMotion},
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1015h\x1b[?1006h\x1b[?1016h\x1b[?1016$psuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1003l\x1b[?1006l\x1b[?1015l\x1b[?1016l",
+		},
+		{
+			name:     "mouse_pixels_disable",
+			cmds:     []Cmd{EnableMousePixelMotion, DisableMousePixelMotion},
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1015h\x1b[?1006h\x1b[?1016h\x1b[?1016$p\x1b[?1016lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1003l\x1b[?1006l\x1b[?1015l",
 		},
 		{
 			name:     "cursor_hide",
 			cmds:     []Cmd{HideCursor},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
 		},
 		{
 			name:     "cursor_hideshow",
 			cmds:     []Cmd{HideCursor, ShowCursor},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?25l\x1b[?25hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?25l\x1b[?25hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l",
+		},
+		{
+			name:     "key_releases",
+			cmds:     []Cmd{EnableKeyReleases},
+			expected: "\x1b[?25l\x1b[?2004h\x1b[>11usuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[<u\x1b[?25h",
+		},
+		{
+			name:     "key_releases_stop_start",
+			cmds:     []Cmd{EnableKeyReleases, DisableKeyReleases, DisableKeyReleases},
+			expected: "\x1b[?25l\x1b[?2004h\x1b[>11u\x1b[<usuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
+		},
+		{
+			name:     "terminal_attributes",
+			cmds:     []Cmd{RequestTerminalAttributes},
+			expected: "\x1b[?25l\x1b[?2004h\x1b[csuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
+		},
+		{
+			name:     "read_clipboard",
+			cmds:     []Cmd{ReadClipboard},
+			expected: "\x1b[?25l\x1b[?2004h\x1b]52;c;?\asuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
 		},
 		{
 			name:     "bp_stop_start",
 			cmds:     []Cmd{DisableBracketedPaste, EnableBracketedPaste},
-			expected: "\x1b[?25l\x1b[?2004h\x1b[?2004l\x1b[?2004hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?2004l\x1b[?2004hsuccess\r\n\x1b[0D\x1b[2K\x1b[?2004l\x1b[?25h",
 		},
 	}
 
@@ -79,3 +116,938 @@ func TestClearMsg(t *testing.T) {
 		})
 	}
 }
+
+// writeRecorder records each write made to it separately.
+type writeRecorder struct {
+	mtx    sync.Mutex
+	writes []string
+}
+
+func (w *writeRecorder) Write(b []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.writes = append(w.writes, string(b))
+	return len(b), nil
+}
+
+func TestStartupModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []ProgramOption
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "\x1b[?25l\x1b[?2004h",
+		},
+		{
+			name:     "altscreen",
+			opts:     []ProgramOption{WithAltScreen()},
+			expected: "\x1b[?25l\x1b7\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25l\x1b[?2004h",
+		},
+		{
+			name:     "mouse_cellmotion",
+			opts:     []ProgramOption{WithMouseCellMotion()},
+			expected: "\x1b[?25l\x1b[?2004h\x1b[?1002h\x1b[?1015h\x1b[?1006h",
+		},
+		{
+			name:     "altscreen_mouse_allmotion",
+			opts:     []ProgramOption{WithAltScreen(), WithMouseAllMotion()},
+			expected: "\x1b[?25l\x1b7\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25l\x1b[?2004h\x1b[?1003h\x1b[?1015h\x1b[?1006h",
+		},
+		{
+			name:     "key_releases_without_bracketed_paste",
+			opts:     []ProgramOption{WithKeyReleases(), WithoutBracketedPaste()},
+			expected: "\x1b[?25l\x1b[>11u",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var w writeRecorder
+			opts := append([]ProgramOption{WithInput(nil), WithOutput(&w)}, test.opts...)
+			p := NewProgram(&testModel{}, opts...)
+			go p.Send(Quit())
+
+			if _, err := p.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The modes are all written at once, before the first frame.
+			if len(w.writes) == 0 || w.writes[0] != test.expected {
+				t.Errorf("expected the first write to be:\n%q\ngot writes:\n%q", test.expected, w.writes)
+			}
+		})
+	}
+}
+
+// tallModel shrinks its view once it's told it doesn't fit, and quits once
+// it fits again.
+type tallModel struct {
+	lines int
+	msgs  []ViewTruncatedMsg
+}
+
+func (m tallModel) Init() Cmd { return nil }
+
+func (m tallModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(ViewTruncatedMsg); ok {
+		m.msgs = append(m.msgs, msg)
+		if msg.DroppedLines == 0 {
+			return m, Quit
+		}
+		m.lines = 5
+	}
+	return m, nil
+}
+
+func (m tallModel) View() string {
+	lines := make([]string, m.lines)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestViewTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(tallModel{lines: 30}, WithInput(nil), WithOutput(&buf), WithInitialWindowSize(20, 10))
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ViewTruncatedMsg{
+		{RenderedLines: 10, DroppedLines: 20},
+		{RenderedLines: 5, DroppedLines: 0},
+	}
+	if msgs := m.(tallModel).msgs; !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("expected %v, got %v", expected, msgs)
+	}
+	if out := buf.String(); strings.Contains(out, "line 19\r") || !strings.Contains(out, "line 20\r") {
+		t.Errorf("expected the top lines to be dropped, got %q", out)
+	}
+}
+
+func TestFlushChangingHeight(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("a\nb\nc\nd")
+	r.flush()
+	buf.Reset()
+
+	// Shrinking clears the lines that are gone, leaving the cursor on the
+	// new last line.
+	r.write("a\nb\nX")
+	r.flush()
+	if expected := "\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2K\x1b[2A\x1b[0D\x1b[2Ka\r\n\x1b[1BX\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// Growing adds lines with newlines, even after skipping unchanged ones.
+	r.write("a\nY\nX\nZ")
+	r.flush()
+	if expected := "\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nY\r\n\r\nZ\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFlushBottomAnchor(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.bottomAnchor = true
+	r.write("a\nb\nc\nd")
+	r.flush()
+	buf.Reset()
+
+	tests := []struct {
+		name     string
+		frame    string
+		expected string
+	}{
+		{
+			// The top row is cleared, and the rest move down a row.
+			name:  "shrink",
+			frame: "b\nc\nd",
+			expected: "\x1b[3A\x1b[0D" +
+				"\x1b[2K\r\n" +
+				"\x1b[2Kb\r\n" +
+				"\x1b[2Kc\r\n" +
+				"\x1b[2Kd\x1b[0D",
+		},
+		{
+			// The row that was cleared is used again.
+			name:  "grow into blank rows",
+			frame: "x\nb\nc\nd",
+			expected: "\x1b[3A\x1b[0D" +
+				"\x1b[2Kx\r\n" +
+				"\x1b[2Kb\r\n" +
+				"\x1b[2Kc\r\n" +
+				"\x1b[2Kd\x1b[0D",
+		},
+		{
+			// Without blank rows, the terminal scrolls.
+			name:  "grow",
+			frame: "w\nx\nb\nc\nd",
+			expected: "\x1b[3A\x1b[0D" +
+				"\x1b[2Kw\r\n" +
+				"\x1b[2Kx\r\n" +
+				"\x1b[2Kb\r\n" +
+				"\x1b[2Kc\r\n" +
+				"\x1b[2Kd\x1b[0D",
+		},
+	}
+	for _, test := range tests {
+		r.write(test.frame)
+		r.flush()
+		if buf.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, buf.String())
+		}
+		buf.Reset()
+	}
+
+	if r.blankAbove != 0 || r.linesRendered != 5 || r.renderingHead != 4 {
+		t.Errorf("unexpected state: %d blank rows above, %d lines rendered, head at %d",
+			r.blankAbove, r.linesRendered, r.renderingHead)
+	}
+}
+
+// viewModel shows a fixed view.
+type viewModel string
+
+func (m viewModel) Init() Cmd { return nil }
+
+func (m viewModel) Update(Msg) (Model, Cmd) { return m, nil }
+
+func (m viewModel) View() string { return string(m) }
+
+func TestShutdownFinalFrame(t *testing.T) {
+	const restore = "\x1b[?2004l\x1b[?25h"
+
+	tests := []struct {
+		name     string
+		view     string
+		opts     []ProgramOption
+		expected string
+	}{
+		{
+			name:     "default",
+			view:     "total\n",
+			expected: "\x1b[?25l\x1b[?2004htotal\r\n\x1b[0D\x1b[2K" + restore,
+		},
+		{
+			name:     "default without newline",
+			view:     "total",
+			expected: "\x1b[?25l\x1b[?2004htotal\x1b[0D\x1b[2K" + restore,
+		},
+		{
+			name:     "keep final frame",
+			view:     "total\n",
+			opts:     []ProgramOption{WithKeepFinalFrame()},
+			expected: "\x1b[?25l\x1b[?2004htotal\r\n\x1b[0D" + restore,
+		},
+		{
+			name:     "keep final frame without newline",
+			view:     "total",
+			opts:     []ProgramOption{WithKeepFinalFrame()},
+			expected: "\x1b[?25l\x1b[?2004htotal\x1b[0D\r\n" + restore,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]ProgramOption{WithInput(nil), WithOutput(&buf)}, test.opts...)
+			p := NewProgram(viewModel(test.view), opts...)
+			go p.Send(QuitMsg{})
+			if _, err := p.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.expected {
+				t.Errorf("expected:\n%q\ngot:\n%q", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestShutdownClearOnQuit(t *testing.T) {
+	const restore = "\x1b[?2004l\x1b[?25h"
+
+	var buf bytes.Buffer
+	p := NewProgram(viewModel("one\ntwo\nthree\n"), WithInput(nil), WithOutput(&buf), WithClearOnQuit())
+	go p.Send(sequenceMsg{Println("printed"), Quit})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The view is four lines, the last one empty, and the cursor is on the
+	// last one.
+	clear := "\x1b[0D" +
+		"\x1b[2K\x1b[1A" +
+		"\x1b[2K\x1b[1A" +
+		"\x1b[2K\x1b[1A" +
+		"\x1b[2K"
+	out := buf.String()
+	if !strings.HasSuffix(out, "three\r\n\x1b[0D"+clear+restore) {
+		t.Errorf("expected the view to be cleared, got %q", out)
+	}
+	if !strings.Contains(out, "printed\r\n") {
+		t.Errorf("expected the printed line above the view, got %q", out)
+	}
+}
+
+func TestShutdownRestoresEnabledModesOnly(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []ProgramOption
+		restore   string
+		untouched []string
+	}{
+		{
+			name:      "default",
+			restore:   "\x1b[?2004l\x1b[?25h",
+			untouched: []string{"?1002l", "?1003l", "?1006l", "?1015l", "?1016l"},
+		},
+		{
+			name:      "without bracketed paste",
+			opts:      []ProgramOption{WithoutBracketedPaste()},
+			restore:   "\x1b[?25h",
+			untouched: []string{"?2004l", "?1002l", "?1003l", "?1006l", "?1015l"},
+		},
+		{
+			name:      "mouse cell motion",
+			opts:      []ProgramOption{WithMouseCellMotion()},
+			restore:   "\x1b[?2004l\x1b[?25h\x1b[?1002l\x1b[?1006l\x1b[?1015l",
+			untouched: []string{"?1003l", "?1016l"},
+		},
+		{
+			name:      "mouse all motion",
+			opts:      []ProgramOption{WithMouseAllMotion()},
+			restore:   "\x1b[?2004l\x1b[?25h\x1b[?1003l\x1b[?1006l\x1b[?1015l",
+			untouched: []string{"?1002l", "?1016l"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := append([]ProgramOption{WithInput(nil), WithOutput(&buf)}, test.opts...)
+			p := NewProgram(viewModel("view\n"), opts...)
+			go p.Send(QuitMsg{})
+			if _, err := p.Run(); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !strings.HasSuffix(out, test.restore) {
+				t.Errorf("expected the output to end with %q, got %q", test.restore, out)
+			}
+			for _, seq := range test.untouched {
+				if strings.Contains(out, seq) {
+					t.Errorf("expected no %q for a mode that wasn't enabled, got %q", seq, out)
+				}
+			}
+		})
+	}
+}
+
+func TestAltScreenRestoresInlineView(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("a\nb\nc")
+	r.flush()
+	buf.Reset()
+
+	r.enterAltScreen()
+	r.write("alt 1\nalt 2\nalt 3\nalt 4")
+	r.flush()
+	r.exitAltScreen()
+
+	// The cursor is saved before switching to the alt screen, and restored
+	// after switching back.
+	out := buf.String()
+	enter := strings.Index(out, "\x1b7\x1b[?1049h")
+	exit := strings.Index(out, "\x1b[?1049l\x1b8")
+	if enter < 0 || exit < enter {
+		t.Errorf("expected the alt screen to be bracketed by saving and restoring the cursor, got %q", out)
+	}
+	buf.Reset()
+
+	// The first flush after exiting repaints every line of the inline view,
+	// starting from where it was.
+	r.write("a\nb\nc")
+	r.flush()
+	if expected := "\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nb\r\nc\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestAltScreenRepaintsChangedInlineView(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("a\nb\nc")
+	r.flush()
+
+	r.enterAltScreen()
+	r.write("alt 1\nalt 2")
+	r.flush()
+	r.exitAltScreen()
+	buf.Reset()
+
+	// Every line is repainted, including the ones that are the same as
+	// before, as the screen may have been cleared.
+	r.write("a\nx\nc\nd")
+	r.flush()
+	if expected := "\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nx\r\nc\r\nd\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestAltScreenRestoreInlineFrame(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.restoreInlineFrame = true
+	r.write("a\nb\nc")
+	r.flush()
+
+	r.enterAltScreen()
+	r.write("alt 1\nalt 2")
+	r.flush()
+	buf.Reset()
+
+	// The inline frame is repainted as soon as the alt screen is exited.
+	r.exitAltScreen()
+	if expected := "\x1b[?1049l\x1b8\x1b[?25h\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nb\r\nc\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// The next frame is diffed against it.
+	r.write("a\nx\nc")
+	r.flush()
+	if expected := "\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nx\r\n\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestRendererClearScreen(t *testing.T) {
+	tests := []struct {
+		name      string
+		altScreen bool
+		clear     string
+		repaint   string
+		diff      string
+	}{
+		{
+			name: "inline",
+			// Back to the top of the view, not the terminal.
+			clear:   "\x1b[2J\x1b[0D\x1b[2A",
+			repaint: "a\r\nb\r\nc\x1b[0D",
+			diff:    "\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nx\r\n\x1b[0D",
+		},
+		{
+			name:      "alt screen",
+			altScreen: true,
+			clear:     "\x1b[2J\x1b[1;1H\x1b[1;1H",
+			repaint:   "a\r\nb\r\nc\x1b[3;0H",
+			diff:      "\x1b[1A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Ka\r\nx\r\n\x1b[3;0H",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+			if tc.altScreen {
+				r.enterAltScreen()
+			}
+			r.write("a\nb\nc")
+			r.flush()
+			buf.Reset()
+
+			r.clearScreen()
+			if buf.String() != tc.clear {
+				t.Errorf("expected the screen to be cleared with %q, got %q", tc.clear, buf.String())
+			}
+			buf.Reset()
+
+			// The same frame is painted again from scratch.
+			r.write("a\nb\nc")
+			r.flush()
+			if buf.String() != tc.repaint {
+				t.Errorf("expected the frame to be repainted with %q, got %q", tc.repaint, buf.String())
+			}
+			buf.Reset()
+
+			// And later frames are diffed against it.
+			r.write("a\nx\nc")
+			r.flush()
+			if buf.String() != tc.diff {
+				t.Errorf("expected the change to be rendered with %q, got %q", tc.diff, buf.String())
+			}
+		})
+	}
+}
+
+func TestPrintBeforeAltScreen(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(viewModel("view\n"), WithInput(nil), WithOutput(&buf))
+	go p.Send(sequenceMsg{Println("printed"), EnterAltScreen, Quit})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The printed line goes to the main screen before switching.
+	out := buf.String()
+	printed := strings.Index(out, "printed\r\n")
+	enter := strings.Index(out, "\x1b[?1049h")
+	if printed < 0 || enter < 0 || printed > enter {
+		t.Errorf("expected the printed line before entering the alt screen, got %q", out)
+	}
+}
+
+func TestStopPrintsQueuedMessages(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("view")
+	r.flush()
+	buf.Reset()
+
+	// Nothing's left to flush when the renderer stops, but the printed line
+	// is still printed, along with the view.
+	r.start()
+	r.handleMessages(printLineMessage{messageBody: "printed"})
+	r.stop()
+	if expected := "\x1b[0D\x1b[2Kprinted\r\nview\x1b[0D\x1b[2K"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestPrintBelow(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.start()
+	r.write("a\nb\n")
+	r.flush()
+	buf.Reset()
+
+	// The printed line takes the place of the empty last line of the view,
+	// which the cursor is on.
+	r.handleMessages(printBelowMessage{messageBody: "warning"})
+	r.write("a\nb\n")
+	r.flush()
+	if expected := "warning\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// It stays below the view as that changes.
+	r.write("a\nb\nc\n")
+	r.flush()
+	if expected := "\x1b[0D\x1b[2K\x1b[2A\x1b[0D\x1b[2Ka\r\n\x1b[1Bc\r\nwarning\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// And is left on screen on exit, with the cursor on the line below.
+	r.stop()
+	if expected := "\r\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestPrintBelowBounded(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.handleMessages(WindowSizeMsg{Width: 20, Height: 5})
+
+	// Only the latest lines that fit on screen are kept, so each frame
+	// takes about as much to write as the last one.
+	var sizes []int
+	for i := 0; i < 50; i++ {
+		r.handleMessages(printBelowMessage{messageBody: fmt.Sprintf("warning %02d", i)})
+		r.write("view\n")
+		r.flush()
+		sizes = append(sizes, buf.Len())
+		buf.Reset()
+	}
+	expected := []string{"warning 45", "warning 46", "warning 47", "warning 48", "warning 49"}
+	if !reflect.DeepEqual(r.footerLines, expected) {
+		t.Errorf("expected the footer to be %q, got %q", expected, r.footerLines)
+	}
+	if r.linesRendered > 5 {
+		t.Errorf("expected at most 5 lines to be rendered, got %d", r.linesRendered)
+	}
+	if first, last := sizes[10], sizes[len(sizes)-1]; last != first {
+		t.Errorf("expected frames to stay the same size, got %d bytes and later %d", first, last)
+	}
+
+	// ClearBelow removes them.
+	r.handleMessages(clearBelowMsg{})
+	r.write("view\n")
+	r.flush()
+	if lines := r.lastLines; !reflect.DeepEqual(lines, []string{"view", ""}) {
+		t.Errorf("expected just the view, got %q", lines)
+	}
+}
+
+func TestSetIgnoredLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		altScreen bool
+		expected  string
+	}{
+		{
+			name:     "inline",
+			expected: "\x1b[3B\x1b[2K\x1b[1A\x1b[2K\x1b[1B\x1b[0D",
+		},
+		{
+			name:      "alt screen",
+			altScreen: true,
+			expected:  "\x1b[3B\x1b[2K\x1b[1A\x1b[2K\x1b[6;0H",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+			if test.altScreen {
+				r.enterAltScreen()
+			}
+			r.write("l0\nl1\nl2\nl3\nl4\nl5")
+			r.flush()
+			buf.Reset()
+
+			// With the cursor in the middle of the view, the lines below it
+			// are cleared, and it's put back on the last line.
+			r.renderingHead = 2
+			r.setIgnoredLines(4, 6)
+			if buf.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, buf.String())
+			}
+			if r.renderingHead != 5 {
+				t.Errorf("expected the rendering head on the last line, got %d", r.renderingHead)
+			}
+		})
+	}
+}
+
+func TestClearIgnoredLines(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("l0\nl1\nl2\nl3\nl4\nl5")
+	r.flush()
+	r.setIgnoredLines(2, 4)
+	r.write("l0\nl1\nX2\nX3\nl4\nl5")
+	r.flush()
+	buf.Reset()
+
+	// The lines that were ignored are painted from the frame, even though
+	// it hasn't changed, and the rest are left alone.
+	r.handleMessages(clearScrollAreaMsg{})
+	r.write("l0\nl1\nX2\nX3\nl4\nl5")
+	r.flush()
+	if expected := "\x1b[2A\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2K\x1b[2A\x1b[0D\x1b[2Kl0\r\n\x1b[1BX2\r\nX3\r\n\x1b[1B\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestResizeInvalidatesScrollArea(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	var invalidated int
+	r.onScrollAreaInvalidated = func() { invalidated++ }
+	r.handleMessages(WindowSizeMsg{Width: 20, Height: 10})
+	r.write("l0\nl1\nl2\nl3")
+	r.flush()
+	r.setIgnoredLines(1, 3)
+
+	// Only the width changing leaves the area alone.
+	r.handleMessages(WindowSizeMsg{Width: 30, Height: 10})
+	if invalidated != 0 || len(r.ignoreLines) != 2 {
+		t.Fatalf("expected the scrollable area to stay, got %d notifications and %d ignored lines",
+			invalidated, len(r.ignoreLines))
+	}
+
+	r.handleMessages(WindowSizeMsg{Width: 30, Height: 5})
+	if invalidated != 1 {
+		t.Errorf("expected a notification, got %d", invalidated)
+	}
+
+	// The lines that were ignored are rendered again.
+	buf.Reset()
+	r.write("l0\nX1\nX2\nl3")
+	r.flush()
+	if out := buf.String(); !strings.Contains(out, "X1") || !strings.Contains(out, "X2") {
+		t.Errorf("expected the lines to be rendered, got %q", out)
+	}
+}
+
+func TestRepaintLines(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("l0\nl1\nl2\nl3\nl4\nl5")
+	r.flush()
+	r.setIgnoredLines(2, 5)
+	buf.Reset()
+
+	// Only the line asked for is cleared and rewritten, and the cursor goes
+	// back to the last line.
+	r.handleMessages(repaintLinesMsg{from: 3, to: 4})
+	if expected := "\x1b[2A\x1b[2Kl3\x1b[0D\x1b[2B\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// The line is still ignored afterwards.
+	r.write("l0\nl1\nl2\nX3\nl4\nl5")
+	r.flush()
+	if strings.Contains(buf.String(), "X3") {
+		t.Errorf("expected the line to be ignored, got %q", buf.String())
+	}
+}
+
+func TestRepaintStaleLines(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.write("l0\nl1\nl2\nl3")
+	r.flush()
+	r.setIgnoredLines(2, 3)
+	r.clearIgnoredLines()
+	buf.Reset()
+
+	// The line holds what was drawn there while it was ignored, so it's
+	// erased, and the cursor doesn't move off it.
+	r.handleMessages(repaintLinesMsg{from: 2, to: 3})
+	if expected := "\x1b[1A\x1b[2K\x1b[0D\x1b[1B\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFlushLongJump(t *testing.T) {
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	frame := strings.Join(lines, "\n")
+	lines[2] = "changed"
+	changed := strings.Join(lines, "\n")
+
+	tests := []struct {
+		name      string
+		altScreen bool
+		expected  string
+	}{
+		{
+			// The cursor is moved to the changed line directly. The first
+			// line is always rendered.
+			name:      "alt screen",
+			altScreen: true,
+			expected: "\x1b[3;1H\x1b[0D\x1b[2K\x1b[2A\x1b[0D\x1b[2Kline 0\r\n" +
+				"\x1b[1Bchanged\r\n\x1b[20;0H",
+		},
+		{
+			// Rows are relative to where the view starts, so the cursor is
+			// moved relative to where it is.
+			name: "inline",
+			expected: "\x1b[17A\x1b[0D\x1b[2K\x1b[2A\x1b[0D\x1b[2Kline 0\r\n" +
+				"\x1b[1Bchanged\r\n\x1b[16B\x1b[0D",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+			if test.altScreen {
+				r.enterAltScreen()
+			}
+			r.write(frame)
+			r.flush()
+			buf.Reset()
+
+			r.write(changed)
+			r.flush()
+			if buf.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestFlushCompressorThreshold(t *testing.T) {
+	const styled = "\x1b[1ma\x1b[0m\x1b[1mb\x1b[0m"
+
+	var buf bytes.Buffer
+	out := termenv.NewOutput(&buf, termenv.WithProfile(termenv.TrueColor))
+	r := newRenderer(out, false, 60, false).(*standardRenderer)
+	r.compressorThreshold = 100
+
+	// Small frames are written as they are.
+	r.write(styled)
+	r.flush()
+	if expected := styled + "\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+
+	// Large ones have the reset and the same style again removed.
+	r.write(strings.Repeat("c", 100) + styled)
+	r.flush()
+	if expected := "\x1b[0D\x1b[2K" + strings.Repeat("c", 100) + "\x1b[1mab\x1b[0m\x1b[0D"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFlushAppend(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		width    int
+		expected string
+	}{
+		{
+			// Only the appended text is written.
+			name:     "plain",
+			from:     "hello",
+			to:       "helloX",
+			expected: "\x1b[5CX\x1b[0D",
+		},
+		{
+			name:     "last line",
+			from:     "> a\n> hello",
+			to:       "> a\n> helloX",
+			expected: "\x1b[7CX\x1b[0D",
+		},
+		{
+			// The style the line ended with is picked up again.
+			name:     "styled",
+			from:     "\x1b[1mhi \x1b[31mhello",
+			to:       "\x1b[1mhi \x1b[31mhelloX",
+			expected: "\x1b[8C\x1b[1m\x1b[31mX\x1b[0D",
+		},
+		{
+			name:     "reset",
+			from:     "\x1b[1mhello\x1b[0m",
+			to:       "\x1b[1mhello\x1b[0mX",
+			expected: "\x1b[5CX\x1b[0D",
+		},
+		{
+			// Other lines changing too are rendered as usual.
+			name:     "other lines",
+			from:     "a\nhello",
+			to:       "b\nhelloX",
+			expected: "\x1b[0D\x1b[2K\x1b[1A\x1b[0D\x1b[2Kb\r\nhelloX\x1b[0D",
+		},
+		{
+			// So are lines that don't fit, as the text isn't appended.
+			name:     "too wide",
+			from:     "hello",
+			to:       "helloX",
+			width:    5,
+			expected: "\x1b[5D\x1b[2Khello\x1b[5D",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			out := termenv.NewOutput(&buf, termenv.WithProfile(termenv.TrueColor))
+			r := newRenderer(out, false, 60, false).(*standardRenderer)
+			r.width = test.width
+			r.write(test.from)
+			r.flush()
+			buf.Reset()
+
+			r.write(test.to)
+			r.flush()
+			if buf.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+// Benchmarks of flushing frames, the renderer's hot path. Run them with
+//
+//	go test -run '^$' -bench Flush -benchmem
+//
+// For reference, with frames of 5000 lines, they came out at:
+//
+//	BenchmarkFlushUnchanged            4249 ns/op        0 B/op       0 allocs/op
+//	BenchmarkFlushLineChange         299699 ns/op    90921 B/op      20 allocs/op
+//	BenchmarkFlushRepaint           2797436 ns/op  1006995 B/op   10050 allocs/op
+//	BenchmarkFlushQueuedMessages    2531903 ns/op  1007016 B/op   10053 allocs/op
+
+// tallFrames returns two frames of n styled lines, differing in one line.
+func tallFrames(n int) (string, string) {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("\x1b[1mline\x1b[0m %d", i)
+	}
+	a := strings.Join(lines, "\n")
+	lines[n/2] = "changed"
+	return a, strings.Join(lines, "\n")
+}
+
+// benchmarkRenderer returns a renderer writing to nowhere, without
+// downgrading colors.
+func benchmarkRenderer() *standardRenderer {
+	out := termenv.NewOutput(io.Discard, termenv.WithProfile(termenv.TrueColor))
+	return newRenderer(out, false, 60, false).(*standardRenderer)
+}
+
+// BenchmarkFlushUnchanged renders the same frame on each tick, which should
+// do nothing but compare it with the last one.
+func BenchmarkFlushUnchanged(b *testing.B) {
+	frame, _ := tallFrames(5000)
+	r := benchmarkRenderer()
+	r.write(frame)
+	r.flush()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.write(frame)
+		r.flush()
+	}
+}
+
+// BenchmarkFlushLineChange flushes a tall frame with a line changing each
+// time, which should allocate little besides the copy of the frame that's
+// kept to compare the next one with.
+func BenchmarkFlushLineChange(b *testing.B) {
+	frames := [2]string{}
+	frames[0], frames[1] = tallFrames(5000)
+	r := benchmarkRenderer()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.write(frames[i%2])
+		r.flush()
+	}
+}
+
+// BenchmarkFlushRepaint repaints a tall frame in full each time.
+func BenchmarkFlushRepaint(b *testing.B) {
+	frame, _ := tallFrames(5000)
+	r := benchmarkRenderer()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.repaint()
+		r.write(frame)
+		r.flush()
+	}
+}
+
+// BenchmarkFlushQueuedMessages prints a line above a tall frame each time,
+// which repaints it in full.
+func BenchmarkFlushQueuedMessages(b *testing.B) {
+	frame, _ := tallFrames(5000)
+	r := benchmarkRenderer()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.handleMessages(printLineMessage{messageBody: "printed"})
+		r.write(frame)
+		r.flush()
+	}
+}
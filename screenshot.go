@@ -0,0 +1,64 @@
+package tea
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+)
+
+// RequestScreenshot is a command that captures what's on screen, according
+// to the renderer, and delivers it with a ScreenshotMsg. A frame waiting to
+// be rendered is rendered first, so the screenshot shows the latest view.
+func RequestScreenshot() Msg {
+	return requestScreenshotMsg{}
+}
+
+// requestScreenshotMsg is an internal message that captures the screen. To
+// send a requestScreenshotMsg, use the RequestScreenshot command.
+type requestScreenshotMsg struct{}
+
+func (requestScreenshotMsg) internalMsg() {}
+
+// ScreenshotMsg holds what's on screen, in response to RequestScreenshot.
+type ScreenshotMsg struct {
+	// Frame is the frame on screen, with escape sequences such as colors
+	// as they are, and without a trailing newline.
+	Frame string
+
+	// Lines are the lines of the frame.
+	Lines []string
+
+	// Width and Height are the size of the screen, or zero if it isn't
+	// known, such as when the output isn't a terminal.
+	Width  int
+	Height int
+}
+
+// Text returns the frame with escape sequences removed.
+func (m ScreenshotMsg) Text() string {
+	return ansi.Strip(m.Frame)
+}
+
+// LastFrame returns the frame the program last rendered, with escape
+// sequences as they are and without a trailing newline. A frame waiting to be
+// rendered isn't, so nothing is written to the output. It's empty before
+// anything has been rendered, and when the program has no renderer.
+func (p *Program) LastFrame() string {
+	return p.screenshot(false).Frame
+}
+
+// screenshot captures what's on screen, rendering a pending frame first if
+// render is set.
+func (p *Program) screenshot(render bool) ScreenshotMsg {
+	if p.renderer == nil {
+		return ScreenshotMsg{}
+	}
+	frame, width, height := p.renderer.currentFrame(render)
+	frame = strings.TrimSuffix(frame, "\n")
+	return ScreenshotMsg{
+		Frame:  frame,
+		Lines:  strings.Split(frame, "\n"),
+		Width:  width,
+		Height: height,
+	}
+}
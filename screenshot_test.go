@@ -0,0 +1,93 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// screenshotModel takes a screenshot of its view and quits.
+type screenshotModel struct {
+	shot ScreenshotMsg
+}
+
+func (m screenshotModel) Init() Cmd { return RequestScreenshot }
+
+func (m screenshotModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(ScreenshotMsg); ok {
+		m.shot = msg
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m screenshotModel) View() string {
+	return "\x1b[1mhello\x1b[0m\nworld\n"
+}
+
+func TestTeaScreenshot(t *testing.T) {
+	expected := ScreenshotMsg{
+		Frame: "\x1b[1mhello\x1b[0m\nworld",
+		Lines: []string{"\x1b[1mhello\x1b[0m", "world"},
+	}
+
+	t.Run("standard renderer", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(screenshotModel{}, WithInput(nil), WithOutput(&buf), WithInitialWindowSize(40, 10))
+		m, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := expected
+		expected.Width, expected.Height = 40, 10
+		shot := m.(screenshotModel).shot
+		if !reflect.DeepEqual(shot, expected) {
+			t.Errorf("expected screenshot %q, got %q", expected, shot)
+		}
+		if text := shot.Text(); text != "hello\nworld" {
+			t.Errorf("expected text %q, got %q", "hello\nworld", text)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte(expected.Lines[0])) {
+			t.Errorf("expected the screenshot to match the output, got %q", buf.String())
+		}
+		if frame := p.LastFrame(); frame != expected.Frame {
+			t.Errorf("expected the last frame to be %q, got %q", expected.Frame, frame)
+		}
+	})
+
+	t.Run("plain renderer", func(t *testing.T) {
+		m, err := NewProgram(screenshotModel{}, WithInput(nil), WithOutput(&bytes.Buffer{}), WithPlainOutput()).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shot := m.(screenshotModel).shot; !reflect.DeepEqual(shot, expected) {
+			t.Errorf("expected screenshot %q, got %q", expected, shot)
+		}
+	})
+}
+
+func TestLastFrameDoesNotRender(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	p := &Program{renderer: r}
+	r.write("first")
+	r.flush()
+	r.write("second")
+	buf.Reset()
+
+	// The pending frame is left to be rendered on the next flush.
+	if frame := p.LastFrame(); frame != "first" {
+		t.Errorf("expected the last frame to be %q, got %q", "first", frame)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %q", buf.String())
+	}
+
+	// A screenshot renders it first.
+	if shot := p.screenshot(true); shot.Frame != "second" {
+		t.Errorf("expected the screenshot to be of %q, got %q", "second", shot.Frame)
+	}
+}
@@ -0,0 +1,75 @@
+package tea
+
+import "time"
+
+// RequestScreenState is a command that reports the state of the terminal, as
+// far as the program knows it, with a ScreenStateMsg. It's meant for models
+// that hand control to others, which need to know what modes are in effect.
+func RequestScreenState() Msg {
+	return requestScreenStateMsg{}
+}
+
+// requestScreenStateMsg is an internal message that reports the state of the
+// terminal. To send a requestScreenStateMsg, use the RequestScreenState
+// command.
+type requestScreenStateMsg struct{}
+
+func (requestScreenStateMsg) internalMsg() {}
+
+// ScreenStateMsg holds the state of the terminal, in response to
+// RequestScreenState.
+type ScreenStateMsg struct {
+	// AltScreen reports whether the alternate screen buffer is in use.
+	AltScreen bool
+
+	// MouseCellMotion, MouseAllMotion and MousePixels report which mouse
+	// modes are enabled. MousePixels implies MouseAllMotion.
+	MouseCellMotion bool
+	MouseAllMotion  bool
+	MousePixels     bool
+
+	// BracketedPaste reports whether bracketed paste is enabled.
+	BracketedPaste bool
+
+	// CursorHidden reports whether the cursor is hidden.
+	CursorHidden bool
+
+	// Width and Height are the size of the screen, or zero if it isn't
+	// known, such as when the output isn't a terminal.
+	Width  int
+	Height int
+
+	// FPS is the rate at which the renderer renders frames, or zero if it
+	// doesn't render at a fixed rate.
+	FPS int
+}
+
+// screenState returns the state of the terminal, with the renderer's
+// bookkeeping and the mouse modes the program enabled.
+func (p *Program) screenState() ScreenStateMsg {
+	var state ScreenStateMsg
+	if p.renderer != nil {
+		state = p.renderer.screenState()
+	}
+	state.MouseCellMotion = p.mouseCellMotion
+	state.MouseAllMotion = p.mouseAllMotion
+	state.MousePixels = p.mousePixels
+	return state
+}
+
+func (r *standardRenderer) screenState() ScreenStateMsg {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return ScreenStateMsg{
+		AltScreen:       r.altScreenActive,
+		MouseCellMotion: r.mouseCellMotionActive,
+		MouseAllMotion:  r.mouseAllMotionActive,
+		MousePixels:     r.mousePixelsActive,
+		BracketedPaste:  r.bpActive,
+		CursorHidden:    r.cursorHidden,
+		Width:           r.width,
+		Height:          r.height,
+		FPS:             int(time.Second / r.framerate),
+	}
+}
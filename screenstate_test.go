@@ -0,0 +1,96 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+)
+
+// screenStateModel sets up the terminal with cmd and quits once it's told
+// the screen state.
+type screenStateModel struct {
+	cmd   Cmd
+	state ScreenStateMsg
+}
+
+func (m screenStateModel) Init() Cmd {
+	return Sequence(m.cmd, RequestScreenState)
+}
+
+func (m screenStateModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(ScreenStateMsg); ok {
+		m.state = msg
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m screenStateModel) View() string { return "" }
+
+func TestRequestScreenState(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      Cmd
+		opts     []ProgramOption
+		expected ScreenStateMsg
+	}{
+		{
+			name:     "default",
+			cmd:      nil,
+			expected: ScreenStateMsg{BracketedPaste: true, CursorHidden: true, FPS: defaultFPS},
+		},
+		{
+			name: "modes",
+			cmd: Sequence(
+				EnterAltScreen,
+				EnableMouseCellMotion,
+				ShowCursor,
+				DisableBracketedPaste,
+				func() Msg { return WindowSizeMsg{Width: 80, Height: 24} },
+			),
+			opts: []ProgramOption{WithFPS(30)},
+			expected: ScreenStateMsg{
+				AltScreen:       true,
+				MouseCellMotion: true,
+				Width:           80,
+				Height:          24,
+				FPS:             30,
+			},
+		},
+		{
+			name:     "mouse startup option",
+			opts:     []ProgramOption{WithMouseAllMotion()},
+			expected: ScreenStateMsg{MouseAllMotion: true, BracketedPaste: true, CursorHidden: true, FPS: defaultFPS},
+		},
+		{
+			name:     "mouse disabled",
+			cmd:      DisableMouse,
+			opts:     []ProgramOption{WithMouseAllMotion()},
+			expected: ScreenStateMsg{BracketedPaste: true, CursorHidden: true, FPS: defaultFPS},
+		},
+		{
+			name: "pixel mouse",
+			cmd:  EnableMousePixelMotion,
+			expected: ScreenStateMsg{
+				MouseAllMotion: true,
+				MousePixels:    true,
+				BracketedPaste: true,
+				CursorHidden:   true,
+				FPS:            defaultFPS,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var in, out bytes.Buffer
+			opts := append([]ProgramOption{WithInput(&in), WithOutput(&out)}, tc.opts...)
+			m, err := NewProgram(screenStateModel{cmd: tc.cmd}, opts...).Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state := m.(screenStateModel).state; state != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, state)
+			}
+		})
+	}
+}
@@ -21,13 +21,5 @@ func (p *Program) listenForResize(done chan struct{}) {
 		close(done)
 	}()
 
-	for {
-		select {
-		case <-p.ctx.Done():
-			return
-		case <-sig:
-		}
-
-		p.checkResize()
-	}
+	debounceResize(p.ctx, sig, p.resizeDebounce, p.checkResize)
 }
@@ -4,13 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/muesli/ansi/compressor"
-	"github.com/muesli/reflow/truncate"
 	"github.com/muesli/termenv"
 )
 
@@ -30,31 +29,166 @@ type standardRenderer struct {
 	mtx *sync.Mutex
 	out *termenv.Output
 
+	// w writes to the terminal, and stops once a write fails.
+	w *failingWriter
+
+	// profile is the color profile of the terminal. Colors in frames are
+	// downgraded to ones it supports.
+	profile termenv.Profile
+
+	// transforms are applied to each frame before it's rendered, and
+	// onTransformError is called when one panics.
+	transforms       []func(string) string
+	onTransformError func(error)
+
+	// droppedLines is how many lines at the top of the last frame didn't
+	// fit on screen, and onTruncate is called when that changes.
+	droppedLines int
+	onTruncate   func(ViewTruncatedMsg)
+
+	// cursorSequence is the first sequence moving the cursor that was
+	// removed from the last frame, if any, and onCursorSequence is called
+	// when that changes.
+	cursorSequence   CursorSequenceMsg
+	onCursorSequence func(CursorSequenceMsg)
+
+	// bottomAnchor keeps the last line of the view in place when its height
+	// changes, and blankAbove is how many rows above it were cleared when
+	// it shrank, which it can grow into again.
+	bottomAnchor bool
+	blankAbove   int
+
+	// keepFinalFrame leaves the final frame as it is when the renderer
+	// stops, rather than clearing the line the cursor is on.
+	keepFinalFrame bool
+
+	// clearOnQuit clears the lines the view takes up when the renderer
+	// stops, leaving the terminal as it was before.
+	clearOnQuit bool
+
+	// compressorThreshold is the size in bytes a flush has to exceed to have
+	// redundant ANSI sequences removed, if set.
+	compressorThreshold int
+
 	buf                bytes.Buffer
 	queuedMessageLines []string
+	footerLines        []string
 	framerate          time.Duration
-	ticker             *time.Ticker
-	done               chan struct{}
 	lastRender         string
+	lastView           viewFrame
+	lastLines          []string
+	spareLines         []string
 	linesRendered      int
 	useANSICompressor  bool
-	once               sync.Once
+
+	// whether the renderer is running, and the channel closed to stop it,
+	// along with the one its goroutine closes once it has, which are made
+	// anew each time it starts
+	running  bool
+	done     chan struct{}
+	listened chan struct{}
 
 	// cursor visibility state
 	cursorHidden bool
 
+	// whether the cursor is saved and restored with CSI s and CSI u rather
+	// than ESC 7 and ESC 8, and the line it was saved on
+	scoCursorSave      bool
+	savedRenderingHead int
+
+	// the cell the cursor is placed in, as marked in the last frame written
+	// and the last one rendered, and whether it was shown for that
+	frameCursor cursorCell
+	cursor      cursorCell
+	cursorShown bool
+
+	// linkFallback renders hyperlinks without their OSC 8 sequences, for
+	// terminals that don't support them, keeping track of where they are
+	// in the frame written and the one rendered so clicks on them can be
+	// reported
+	linkFallback bool
+	frameLinks   []linkArea
+	links        []linkArea
+
+	// the rows covered by inline images in the frame written and the one
+	// rendered, and a hash of the images each line rendered draws, so that
+	// images already on screen aren't drawn again
+	frameImageRegions []imageRegion
+	imageRegions      []imageRegion
+	imageHashes       []uint64
+
+	// the regions of the view graphics are drawn over, which lines are
+	// painted around, and what to call when the graphics are lost
+	graphicsRegions []graphicsRegion
+	onRegionDamaged func(RegionDamagedMsg)
+
+	// what to call with the frames dropped, if they're reported, and what
+	// they're tracked with
+	onDroppedFrames func(DroppedFramesMsg)
+	pacing          framePacing
+
 	// essentially whether or not we're using the full size of the terminal
 	altScreenActive bool
 
+	// the bookkeeping of the inline view from before entering the alt
+	// screen, which is restored along with the cursor when exiting it
+	inlineLinesRendered int
+	inlineRenderingHead int
+	inlineBlankAbove    int
+	inlineAreaTop       int
+	inlineAreaTopKnown  bool
+
+	// the row of the screen, from 0, the top of the inline view's area is
+	// on, if we know it from asking the terminal where the cursor is, and
+	// how far below it the cursor was when we last asked. viewPosition is
+	// whether we ask.
+	viewPosition bool
+	areaTop      int
+	areaTopKnown bool
+	positionRow  int
+
+	// restoreInlineFrame repaints the frame on screen before entering the
+	// alt screen right after exiting it, as terminals without an alt screen
+	// have cleared it by then
+	restoreInlineFrame bool
+	inlineFrame        string
+	inlineCursor       cursorCell
+
 	// whether or not we're currently using bracketed paste
 	bpActive bool
 
+	// whether the taskbar progress bar is set
+	progressActive bool
+
+	// whether or not we've asked the terminal to report key releases
+	krActive bool
+
+	// the mouse modes we've enabled, which are the only ones disabled again,
+	// as some terminals complain about modes they don't know
+	mouseCellMotionActive bool
+	mouseAllMotionActive  bool
+	mouseSGRActive        bool
+
+	// whether or not mouse events are reported in pixels
+	mousePixelsActive bool
+
+	// whether or not keys are reported in win32-input-mode
+	w32Active bool
+
 	// renderer dimensions; usually the size of the window
 	width  int
 	height int
 
-	// lines explicitly set not to render
-	ignoreLines map[int]struct{}
+	// measures how many cells a rune takes up when truncating lines
+	widthCond *runewidth.Condition
+
+	// how many columns apart tab stops are when expanding tabs
+	tabWidth int
+
+	// lines explicitly set not to render, and what to call when they're no
+	// longer set as the height changed
+	ignoreLines             map[int]struct{}
+	onScrollAreaInvalidated func()
 
 	// buffer of which lines to skip in the current render,
 	// which is reused between renders as a performance optimization
@@ -66,49 +200,135 @@ type standardRenderer struct {
 
 // newRenderer creates a new renderer. Normally you'll want to initialize it
 // with os.Stdout as the first argument.
-func newRenderer(out *termenv.Output, useANSICompressor bool, fps int) renderer {
+func newRenderer(out *termenv.Output, useANSICompressor bool, fps int, eastAsianWidth bool) renderer {
 	if fps < 1 {
 		fps = defaultFPS
 	} else if fps > maxFPS {
 		fps = maxFPS
 	}
 	r := &standardRenderer{
-		out:                out,
+		w:                  &failingWriter{forward: out},
+		profile:            out.Profile,
 		mtx:                &sync.Mutex{},
-		done:               make(chan struct{}),
 		framerate:          time.Second / time.Duration(fps),
 		useANSICompressor:  useANSICompressor,
 		queuedMessageLines: []string{},
+		widthCond:          newWidthCondition(eastAsianWidth),
+		tabWidth:           defaultTabWidth,
 	}
 	if r.useANSICompressor {
-		r.out = termenv.NewOutput(&compressor.Writer{Forward: out})
+		r.out = r.newOutput(&compressor.Writer{Forward: r.w})
+	} else {
+		r.out = r.newOutput(r.w)
 	}
 	return r
 }
 
-// start starts the renderer.
+// newOutput returns an output writing to w with the terminal's color profile.
+func (r *standardRenderer) newOutput(w io.Writer) *termenv.Output {
+	return termenv.NewOutput(w, termenv.WithProfile(r.profile))
+}
+
+// onWriteError sets a function to call when writing to the terminal fails.
+func (r *standardRenderer) onWriteError(fn func(error)) {
+	r.w.mtx.Lock()
+	defer r.w.mtx.Unlock()
+	r.w.onError = fn
+}
+
+// start starts the renderer, unless it's running. It can be started again
+// after it's stopped.
 func (r *standardRenderer) start() {
-	if r.ticker == nil {
-		r.ticker = time.NewTicker(r.framerate)
-	} else {
-		// If the ticker already exists, it has been stopped and we need to
-		// reset it.
-		r.ticker.Reset(r.framerate)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.running {
+		return
+	}
+	r.running = true
+	r.done = make(chan struct{})
+	r.listened = make(chan struct{})
+
+	// Ticks missed while it was stopped weren't dropped.
+	r.pacing = framePacing{}
+
+	go r.listen(time.NewTicker(r.framerate), r.done, r.listened)
+}
+
+// stopListening stops the renderer's goroutine, if it's running, and waits
+// for it to finish the flush it might be in the middle of.
+func (r *standardRenderer) stopListening() {
+	r.mtx.Lock()
+	if !r.running {
+		r.mtx.Unlock()
+		return
 	}
+	r.running = false
+	close(r.done)
+	listened := r.listened
+	r.mtx.Unlock()
+
+	// The goroutine might be waiting for the mutex to flush, so it's waited
+	// for without it.
+	<-listened
+}
+
+// pause stops the renderer from flushing while a program sharing its output
+// draws to the terminal, until resume is called.
+func (r *standardRenderer) pause() {
+	r.stopListening()
+}
 
-	// Since the renderer can be restarted after a stop, we need to reset
-	// the done channel and its corresponding sync.Once.
-	r.once = sync.Once{}
+// resume picks up where pause left off, once the program sharing the output
+// has exited. That program drew over the view and may have turned off modes
+// the renderer had on, so they're turned on again and the view is painted
+// from scratch.
+func (r *standardRenderer) resume() {
+	r.mtx.Lock()
+	if r.mouseCellMotionActive {
+		r.out.EnableMouseCellMotion()
+	}
+	if r.mouseAllMotionActive {
+		r.out.EnableMouseAllMotion()
+	}
+	if r.mouseSGRActive {
+		_, _ = r.out.WriteString(termenv.CSI + enableMouseURXVTModeSeq)
+		r.out.EnableMouseExtendedMode()
+	}
+	if r.mousePixelsActive {
+		r.out.EnableMousePixelsMode()
+	}
+	if r.bpActive {
+		r.out.EnableBracketedPaste()
+	}
+	if r.cursorHidden {
+		r.out.HideCursor()
+	} else {
+		r.out.ShowCursor()
+	}
+	if r.altScreenActive {
+		r.out.ClearScreen()
+		r.out.MoveCursor(1, 1)
+		r.blankAbove = 0
+		r.linesRendered = 0
+		r.renderingHead = 0
+	}
+	frame := r.lastRender
+	r.damageGraphics()
+	r.repaint()
+	if r.buf.Len() == 0 {
+		// Nothing new was written while paused, so paint the last frame.
+		r.buf.WriteString(frame)
+	}
+	r.mtx.Unlock()
 
-	go r.listen()
+	r.start()
 }
 
-// stop permanently halts the renderer, rendering the final frame.
+// stop halts the renderer, rendering the final frame. It's safe to call
+// whether or not the renderer was started, or has been stopped already.
 func (r *standardRenderer) stop() {
-	// Stop the renderer before acquiring the mutex to avoid a deadlock.
-	r.once.Do(func() {
-		r.done <- struct{}{}
-	})
+	r.stopListening()
 
 	// flush locks the mutex
 	r.flush()
@@ -116,7 +336,30 @@ func (r *standardRenderer) stop() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	r.out.ClearLine()
+	// Print anything that didn't make it into the last frame.
+	r.printQueuedMessages()
+
+	// What follows expects the cursor at the start of the last line.
+	if r.cursor.set {
+		r.parkCursor(r.out)
+	}
+
+	// Lines printed below the view stay, like the ones printed above it.
+	footer := len(r.footerLines) > 0 && !r.altScreenActive
+
+	switch {
+	case r.clearOnQuit && !r.altScreenActive:
+		r.clearView()
+		for _, line := range r.footerLines {
+			_, _ = r.out.WriteString(line)
+			_, _ = r.out.WriteString("\r\n")
+		}
+	case !r.keepFinalFrame && !footer:
+		r.out.ClearLine()
+	case !r.altScreenActive && len(r.lastLines) > 0 && r.lastLines[len(r.lastLines)-1] != "":
+		// Start whatever comes next on a line of its own.
+		_, _ = r.out.WriteString("\r\n")
+	}
 
 	if r.useANSICompressor {
 		if w, ok := r.out.TTY().(io.WriteCloser); ok {
@@ -125,12 +368,29 @@ func (r *standardRenderer) stop() {
 	}
 }
 
+// clearView clears the lines the view takes up, and any blank rows above it,
+// leaving the cursor where the first frame started.
+func (r *standardRenderer) clearView() {
+	r.out.CursorBack(r.width)
+	for i := r.renderingHead; i > 0; i-- {
+		r.out.ClearLine()
+		r.out.CursorUp(1)
+	}
+	r.out.ClearLine()
+	if r.blankAbove > 0 {
+		r.out.CursorUp(r.blankAbove)
+	}
+
+	r.linesRendered = 0
+	r.renderingHead = 0
+	r.blankAbove = 0
+	r.lastLines = nil
+	r.lastRender = ""
+}
+
 // kill halts the renderer. The final frame will not be rendered.
 func (r *standardRenderer) kill() {
-	// Stop the renderer before acquiring the mutex to avoid a deadlock.
-	r.once.Do(func() {
-		r.done <- struct{}{}
-	})
+	r.stopListening()
 
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -138,16 +398,30 @@ func (r *standardRenderer) kill() {
 	r.out.ClearLine()
 }
 
-// listen waits for ticks on the ticker, or a signal to stop the renderer.
-func (r *standardRenderer) listen() {
+// listen waits for ticks on the ticker, flushing on each, until done is
+// closed, and then closes listened.
+func (r *standardRenderer) listen(ticker *time.Ticker, done, listened chan struct{}) {
+	defer close(listened)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-r.done:
-			r.ticker.Stop()
+		case <-done:
 			return
 
-		case <-r.ticker.C:
+		case tick := <-ticker.C:
 			r.flush()
+
+			if r.onDroppedFrames != nil {
+				r.mtx.Lock()
+				r.trackTick(tick)
+				r.mtx.Unlock()
+			}
+
+			// Stop trying once the terminal can't be written to.
+			if r.w.failed() {
+				ticker.Stop()
+			}
 		}
 	}
 }
@@ -156,109 +430,174 @@ func (r *standardRenderer) listen() {
 func (r *standardRenderer) flush() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
+	r.render()
+}
 
-	if r.buf.Len() == 0 || r.buf.String() == r.lastRender {
+// render does what flush does, with the mutex held.
+func (r *standardRenderer) render() {
+	// Comparing the converted bytes doesn't copy them.
+	if r.buf.Len() == 0 || string(r.buf.Bytes()) == r.lastRender && r.frameCursor == r.cursor {
 		// Nothing to do
 		return
 	}
 
 	// Output buffer
 	buf := &bytes.Buffer{}
-	out := termenv.NewOutput(buf)
-
-	newLines := strings.Split(r.buf.String(), "\n")
+	out := r.newOutput(buf)
+
+	frame := r.buf.String()
+	newLines := r.splitLines(downgradeColors(frame, r.profile))
+	r.links = r.frameLinks
+	r.imageRegions = r.frameImageRegions
+
+	// Lines printed below the view are rendered along with it, in place of
+	// the empty line most views end with.
+	if len(r.footerLines) > 0 && !r.altScreenActive {
+		if last := len(newLines) - 1; last > 0 && newLines[last] == "" {
+			newLines = newLines[:last]
+		}
+		newLines = append(newLines, r.footerLines...)
+	}
 
 	// If we know the output's height, we can use it to determine how many
 	// lines we can render. We drop lines from the top of the render buffer if
 	// necessary, as we can't navigate the cursor into the terminal's scrollback
 	// buffer.
+	dropped := 0
 	if r.height > 0 && len(newLines) > r.height {
+		dropped = len(newLines) - r.height
 		newLines = newLines[len(newLines)-r.height:]
 	}
+	if dropped != r.droppedLines {
+		r.droppedLines = dropped
+		if r.onTruncate != nil {
+			r.onTruncate(ViewTruncatedMsg{RenderedLines: len(newLines), DroppedLines: dropped})
+		}
+	}
 
 	numLinesThisFlush := len(newLines)
-	oldLines := strings.Split(r.lastRender, "\n")
-	// reset the skipLines buffer to the correct size
-	if len(r.skipLines) < numLinesThisFlush {
-		if cap(r.skipLines) < numLinesThisFlush {
-			r.skipLines = make([]bool, numLinesThisFlush)
-		} else {
-			// You can safely resize a slice to a larger capcity of its length
-			// See: https://go.dev/tour/moretypes/11
-			r.skipLines = r.skipLines[:numLinesThisFlush]
+	oldLines := r.lastLines
+
+	// Inline images are hashed line by line, to tell whether they're on
+	// screen already.
+	var imageHashes []uint64
+	if hasImages(frame) {
+		imageHashes = make([]uint64, numLinesThisFlush)
+		for i, line := range newLines {
+			imageHashes[i] = imageHash(line)
 		}
 	}
+
+	// Reset the skipLines buffer to cover both the old and the new lines.
+	numLines := numLinesThisFlush
+	if r.linesRendered > numLines {
+		numLines = r.linesRendered
+	}
+	if cap(r.skipLines) < numLines {
+		r.skipLines = make([]bool, numLines)
+	} else {
+		// You can safely resize a slice to a larger capcity of its length
+		// See: https://go.dev/tour/moretypes/11
+		r.skipLines = r.skipLines[:numLines]
+	}
 	for i := 0; i < len(r.skipLines); i++ {
 		r.skipLines[i] = false
 	}
 
 	flushQueuedMessages := len(r.queuedMessageLines) > 0 && !r.altScreenActive
 
-	// TODO remove printf
-	fmt.Fprintln(os.Stderr, "--------- start new render:", "r.linesRendered=", r.linesRendered, "flushQueuedMessages=", flushQueuedMessages, "r.renderingHead=", r.renderingHead, "numLinesThisFlush=", numLinesThisFlush, "---------")
+	if r.bottomAnchor && !r.altScreenActive && !flushQueuedMessages && len(r.ignoreLines) == 0 &&
+		len(r.graphicsRegions) == 0 && r.linesRendered > 0 && numLinesThisFlush != r.linesRendered {
+		r.paintAnchored(out, newLines)
+		r.trackScrolling()
+		r.imageHashes = imageHashes
+		r.cursor = r.frameCursor
+		r.placeCursor(out)
+		r.writeFlush(buf.Bytes())
+		r.lastRender = frame
+		r.buf.Reset()
+		return
+	}
+
+	if !flushQueuedMessages && len(r.ignoreLines) == 0 && len(r.graphicsRegions) == 0 && r.flushAppend(out, newLines) {
+		r.imageHashes = imageHashes
+		r.writeFlush(buf.Bytes())
+		r.lastRender = frame
+		r.buf.Reset()
+		return
+	}
+
+	// Rows covered by images that are still on screen are left alone, unless
+	// the view is pushed down by printed lines, which leaves graphics behind.
+	if !flushQueuedMessages {
+		r.coveredRows(r.skipLines, imageHashes)
+	} else {
+		r.damageGraphics()
+	}
 
-	// Find all the lines we want to skip
-	var highestRenderedLine = 0;
-	if r.linesRendered > 0 && !flushQueuedMessages {
+	// Find all the lines we want to skip, and the highest line we need to
+	// clear. The first line is always rendered.
+	highestRenderedLine := 0
+	if r.linesRendered > 0 {
 		for i := r.linesRendered - 1; i > 0; i-- {
-			if (len(newLines) > i && len(oldLines) > i) && (newLines[i] == oldLines[i]) {
+			if r.skipLines[i] {
+				continue
+			}
+			if !flushQueuedMessages && len(newLines) > i && len(oldLines) > i && newLines[i] == oldLines[i] {
 				// If the number of lines we want to render hasn't increased and
 				// new line is the same as the old line we can skip rendering for
 				// this line as a performance optimization.
 				r.skipLines[i] = true
-			} else if _, shouldIgnore := r.ignoreLines[i]; shouldIgnore {
+			} else if _, shouldIgnore := r.ignoreLines[i]; shouldIgnore && !flushQueuedMessages {
 				r.skipLines[i] = true
-			} else {
+			} else if highestRenderedLine == 0 {
 				highestRenderedLine = i
 			}
 		}
 	}
-	// Actually erase the lines we want to skip, tracking the relative position of
-	// the cursor in the write-buffer over time to avoid unnecessary cursor movement.
+
+	// If we have rendered anyting previously, we need to clear the lines that
+	// were not skipped, tracking the relative position of the cursor in the
+	// write-buffer over time to avoid unnecessary cursor movement.
 	//
 	// This is because unecessary cursor movement can cause "flickering" in the
 	// terminal, where the cursor jumps around as the terminal is being updated.
-	fmt.Fprintln(os.Stderr, "skipLines", r.skipLines)
-	fmt.Fprintln(os.Stderr, "highestReneredLine", r.skipLines)
-	fmt.Fprintln(os.Stderr, "skipping")
-
-	// If we have rendered anyting previously, we need to clear the lines that
-	// were not skipped
 	if r.linesRendered > 0 {
 		// The rendering head is the index of the cursor in the current render.
 		// If we have more lines to render, we need to move the cursor down to
 		// the line we want to replace.
 		if r.renderingHead < highestRenderedLine {
-			fmt.Fprintln(os.Stderr, "  jump down to line", highestRenderedLine, "delta=", highestRenderedLine-r.renderingHead)
-			out.CursorDown(highestRenderedLine - r.renderingHead)
+			r.moveRenderingHead(out, highestRenderedLine)
 		}
-		r.renderingHead = highestRenderedLine
 
-		// iterate backwards, starting from the last rendered line
+		// iterate backwards, starting from the highest line to clear
 		for i := highestRenderedLine; i >= 0; i-- {
 			if !r.skipLines[i] {
-				// jump to this position and clear it
-				fmt.Fprintln(os.Stderr, "  up + clear to line ", i, "delta", r.renderingHead-i, "newLine", newLines[i])
-				out.CursorUp(r.renderingHead - i)
+				// jump to this position and clear it, unless that would
+				// erase images we're keeping
+				r.moveRenderingHead(out, i)
 				out.CursorBack(r.width)
-				out.ClearLine()
-				r.renderingHead = i
+				if !r.imageOnScreen(i, imageHashes, flushQueuedMessages) &&
+					(flushQueuedMessages || len(r.graphicsSpans(i)) == 0) {
+					out.ClearLine()
+				}
 			}
 		}
 	}
 
 	if flushQueuedMessages {
-		// Dump the lines we've queued up for printing
+		// Dump the lines we've queued up for printing, which pushes the
+		// view down.
 		for _, line := range r.queuedMessageLines {
-			_, _ = out.WriteString(line)
+			_, _ = out.WriteString(downgradeColors(line, r.profile))
 			_, _ = out.WriteString("\r\n")
 		}
+		r.areaTop += len(r.queuedMessageLines)
 		// clear the queued message lines
 		r.queuedMessageLines = []string{}
 	}
 
 	// Paint new lines, starting at the current position of the rendering head
-	fmt.Fprintln(os.Stderr, "painting")
 	for i := r.renderingHead; i < numLinesThisFlush; i++ {
 		if skip := r.skipLines[i]; !skip {
 			line := newLines[i]
@@ -271,18 +610,39 @@ func (r *standardRenderer) flush() {
 			// program initialization, so after a resize this won't perform
 			// correctly (signal SIGWINCH is not supported on Windows).
 			if r.width > 0 {
-				line = truncate.String(line, uint(r.width))
+				line = truncateLine(line, r.width, r.widthCond)
 			}
 
-			// move the rendering head down to the current line
-			if r.renderingHead != i {
-				fmt.Fprintln(os.Stderr, "skip down:", "renderingHead", r.renderingHead, "i", i, "down:", i-r.renderingHead)
-				out.CursorDown(i - r.renderingHead)
-				r.renderingHead = i
+			// Images already on screen aren't drawn again, and as the line
+			// wasn't cleared to keep them, its old text is cleared after
+			// the new.
+			keepImages := r.imageOnScreen(i, imageHashes, flushQueuedMessages)
+			if keepImages {
+				line = stripImages(line)
 			}
 
-			fmt.Fprintln(os.Stderr, "render:", "renderingHead", r.renderingHead, "i", i, " |||", line)
-			_, _ = out.WriteString(line)
+			// move the rendering head down to the current line. Lines below
+			// the ones rendered before don't exist yet, so they're added
+			// with newlines, as the cursor can't move into them.
+			if last := r.linesRendered - 1; r.renderingHead < i && r.renderingHead < last {
+				to := i
+				if to > last {
+					to = last
+				}
+				r.moveRenderingHead(out, to)
+			}
+			for ; r.renderingHead < i; r.renderingHead++ {
+				_, _ = out.WriteString("\r\n")
+			}
+
+			if spans := r.graphicsSpans(i); len(spans) > 0 && !flushQueuedMessages {
+				r.paintAround(out, line, spans)
+			} else {
+				_, _ = out.WriteString(line)
+				if keepImages {
+					out.ClearLineRight()
+				}
+			}
 			if i < numLinesThisFlush-1 {
 				_, _ = out.WriteString("\r\n")
 				r.renderingHead++
@@ -290,21 +650,197 @@ func (r *standardRenderer) flush() {
 		}
 	}
 	r.linesRendered = numLinesThisFlush
+	r.spareLines, r.lastLines = r.lastLines, newLines
+	r.imageHashes = imageHashes
+	r.trackScrolling()
+	r.drawGraphics(out)
+
+	r.cursor = r.frameCursor
+	r.placeCursor(out)
+
+	r.writeFlush(buf.Bytes())
+	r.lastRender = frame
+	r.buf.Reset()
+}
 
-	// Make sure the cursor is at the start of the last line to keep rendering
-	// behavior consistent.
+// printQueuedMessages prints the lines queued with Println and Printf above
+// the view right away, along with the current frame, rather than on the next
+// flush, for when there might not be one in the main screen.
+func (r *standardRenderer) printQueuedMessages() {
+	if len(r.queuedMessageLines) == 0 || r.altScreenActive {
+		return
+	}
+	if r.buf.Len() == 0 {
+		frame := r.lastView.frame
+		if frame == "" {
+			frame = " "
+		}
+		_, _ = r.buf.WriteString(frame)
+	}
+	r.repaint()
+	r.render()
+}
+
+// parkCursor moves the cursor to the start of the last line of the view,
+// where each flush leaves it to keep rendering behavior consistent.
+func (r *standardRenderer) parkCursor(out *termenv.Output) {
 	if r.altScreenActive {
 		// This case fixes a bug in macOS terminal. In other terminals the
 		// other case seems to do the job regardless of whether or not we're
 		// using the full terminal window.
 		out.MoveCursor(r.linesRendered, 0)
 	} else {
+		if last := r.linesRendered - 1; r.renderingHead < last {
+			out.CursorDown(last - r.renderingHead)
+		}
 		out.CursorBack(r.width)
 	}
+	r.renderingHead = r.linesRendered - 1
+}
 
-	_, _ = r.out.Write(buf.Bytes())
-	r.lastRender = r.buf.String()
-	r.buf.Reset()
+// flushAppend renders a frame that only differs from the last one in text
+// appended to the line the cursor is on, as when typing into an input, by
+// writing just that text. It reports whether the frame was such a frame.
+func (r *standardRenderer) flushAppend(out *termenv.Output, newLines []string) bool {
+	i := r.renderingHead
+	if i < 0 || len(newLines) != r.linesRendered || len(r.lastLines) != len(newLines) {
+		return false
+	}
+	for j := range newLines {
+		if j != i && newLines[j] != r.lastLines[j] {
+			return false
+		}
+	}
+
+	old, line := r.lastLines[i], newLines[i]
+	if r.width > 0 {
+		line = truncateLine(line, r.width, r.widthCond)
+	}
+	if len(line) <= len(old) || !strings.HasPrefix(line, old) {
+		return false
+	}
+
+	// Move past the text that's there, and pick up its style where it left
+	// off.
+	if r.cursor.set {
+		out.CursorBack(r.width)
+	}
+	if w := stringWidth(old, r.widthCond); w > 0 {
+		out.CursorForward(w)
+	}
+	_, _ = out.WriteString(activeStyle(old))
+	_, _ = out.WriteString(line[len(old):])
+
+	r.cursor = r.frameCursor
+	r.placeCursor(out)
+	r.spareLines, r.lastLines = r.lastLines, newLines
+	return true
+}
+
+// writeFlush writes what a flush rendered to the terminal, removing redundant
+// ANSI sequences first if it's larger than the compressor threshold. Each
+// flush is compressed on its own, so no state carries over between them.
+func (r *standardRenderer) writeFlush(b []byte) {
+	if r.compressorThreshold > 0 && len(b) > r.compressorThreshold {
+		b = compressor.Bytes(b)
+	}
+	if r.onDroppedFrames == nil {
+		_, _ = r.out.Write(b)
+		return
+	}
+
+	start := time.Now()
+	_, _ = r.out.Write(b)
+	if d := time.Since(start); d > r.pacing.maxWriteLatency {
+		r.pacing.maxWriteLatency = d
+	}
+}
+
+// splitLines splits a frame into its lines, which share its memory. The
+// slice they're kept in reuses the one the lines of the frame before the last
+// were kept in, which aren't needed anymore.
+func (r *standardRenderer) splitLines(frame string) []string {
+	lines := r.spareLines[:0]
+	for {
+		i := strings.IndexByte(frame, '\n')
+		if i < 0 {
+			return append(lines, frame)
+		}
+		lines = append(lines, frame[:i])
+		frame = frame[i+1:]
+	}
+}
+
+// absoluteMoveThreshold is how many lines the rendering head has to move in
+// the alt screen before the cursor is moved to the line directly, with a
+// single sequence, rather than relative to where it is.
+const absoluteMoveThreshold = 4
+
+// moveRenderingHead moves the cursor from the start of the line the rendering
+// head is on to the start of the given line of the view. In the alt screen,
+// where the view starts at the top of the screen, longer moves address the
+// line's row directly, which also puts the cursor back where we think it is
+// should anything have moved it.
+func (r *standardRenderer) moveRenderingHead(out *termenv.Output, to int) {
+	delta := to - r.renderingHead
+	switch {
+	case delta == 0:
+	case r.altScreenActive && (delta > absoluteMoveThreshold || -delta > absoluteMoveThreshold):
+		out.MoveCursor(to+1, 1)
+	case delta > 0:
+		out.CursorDown(delta)
+	default:
+		out.CursorUp(-delta)
+	}
+	r.renderingHead = to
+}
+
+// paintAnchored repaints the area of the screen the view takes up, and the
+// blank rows above it, placing a view of a different height so that its last
+// line stays on the same row. If it's taller than the area, the terminal is
+// scrolled to make room, as usual.
+func (r *standardRenderer) paintAnchored(out *termenv.Output, newLines []string) {
+	rows := r.blankAbove + r.linesRendered
+	blank := rows - len(newLines)
+	if blank < 0 {
+		blank = 0
+	}
+
+	// Move to the top of the area.
+	if up := r.blankAbove + r.renderingHead; up > 0 {
+		out.CursorUp(up)
+	}
+	out.CursorBack(r.width)
+
+	for row := 0; row < blank+len(newLines); row++ {
+		if row > 0 {
+			_, _ = out.WriteString("\r\n")
+		}
+		out.ClearLine()
+		if row < blank {
+			continue
+		}
+		line := newLines[row-blank]
+		if r.width > 0 {
+			line = truncateLine(line, r.width, r.widthCond)
+		}
+		_, _ = out.WriteString(line)
+	}
+
+	r.blankAbove = blank
+	r.linesRendered = len(newLines)
+	r.renderingHead = r.linesRendered - 1
+	r.spareLines, r.lastLines = r.lastLines, newLines
+}
+
+// viewFrame is a view along with everything made from it when it was last
+// written: the frame, and the cursor, links and image regions marked in it.
+type viewFrame struct {
+	view         string
+	frame        string
+	cursor       cursorCell
+	links        []linkArea
+	imageRegions []imageRegion
 }
 
 // write writes to the internal buffer. The buffer will be outputted via the
@@ -322,20 +858,117 @@ func (r *standardRenderer) write(s string) {
 		s = " "
 	}
 
+	// Views often don't change between updates, and then neither does the
+	// frame made from them.
+	if last := r.lastView; s == last.view {
+		_, _ = r.buf.WriteString(last.frame)
+		r.frameCursor = last.cursor
+		r.frameLinks = last.links
+		r.frameImageRegions = last.imageRegions
+		return
+	}
+	view := s
+
+	s = normalizeFrame(s, r.tabWidth, r.widthCond)
+
+	// Sequences that move the cursor would throw off our idea of where it
+	// is, so they're removed. They're reported when they change, as the
+	// report leads to another frame with the same ones.
+	var found *CursorSequenceMsg
+	s, found = stripCursorSequences(s)
+	if found == nil {
+		r.cursorSequence = CursorSequenceMsg{}
+	} else if *found != r.cursorSequence {
+		r.cursorSequence = *found
+		if r.onCursorSequence != nil {
+			r.onCursorSequence(*found)
+		}
+	}
+
+	// Transform the frame before it's stored, so it's compared with the
+	// last one as it's rendered.
+	if len(r.transforms) > 0 {
+		var err error
+		s, err = transformFrame(s, r.transforms)
+		if err != nil && r.onTransformError != nil {
+			r.onTransformError(err)
+		}
+	}
+
+	// The rows images cover, and the cell the cursor is placed in, are
+	// marked in the frame.
+	s, r.frameImageRegions = extractImageRows(s)
+	s, r.frameCursor = extractCursor(s, r.widthCond)
+
+	// Links are made clickable instead, when the terminal can't show them.
+	r.frameLinks = nil
+	if r.linkFallback {
+		s, r.frameLinks = extractLinks(s, r.widthCond)
+	}
+
+	r.lastView = viewFrame{
+		view:         view,
+		frame:        s,
+		cursor:       r.frameCursor,
+		links:        r.frameLinks,
+		imageRegions: r.frameImageRegions,
+	}
 	_, _ = r.buf.WriteString(s)
 }
 
 func (r *standardRenderer) repaint() {
 	r.lastRender = ""
+	r.lastLines = nil
+	// The frame is kept for printQueuedMessages, but the next view is made
+	// into one again. Views are never empty, so none matches this one.
+	r.lastView.view = ""
+	r.imageHashes = nil
+}
+
+// batch runs fn, holding back what it writes to the terminal and then
+// writing it all at once, so that the terminal gets it in one go.
+func (r *standardRenderer) batch(fn func()) {
+	var buf bytes.Buffer
+	r.mtx.Lock()
+	out := r.out
+	r.out = r.newOutput(&buf)
+	r.mtx.Unlock()
+
+	fn()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.out = out
+	if buf.Len() > 0 {
+		_, _ = r.out.Write(buf.Bytes())
+	}
 }
 
 func (r *standardRenderer) clearScreen() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	r.out.ClearScreen()
-	r.out.MoveCursor(1, 1)
+	if r.altScreenActive {
+		r.out.ClearScreen()
+		r.out.MoveCursor(1, 1)
+	} else {
+		// Erase the screen without moving the cursor to the top of the
+		// terminal, as ClearScreen does, and go back to the top of the view
+		// instead, so the view is painted where it was.
+		fmt.Fprintf(r.out, termenv.CSI+termenv.EraseDisplaySeq, 2) //nolint:gomnd
+		r.out.CursorBack(r.width)
+		if up := r.renderingHead + r.blankAbove; up > 0 {
+			r.out.CursorUp(up)
+		}
+	}
 
+	// Nothing we rendered is on screen anymore, so the next frame is painted
+	// from scratch.
+	r.linesRendered = 0
+	r.renderingHead = 0
+	r.blankAbove = 0
+	r.cursor = cursorCell{}
+	r.damageGraphics()
 	r.repaint()
 }
 
@@ -346,6 +979,13 @@ func (r *standardRenderer) altScreen() bool {
 	return r.altScreenActive
 }
 
+// Sequences that save and restore the cursor (DECSC and DECRC), which we use
+// around the alt screen, as not every terminal restores it on exit.
+const (
+	saveCursorSeq    = "\x1b7"
+	restoreCursorSeq = "\x1b8"
+)
+
 func (r *standardRenderer) enterAltScreen() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -354,8 +994,25 @@ func (r *standardRenderer) enterAltScreen() {
 		return
 	}
 
+	// Lines printed with Println and Printf go to the main screen's
+	// scrollback, so they're printed before leaving it.
+	r.printQueuedMessages()
+
+	// Save the cursor, which the terminal doesn't always do for us, along
+	// with where the inline view is relative to it.
+	r.inlineLinesRendered = r.linesRendered
+	r.inlineRenderingHead = r.renderingHead
+	r.inlineBlankAbove = r.blankAbove
+	r.inlineAreaTop, r.inlineAreaTopKnown = r.areaTop, r.areaTopKnown
+	r.inlineFrame = r.lastRender
+	r.inlineCursor = r.cursor
+	_, _ = r.out.WriteString(saveCursorSeq)
+
 	r.altScreenActive = true
 	r.out.AltScreen()
+	r.blankAbove = 0
+	r.linesRendered = 0
+	r.renderingHead = 0
 
 	// Ensure that the terminal is cleared, even when it doesn't support
 	// alt screen (or alt screen support is disabled, like GNU screen by
@@ -375,6 +1032,7 @@ func (r *standardRenderer) enterAltScreen() {
 		r.out.ShowCursor()
 	}
 
+	r.damageGraphics()
 	r.repaint()
 }
 
@@ -389,6 +1047,15 @@ func (r *standardRenderer) exitAltScreen() {
 	r.altScreenActive = false
 	r.out.ExitAltScreen()
 
+	// Put the cursor back where it was in the inline view, and pick up the
+	// view's bookkeeping from there. The repaint below makes the next flush
+	// redraw every line, rather than diffing against the alt screen's.
+	_, _ = r.out.WriteString(restoreCursorSeq)
+	r.linesRendered = r.inlineLinesRendered
+	r.renderingHead = r.inlineRenderingHead
+	r.blankAbove = r.inlineBlankAbove
+	r.areaTop, r.areaTopKnown = r.inlineAreaTop, r.inlineAreaTopKnown
+
 	// cmd.exe and other terminals keep separate cursor states for the AltScreen
 	// and the main buffer. We have to explicitly reset the cursor visibility
 	// whenever we exit AltScreen.
@@ -398,13 +1065,26 @@ func (r *standardRenderer) exitAltScreen() {
 		r.out.ShowCursor()
 	}
 
+	r.damageGraphics()
 	r.repaint()
+
+	// Put the inline frame back right away, rather than waiting for the next
+	// one, which is then diffed against it.
+	if r.restoreInlineFrame && r.inlineFrame != "" {
+		r.buf.Reset()
+		r.buf.WriteString(r.inlineFrame)
+		r.frameCursor = r.inlineCursor
+		r.render()
+	}
 }
 
 func (r *standardRenderer) showCursor() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	if !r.cursorHidden {
+		return
+	}
 	r.cursorHidden = false
 	r.out.ShowCursor()
 }
@@ -422,13 +1102,21 @@ func (r *standardRenderer) enableMouseCellMotion() {
 	defer r.mtx.Unlock()
 
 	r.out.EnableMouseCellMotion()
+	r.mouseCellMotionActive = true
+	if !r.areaTopKnown {
+		r.requestViewPosition()
+	}
 }
 
 func (r *standardRenderer) disableMouseCellMotion() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	if !r.mouseCellMotionActive {
+		return
+	}
 	r.out.DisableMouseCellMotion()
+	r.mouseCellMotionActive = false
 }
 
 func (r *standardRenderer) enableMouseAllMotion() {
@@ -436,27 +1124,73 @@ func (r *standardRenderer) enableMouseAllMotion() {
 	defer r.mtx.Unlock()
 
 	r.out.EnableMouseAllMotion()
+	r.mouseAllMotionActive = true
+	if !r.areaTopKnown {
+		r.requestViewPosition()
+	}
 }
 
 func (r *standardRenderer) disableMouseAllMotion() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	if !r.mouseAllMotionActive {
+		return
+	}
 	r.out.DisableMouseAllMotion()
+	r.mouseAllMotionActive = false
 }
 
+// Sequences for the urxvt mouse encoding (1015), which we request ahead of SGR
+// (1006) as a fallback for terminals that only support the former. Terminals
+// that support both use whichever was requested last.
+const (
+	enableMouseURXVTModeSeq  = "?1015h"
+	disableMouseURXVTModeSeq = "?1015l"
+)
+
 func (r *standardRenderer) enableMouseSGRMode() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	_, _ = r.out.WriteString(termenv.CSI + enableMouseURXVTModeSeq)
 	r.out.EnableMouseExtendedMode()
+	r.mouseSGRActive = true
 }
 
 func (r *standardRenderer) disableMouseSGRMode() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	if !r.mouseSGRActive {
+		return
+	}
 	r.out.DisableMouseExtendedMode()
+	_, _ = r.out.WriteString(termenv.CSI + disableMouseURXVTModeSeq)
+	r.mouseSGRActive = false
+}
+
+func (r *standardRenderer) enableMousePixelsMode() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.out.EnableMousePixelsMode()
+	r.mousePixelsActive = true
+
+	// Terminals that don't support pixel mode keep reporting cells, so
+	// they're asked whether they're in it.
+	_, _ = r.out.WriteString(termenv.CSI + requestMousePixelsSeq)
+}
+
+func (r *standardRenderer) disableMousePixelsMode() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.mousePixelsActive {
+		return
+	}
+	r.out.DisableMousePixelsMode()
+	r.mousePixelsActive = false
 }
 
 func (r *standardRenderer) enableBracketedPaste() {
@@ -471,6 +1205,9 @@ func (r *standardRenderer) disableBracketedPaste() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	if !r.bpActive {
+		return
+	}
 	r.out.DisableBracketedPaste()
 	r.bpActive = false
 }
@@ -482,15 +1219,104 @@ func (r *standardRenderer) bracketedPasteActive() bool {
 	return r.bpActive
 }
 
+// kittyKeyboardFlags are the kitty keyboard protocol enhancements we push
+// when enabling key releases: disambiguate escape codes (1), report event
+// types (2) and report all keys as escape codes (8), the latter being needed
+// to receive releases of text keys.
+const kittyKeyboardFlags = 0b1011
+
+func (r *standardRenderer) enableKeyReleases() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.krActive {
+		return
+	}
+	_, _ = fmt.Fprintf(r.out, termenv.CSI+">%du", kittyKeyboardFlags)
+	r.krActive = true
+}
+
+func (r *standardRenderer) disableKeyReleases() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// Only pop the flags we pushed, so that we don't pop flags belonging to
+	// whoever ran before us.
+	if !r.krActive {
+		return
+	}
+	_, _ = r.out.WriteString(termenv.CSI + "<u")
+	r.krActive = false
+}
+
+func (r *standardRenderer) enableWin32InputMode() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.w32Active {
+		return
+	}
+	_, _ = r.out.WriteString(termenv.CSI + enableWin32InputModeSeq)
+	r.w32Active = true
+}
+
+func (r *standardRenderer) disableWin32InputMode() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.w32Active {
+		return
+	}
+	_, _ = r.out.WriteString(termenv.CSI + disableWin32InputModeSeq)
+	r.w32Active = false
+}
+
+func (r *standardRenderer) requestTerminalAttributes() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	_, _ = r.out.WriteString(termenv.CSI + "c")
+}
+
+func (r *standardRenderer) queryWindowSize() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	_, _ = r.out.WriteString(termenv.CSI + querySizeSeq)
+}
+
+func (r *standardRenderer) readClipboard() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	_, _ = r.out.WriteString(termenv.OSC + queryClipboardSeq)
+}
+
+func (r *standardRenderer) currentFrame(render bool) (string, int, int) {
+	// Render the pending frame, so it's the one on screen.
+	if render {
+		r.flush()
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.lastRender, r.width, r.height
+}
+
+func (r *standardRenderer) keyReleasesActive() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.krActive
+}
+
 // setIgnoredLines specifies lines not to be touched by the standard Bubble Tea
 // renderer.
 func (r *standardRenderer) setIgnoredLines(from int, to int) {
-	// Lock if we're going to be clearing some lines since we don't want
-	// anything jacking our cursor.
-	if r.linesRendered > 0 {
-		r.mtx.Lock()
-		defer r.mtx.Unlock()
-	}
+	// Lock, as we're going to be clearing some lines and don't want anything
+	// jacking our cursor.
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
 	if r.ignoreLines == nil {
 		r.ignoreLines = make(map[int]struct{})
@@ -498,28 +1324,85 @@ func (r *standardRenderer) setIgnoredLines(from int, to int) {
 	for i := from; i < to; i++ {
 		r.ignoreLines[i] = struct{}{}
 	}
+	if r.linesRendered == 0 {
+		return
+	}
 
-	// Erase ignored lines
-	if r.linesRendered > 0 {
-		buf := &bytes.Buffer{}
-		out := termenv.NewOutput(buf)
+	// Erase ignored lines, moving from wherever the cursor is, and put it
+	// back where the next flush expects it.
+	buf := &bytes.Buffer{}
+	out := r.newOutput(buf)
+	for i := r.linesRendered - 1; i >= 0; i-- {
+		if _, exists := r.ignoreLines[i]; exists {
+			r.moveRenderingHead(out, i)
+			out.ClearLine()
+		}
+	}
+	r.placeCursor(out)
+	_, _ = r.out.Write(buf.Bytes())
+}
 
-		for i := r.linesRendered - 1; i >= 0; i-- {
-			if _, exists := r.ignoreLines[i]; exists {
-				out.ClearLine()
-			}
-			out.CursorUp(1)
+// repaintLines rewrites lines of the view, from the first up to but not
+// including the last, as they were in the last frame rendered. They're
+// repainted whether or not they're ignored, which they still are afterwards.
+func (r *standardRenderer) repaintLines(from, to int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if from < 0 {
+		from = 0
+	}
+	if to > len(r.lastLines) {
+		to = len(r.lastLines)
+	}
+	if from >= to || r.linesRendered == 0 {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	out := r.newOutput(buf)
+	for i := from; i < to; i++ {
+		line := r.lastLines[i]
+		if line == staleLine {
+			// What was drawn there instead is erased.
+			line = ""
 		}
-		out.MoveCursor(r.linesRendered, 0) // put cursor back
-		_, _ = r.out.Write(buf.Bytes())
+		if r.width > 0 {
+			line = truncateLine(line, r.width, r.widthCond)
+		}
+		r.moveRenderingHead(out, i)
+		if r.cursor.set && i == from {
+			out.CursorBack(r.width)
+		}
+		out.ClearLine()
+		_, _ = out.WriteString(line)
+		out.CursorBack(r.width)
 	}
+	r.placeCursor(out)
+	_, _ = r.out.Write(buf.Bytes())
 }
 
+// staleLine stands in for a line of the last frame that holds whatever was
+// drawn there instead. Lines can't contain newlines, so it doesn't match the
+// line of the next frame.
+const staleLine = "\n"
+
 // clearIgnoredLines returns control of any ignored lines to the standard
 // Bubble Tea renderer. That is, any lines previously set to be ignored can be
 // rendered to again.
 func (r *standardRenderer) clearIgnoredLines() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// The lines hold whatever was drawn there instead, so they're repainted
+	// on the next flush.
+	for i := range r.ignoreLines {
+		if i >= 0 && i < len(r.lastLines) {
+			r.lastLines[i] = staleLine
+		}
+	}
 	r.ignoreLines = nil
+	r.lastRender = ""
 }
 
 // insertTop effectively scrolls up. It inserts lines at the top of a given
@@ -545,12 +1428,12 @@ func (r *standardRenderer) insertTop(lines []string, topBoundary, bottomBoundary
 	defer r.mtx.Unlock()
 
 	buf := &bytes.Buffer{}
-	out := termenv.NewOutput(buf)
+	out := r.newOutput(buf)
 
 	out.ChangeScrollingRegion(topBoundary, bottomBoundary)
 	out.MoveCursor(topBoundary, 0)
 	out.InsertLines(len(lines))
-	_, _ = out.WriteString(strings.Join(lines, "\r\n"))
+	_, _ = out.WriteString(downgradeColors(strings.Join(lines, "\r\n"), r.profile))
 	out.ChangeScrollingRegion(0, r.height)
 
 	// Move cursor back to where the main rendering routine expects it to be
@@ -573,11 +1456,11 @@ func (r *standardRenderer) insertBottom(lines []string, topBoundary, bottomBound
 	defer r.mtx.Unlock()
 
 	buf := &bytes.Buffer{}
-	out := termenv.NewOutput(buf)
+	out := r.newOutput(buf)
 
 	out.ChangeScrollingRegion(topBoundary, bottomBoundary)
 	out.MoveCursor(bottomBoundary, 0)
-	_, _ = out.WriteString("\r\n" + strings.Join(lines, "\r\n"))
+	_, _ = out.WriteString("\r\n" + downgradeColors(strings.Join(lines, "\r\n"), r.profile))
 	out.ChangeScrollingRegion(0, r.height)
 
 	// Move cursor back to where the main rendering routine expects it to be
@@ -598,20 +1481,49 @@ func (r *standardRenderer) handleMessages(msg Msg) {
 
 	case WindowSizeMsg:
 		r.mtx.Lock()
+		// The scrollable area moves with the height, so the lines it takes
+		// up are no longer known.
+		invalidated := msg.Height != r.height && len(r.ignoreLines) > 0
+		if invalidated {
+			r.ignoreLines = nil
+		}
+
+		// Terminals move or erase graphics when they're resized.
+		if msg.Width != r.width || msg.Height != r.height {
+			r.damageGraphics()
+		}
+
+		// The terminal may have moved the view when it was resized, so we
+		// ask where it is again.
+		if r.areaTopKnown && r.height > 0 && (msg.Width != r.width || msg.Height != r.height) {
+			r.areaTopKnown = false
+			r.requestViewPosition()
+		}
+
 		r.width = msg.Width
 		r.height = msg.Height
+		r.trimFooter()
 		r.repaint()
 		r.mtx.Unlock()
 
-	case clearScrollAreaMsg:
-		r.clearIgnoredLines()
+		if invalidated && r.onScrollAreaInvalidated != nil {
+			r.onScrollAreaInvalidated()
+		}
+
+	case cursorPositionReportMsg:
+		r.placeView(msg)
 
-		// Force a repaint on the area where the scrollable stuff was in this
-		// update cycle
+	case setEastAsianWidthMsg:
 		r.mtx.Lock()
-		r.repaint()
+		if r.widthCond.EastAsianWidth != bool(msg) {
+			r.widthCond = newWidthCondition(bool(msg))
+			r.repaint()
+		}
 		r.mtx.Unlock()
 
+	case clearScrollAreaMsg:
+		r.clearIgnoredLines()
+
 	case syncScrollAreaMsg:
 		// Re-render scrolling area
 		r.clearIgnoredLines()
@@ -623,6 +1535,40 @@ func (r *standardRenderer) handleMessages(msg Msg) {
 		r.repaint()
 		r.mtx.Unlock()
 
+	case saveCursorMsg:
+		r.mtx.Lock()
+		r.savedRenderingHead = r.renderingHead
+		if r.scoCursorSave {
+			_, _ = r.out.WriteString(termenv.CSI + termenv.SaveCursorPositionSeq)
+		} else {
+			_, _ = r.out.WriteString(saveCursorSeq)
+		}
+		r.mtx.Unlock()
+
+	case restoreCursorMsg:
+		r.mtx.Lock()
+		if r.scoCursorSave {
+			_, _ = r.out.WriteString(termenv.CSI + termenv.RestoreCursorPositionSeq)
+		} else {
+			_, _ = r.out.WriteString(restoreCursorSeq)
+		}
+		// The cursor is back on the line it was saved on, as far as we can
+		// tell, but not necessarily at its start, so the next frame is
+		// painted in full from there.
+		r.renderingHead = r.savedRenderingHead
+		r.cursor = cursorCell{}
+		r.repaint()
+		r.mtx.Unlock()
+
+	case repaintLinesMsg:
+		r.repaintLines(msg.from, msg.to)
+
+	case setGraphicsRegionMsg:
+		r.setGraphicsRegion(msg.region, msg.graphics)
+
+	case clearGraphicsRegionMsg:
+		r.clearGraphicsRegion(string(msg))
+
 	case scrollUpMsg:
 		r.insertTop(msg.lines, msg.topBoundary, msg.bottomBoundary)
 
@@ -637,6 +1583,38 @@ func (r *standardRenderer) handleMessages(msg Msg) {
 			r.repaint()
 			r.mtx.Unlock()
 		}
+
+	case printBelowMessage:
+		lines := strings.Split(downgradeColors(msg.messageBody, r.profile), "\n")
+		r.mtx.Lock()
+		r.footerLines = append(r.footerLines, lines...)
+		r.trimFooter()
+		r.lastRender = ""
+		r.mtx.Unlock()
+
+	case clearBelowMsg:
+		r.mtx.Lock()
+		if len(r.footerLines) > 0 {
+			r.footerLines = nil
+			r.lastRender = ""
+		}
+		r.mtx.Unlock()
+	}
+}
+
+// maxFooterLines is how many lines printed below the view are kept when the
+// terminal's height isn't known.
+const maxFooterLines = 100
+
+// trimFooter drops the oldest lines printed below the view that don't fit on
+// screen, so they don't pile up.
+func (r *standardRenderer) trimFooter() {
+	limit := r.height
+	if limit <= 0 {
+		limit = maxFooterLines
+	}
+	if n := len(r.footerLines); n > limit {
+		r.footerLines = append([]string(nil), r.footerLines[n-limit:]...)
 	}
 }
 
@@ -648,6 +1626,8 @@ type syncScrollAreaMsg struct {
 	bottomBoundary int
 }
 
+func (syncScrollAreaMsg) internalMsg() {}
+
 // SyncScrollArea performs a paint of the entire region designated to be the
 // scrollable area. This is required to initialize the scrollable region and
 // should also be called on resize (WindowSizeMsg).
@@ -665,6 +1645,8 @@ func SyncScrollArea(lines []string, topBoundary int, bottomBoundary int) Cmd {
 
 type clearScrollAreaMsg struct{}
 
+func (clearScrollAreaMsg) internalMsg() {}
+
 // ClearScrollArea deallocates the scrollable region and returns the control of
 // those lines to the main rendering routine.
 //
@@ -673,12 +1655,41 @@ func ClearScrollArea() Msg {
 	return clearScrollAreaMsg{}
 }
 
+type repaintLinesMsg struct {
+	from int
+	to   int
+}
+
+func (repaintLinesMsg) internalMsg() {}
+
+// RepaintLines rewrites lines of the view, from the first up to but not
+// including the last, through the renderer, even if they're ignored because
+// they're part of the scrollable area. This lets code that draws those lines
+// itself hand some of them back for a moment. They're repainted as they were
+// in the last frame rendered, and are ignored again afterwards.
+//
+// For high-performance, scroll-based rendering only.
+func RepaintLines(from, to int) Cmd {
+	return func() Msg {
+		return repaintLinesMsg{from: from, to: to}
+	}
+}
+
+// ScrollAreaInvalidatedMsg is sent when the height of the terminal changes
+// while there's a scrollable area, which the renderer stops leaving alone, as
+// the lines it takes up have moved. Set it up again with SyncScrollArea.
+//
+// For high-performance, scroll-based rendering only.
+type ScrollAreaInvalidatedMsg struct{}
+
 type scrollUpMsg struct {
 	lines          []string
 	topBoundary    int
 	bottomBoundary int
 }
 
+func (scrollUpMsg) internalMsg() {}
+
 // ScrollUp adds lines to the top of the scrollable region, pushing existing
 // lines below down. Lines that are pushed out the scrollable region disappear
 // from view.
@@ -700,6 +1711,8 @@ type scrollDownMsg struct {
 	bottomBoundary int
 }
 
+func (scrollDownMsg) internalMsg() {}
+
 // ScrollDown adds lines to the bottom of the scrollable region, pushing
 // existing lines above up. Lines that are pushed out of the scrollable region
 // disappear from view.
@@ -715,10 +1728,31 @@ func ScrollDown(newLines []string, topBoundary, bottomBoundary int) Cmd {
 	}
 }
 
+// ViewTruncatedMsg is sent when the view gets taller than the terminal, so
+// that lines at the top are dropped, as the cursor can't reach them in the
+// terminal's scrollback. Models can use it to switch to a layout that fits,
+// such as one that scrolls. It's sent again when the number of dropped lines
+// changes, with DroppedLines set to zero once the view fits again.
+type ViewTruncatedMsg struct {
+	// RenderedLines is how many lines of the view are rendered.
+	RenderedLines int
+
+	// DroppedLines is how many lines at the top of the view are dropped.
+	DroppedLines int
+}
+
 type printLineMessage struct {
 	messageBody string
 }
 
+func (printLineMessage) internalMsg() {}
+
+type printBelowMessage struct {
+	messageBody string
+}
+
+func (printBelowMessage) internalMsg() {}
+
 // Println prints above the Program. This output is unmanaged by the program and
 // will persist across renders by the Program.
 //
@@ -749,3 +1783,32 @@ func Printf(template string, args ...interface{}) Cmd {
 		}
 	}
 }
+
+// PrintBelow prints below the Program. Unlike the output of Println, which
+// scrolls away above the program, these lines stay right below its view,
+// which moves up to make room for them, and are left in place after the final
+// frame when the program exits. This suits messages such as warnings that
+// should outlast the program.
+//
+// Each call adds lines below the ones printed before. Only the latest lines
+// that fit on screen are kept, and ClearBelow removes them. If the altscreen
+// is active the lines are held back until it's exited.
+func PrintBelow(args ...interface{}) Cmd {
+	return func() Msg {
+		return printBelowMessage{
+			messageBody: fmt.Sprint(args...),
+		}
+	}
+}
+
+// ClearBelow is a command that removes the lines printed with PrintBelow, so
+// the view ends where it did before.
+func ClearBelow() Msg {
+	return clearBelowMsg{}
+}
+
+// clearBelowMsg is an internal message that removes the lines printed below
+// the view. To send a clearBelowMsg, use the ClearBelow command.
+type clearBelowMsg struct{}
+
+func (clearBelowMsg) internalMsg() {}
@@ -0,0 +1,52 @@
+package tea
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+func TestRendererLifecycle(t *testing.T) {
+	before := runtime.NumGoroutine()
+	r := newRenderer(termenv.NewOutput(io.Discard), false, 120, false).(*standardRenderer)
+
+	// Stopping a renderer that was never started, or twice, doesn't block.
+	r.stop()
+	r.kill()
+	r.start()
+	r.start()
+	r.stop()
+	r.stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				r.start()
+				r.write("frame")
+				if (i+j)%2 == 0 {
+					r.stop()
+				} else {
+					r.kill()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	r.stop()
+
+	// Goroutines that are done may take a moment to be gone.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected no goroutines left behind, got %d more", n-before)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/muesli/cancelreader"
 	"github.com/muesli/termenv"
@@ -34,6 +35,13 @@ var ErrProgramKilled = errors.New("program was killed")
 // function and, henceforth, the UI.
 type Msg interface{}
 
+// internalMsg is implemented by the unexported messages the program uses
+// internally, which control the program rather than being meant for the
+// model.
+type internalMsg interface {
+	internalMsg()
+}
+
 // Model contains the program's state as well as its core functions.
 type Model interface {
 	// Init is the first function that will be called. It returns an optional
@@ -80,7 +88,7 @@ func (i inputType) String() string {
 // generally set with ProgramOptions.
 //
 // The options here are treated as bits.
-type startupOptions int16
+type startupOptions int32
 
 func (s startupOptions) has(option startupOptions) bool {
 	return s&option != 0
@@ -98,6 +106,28 @@ const (
 	// feature is on by default.
 	withoutCatchPanics
 	withoutBracketedPaste
+	withKeyReleases
+	withMouseMotionCoalescing
+	withWin32InputMode
+	withLineInput
+	withRawPaste
+	withPlainOutput
+	withPlainOutputFinalFrame
+	withRecordingInput
+	withInterruptMsg
+	withQuitOnInputEOF
+	withBottomAnchor
+	withKeepFinalFrame
+	withClearOnQuit
+	withStrictView
+	withSCOCursorSave
+	withWindowSizeQuery
+	withRestoreInlineFrame
+	withHyperlinkFallback
+	withAccessibleOutput
+	withDroppedFrameReports
+	withSharedOutput
+	withMouseViewPosition
 )
 
 // channelHandlers manages the series of channels returned by various processes.
@@ -137,14 +167,49 @@ type Program struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// parentCtx is the context the program runs in, which each run gets a
+	// context of its own from, and ran is set once the program has run, as
+	// the next run starts afresh.
+	parentCtx context.Context
+	ran       bool
+
+	// mu guards ctx and cancel, which are replaced when the program is run
+	// again, for the goroutines that send to the program from outside.
+	mu sync.Mutex
+
 	msgs     chan Msg
 	errs     chan error
 	finished chan struct{}
 
+	// priorityMsgs carries messages that go ahead of the ones in msgs.
+	priorityMsgs chan Msg
+
+	// queue holds sent messages until the event loop gets to them, if a
+	// message queue size was set.
+	queue *msgQueue
+
 	// where to send output, this will usually be os.Stdout.
 	output        *termenv.Output
 	restoreOutput func() error
-	renderer      renderer
+
+	// outputWriter is the writer set with WithOutput, which tells outputs
+	// that aren't files apart.
+	outputWriter io.Writer
+	renderer     renderer
+
+	// outputClaim is the program's claim on its output, and sharedClaim the
+	// claim of the program it shares its output with, if any.
+	outputClaim *outputClaim
+	sharedClaim *outputClaim
+
+	// pickedRenderer is set when Run picked the renderer for the output,
+	// rather than it being set with an option, so the next run picks a new
+	// one.
+	pickedRenderer bool
+
+	// noVTProcessing is set when the Windows console can't interpret escape
+	// sequences, in which case frames are written as plain text.
+	noVTProcessing bool
 
 	// where to read inputs from, this will usually be os.Stdin.
 	input io.Reader
@@ -159,28 +224,175 @@ type Program struct {
 	ignoreSignals      uint32
 
 	bpWasActive bool // was the bracketed paste mode active before releasing the terminal?
+	krWasActive bool // were key releases enabled before releasing the terminal?
 
 	filter func(Model, Msg) Msg
 
+	// the mouse modes enabled, as reported by RequestScreenState
+	mouseCellMotion bool
+	mouseAllMotion  bool
+
+	// whether mouse events are reported in pixels, as reported by
+	// RequestScreenState, and what translates the ones read from the
+	// terminal to cells.
+	mousePixels bool
+	pixelMouse  pixelMouse
+
 	// fps is the frames per second we should set on the renderer, if
 	// applicable,
 	fps int
+
+	// eastAsianWidth is whether characters of ambiguous width are treated as
+	// double width when truncating lines.
+	eastAsianWidth bool
+
+	// keySequences are key sequences to detect on top of the built-in ones.
+	keySequences map[string]Key
+
+	// additionalInputs are read alongside the main input.
+	additionalInputs []*additionalInput
+
+	// resizeDebounce is how long the terminal size has to stay the same
+	// before we report it after a burst of resizes.
+	resizeDebounce time.Duration
+
+	// msgLog traces the messages delivered to Update, if set.
+	msgLog *msgLogger
+
+	// recording records the session, if set.
+	recording *recorder
+
+	// inputRecorder records what's read from the input, if set.
+	inputRecorder *inputRecorder
+
+	// initialSize is the window size reported when the output isn't a
+	// terminal, if set.
+	initialSize *WindowSizeMsg
+
+	// exitCode is the exit code the program quit with.
+	exitCode int
+
+	// forceQuit is set when the program was killed by a second SIGINT
+	// after the first one was delivered as an InterruptMsg.
+	forceQuit uint32
+
+	// slowThreshold is how long a call to Update or View can take before
+	// it's reported with a SlowHandlerMsg. Zero disables this.
+	slowThreshold time.Duration
+
+	// environ is the environment of the terminal, if set, used instead of
+	// the process's to detect its features.
+	environ environ
+
+	// colorProfile is the color profile of the terminal, if set.
+	colorProfile *termenv.Profile
+
+	// frameTransforms are applied to each frame before it's rendered.
+	frameTransforms []func(string) string
+
+	// tabWidth is how many columns apart tab stops are when tabs in the view
+	// are expanded, if set.
+	tabWidth int
+
+	// compressorThreshold is the size in bytes a flush has to exceed to be
+	// compressed, if set.
+	compressorThreshold int
 }
 
+// defaultResizeDebounce is the default quiet period after a burst of resizes.
+const defaultResizeDebounce = 50 * time.Millisecond
+
 // Quit is a special command that tells the Bubble Tea program to exit.
 func Quit() Msg {
 	return QuitMsg{}
 }
 
+// QuitWithCode is a command that tells the Bubble Tea program to exit, like
+// Quit, reporting the given exit code from [Program.ExitCode]. Use it to exit
+// with a nonzero status when the user's operation failed:
+//
+//	m, err := p.Run()
+//	if err != nil {
+//	    // ...
+//	}
+//	os.Exit(p.ExitCode())
+func QuitWithCode(code int) Cmd {
+	return func() Msg {
+		return QuitMsg{Code: code}
+	}
+}
+
 // QuitMsg signals that the program should quit. You can send a QuitMsg with
-// Quit.
-type QuitMsg struct{}
+// Quit or QuitWithCode.
+type QuitMsg struct {
+	// Code is the exit code reported by [Program.ExitCode].
+	Code int
+}
+
+// Exit codes reported by [Program.ExitCode] when the program quits because
+// of a signal, following the shell convention of 128 plus the signal number.
+const (
+	// ExitInterrupted is reported when the program was interrupted with
+	// SIGINT, such as when ^C was pressed and input isn't a terminal.
+	ExitInterrupted = 128 + int(syscall.SIGINT)
+
+	// ExitTerminated is reported when the program was terminated with
+	// SIGTERM.
+	ExitTerminated = 128 + int(syscall.SIGTERM)
+
+	// ExitHangup is reported when the program quit with SIGHUP, which is
+	// sent when the terminal is closed.
+	ExitHangup = 128 + int(syscall.SIGHUP)
+)
+
+// InterruptMsg is sent when the program receives SIGINT, if
+// WithInterruptMsg is set, instead of quitting. The model can then decide
+// whether and how to quit, such as after asking to save changes.
+type InterruptMsg struct{}
+
+// TerminateMsg is sent when the program receives SIGTERM or SIGHUP, right
+// before it quits. The program quits regardless, rendering the final frame
+// and restoring the terminal, but the model gets a chance to see why.
+//
+// To handle these signals yourself, use WithoutSignalHandler.
+type TerminateMsg struct {
+	Signal os.Signal
+}
+
+// signalExitCode returns the exit code to report when quitting because of a
+// signal.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// SlowHandlerMsg is sent when a call to Update or View takes longer than the
+// threshold set with WithSlowHandlerThreshold, which usually means it blocked
+// on something that should be done in a Cmd instead. It's delivered once the
+// call returns, as the program can't handle messages before then.
+type SlowHandlerMsg struct {
+	// Handler is "Update" or "View".
+	Handler string
+
+	// MsgType is the type of the message being handled, such as
+	// "tea.KeyMsg".
+	MsgType string
+
+	// Elapsed is how long the call had run for when it was reported, which
+	// is the threshold.
+	Elapsed time.Duration
+}
 
 // NewProgram creates a new Program.
 func NewProgram(model Model, opts ...ProgramOption) *Program {
 	p := &Program{
-		initialModel: model,
-		msgs:         make(chan Msg),
+		initialModel:   model,
+		msgs:           make(chan Msg),
+		priorityMsgs:   make(chan Msg),
+		resizeDebounce: defaultResizeDebounce,
+		eastAsianWidth: detectEastAsianWidth(),
 	}
 
 	// Apply all options to the program.
@@ -194,6 +406,7 @@ func NewProgram(model Model, opts ...ProgramOption) *Program {
 		p.ctx = context.Background()
 	}
 	// Initialize context and teardown channel.
+	p.parentCtx = p.ctx
 	p.ctx, p.cancel = context.WithCancel(p.ctx)
 
 	// if no output was set, set it to stdout
@@ -203,41 +416,73 @@ func NewProgram(model Model, opts ...ProgramOption) *Program {
 		// cache detected color values
 		termenv.WithColorCache(true)(p.output)
 	}
-
-	p.restoreOutput, _ = termenv.EnableVirtualTerminalProcessing(p.output)
+	p.detectColorProfile()
 
 	return p
 }
 
+// notifySignals and stopSignals relay signals to a channel. Tests replace
+// them to send signals without involving the process.
+var (
+	notifySignals = signal.Notify
+	stopSignals   = signal.Stop
+)
+
+// interruptGracePeriod is how soon after SIGINT is delivered as an
+// InterruptMsg a second one kills the program.
+const interruptGracePeriod = 3 * time.Second
+
 func (p *Program) handleSignals() chan struct{} {
 	ch := make(chan struct{})
 
-	// Listen for SIGINT and SIGTERM.
+	// Listen for SIGINT, SIGTERM and SIGHUP.
 	//
 	// In most cases ^C will not send an interrupt because the terminal will be
 	// in raw mode and ^C will be captured as a keystroke and sent along to
 	// Program.Update as a KeyMsg. When input is not a TTY, however, ^C will be
 	// caught here.
 	//
-	// SIGTERM is sent by unix utilities (like kill) to terminate a process.
+	// SIGTERM is sent by unix utilities (like kill) and service managers to
+	// terminate a process, and SIGHUP when the terminal is closed. Both quit
+	// the program gracefully, after letting the model know with a
+	// TerminateMsg.
 	go func() {
 		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		notifySignals(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 		defer func() {
-			signal.Stop(sig)
+			stopSignals(sig)
 			close(ch)
 		}()
 
+		var interrupted time.Time
 		for {
 			select {
 			case <-p.ctx.Done():
 				return
 
-			case <-sig:
-				if atomic.LoadUint32(&p.ignoreSignals) == 0 {
-					p.msgs <- QuitMsg{}
-					return
+			case s := <-sig:
+				if atomic.LoadUint32(&p.ignoreSignals) != 0 {
+					continue
+				}
+
+				if s == syscall.SIGINT && p.startupOptions.has(withInterruptMsg) {
+					// Let the program decide what to do, unless it
+					// hasn't done anything about the last interrupt.
+					if !interrupted.IsZero() && time.Since(interrupted) < interruptGracePeriod {
+						atomic.StoreUint32(&p.forceQuit, 1)
+						p.Kill()
+						return
+					}
+					interrupted = time.Now()
+					go p.Send(InterruptMsg{})
+					continue
+				}
+
+				if s != syscall.SIGINT {
+					p.Send(TerminateMsg{Signal: s})
 				}
+				p.Send(QuitMsg{Code: signalExitCode(s)})
+				return
 			}
 		}
 	}()
@@ -249,30 +494,51 @@ func (p *Program) handleSignals() chan struct{} {
 func (p *Program) handleResize() chan struct{} {
 	ch := make(chan struct{})
 
-	if f, ok := p.output.TTY().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+	if _, ok := p.sizeFromTerminal(); ok {
 		// Get the initial terminal size and send it to the program.
 		go p.checkResize()
 
 		// Listen for window resizes.
 		go p.listenForResize(ch)
 	} else {
+		if p.startupOptions.has(withWindowSizeQuery) {
+			// Ask the terminal at the other end instead.
+			p.renderer.queryWindowSize()
+		}
 		close(ch)
 	}
 
 	return ch
 }
 
+// syntheticWindowSize returns the window size to report to the program when
+// the output isn't a terminal, and so has no size of its own: the initial
+// size if one was set, or a default size for plain output.
+func (p *Program) syntheticWindowSize() (WindowSizeMsg, bool) {
+	if f, ok := p.output.TTY().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return WindowSizeMsg{}, false
+	}
+	if p.initialSize != nil {
+		return *p.initialSize, true
+	}
+	if _, ok := p.renderer.(*plainRenderer); ok {
+		return WindowSizeMsg{Width: plainOutputWidth, Height: plainOutputHeight}, true
+	}
+	return WindowSizeMsg{}, false
+}
+
 // handleCommands runs commands in a goroutine and sends the result to the
 // program's message channel.
 func (p *Program) handleCommands(cmds chan Cmd) chan struct{} {
 	ch := make(chan struct{})
+	ctx := p.ctx
 
 	go func() {
 		defer close(ch)
 
 		for {
 			select {
-			case <-p.ctx.Done():
+			case <-ctx.Done():
 				return
 
 			case cmd := <-cmds:
@@ -286,8 +552,8 @@ func (p *Program) handleCommands(cmds chan Cmd) chan struct{} {
 				// possible to cancel them so we'll have to leak the goroutine
 				// until Cmd returns.
 				go func() {
-					msg := cmd() // this can be long.
-					p.Send(msg)
+					msg := p.execCmd(ctx, cmd) // this can be long.
+					p.send(ctx, msg)
 				}()
 			}
 		}
@@ -300,126 +566,341 @@ func (p *Program) disableMouse() {
 	p.renderer.disableMouseCellMotion()
 	p.renderer.disableMouseAllMotion()
 	p.renderer.disableMouseSGRMode()
+	p.renderer.disableMousePixelsMode()
+	p.mouseCellMotion, p.mouseAllMotion, p.mousePixels = false, false, false
+	p.pixelMouse.reset()
+}
+
+// updateCellSize records the size of a terminal cell in pixels, which is
+// needed to translate mouse events reported in pixels into cells.
+func (p *Program) updateCellSize() {
+	var width, height int
+	if f, ok := p.output.TTY().(*os.File); ok {
+		width, height, _ = cellSize(f)
+	}
+	p.pixelMouse.setCellSize(width, height)
+}
+
+// headless reports whether the program runs without a renderer, in which
+// case it leaves the terminal alone.
+func (p *Program) headless() bool {
+	switch p.renderer.(type) {
+	case nilRenderer, *nilRenderer, *TestRenderer:
+		return true
+	}
+	return false
+}
+
+// usePlainOutput reports whether frames should be written as plain text:
+// either because it was asked for, because the output is a file or a pipe
+// rather than a terminal, or because it's a terminal that can't interpret
+// escape sequences. A pipe or serial line the terminal's size is asked over
+// with WithWindowSizeQuery leads to a terminal, and gets the standard
+// renderer.
+func (p *Program) usePlainOutput() bool {
+	if p.startupOptions.has(withPlainOutput) || p.noVTProcessing || p.dumbTerminal() || p.accessible() {
+		return true
+	}
+	f, ok := p.output.TTY().(*os.File)
+	return ok && !term.IsTerminal(int(f.Fd())) && !p.startupOptions.has(withWindowSizeQuery)
+}
+
+// outputIsTerminal reports whether the output is a terminal, which input may
+// have put in raw mode.
+func (p *Program) outputIsTerminal() bool {
+	f, ok := p.output.TTY().(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// accessible reports whether output is meant for a screen reader, because it
+// was asked for, or because the ACCESSIBLE environment variable is set.
+func (p *Program) accessible() bool {
+	return p.startupOptions.has(withAccessibleOutput) || p.getenv("ACCESSIBLE") != ""
+}
+
+// dumbTerminal reports whether the terminal is a dumb one, such as an Emacs
+// shell buffer, which doesn't understand escape sequences at all.
+func (p *Program) dumbTerminal() bool {
+	return p.getenv("TERM") == "dumb"
 }
 
 // eventLoop is the central message loop. It receives and handles the default
 // Bubble Tea messages, update the model and triggers redraws.
-func (p *Program) eventLoop(model Model, cmds chan Cmd) (Model, error) {
+//
+// Pending messages are delivered before any other message.
+func (p *Program) eventLoop(model Model, cmds chan Cmd, pending []Msg) (Model, error) {
+	var lastSize *WindowSizeMsg
 	for {
-		select {
-		case <-p.ctx.Done():
-			return model, nil
+		var msg Msg
+		if len(pending) > 0 {
+			msg, pending = pending[0], pending[1:]
+		} else {
+			// Messages in the priority lane go ahead of the others.
+			select {
+			case msg = <-p.priorityMsgs:
+			default:
+				select {
+				case <-p.ctx.Done():
+					return model, nil
 
-		case err := <-p.errs:
-			return model, err
+				case err := <-p.errs:
+					return model, err
+
+				case msg = <-p.priorityMsgs:
+				case msg = <-p.msgs:
+				}
+			}
+		}
+
+		switch m := msg.(type) {
+		case repaintMsg:
+			// Repaint requests made before we got to them all repaint the
+			// same content, so collapse them into one.
+			if next := p.skipRepaints(); next != nil {
+				pending = append([]Msg{next}, pending...)
+			}
+		case WindowSizeMsg:
+			// Only deliver sizes that changed.
+			if lastSize != nil && *lastSize == m {
+				continue
+			}
+			lastSize = &m
+		case currentWindowSizeMsg:
+			// Sizes asked for with WindowSize are delivered even when they
+			// didn't change.
+			size := WindowSizeMsg(m)
+			lastSize = &size
+			msg = size
+		}
 
-		case msg := <-p.msgs:
-			// Filter messages.
-			if p.filter != nil {
-				msg = p.filter(model, msg)
+		// Clicks on links rendered without OSC 8 open them.
+		if m, ok := msg.(MouseMsg); ok && m.Action == MouseActionPress && m.Button == MouseButtonLeft {
+			if r, ok := p.renderer.(*standardRenderer); ok && r.linkFallback {
+				if url, ok := r.linkAt(MouseEvent(m)); ok {
+					go p.Send(OpenLinkMsg{URL: url})
+				}
 			}
-			if msg == nil {
+		}
+
+		// Drop ticks that were canceled while they were in flight.
+		if c, ok := msg.(cancelableMsg); ok {
+			if c.canceled() || c.msg == nil {
 				continue
 			}
+			msg = c.msg
+		}
 
-			// Handle special internal messages.
-			switch msg := msg.(type) {
-			case QuitMsg:
-				return model, nil
+		// Filter messages.
+		if p.filter != nil {
+			msg = p.filter(model, msg)
+		}
+		if msg == nil {
+			continue
+		}
 
-			case clearScreenMsg:
-				p.renderer.clearScreen()
+		// Handle special internal messages.
+		switch msg := msg.(type) {
+		case QuitMsg:
+			p.msgLog.log("Quit")
+			p.exitCode = msg.Code
+			return model, nil
 
-			case enterAltScreenMsg:
-				p.renderer.enterAltScreen()
+		case clearScreenMsg:
+			p.renderer.clearScreen()
 
-			case exitAltScreenMsg:
-				p.renderer.exitAltScreen()
+		case enterAltScreenMsg:
+			p.renderer.enterAltScreen()
 
-			case enableMouseCellMotionMsg, enableMouseAllMotionMsg:
-				switch msg.(type) {
-				case enableMouseCellMotionMsg:
-					p.renderer.enableMouseCellMotion()
-				case enableMouseAllMotionMsg:
-					p.renderer.enableMouseAllMotion()
-				}
-				// mouse mode (1006) is a no-op if the terminal doesn't support it.
-				p.renderer.enableMouseSGRMode()
+		case exitAltScreenMsg:
+			p.renderer.exitAltScreen()
 
-			case disableMouseMsg:
-				p.disableMouse()
+		case enableMouseCellMotionMsg, enableMouseAllMotionMsg:
+			switch msg.(type) {
+			case enableMouseCellMotionMsg:
+				p.renderer.enableMouseCellMotion()
+				p.mouseCellMotion = true
+			case enableMouseAllMotionMsg:
+				p.renderer.enableMouseAllMotion()
+				p.mouseAllMotion = true
+			}
+			// mouse mode (1006) is a no-op if the terminal doesn't support it.
+			p.renderer.enableMouseSGRMode()
+
+		case enableMousePixelMotionMsg:
+			p.renderer.enableMouseAllMotion()
+			p.renderer.enableMouseSGRMode()
+			p.renderer.enableMousePixelsMode()
+			p.mouseAllMotion, p.mousePixels = true, true
+			p.pixelMouse.request()
+			p.updateCellSize()
+
+		case disableMousePixelMotionMsg:
+			p.renderer.disableMousePixelsMode()
+			p.mousePixels = false
+			p.pixelMouse.reset()
+
+		case disableMouseMsg:
+			p.disableMouse()
+
+		case WindowSizeMsg:
+			if p.mousePixels {
+				p.updateCellSize()
+			}
+			if p.recording != nil {
+				p.recording.setSize(msg.Width, msg.Height)
+			}
 
-			case showCursorMsg:
-				p.renderer.showCursor()
+		case showCursorMsg:
+			p.renderer.showCursor()
 
-			case hideCursorMsg:
-				p.renderer.hideCursor()
+		case hideCursorMsg:
+			p.renderer.hideCursor()
 
-			case enableBracketedPasteMsg:
-				p.renderer.enableBracketedPaste()
+		case enableBracketedPasteMsg:
+			p.renderer.enableBracketedPaste()
 
-			case disableBracketedPasteMsg:
-				p.renderer.disableBracketedPaste()
+		case disableBracketedPasteMsg:
+			p.renderer.disableBracketedPaste()
 
-			case execMsg:
-				// NB: this blocks.
-				p.exec(msg.cmd, msg.fn)
+		case enableKeyReleasesMsg:
+			p.renderer.enableKeyReleases()
 
-			case BatchMsg:
-				for _, cmd := range msg {
-					cmds <- cmd
-				}
-				continue
+		case disableKeyReleasesMsg:
+			p.renderer.disableKeyReleases()
 
-			case sequenceMsg:
-				go func() {
-					// Execute commands one at a time, in order.
-					for _, cmd := range msg {
-						if cmd == nil {
-							continue
-						}
-
-						msg := cmd()
-						if batchMsg, ok := msg.(BatchMsg); ok {
-							g, _ := errgroup.WithContext(p.ctx)
-							for _, cmd := range batchMsg {
-								cmd := cmd
-								g.Go(func() error {
-									p.Send(cmd())
-									return nil
-								})
-							}
-
-							//nolint:errcheck
-							g.Wait() // wait for all commands from batch msg to finish
-							continue
-						}
-
-						p.Send(msg)
-					}
-				}()
+		case execMsg:
+			// NB: this blocks.
+			p.exec(msg.cmd, msg.fn)
 
-			case setWindowTitleMsg:
-				p.SetWindowTitle(string(msg))
+		case BatchMsg:
+			for _, cmd := range msg {
+				cmds <- cmd
 			}
+			continue
 
-			// Process internal messages for the renderer.
-			if r, ok := p.renderer.(*standardRenderer); ok {
-				r.handleMessages(msg)
-			}
+		case sequenceMsg:
+			go p.execSequenceMsg(p.ctx, msg)
 
-			var cmd Cmd
+		case contextCmdMsg:
+			ctx := p.ctx
+			go func() {
+				p.send(ctx, msg(ctx))
+			}()
+			continue
+
+		case setWindowTitleMsg:
+			p.SetWindowTitle(string(msg))
+
+		case requestTerminalAttributesMsg:
+			p.renderer.requestTerminalAttributes()
+
+		case readClipboardMsg:
+			p.renderer.readClipboard()
+
+		case setProgressMsg:
+			p.renderer.setProgress(msg.state, msg.percent)
+
+		case queryWindowSizeMsg:
+			p.renderer.queryWindowSize()
+
+		case windowSizeMsg:
+			p.windowSize()
+
+		case requestScreenshotMsg:
+			shot := p.screenshot(true)
+			go p.Send(shot)
+
+		case requestScreenStateMsg:
+			state := p.screenState()
+			go p.Send(state)
+		}
+
+		// Process internal messages for the renderer.
+		switch r := p.renderer.(type) {
+		case *standardRenderer:
+			r.handleMessages(msg)
+		case *TestRenderer:
+			r.handleMessages(msg)
+		}
+
+		var cmd Cmd
+		p.msgLog.logMsg(msg)
+		p.timeHandler("Update", msg, func() {
 			model, cmd = model.Update(msg) // run update
-			cmds <- cmd                    // process command (if any)
-			p.renderer.write(model.View()) // send view to renderer
+		})
+		select {
+		case cmds <- cmd: // process command (if any)
+		case <-p.ctx.Done():
+			// The program was killed while updating; the command
+			// processor has stopped.
+			return model, nil
+		}
+		var view string
+		p.timeHandler("View", msg, func() {
+			view = p.view(model)
+		})
+		p.renderer.write(view) // send view to renderer
+	}
+}
+
+// timeHandler calls fn, which calls Update or View, and reports it with a
+// SlowHandlerMsg once it takes too long. The report is made while the call is
+// still running, so a call that never returns is reported too. Calls handling
+// a SlowHandlerMsg aren't timed, so that a View that is always slow isn't
+// reported over and over.
+func (p *Program) timeHandler(handler string, msg Msg, fn func()) {
+	if _, ok := msg.(SlowHandlerMsg); ok || p.slowThreshold <= 0 {
+		fn()
+		return
+	}
+
+	ctx := p.ctx
+	msgType := fmt.Sprintf("%T", msg)
+	logged := make(chan struct{})
+	watchdog := time.AfterFunc(p.slowThreshold, func() {
+		slow := SlowHandlerMsg{
+			Handler: handler,
+			MsgType: msgType,
+			Elapsed: p.slowThreshold,
 		}
+		p.msgLog.log(fmt.Sprintf("Slow %s %s took over %v", handler, msgType, slow.Elapsed))
+		close(logged)
+		p.send(ctx, slow)
+	})
+	fn()
+	if !watchdog.Stop() {
+		// The logger is only used from the event loop, so wait for the
+		// watchdog to be done with it.
+		<-logged
 	}
 }
 
+// view renders the model's view.
+func (p *Program) view(model Model) string {
+	p.msgLog.log("View")
+	return model.View()
+}
+
+// openTTY opens the controlling terminal for input. Tests replace it to avoid
+// depending on one being present.
+var openTTY = openInputTTY
+
 // Run initializes the program and runs its event loops, blocking until it gets
 // terminated by either [Program.Quit], [Program.Kill], or its signal handler.
 // Returns the final model.
+//
+// A program can be run again once Run returns. Each run starts afresh, with
+// the initial model, as the first did: the view is rendered from scratch, the
+// input is read anew, and the terminal modes set with options are entered
+// again.
 func (p *Program) Run() (Model, error) {
+	p.mu.Lock()
+	if p.ran {
+		p.resetRun()
+	}
+	p.ran = true
+	p.mu.Unlock()
+
 	handlers := channelHandlers{}
 	cmds := make(chan Cmd)
 	p.errs = make(chan error)
@@ -427,6 +908,23 @@ func (p *Program) Run() (Model, error) {
 
 	defer p.cancel()
 
+	// Make sure no other program is writing to the same output.
+	unlockOutput, err := p.lockOutput()
+	if err != nil {
+		return p.initialModel, err
+	}
+	defer unlockOutput()
+
+	p.msgLog.start()
+	defer p.msgLog.stop()
+
+	if p.recording != nil {
+		p.recording.begin()
+	}
+	if p.inputRecorder != nil {
+		p.inputRecorder.begin()
+	}
+
 	switch p.inputType {
 	case defaultInput:
 		p.input = os.Stdin
@@ -444,8 +942,18 @@ func (p *Program) Run() (Model, error) {
 		if term.IsTerminal(int(f.Fd())) {
 			break
 		}
+		if p.startupOptions.has(withLineInput) {
+			// Lines will be read from standard input instead.
+			break
+		}
+		if p.headless() {
+			// There's no user interface to type into, so don't go looking
+			// for a terminal.
+			p.input = nil
+			break
+		}
 
-		f, err := openInputTTY()
+		f, err := openTTY()
 		if err != nil {
 			return p.initialModel, err
 		}
@@ -454,7 +962,7 @@ func (p *Program) Run() (Model, error) {
 
 	case ttyInput:
 		// Open a new TTY, by request
-		f, err := openInputTTY()
+		f, err := openTTY()
 		if err != nil {
 			return p.initialModel, err
 		}
@@ -482,30 +990,93 @@ func (p *Program) Run() (Model, error) {
 		}()
 	}
 
-	// If no renderer is set use the standard one.
-	if p.renderer == nil {
-		p.renderer = newRenderer(p.output, p.startupOptions.has(withANSICompressor), p.fps)
+	if !p.headless() {
+		p.enableVTProcessing()
 	}
 
-	// Check if output is a TTY before entering raw mode, hiding the cursor and
-	// so on.
-	if err := p.initTerminal(); err != nil {
-		return p.initialModel, err
+	// If no renderer is set use the standard one, or the plain one if the
+	// output isn't a terminal.
+	if p.renderer == nil {
+		p.pickedRenderer = true
+		out := p.output
+		if p.recording != nil {
+			out = termenv.NewOutput(p.recording.output(p.output), termenv.WithProfile(p.output.Profile), termenv.WithColorCache(true))
+		}
+		switch {
+		case p.accessible():
+			r := newPlainRenderer(out, false, true)
+			r.changedLines = true
+			r.crlf = p.outputIsTerminal()
+			p.renderer = r
+		case p.usePlainOutput():
+			r := newPlainRenderer(out, p.startupOptions.has(withPlainOutputFinalFrame), p.dumbTerminal())
+			r.crlf = p.outputIsTerminal()
+			p.renderer = r
+		default:
+			compress := p.startupOptions.has(withANSICompressor) && p.compressorThreshold < 1
+			p.renderer = newRenderer(out, compress, p.fps, p.eastAsianWidth)
+		}
 	}
-
-	// Honor program startup options.
-	if p.startupOptions&withAltScreen != 0 {
-		p.renderer.enterAltScreen()
+	if r, ok := p.renderer.(*standardRenderer); ok {
+		r.onWriteError(func(err error) {
+			go p.Send(RenderErrorMsg{Err: err})
+		})
+		r.transforms = p.frameTransforms
+		r.onTransformError = func(err error) {
+			go p.Send(FrameTransformErrorMsg{Err: err})
+		}
+		r.onTruncate = func(msg ViewTruncatedMsg) {
+			go p.Send(msg)
+		}
+		r.onScrollAreaInvalidated = func() {
+			go p.Send(ScrollAreaInvalidatedMsg{})
+		}
+		r.onRegionDamaged = func(msg RegionDamagedMsg) {
+			go p.Send(msg)
+		}
+		if p.startupOptions.has(withDroppedFrameReports) {
+			r.onDroppedFrames = func(msg DroppedFramesMsg) {
+				go p.Send(msg)
+			}
+		}
+		r.bottomAnchor = p.startupOptions.has(withBottomAnchor)
+		r.keepFinalFrame = p.startupOptions.has(withKeepFinalFrame)
+		r.clearOnQuit = p.startupOptions.has(withClearOnQuit)
+		r.scoCursorSave = p.startupOptions.has(withSCOCursorSave)
+		r.restoreInlineFrame = p.startupOptions.has(withRestoreInlineFrame)
+		r.linkFallback = p.startupOptions.has(withHyperlinkFallback)
+		r.viewPosition = p.startupOptions.has(withMouseViewPosition) || r.linkFallback
+		if p.startupOptions.has(withStrictView) {
+			r.onCursorSequence = func(msg CursorSequenceMsg) {
+				go p.Send(msg)
+			}
+		}
+		if p.tabWidth > 0 {
+			r.tabWidth = p.tabWidth
+		}
+		r.compressorThreshold = p.compressorThreshold
 	}
-	if p.startupOptions&withoutBracketedPaste == 0 {
-		p.renderer.enableBracketedPaste()
+	if r, ok := p.renderer.(*TestRenderer); ok {
+		r.widthCond = newWidthCondition(p.eastAsianWidth)
+		if p.tabWidth > 0 {
+			r.tabWidth = p.tabWidth
+		}
 	}
-	if p.startupOptions&withMouseCellMotion != 0 {
-		p.renderer.enableMouseCellMotion()
-		p.renderer.enableMouseSGRMode()
-	} else if p.startupOptions&withMouseAllMotion != 0 {
-		p.renderer.enableMouseAllMotion()
-		p.renderer.enableMouseSGRMode()
+	p.claimRenderer()
+
+	// Set up the terminal and enter the modes the program was configured
+	// with all at once, before anything is rendered or any input is read, so
+	// the first frame is drawn in the right mode.
+	p.batchOutput(func() {
+		// Check if output is a TTY before entering raw mode, hiding the
+		// cursor and so on.
+		if err = p.initTerminal(); err != nil {
+			return
+		}
+		p.enterStartupModes()
+	})
+	if err != nil {
+		return p.initialModel, err
 	}
 
 	// Start the renderer.
@@ -513,6 +1084,7 @@ func (p *Program) Run() (Model, error) {
 
 	// Initialize the program.
 	model := p.initialModel
+	p.msgLog.log("Init")
 	if initCmd := model.Init(); initCmd != nil {
 		ch := make(chan struct{})
 		handlers.add(ch)
@@ -527,14 +1099,35 @@ func (p *Program) Run() (Model, error) {
 		}()
 	}
 
+	var initialMsgs []Msg
+	if profile, ok := p.colorProfileMsg(); ok {
+		initialMsgs = append(initialMsgs, profile)
+	}
+	if r, ok := p.renderer.(*plainRenderer); ok && r.changedLines {
+		initialMsgs = append(initialMsgs, AccessibleModeMsg{})
+	}
+
+	// Without a terminal to measure, report a made-up size right away, and
+	// let the renderer know about it before it renders anything.
+	if size, ok := p.syntheticWindowSize(); ok {
+		initialMsgs = append(initialMsgs, size)
+		if r, ok := p.renderer.(*standardRenderer); ok {
+			r.handleMessages(size)
+		}
+	}
+
 	// Render the initial view.
-	p.renderer.write(model.View())
+	p.renderer.write(p.view(model))
 
 	// Subscribe to user input.
 	if p.input != nil {
 		if err := p.initCancelReader(); err != nil {
 			return model, err
 		}
+		p.enableWin32InputMode()
+	}
+	if err := p.startAdditionalInputs(); err != nil {
+		return model, err
 	}
 
 	// Handle resize events.
@@ -543,14 +1136,19 @@ func (p *Program) Run() (Model, error) {
 	// Process commands.
 	handlers.add(p.handleCommands(cmds))
 
+	// Deliver queued messages.
+	if p.queue != nil {
+		handlers.add(p.forwardQueue())
+	}
+
 	// Run event loop, handle updates and draw.
-	model, err := p.eventLoop(model, cmds)
+	model, err = p.eventLoop(model, cmds, initialMsgs)
 	killed := p.ctx.Err() != nil
 	if killed {
 		err = ErrProgramKilled
 	} else {
 		// Ensure we rendered the final state of the model.
-		p.renderer.write(model.View())
+		p.renderer.write(p.view(model))
 	}
 
 	// Tear down.
@@ -564,6 +1162,7 @@ func (p *Program) Run() (Model, error) {
 		}
 		_ = p.cancelReader.Close()
 	}
+	p.stopAdditionalInputs()
 
 	// Wait for all handlers to finish.
 	handlers.shutdown()
@@ -571,9 +1170,70 @@ func (p *Program) Run() (Model, error) {
 	// Restore terminal state.
 	p.shutdown(killed)
 
+	if p.recording != nil {
+		p.recording.finish()
+	}
+
 	return model, err
 }
 
+// resetRun resets what's left of the last run, so the next one starts
+// afresh. It's called with p.mu held.
+func (p *Program) resetRun() {
+	p.ctx, p.cancel = context.WithCancel(p.parentCtx)
+	if p.pickedRenderer {
+		p.renderer = nil
+	}
+	p.tty, p.previousTtyState = nil, nil
+	p.cancelReader = nil
+	p.altScreenWasActive, p.bpWasActive, p.krWasActive = false, false, false
+	p.mouseCellMotion, p.mouseAllMotion, p.mousePixels = false, false, false
+	p.pixelMouse.reset()
+	p.exitCode = 0
+	atomic.StoreUint32(&p.ignoreSignals, 0)
+	atomic.StoreUint32(&p.forceQuit, 0)
+}
+
+// context returns the context of the current run, for goroutines other than
+// the ones the run started.
+func (p *Program) context() context.Context {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ctx
+}
+
+// enterStartupModes enters the terminal modes set with program options.
+func (p *Program) enterStartupModes() {
+	if p.startupOptions&withAltScreen != 0 {
+		p.renderer.enterAltScreen()
+	}
+	if p.startupOptions&withoutBracketedPaste == 0 {
+		p.renderer.enableBracketedPaste()
+	}
+	if p.startupOptions&withKeyReleases != 0 {
+		p.renderer.enableKeyReleases()
+	}
+	if p.startupOptions&withMouseCellMotion != 0 {
+		p.renderer.enableMouseCellMotion()
+		p.renderer.enableMouseSGRMode()
+		p.mouseCellMotion = true
+	} else if p.startupOptions&withMouseAllMotion != 0 {
+		p.renderer.enableMouseAllMotion()
+		p.renderer.enableMouseSGRMode()
+		p.mouseAllMotion = true
+	}
+}
+
+// batchOutput runs fn, writing what it has the renderer write to the
+// terminal all at once, if the renderer supports it.
+func (p *Program) batchOutput(fn func()) {
+	if r, ok := p.renderer.(*standardRenderer); ok {
+		r.batch(fn)
+		return
+	}
+	fn()
+}
+
 // StartReturningModel initializes the program and runs its event loops,
 // blocking until it gets terminated by either [Program.Quit], [Program.Kill],
 // or its signal handler. Returns the final model.
@@ -593,20 +1253,154 @@ func (p *Program) Start() error {
 	return err
 }
 
+// skipRepaints drops the repaint requests waiting in the priority lane. It
+// returns the first other message it came across, if any, so it can be
+// handled next.
+func (p *Program) skipRepaints() Msg {
+	for {
+		select {
+		case msg := <-p.priorityMsgs:
+			if _, ok := msg.(repaintMsg); !ok {
+				return msg
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// execCmd runs a command and returns its message. Commands made with
+// CmdWithContext are given the context of the run.
+func (p *Program) execCmd(ctx context.Context, cmd Cmd) Msg {
+	msg := cmd()
+	if fn, ok := msg.(contextCmdMsg); ok {
+		return fn(ctx)
+	}
+	return msg
+}
+
+// execSequenceMsg runs the commands of a sequence one at a time, in order,
+// sending each message before running the next command. Nested batches run to
+// completion, and nested sequences run in order, before the sequence moves on.
+func (p *Program) execSequenceMsg(ctx context.Context, msg sequenceMsg) {
+	for _, cmd := range msg {
+		if cmd == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			// The program has shut down; nothing would receive the rest.
+			return
+		}
+		p.execNestedCmd(ctx, cmd)
+	}
+}
+
+// execBatchMsg runs the commands of a batch concurrently and waits for all of
+// them, including any nested batches and sequences, to finish.
+func (p *Program) execBatchMsg(ctx context.Context, msg BatchMsg) {
+	g, _ := errgroup.WithContext(ctx)
+	for _, cmd := range msg {
+		if cmd == nil {
+			continue
+		}
+		cmd := cmd
+		g.Go(func() error {
+			p.execNestedCmd(ctx, cmd)
+			return nil
+		})
+	}
+
+	//nolint:errcheck
+	g.Wait() // wait for all commands from batch msg to finish
+}
+
+// execNestedCmd runs a command from a batch or sequence and sends its
+// message, waiting for nested batches and sequences to finish.
+func (p *Program) execNestedCmd(ctx context.Context, cmd Cmd) {
+	switch msg := p.execCmd(ctx, cmd).(type) {
+	case BatchMsg:
+		p.execBatchMsg(ctx, msg)
+	case sequenceMsg:
+		p.execSequenceMsg(ctx, msg)
+	default:
+		p.send(ctx, msg)
+	}
+}
+
 // Send sends a message to the main update function, effectively allowing
 // messages to be injected from outside the program for interoperability
 // purposes.
 //
-// If the program hasn't started yet this will be a blocking operation.
+// Key and mouse messages without a Time are stamped with the time they were
+// sent. Window size messages are delivered ahead of other pending messages,
+// so the program is drawn at the right size even when it's behind.
+//
+// If the program hasn't started yet this will be a blocking operation, unless
+// a message queue was set up with WithMessageQueueSize.
 // If the program has already been terminated this will be a no-op, so it's safe
-// to send messages after the program has exited.
+// to send messages after the program has exited. That holds until the program
+// is run again, so messages for the next run are sent once Run has been called.
 func (p *Program) Send(msg Msg) {
+	p.send(p.context(), msg)
+}
+
+// send sends a message like Send, for as long as ctx isn't done. Goroutines
+// of a run send with the run's context, so they don't hold on to messages
+// meant for it past its end.
+func (p *Program) send(ctx context.Context, msg Msg) {
+	msg = stampInput(msg, time.Now())
+	msgs := p.msgs
+	if isPriorityMsg(msg) {
+		msgs = p.priorityMsgs
+	} else if p.queue != nil {
+		p.queue.push(ctx, msg)
+		return
+	}
 	select {
-	case <-p.ctx.Done():
-	case p.msgs <- msg:
+	case <-ctx.Done():
+	case msgs <- msg:
 	}
 }
 
+// TrySend sends a message to the program like Send, but without blocking:
+// if the program can't take the message right away, it returns false and the
+// message isn't sent. This lets busy producers drop or sample messages
+// rather than being held up by the program.
+//
+// With a message queue set up with WithMessageQueueSize, the message is sent
+// if there's room in the queue, including before the program has started.
+// Otherwise, it's only sent if the program is waiting for a message. Once the
+// program has exited, TrySend always returns false.
+func (p *Program) TrySend(msg Msg) bool {
+	if p.context().Err() != nil {
+		return false
+	}
+
+	msg = stampInput(msg, time.Now())
+	msgs := p.msgs
+	if isPriorityMsg(msg) {
+		msgs = p.priorityMsgs
+	} else if p.queue != nil {
+		return p.queue.tryPush(msg)
+	}
+	select {
+	case msgs <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// isPriorityMsg reports whether a message affects how the whole screen is
+// drawn, and so is delivered ahead of other pending messages.
+func isPriorityMsg(msg Msg) bool {
+	switch msg.(type) {
+	case WindowSizeMsg, repaintMsg:
+		return true
+	}
+	return false
+}
+
 // Quit is a convenience function for quitting Bubble Tea programs. Use it
 // when you need to shut down a Bubble Tea program from the outside.
 //
@@ -618,10 +1412,24 @@ func (p *Program) Quit() {
 	p.Send(Quit())
 }
 
+// ExitCode returns the exit code the program quit with, once Run has
+// returned: the code passed to QuitWithCode, or zero if the program quit with
+// Quit. If the program quit because of a signal, the code follows the shell
+// convention of 128 plus the signal number, such as ExitInterrupted for
+// SIGINT.
+func (p *Program) ExitCode() int {
+	if atomic.LoadUint32(&p.forceQuit) != 0 {
+		return ExitInterrupted
+	}
+	return p.exitCode
+}
+
 // Kill stops the program immediately and restores the former terminal state.
 // The final render that you would normally see when quitting will be skipped.
 // [program.Run] returns a [ErrProgramKilled] error.
 func (p *Program) Kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.cancel()
 }
 
@@ -661,6 +1469,7 @@ func (p *Program) ReleaseTerminal() error {
 
 	p.altScreenWasActive = p.renderer.altScreen()
 	p.bpWasActive = p.renderer.bracketedPasteActive()
+	p.krWasActive = p.renderer.keyReleasesActive()
 	return p.restoreTerminalState()
 }
 
@@ -676,6 +1485,7 @@ func (p *Program) RestoreTerminal() error {
 	if err := p.initCancelReader(); err != nil {
 		return err
 	}
+	p.enableWin32InputMode()
 	if p.altScreenWasActive {
 		p.renderer.enterAltScreen()
 	} else {
@@ -688,6 +1498,9 @@ func (p *Program) RestoreTerminal() error {
 	if p.bpWasActive {
 		p.renderer.enableBracketedPaste()
 	}
+	if p.krWasActive {
+		p.renderer.enableKeyReleases()
+	}
 
 	// If the output is a terminal, it may have been resized while another
 	// process was at the foreground, in which case we may not have received
@@ -701,11 +1514,14 @@ func (p *Program) RestoreTerminal() error {
 // Println prints above the Program. This output is unmanaged by the program
 // and will persist across renders by the Program.
 //
+// Like Send, it's safe to call from any goroutine, blocks until the program
+// has started, and does nothing once the program has exited.
+//
 // If the altscreen is active no output will be printed.
 func (p *Program) Println(args ...interface{}) {
-	p.msgs <- printLineMessage{
+	p.Send(printLineMessage{
 		messageBody: fmt.Sprint(args...),
-	}
+	})
 }
 
 // Printf prints above the Program. It takes a format template followed by
@@ -715,9 +1531,12 @@ func (p *Program) Println(args ...interface{}) {
 // Unlike fmt.Printf (but similar to log.Printf) the message will be print on
 // its own line.
 //
+// Like Send, it's safe to call from any goroutine, blocks until the program
+// has started, and does nothing once the program has exited.
+//
 // If the altscreen is active no output will be printed.
 func (p *Program) Printf(template string, args ...interface{}) {
-	p.msgs <- printLineMessage{
+	p.Send(printLineMessage{
 		messageBody: fmt.Sprintf(template, args...),
-	}
+	})
 }
@@ -3,7 +3,15 @@ package tea
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -77,6 +85,40 @@ func TestTeaQuit(t *testing.T) {
 	}
 }
 
+func TestTeaQuitWithCode(t *testing.T) {
+	run := func(quit Cmd) (*Program, string) {
+		var buf bytes.Buffer
+		p := NewProgram(&testModel{}, WithInput(nil), WithOutput(&buf))
+		go p.Send(quit())
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return p, buf.String()
+	}
+
+	p, quitOut := run(Quit)
+	if code := p.ExitCode(); code != 0 {
+		t.Errorf("expected exit code 0 after Quit, got %d", code)
+	}
+
+	p, out := run(QuitWithCode(3))
+	if code := p.ExitCode(); code != 3 {
+		t.Errorf("expected exit code 3, got %d", code)
+	}
+	if out != quitOut {
+		t.Errorf("expected the same output as quitting normally:\n%q\ngot:\n%q", quitOut, out)
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	if code := signalExitCode(syscall.SIGINT); code != 130 {
+		t.Errorf("expected exit code 130 for SIGINT, got %d", code)
+	}
+	if code := signalExitCode(syscall.SIGTERM); code != 143 {
+		t.Errorf("expected exit code 143 for SIGTERM, got %d", code)
+	}
+}
+
 func TestTeaWithFilter(t *testing.T) {
 	testTeaWithFilter(t, 0)
 	testTeaWithFilter(t, 1)
@@ -238,6 +280,321 @@ func TestTeaSequenceMsgWithBatchMsg(t *testing.T) {
 	}
 }
 
+type orderMsg int
+
+type orderModel struct {
+	mtx  *sync.Mutex
+	msgs *[]int
+}
+
+func (m orderModel) Init() Cmd { return nil }
+
+func (m orderModel) Update(msg Msg) (Model, Cmd) {
+	if o, ok := msg.(orderMsg); ok {
+		m.mtx.Lock()
+		*m.msgs = append(*m.msgs, int(o))
+		m.mtx.Unlock()
+	}
+	return m, nil
+}
+
+func (m orderModel) View() string { return "" }
+
+func TestTeaSequenceOrder(t *testing.T) {
+	send := func(i int) Cmd {
+		return func() Msg {
+			// Make earlier commands slower so they'd lose a race.
+			time.Sleep(time.Duration(10-i) * time.Millisecond)
+			return orderMsg(i)
+		}
+	}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	var msgs []int
+	m := orderModel{mtx: &sync.Mutex{}, msgs: &msgs}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+	go p.Send(Sequence(
+		send(1),
+		nil,
+		Batch(send(2), send(3)),
+		Sequence(send(4), send(5)),
+		send(6),
+		Quit,
+		send(7),
+	)())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if len(msgs) != 6 {
+		t.Fatalf("expected 6 messages before quitting, got %v", msgs)
+	}
+	if msgs[0] != 1 {
+		t.Errorf("expected 1 first, got %v", msgs)
+	}
+	// The batch runs concurrently, but completes before the sequence moves
+	// on.
+	if !(msgs[1] == 2 && msgs[2] == 3) && !(msgs[1] == 3 && msgs[2] == 2) {
+		t.Errorf("expected the batch to be delivered second, got %v", msgs)
+	}
+	if msgs[3] != 4 || msgs[4] != 5 || msgs[5] != 6 {
+		t.Errorf("expected the nested sequence and then 6, got %v", msgs)
+	}
+}
+
+func TestTeaCanceledTick(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	var msgs []int
+	m := orderModel{mtx: &sync.Mutex{}, msgs: &msgs}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	canceled, cancel := newCancelFunc()
+	cancel()
+	live, _ := newCancelFunc()
+	go func() {
+		p.Send(cancelableMsg{done: canceled, msg: orderMsg(1)})
+		p.Send(cancelableMsg{done: live, msg: orderMsg(2)})
+		p.Quit()
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if len(msgs) != 1 || msgs[0] != 2 {
+		t.Errorf("expected only the live tick to be delivered, got %v", msgs)
+	}
+}
+
+type cmdModel struct {
+	cmd Cmd
+}
+
+func (m cmdModel) Init() Cmd { return m.cmd }
+
+func (m cmdModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(KeyMsg); ok {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m cmdModel) View() string { return "" }
+
+func TestTeaCmdWithContext(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	cmd := CmdWithContext(func(ctx context.Context) Msg {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return incrementMsg{}
+	})
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	p := NewProgram(cmdModel{cmd: Batch(cmd, nil)}, WithInput(&in), WithOutput(&buf))
+	go func() {
+		<-started
+		p.Send(KeyMsg{Type: KeyEnter})
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the command's context to be canceled when the program quit")
+	}
+}
+
+type priorityModel struct {
+	gate    chan struct{}
+	total   int
+	resized *int
+	seen    *int
+}
+
+func (m priorityModel) Init() Cmd { return nil }
+
+func (m priorityModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case orderMsg:
+		if *m.seen == 0 {
+			// Stall until the backlog has built up.
+			<-m.gate
+		}
+	case WindowSizeMsg:
+		*m.resized = *m.seen
+	default:
+		return m, nil
+	}
+	*m.seen++
+	if *m.seen == m.total {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m priorityModel) View() string { return "" }
+
+func TestTeaPriorityMsgs(t *testing.T) {
+	const fillers = 1000
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	resized, seen := -1, 0
+	m := priorityModel{gate: make(chan struct{}), total: fillers + 2, resized: &resized, seen: &seen}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go func() {
+		p.Send(orderMsg(0))
+		for i := 0; i < fillers; i++ {
+			go p.Send(orderMsg(i + 1))
+		}
+		time.Sleep(50 * time.Millisecond)
+		go p.Send(WindowSizeMsg{Width: 80, Height: 24})
+		time.Sleep(50 * time.Millisecond)
+		close(m.gate)
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if resized < 0 || resized > 2 {
+		t.Errorf("expected the resize within the first few messages, got it after %d", resized)
+	}
+}
+
+type countingModel struct {
+	stalled chan struct{}
+	gate    chan struct{}
+	mtx     *sync.Mutex
+	counts  map[string]int
+}
+
+func (m countingModel) Init() Cmd { return nil }
+
+func (m countingModel) Update(msg Msg) (Model, Cmd) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	switch msg := msg.(type) {
+	case orderMsg:
+		if msg == 0 {
+			m.mtx.Unlock()
+			close(m.stalled)
+			<-m.gate
+			m.mtx.Lock()
+			return m, nil
+		}
+		return m, Quit
+	case repaintMsg:
+		m.counts["repaint"]++
+	case WindowSizeMsg:
+		m.counts[fmt.Sprintf("%dx%d", msg.Width, msg.Height)]++
+	}
+	return m, nil
+}
+
+func (m countingModel) View() string { return "top\nbottom" }
+
+func TestTeaCoalesceRepaints(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	m := countingModel{
+		stalled: make(chan struct{}),
+		gate:    make(chan struct{}),
+		mtx:     &sync.Mutex{},
+		counts:  map[string]int{},
+	}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go func() {
+		p.Send(orderMsg(0))
+		<-m.stalled
+		for _, msg := range []Msg{
+			WindowSizeMsg{Width: 80, Height: 24},
+			repaintMsg{}, repaintMsg{}, repaintMsg{}, repaintMsg{}, repaintMsg{},
+			WindowSizeMsg{Width: 80, Height: 24},
+			WindowSizeMsg{Width: 100, Height: 24},
+		} {
+			go p.Send(msg)
+			// Give the sender time to queue up so the order is kept.
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(m.gate)
+
+		// A repaint after new content was rendered isn't collapsed.
+		time.Sleep(10 * time.Millisecond)
+		p.Send(repaintMsg{})
+		p.Send(orderMsg(1))
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	expected := map[string]int{"repaint": 2, "80x24": 1, "100x24": 1}
+	if !reflect.DeepEqual(m.counts, expected) {
+		t.Errorf("expected %v, got %v", expected, m.counts)
+	}
+}
+
+func TestTeaCoalescedRepaintRewritesFrame(t *testing.T) {
+	out := &lockedBuffer{}
+	m := countingModel{
+		stalled: make(chan struct{}),
+		gate:    make(chan struct{}),
+		mtx:     &sync.Mutex{},
+		counts:  map[string]int{},
+	}
+	p := NewProgram(m, WithInput(nil), WithOutput(out))
+	done := make(chan error)
+	go func() {
+		_, err := p.Run()
+		done <- err
+	}()
+	waitForOutput(t, out, 0, "top\r\nbottom")
+
+	p.Send(orderMsg(0))
+	<-m.stalled
+	for i := 0; i < 5; i++ {
+		go p.Send(repaintMsg{})
+	}
+	time.Sleep(20 * time.Millisecond)
+	n := len(out.String())
+	close(m.gate)
+
+	// The view didn't change, but the repaints, collapsed into one, write
+	// the whole frame again.
+	waitForOutput(t, out, n, "top\r\nbottom")
+	p.Send(orderMsg(1))
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if rewritten := strings.Count(out.String()[n:], "top"); rewritten != 1 {
+		t.Errorf("expected the frame to be written again once, got it %d times in %q", rewritten, out.String()[n:])
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if expected := map[string]int{"repaint": 1}; !reflect.DeepEqual(m.counts, expected) {
+		t.Errorf("expected %v, got %v", expected, m.counts)
+	}
+}
+
 func TestTeaSend(t *testing.T) {
 	var buf bytes.Buffer
 	var in bytes.Buffer
@@ -256,6 +613,32 @@ func TestTeaSend(t *testing.T) {
 	p.Send(Quit())
 }
 
+type releaseModel struct{}
+
+func (m releaseModel) Init() Cmd { return nil }
+
+func (m releaseModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(KeyReleaseMsg); ok {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m releaseModel) View() string { return "" }
+
+func TestTeaSendKeyRelease(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(releaseModel{}, WithInput(nil), WithOutput(&buf))
+
+	// Key releases sent to the program are delivered, even though the
+	// terminal wasn't asked to report them.
+	go p.Send(KeyReleaseMsg{Type: KeyEnter})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestTeaNoRun(t *testing.T) {
 	var buf bytes.Buffer
 	var in bytes.Buffer
@@ -263,3 +646,751 @@ func TestTeaNoRun(t *testing.T) {
 	m := &testModel{}
 	NewProgram(m, WithInput(&in), WithOutput(&buf))
 }
+
+func TestDebounceResize(t *testing.T) {
+	const delay = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notify := make(chan os.Signal)
+	var resizes int32
+	go debounceResize(ctx, notify, delay, func() {
+		atomic.AddInt32(&resizes, 1)
+	})
+
+	// A burst of resizes is reported once right away and once it settles.
+	for i := 0; i < 20; i++ {
+		notify <- os.Interrupt
+	}
+	if n := atomic.LoadInt32(&resizes); n != 1 {
+		t.Fatalf("expected the first resize to be reported right away, got %d", n)
+	}
+	time.Sleep(3 * delay)
+	if n := atomic.LoadInt32(&resizes); n != 2 {
+		t.Fatalf("expected the final size to be reported once, got %d resizes", n)
+	}
+
+	// A lone resize is reported right away, and only once.
+	notify <- os.Interrupt
+	time.Sleep(3 * delay)
+	if n := atomic.LoadInt32(&resizes); n != 3 {
+		t.Fatalf("expected a lone resize to be reported once, got %d resizes", n)
+	}
+}
+
+func TestTeaInputTTY(t *testing.T) {
+	defer func(open func() (*os.File, error)) { openTTY = open }(openTTY)
+
+	t.Run("no controlling terminal", func(t *testing.T) {
+		errNoTTY := errors.New("no tty")
+		openTTY = func() (*os.File, error) { return nil, errNoTTY }
+
+		var buf bytes.Buffer
+		p := NewProgram(&testModel{}, WithInputTTY(), WithOutput(&buf))
+		if _, err := p.Run(); !errors.Is(err, errNoTTY) {
+			t.Fatalf("expected error %v, got %v", errNoTTY, err)
+		}
+	})
+
+	t.Run("reads from tty", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close() //nolint:errcheck
+		openTTY = func() (*os.File, error) { return r, nil }
+
+		if _, err := w.WriteString("q"); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		p := NewProgram(&testModel{}, WithInputTTY(), WithOutput(&buf))
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("expected tty to be closed, got %v", err)
+		}
+	})
+}
+
+func TestTeaWithoutRenderer(t *testing.T) {
+	defer func(open func() (*os.File, error)) { openTTY = open }(openTTY)
+	openTTY = func() (*os.File, error) {
+		t.Error("expected the controlling terminal not to be opened")
+		return nil, errors.New("no tty")
+	}
+
+	var buf bytes.Buffer
+	m := cmdModel{cmd: Sequence(
+		EnterAltScreen,
+		EnableMouseAllMotion,
+		HideCursor,
+		SetWindowTitle("title"),
+		ClearScreen,
+		Println("hello"),
+		func() Msg { return KeyMsg{Type: KeyEnter} },
+	)}
+	p := NewProgram(m, WithoutRenderer(), WithOutput(&buf))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.tty != nil {
+		t.Errorf("expected the terminal to be left alone")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestTeaShutdownWithIdleInput(t *testing.T) {
+	stops := map[string]func(p *Program, cancel context.CancelFunc){
+		"quit":    func(p *Program, _ context.CancelFunc) { p.Quit() },
+		"kill":    func(p *Program, _ context.CancelFunc) { p.Kill() },
+		"context": func(_ *Program, cancel context.CancelFunc) { cancel() },
+	}
+
+	for name, stop := range stops {
+		stop := stop
+		t.Run(name, func(t *testing.T) {
+			// An input that never produces any data.
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close() //nolint:errcheck
+			defer w.Close() //nolint:errcheck
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var buf bytes.Buffer
+			p := NewProgram(&testModel{}, WithContext(ctx), WithInput(r), WithOutput(&buf))
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _ = p.Run()
+			}()
+
+			p.Send(incrementMsg{})
+			stop(p, cancel)
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Run didn't return while waiting for input")
+			}
+			select {
+			case <-p.readLoopDone:
+			case <-time.After(time.Second):
+				t.Error("input reader is still running")
+			}
+		})
+	}
+}
+
+type keyTimeModel struct {
+	times chan time.Time
+}
+
+func (m keyTimeModel) Init() Cmd { return nil }
+
+func (m keyTimeModel) Update(msg Msg) (Model, Cmd) {
+	if k, ok := msg.(KeyMsg); ok {
+		m.times <- k.Time
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m keyTimeModel) View() string { return "" }
+
+func TestTeaSendStampsInput(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := keyTimeModel{times: make(chan time.Time, 1)}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	before := time.Now()
+	go p.Send(KeyMsg{Type: KeyEnter})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := <-m.times
+	if ts.Before(before) || ts.After(time.Now()) {
+		t.Errorf("expected key to be stamped when it was sent, got %v", ts)
+	}
+}
+
+type sizeModel struct {
+	msgs *[]Msg
+	quit int
+}
+
+func (m sizeModel) Init() Cmd {
+	return func() Msg { return incrementMsg{} }
+}
+
+func (m sizeModel) Update(msg Msg) (Model, Cmd) {
+	*m.msgs = append(*m.msgs, msg)
+	if len(*m.msgs) == m.quit {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m sizeModel) View() string { return strings.Repeat("x", 20) + "\n" }
+
+func TestTeaInitialWindowSize(t *testing.T) {
+	var buf bytes.Buffer
+	var msgs []Msg
+	p := NewProgram(sizeModel{msgs: &msgs, quit: 2},
+		WithInput(nil),
+		WithOutput(&buf),
+		WithInitialWindowSize(10, 5))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 2 || msgs[0] != (WindowSizeMsg{Width: 10, Height: 5}) {
+		t.Fatalf("expected the initial window size first, got %v", msgs)
+	}
+	if out := buf.String(); !strings.Contains(out, strings.Repeat("x", 10)) || strings.Contains(out, strings.Repeat("x", 11)) {
+		t.Errorf("expected the view to be truncated to 10 columns, got %q", out)
+	}
+
+	t.Run("resize", func(t *testing.T) {
+		var msgs []Msg
+		p := NewProgram(sizeModel{msgs: &msgs, quit: 3},
+			WithInput(nil),
+			WithOutput(&bytes.Buffer{}),
+			WithInitialWindowSize(10, 5))
+		go p.Send(WindowSizeMsg{Width: 15, Height: 5})
+		m, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var last WindowSizeMsg
+		for _, msg := range *m.(sizeModel).msgs {
+			if size, ok := msg.(WindowSizeMsg); ok {
+				last = size
+			}
+		}
+		if last.Width != 15 {
+			t.Errorf("expected a later resize to override the initial size, got %v", msgs)
+		}
+	})
+}
+
+type printModel struct{}
+
+func (m printModel) Init() Cmd { return nil }
+
+func (m printModel) Update(msg Msg) (Model, Cmd) { return m, nil }
+
+func (m printModel) View() string { return "managed\n" }
+
+func TestTeaPrintln(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(printModel{}, WithInput(nil), WithOutput(&buf))
+
+	// Print before the program has started.
+	go func() {
+		p.Println("line", 1)
+		p.Printf("line %d", 2)
+		p.Quit()
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	first, second, managed := strings.Index(out, "line1"), strings.Index(out, "line 2"), strings.LastIndex(out, "managed")
+	if first < 0 || second < first || managed < second {
+		t.Errorf("expected the lines to be printed in order above the managed output, got %q", out)
+	}
+
+	// Printing once the program has exited does nothing.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Println("too late")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Println to return after the program exited")
+	}
+}
+
+type slowModel struct {
+	slow  *SlowHandlerMsg
+	sleep time.Duration
+}
+
+func (m slowModel) Init() Cmd {
+	return func() Msg {
+		// Time spent in commands doesn't count.
+		time.Sleep(2 * m.sleep)
+		return incrementMsg{}
+	}
+}
+
+func (m slowModel) Update(msg Msg) (Model, Cmd) {
+	switch msg := msg.(type) {
+	case incrementMsg:
+		time.Sleep(m.sleep)
+	case SlowHandlerMsg:
+		m.slow = &msg
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m slowModel) View() string { return "" }
+
+func TestTeaSlowHandler(t *testing.T) {
+	var trace bytes.Buffer
+	p := NewProgram(slowModel{sleep: 50 * time.Millisecond},
+		WithInput(nil),
+		WithOutput(&bytes.Buffer{}),
+		WithMessageLogger(&trace, false),
+		WithSlowHandlerThreshold(20*time.Millisecond))
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slow := m.(slowModel).slow
+	if slow == nil {
+		t.Fatal("expected a SlowHandlerMsg")
+	}
+	if slow.Handler != "Update" || slow.MsgType != "tea.incrementMsg" || slow.Elapsed != 20*time.Millisecond {
+		t.Errorf("expected a slow Update handling a tea.incrementMsg, got %+v", *slow)
+	}
+	if !strings.Contains(trace.String(), " Slow Update tea.incrementMsg took ") {
+		t.Errorf("expected the slow Update to be logged, got %q", trace.String())
+	}
+}
+
+type hungModel struct {
+	release chan struct{}
+}
+
+func (m hungModel) Init() Cmd {
+	return func() Msg { return incrementMsg{} }
+}
+
+func (m hungModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case incrementMsg:
+		<-m.release
+	case SlowHandlerMsg:
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m hungModel) View() string { return "" }
+
+func TestTeaSlowHandlerHung(t *testing.T) {
+	trace := &lockedBuffer{}
+	m := hungModel{release: make(chan struct{})}
+	p := NewProgram(m,
+		WithInput(nil),
+		WithOutput(&bytes.Buffer{}),
+		WithMessageLogger(trace, false),
+		WithSlowHandlerThreshold(20*time.Millisecond))
+	done := make(chan error)
+	go func() {
+		_, err := p.Run()
+		done <- err
+	}()
+
+	// The Update is logged while it's still hung.
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(trace.String(), " Slow Update tea.incrementMsg took over 20ms") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the hung Update to be logged, got %q", trace.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Its report is delivered once it returns.
+	close(m.release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the SlowHandlerMsg")
+	}
+}
+
+type slowViewModel struct {
+	slow *[]SlowHandlerMsg
+}
+
+func (m slowViewModel) Init() Cmd {
+	return Batch(
+		func() Msg { return incrementMsg{} },
+		Tick(150*time.Millisecond, func(time.Time) Msg { return QuitMsg{} }),
+	)
+}
+
+func (m slowViewModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(SlowHandlerMsg); ok {
+		*m.slow = append(*m.slow, msg)
+	}
+	return m, nil
+}
+
+func (m slowViewModel) View() string {
+	time.Sleep(20 * time.Millisecond)
+	return ""
+}
+
+func TestTeaSlowView(t *testing.T) {
+	var slow []SlowHandlerMsg
+	p := NewProgram(slowViewModel{slow: &slow},
+		WithInput(nil),
+		WithOutput(&bytes.Buffer{}),
+		WithSlowHandlerThreshold(5*time.Millisecond))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drawing the reports of slow views isn't reported, or the program
+	// would do nothing else.
+	if len(slow) == 0 {
+		t.Fatal("expected a SlowHandlerMsg")
+	}
+	for _, msg := range slow {
+		if msg.Handler != "View" || msg.MsgType == "tea.SlowHandlerMsg" {
+			t.Errorf("expected only views of other messages to be reported, got %+v", msg)
+		}
+	}
+	if len(slow) != 1 {
+		t.Errorf("expected the view of the one message to be reported, got %d reports", len(slow))
+	}
+}
+
+// fakeSignals makes the signal handler listen to a channel returned by the
+// function it returns, rather than to signals sent to the process.
+func fakeSignals(t *testing.T) func() chan<- os.Signal {
+	t.Helper()
+
+	chans := make(chan chan<- os.Signal, 1)
+	notify, stop := notifySignals, stopSignals
+	notifySignals = func(c chan<- os.Signal, _ ...os.Signal) { chans <- c }
+	stopSignals = func(chan<- os.Signal) {}
+	t.Cleanup(func() {
+		notifySignals, stopSignals = notify, stop
+	})
+
+	var ch chan<- os.Signal
+	return func() chan<- os.Signal {
+		if ch == nil {
+			ch = <-chans
+		}
+		return ch
+	}
+}
+
+type interruptModel struct {
+	interrupts *int32
+	quit       bool
+}
+
+func (m interruptModel) Init() Cmd { return nil }
+
+func (m interruptModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(InterruptMsg); ok {
+		atomic.AddInt32(m.interrupts, 1)
+		if m.quit {
+			return m, Quit
+		}
+	}
+	return m, nil
+}
+
+func (m interruptModel) View() string { return "" }
+
+func TestTeaSignals(t *testing.T) {
+	t.Run("quit", func(t *testing.T) {
+		sigs := fakeSignals(t)
+		p := NewProgram(interruptModel{interrupts: new(int32)}, WithInput(nil), WithOutput(&bytes.Buffer{}))
+		go func() { sigs() <- syscall.SIGINT }()
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if code := p.ExitCode(); code != ExitInterrupted {
+			t.Errorf("expected exit code %d, got %d", ExitInterrupted, code)
+		}
+	})
+
+	t.Run("interrupt", func(t *testing.T) {
+		sigs := fakeSignals(t)
+		var interrupts int32
+		p := NewProgram(interruptModel{interrupts: &interrupts, quit: true},
+			WithInput(nil),
+			WithOutput(&bytes.Buffer{}),
+			WithInterruptMsg())
+		go func() { sigs() <- syscall.SIGINT }()
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if interrupts != 1 {
+			t.Errorf("expected an InterruptMsg, got %d", interrupts)
+		}
+		if code := p.ExitCode(); code != 0 {
+			t.Errorf("expected the model to quit with exit code 0, got %d", code)
+		}
+	})
+
+	t.Run("interrupt twice", func(t *testing.T) {
+		sigs := fakeSignals(t)
+		var interrupts int32
+		p := NewProgram(interruptModel{interrupts: &interrupts},
+			WithInput(nil),
+			WithOutput(&bytes.Buffer{}),
+			WithInterruptMsg())
+		go func() {
+			sigs() <- syscall.SIGINT
+			for atomic.LoadInt32(&interrupts) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			sigs() <- syscall.SIGINT
+		}()
+
+		if _, err := p.Run(); !errors.Is(err, ErrProgramKilled) {
+			t.Fatalf("expected the program to be killed, got %v", err)
+		}
+		if n := atomic.LoadInt32(&interrupts); n != 1 {
+			t.Errorf("expected one InterruptMsg, got %d", n)
+		}
+		if code := p.ExitCode(); code != ExitInterrupted {
+			t.Errorf("expected exit code %d, got %d", ExitInterrupted, code)
+		}
+	})
+}
+
+type terminateModel struct {
+	terminated *TerminateMsg
+}
+
+func (m terminateModel) Init() Cmd { return nil }
+
+func (m terminateModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(TerminateMsg); ok {
+		m.terminated = &msg
+	}
+	return m, nil
+}
+
+func (m terminateModel) View() string { return "success\n" }
+
+func TestTeaTerminate(t *testing.T) {
+	var quitOut bytes.Buffer
+	p := NewProgram(terminateModel{}, WithInput(nil), WithOutput(&quitOut))
+	go p.Quit()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		sig  os.Signal
+		code int
+	}{
+		{syscall.SIGTERM, ExitTerminated},
+		{syscall.SIGHUP, ExitHangup},
+	} {
+		t.Run(tc.sig.String(), func(t *testing.T) {
+			sigs := fakeSignals(t)
+			var buf bytes.Buffer
+			p := NewProgram(terminateModel{}, WithInput(nil), WithOutput(&buf))
+			go func() { sigs() <- tc.sig }()
+
+			m, err := p.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tm := m.(terminateModel).terminated; tm == nil || tm.Signal != tc.sig {
+				t.Errorf("expected a TerminateMsg for %v, got %v", tc.sig, tm)
+			}
+			if code := p.ExitCode(); code != tc.code {
+				t.Errorf("expected exit code %d, got %d", tc.code, code)
+			}
+			// The program shuts down the same way as when quitting.
+			if buf.String() != quitOut.String() {
+				t.Errorf("expected output:\n%q\ngot:\n%q", quitOut.String(), buf.String())
+			}
+		})
+	}
+}
+
+type eofModel struct {
+	msgs *[]Msg
+	quit bool
+}
+
+func (m eofModel) Init() Cmd { return nil }
+
+func (m eofModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case KeyMsg:
+		*m.msgs = append(*m.msgs, msg)
+	case InputEOFMsg:
+		*m.msgs = append(*m.msgs, msg)
+		if m.quit {
+			return m, Quit
+		}
+	}
+	return m, nil
+}
+
+func (m eofModel) View() string { return "" }
+
+func TestTeaInputEOF(t *testing.T) {
+	t.Run("message", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = pw.Write([]byte("a"))
+			_ = pw.Close()
+		}()
+
+		var msgs []Msg
+		p := NewProgram(eofModel{msgs: &msgs, quit: true}, WithInput(pr), WithOutput(&bytes.Buffer{}))
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 2 || msgs[1] != (InputEOFMsg{}) {
+			t.Errorf("expected a key followed by an InputEOFMsg, got %v", msgs)
+		}
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		var msgs []Msg
+		p := NewProgram(eofModel{msgs: &msgs},
+			WithInput(bytes.NewBufferString("a")),
+			WithOutput(&bytes.Buffer{}),
+			WithQuitOnInputEOF())
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 2 || msgs[1] != (InputEOFMsg{}) {
+			t.Errorf("expected an InputEOFMsg before quitting, got %v", msgs)
+		}
+	})
+}
+
+type runAgainModel struct {
+	inits *int
+}
+
+func (m runAgainModel) Init() Cmd {
+	*m.inits++
+	return Quit
+}
+
+func (m runAgainModel) Update(Msg) (Model, Cmd) { return m, nil }
+
+func (m runAgainModel) View() string { return "ran" }
+
+func TestTeaRunAgain(t *testing.T) {
+	var inits int
+	var buf bytes.Buffer
+	p := NewProgram(runAgainModel{inits: &inits},
+		WithInput(nil),
+		WithOutput(&buf),
+		WithAltScreen(),
+		WithMessageLogger(io.Discard, false))
+
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		if _, err := p.Run(); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+		outputs = append(outputs, buf.String())
+	}
+
+	if inits != 2 {
+		t.Errorf("expected the model to be initialized on each run, got %d inits", inits)
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("expected the second run to write what the first did:\n%q\n%q", outputs[0], outputs[1])
+	}
+}
+
+type runAgainSendModel struct {
+	started chan struct{}
+}
+
+func (m runAgainSendModel) Init() Cmd {
+	return func() Msg {
+		m.started <- struct{}{}
+		return nil
+	}
+}
+
+func (m runAgainSendModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(incrementMsg); ok {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m runAgainSendModel) View() string { return "" }
+
+func TestTeaRunAgainSend(t *testing.T) {
+	m := runAgainSendModel{started: make(chan struct{}, 1)}
+	p := NewProgram(m, WithInput(nil), WithOutput(io.Discard))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		// Messages sent as the run starts go to it, or are dropped if sent
+		// before, but don't race with it.
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			p.Send(struct{}{})
+		}()
+		go func() {
+			defer wg.Done()
+			p.TrySend(struct{}{})
+		}()
+		// Once it has started, messages go to the running program.
+		go func() {
+			defer wg.Done()
+			<-m.started
+			p.Send(incrementMsg{})
+		}()
+
+		errc := make(chan error, 1)
+		go func() {
+			_, err := p.Run()
+			errc <- err
+		}()
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Fatalf("run %d: %v", i+1, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("run %d: timeout waiting for the message sent to it", i+1)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,149 @@
+package teatest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+// Colors used to highlight where output differs from what was expected.
+const (
+	expectedColor = "\x1b[32m"
+	actualColor   = "\x1b[31m"
+	resetColor    = "\x1b[0m"
+)
+
+// AssertEqualBytes reports a test error if actual differs from expected,
+// reporting false. Both are shown with escape sequences and other control
+// characters spelled out, with the part from the first difference on
+// highlighted.
+func AssertEqualBytes(tb testing.TB, expected, actual []byte) bool {
+	tb.Helper()
+
+	i := mismatchIndex(expected, actual)
+	if i < 0 {
+		return true
+	}
+	tb.Errorf("output differs at byte %d:\nexpected: %s\n     got: %s",
+		i, ByteString(expected, i, expectedColor), ByteString(actual, i, actualColor))
+	return false
+}
+
+// AssertEqualText is like AssertEqualBytes, but ignores escape sequences, for
+// tests that only care about the text that's visible.
+func AssertEqualText(tb testing.TB, expected, actual []byte) bool {
+	tb.Helper()
+	return AssertEqualBytes(tb, StripANSI(expected), StripANSI(actual))
+}
+
+// AssertGolden compares actual with the golden file for the test, named after
+// the test and kept in testdata, using AssertEqualBytes. Run the tests with
+// the -update flag to write actual to the golden file instead.
+func AssertGolden(tb testing.TB, actual []byte) bool {
+	tb.Helper()
+	return assertGolden(tb, goldenPath(tb), actual, *update, AssertEqualBytes)
+}
+
+// AssertGoldenText is like AssertGolden, but ignores escape sequences like
+// AssertEqualText. The golden file holds the output as is.
+func AssertGoldenText(tb testing.TB, actual []byte) bool {
+	tb.Helper()
+	return assertGolden(tb, goldenPath(tb), actual, *update, AssertEqualText)
+}
+
+func goldenPath(tb testing.TB) string {
+	return filepath.Join("testdata", filepath.FromSlash(tb.Name())+".golden")
+}
+
+func assertGolden(
+	tb testing.TB,
+	path string,
+	actual []byte,
+	update bool,
+	assert func(tb testing.TB, expected, actual []byte) bool,
+) bool {
+	tb.Helper()
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gomnd
+			tb.Fatalf("could not create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil { //nolint:gomnd
+			tb.Fatalf("could not update golden file: %v", err)
+		}
+		return true
+	}
+
+	expected, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		tb.Fatalf("golden file %s doesn't exist, run the test with -update to create it", path)
+	}
+	if err != nil {
+		tb.Fatalf("could not read golden file: %v", err)
+	}
+	return assert(tb, expected, actual)
+}
+
+// mismatchIndex returns the index of the first byte where a and b differ, or
+// -1 if they're equal.
+func mismatchIndex(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
+
+// ByteString renders b on a single line with escape sequences and other
+// control characters spelled out, such as "\x1b[2J\n". If from is within b,
+// everything from that byte on is highlighted with the given color.
+func ByteString(b []byte, from int, color string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i, c := range b {
+		if i == from {
+			sb.WriteString(color)
+		}
+		switch {
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if from >= 0 && from < len(b) {
+		sb.WriteString(resetColor)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// StripANSI removes escape sequences from b, leaving the text.
+func StripANSI(b []byte) []byte {
+	return []byte(ansi.Strip(string(b)))
+}
@@ -0,0 +1,135 @@
+package teatest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTB records the errors reported to it.
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqualBytes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected string
+		actual   string
+		index    int
+	}{
+		{"equal", "\x1b[2Jhello", "\x1b[2Jhello", -1},
+		{"sequence", "\x1b[2Jhello", "\x1b[1Jhello", 2},
+		{"text", "hello\r\n", "help\r\n", 3},
+		{"shorter", "hello", "hell", 4},
+		{"longer", "hell", "hello", 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tb := &fakeTB{}
+			ok := AssertEqualBytes(tb, []byte(tc.expected), []byte(tc.actual))
+			if ok != (tc.index < 0) {
+				t.Fatalf("expected equal to be %v, got %v", tc.index < 0, ok)
+			}
+			if tc.index < 0 {
+				if len(tb.errors) != 0 {
+					t.Errorf("expected no errors, got %q", tb.errors)
+				}
+				return
+			}
+			if len(tb.errors) != 1 {
+				t.Fatalf("expected an error, got %q", tb.errors)
+			}
+			if prefix := fmt.Sprintf("output differs at byte %d:", tc.index); !strings.HasPrefix(tb.errors[0], prefix) {
+				t.Errorf("expected error to start with %q, got %q", prefix, tb.errors[0])
+			}
+		})
+	}
+}
+
+func TestAssertEqualText(t *testing.T) {
+	tb := &fakeTB{}
+	if !AssertEqualText(tb, []byte("\x1b[1mhello\x1b[0m"), []byte("\x1b[?25l\x1b]0;title\ahello")) {
+		t.Errorf("expected the text to be equal, got %q", tb.errors)
+	}
+	if AssertEqualText(tb, []byte("\x1b[1mhello"), []byte("\x1b[1mhullo")) {
+		t.Error("expected the text to differ")
+	}
+}
+
+func TestByteString(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		from     int
+		expected string
+	}{
+		{"\x1b[2Jhi\r\n", -1, `"\x1b[2Jhi\r\n"`},
+		{"abc", 1, `"a` + actualColor + `bc` + resetColor + `"`},
+		{"abc", 3, `"abc"`},
+		{`a"b\`, -1, `"a\"b\\"`},
+	} {
+		if s := ByteString([]byte(tc.in), tc.from, actualColor); s != tc.expected {
+			t.Errorf("expected %q to render as %q, got %q", tc.in, tc.expected, s)
+		}
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	for in, expected := range map[string]string{
+		"plain":                           "plain",
+		"\x1b[38;5;1mred\x1b[0m":          "red",
+		"\x1b]0;title\x07text":            "text",
+		"\x1b]52;c;?\x1b\\text":           "text",
+		"\x1b7saved\x1b8":                 "saved",
+		"\x1b[?25l\x1b[2J\x1b[1;1Hscreen": "screen",
+		"trailing\x1b[":                   "trailing",
+	} {
+		if s := string(StripANSI([]byte(in))); s != expected {
+			t.Errorf("expected %q to be stripped to %q, got %q", in, expected, s)
+		}
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "golden", "output.golden")
+	out := []byte("\x1b[2Jhello\r\n")
+
+	tb := &fakeTB{TB: t}
+	if !assertGolden(tb, path, out, true, AssertEqualBytes) {
+		t.Fatal("expected updating the golden file to succeed")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(out) {
+		t.Errorf("expected golden file to contain %q, got %q", out, b)
+	}
+
+	if !assertGolden(tb, path, out, false, AssertEqualBytes) {
+		t.Errorf("expected output to match the golden file, got %q", tb.errors)
+	}
+	if assertGolden(tb, path, []byte("\x1b[2Jhullo\r\n"), false, AssertEqualBytes) {
+		t.Error("expected different output not to match the golden file")
+	}
+	if !assertGolden(tb, path, []byte("\x1b[1mhello\r\n"), false, AssertEqualText) {
+		t.Errorf("expected the text to match the golden file, got %q", tb.errors)
+	}
+}
+
+func TestGoldenPath(t *testing.T) {
+	t.Run("sub", func(t *testing.T) {
+		expected := filepath.Join("testdata", "TestGoldenPath", "sub.golden")
+		if p := goldenPath(t); p != expected {
+			t.Errorf("expected golden file %q, got %q", expected, p)
+		}
+	})
+}
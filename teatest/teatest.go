@@ -0,0 +1,170 @@
+// Package teatest provides helpers for testing Bubble Tea programs end to
+// end. A TestModel runs a program against in-memory input and output, so
+// tests can send it keys and other messages, wait for its output to show
+// something, and inspect the model it quits with:
+//
+//	tm := teatest.NewTestModel(t, model{})
+//	tm.Type("hello")
+//	tm.PressKey(tea.KeyEnter)
+//	tm.WaitFor(func(out []byte) bool {
+//	    return bytes.Contains(out, []byte("Hello, hello!"))
+//	}, time.Second)
+//	tm.Quit()
+//	final := tm.FinalModel(time.Second).(model)
+package teatest
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pollInterval is how often WaitFor checks the output.
+const pollInterval = 10 * time.Millisecond
+
+// TestModel runs a program for a test.
+type TestModel struct {
+	tb      testing.TB
+	program *tea.Program
+	out     *safeBuffer
+
+	done       chan struct{}
+	finalModel tea.Model
+	err        error
+}
+
+// NewTestModel starts running a program with the given model, capturing its
+// output. It doesn't read any input; use Type, PressKey and Send to feed it
+// messages. Options are applied after the ones setting up the program's
+// input and output.
+//
+// The program is killed when the test finishes, if it hasn't quit by then.
+func NewTestModel(tb testing.TB, m tea.Model, opts ...tea.ProgramOption) *TestModel {
+	tb.Helper()
+
+	tm := &TestModel{
+		tb:   tb,
+		out:  &safeBuffer{},
+		done: make(chan struct{}),
+	}
+	opts = append([]tea.ProgramOption{
+		tea.WithInput(nil),
+		tea.WithOutput(tm.out),
+		tea.WithoutSignals(),
+	}, opts...)
+	tm.program = tea.NewProgram(m, opts...)
+
+	go func() {
+		defer close(tm.done)
+		tm.finalModel, tm.err = tm.program.Run()
+	}()
+	tb.Cleanup(func() {
+		tm.program.Kill()
+		<-tm.done
+	})
+
+	return tm
+}
+
+// Send sends a message to the program.
+func (tm *TestModel) Send(msg tea.Msg) {
+	tm.program.Send(msg)
+}
+
+// Type sends a key message for each character in s, as if it was typed.
+func (tm *TestModel) Type(s string) {
+	for _, r := range s {
+		tm.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+// PressKey sends a key message for the given key, such as tea.KeyEnter.
+func (tm *TestModel) PressKey(k tea.KeyType) {
+	tm.Send(tea.KeyMsg{Type: k})
+}
+
+// Resize sends a window size message, as if the terminal was resized.
+func (tm *TestModel) Resize(width, height int) {
+	tm.Send(tea.WindowSizeMsg{Width: width, Height: height})
+}
+
+// Quit tells the program to quit.
+func (tm *TestModel) Quit() {
+	tm.program.Quit()
+}
+
+// Output returns everything the program has written so far.
+func (tm *TestModel) Output() []byte {
+	return tm.out.Bytes()
+}
+
+// WaitFor waits for the program's output to satisfy cond, failing the test
+// if it doesn't within the given timeout. cond is called with everything the
+// program has written so far.
+func (tm *TestModel) WaitFor(cond func(output []byte) bool, timeout time.Duration) {
+	tm.tb.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		out := tm.Output()
+		if cond(out) {
+			return
+		}
+		if time.Now().After(deadline) {
+			tm.tb.Fatalf("condition not met after %v, output: %q", timeout, out)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// FinalModel waits for the program to finish and returns the model it
+// finished with, failing the test if it doesn't finish within the given
+// timeout or returns an error.
+func (tm *TestModel) FinalModel(timeout time.Duration) tea.Model {
+	tm.tb.Helper()
+	tm.waitFinished(timeout)
+	return tm.finalModel
+}
+
+// FinalOutput waits for the program to finish like FinalModel, and returns
+// everything it wrote.
+func (tm *TestModel) FinalOutput(timeout time.Duration) []byte {
+	tm.tb.Helper()
+	tm.waitFinished(timeout)
+	return tm.Output()
+}
+
+func (tm *TestModel) waitFinished(timeout time.Duration) {
+	tm.tb.Helper()
+
+	select {
+	case <-tm.done:
+	case <-time.After(timeout):
+		tm.tb.Fatalf("program didn't finish after %v", timeout)
+	}
+	if tm.err != nil {
+		tm.tb.Fatalf("program failed: %v", tm.err)
+	}
+}
+
+// safeBuffer is a buffer that can be written to and read from concurrently.
+type safeBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p) //nolint:wrapcheck
+}
+
+// Bytes returns a copy of what has been written.
+func (b *safeBuffer) Bytes() []byte {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
@@ -0,0 +1,98 @@
+package teatest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type successModel struct{}
+
+func (m successModel) Init() tea.Cmd { return nil }
+
+func (m successModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+
+func (m successModel) View() string { return "success\n" }
+
+func TestClearScreen(t *testing.T) {
+	tm := NewTestModel(t, successModel{})
+	tm.Send(tea.Sequence(tea.ClearScreen, tea.Quit)())
+	AssertGolden(t, tm.FinalOutput(time.Second))
+}
+
+// textInputModel is a minimal text input: typed characters are added to the
+// value, backspace deletes the last one and enter submits it.
+type textInputModel struct {
+	value     string
+	submitted bool
+	width     int
+}
+
+func (m textInputModel) Init() tea.Cmd { return nil }
+
+func (m textInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyRunes:
+			m.value += string(msg.Runes)
+		case tea.KeyBackspace:
+			if m.value != "" {
+				m.value = m.value[:len(m.value)-1]
+			}
+		case tea.KeyEnter:
+			m.submitted = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m textInputModel) View() string {
+	if m.submitted {
+		return "Hello, " + m.value + "!\n"
+	}
+	return "Name: " + m.value + "_ (width " + strings.Repeat("=", m.width/10) + ")\n"
+}
+
+func TestTextInput(t *testing.T) {
+	tm := NewTestModel(t, textInputModel{})
+	tm.Resize(40, 10)
+	tm.Type("gopherr")
+	tm.PressKey(tea.KeyBackspace)
+
+	tm.WaitFor(func(out []byte) bool {
+		return bytes.Contains(out, []byte("Name: gopher_ (width ====)"))
+	}, time.Second)
+
+	tm.PressKey(tea.KeyEnter)
+
+	m, ok := tm.FinalModel(time.Second).(textInputModel)
+	if !ok {
+		t.Fatalf("expected a textInputModel, got %T", m)
+	}
+	if !m.submitted || m.value != "gopher" {
+		t.Errorf("expected %q to be submitted, got %+v", "gopher", m)
+	}
+	if out := tm.Output(); !bytes.Contains(out, []byte("Hello, gopher!")) {
+		t.Errorf("expected the final view in the output, got %q", out)
+	}
+}
+
+func TestKilledOnCleanup(t *testing.T) {
+	var tm *TestModel
+	t.Run("never quits", func(t *testing.T) {
+		tm = NewTestModel(t, successModel{})
+	})
+
+	select {
+	case <-tm.done:
+	default:
+		t.Fatal("expected the program to be stopped when the test finished")
+	}
+}
@@ -0,0 +1,197 @@
+package tea
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// TestRenderer is a renderer for testing views, such as those of components,
+// without a terminal. Rather than drawing frames it records them, and it
+// works out which lines of the last one would be visible the way the standard
+// renderer does: lines too wide for the screen are cut off, and when the
+// frame is taller than the screen, only its last lines fit. It doesn't touch
+// the terminal, so it doesn't get in the way of the test's output.
+//
+//	r := tea.NewTestRenderer(80, 24)
+//	p := tea.NewProgram(model{}, tea.WithInput(nil), tea.WithRenderer(r))
+//	if _, err := p.Run(); err != nil {
+//	    t.Fatal(err)
+//	}
+//	lines := r.LastVisibleLines()
+//
+// The size of the screen changes with the WindowSizeMsgs the test sends, as
+// it does for the standard renderer. It isn't measured from the terminal the
+// tests run in, so that their output doesn't depend on it.
+type TestRenderer struct {
+	mtx       sync.Mutex
+	frames    []string
+	lines     []string
+	width     int
+	height    int
+	tabWidth  int
+	widthCond *runewidth.Condition
+
+	altScreenActive bool
+	cursorHidden    bool
+	bpActive        bool
+	krActive        bool
+}
+
+// NewTestRenderer returns a TestRenderer for a screen of the given size. A
+// width or height of zero means it isn't known, in which case lines aren't
+// cut off in that direction.
+func NewTestRenderer(width, height int) *TestRenderer {
+	return &TestRenderer{
+		width:     width,
+		height:    height,
+		tabWidth:  defaultTabWidth,
+		widthCond: newWidthCondition(false),
+	}
+}
+
+// Frames returns every frame the renderer was given, as the view returned
+// them.
+func (r *TestRenderer) Frames() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	frames := make([]string, len(r.frames))
+	copy(frames, r.frames)
+	return frames
+}
+
+// LastVisibleLines returns the lines of the last frame that would be on
+// screen, cut off to the width of the screen, with escape sequences such as
+// colors as they are. As with the standard renderer, a view that ends with a
+// newline ends with an empty line, where the cursor rests.
+func (r *TestRenderer) LastVisibleLines() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	lines := make([]string, len(r.lines))
+	copy(lines, r.lines)
+	return lines
+}
+
+func (r *TestRenderer) write(s string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.frames = append(r.frames, s)
+	if s == "" {
+		s = " "
+	}
+	s = normalizeFrame(s, r.tabWidth, r.widthCond)
+	s, _ = stripCursorSequences(s)
+	s, _ = extractImageRows(s)
+	s, _ = extractCursor(s, r.widthCond)
+	r.lines = r.visibleLines(s)
+}
+
+// visibleLines splits a frame into lines, keeping the ones that fit on
+// screen.
+func (r *TestRenderer) visibleLines(frame string) []string {
+	lines := strings.Split(frame, "\n")
+	if r.height > 0 && len(lines) > r.height {
+		lines = lines[len(lines)-r.height:]
+	}
+	if r.width > 0 {
+		for i, line := range lines {
+			lines[i] = truncateLine(line, r.width, r.widthCond)
+		}
+	}
+	return lines
+}
+
+// handleMessages handles the messages that affect how frames are rendered.
+func (r *TestRenderer) handleMessages(msg Msg) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	switch msg := msg.(type) {
+	case WindowSizeMsg:
+		r.width = msg.Width
+		r.height = msg.Height
+	case setEastAsianWidthMsg:
+		r.widthCond = newWidthCondition(bool(msg))
+	}
+}
+
+func (r *TestRenderer) start()       {}
+func (r *TestRenderer) stop()        {}
+func (r *TestRenderer) kill()        {}
+func (r *TestRenderer) repaint()     {}
+func (r *TestRenderer) clearScreen() {}
+
+func (r *TestRenderer) altScreen() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.altScreenActive
+}
+
+func (r *TestRenderer) enterAltScreen() { r.setMode(&r.altScreenActive, true) }
+func (r *TestRenderer) exitAltScreen()  { r.setMode(&r.altScreenActive, false) }
+func (r *TestRenderer) showCursor()     { r.setMode(&r.cursorHidden, false) }
+func (r *TestRenderer) hideCursor()     { r.setMode(&r.cursorHidden, true) }
+
+func (r *TestRenderer) enableBracketedPaste()  { r.setMode(&r.bpActive, true) }
+func (r *TestRenderer) disableBracketedPaste() { r.setMode(&r.bpActive, false) }
+
+func (r *TestRenderer) bracketedPasteActive() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.bpActive
+}
+
+func (r *TestRenderer) enableKeyReleases()  { r.setMode(&r.krActive, true) }
+func (r *TestRenderer) disableKeyReleases() { r.setMode(&r.krActive, false) }
+
+func (r *TestRenderer) keyReleasesActive() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.krActive
+}
+
+// setMode records whether a terminal mode is on.
+func (r *TestRenderer) setMode(mode *bool, on bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	*mode = on
+}
+
+func (r *TestRenderer) enableMouseCellMotion()     {}
+func (r *TestRenderer) disableMouseCellMotion()    {}
+func (r *TestRenderer) enableMouseAllMotion()      {}
+func (r *TestRenderer) disableMouseAllMotion()     {}
+func (r *TestRenderer) enableMouseSGRMode()        {}
+func (r *TestRenderer) disableMouseSGRMode()       {}
+func (r *TestRenderer) enableMousePixelsMode()     {}
+func (r *TestRenderer) disableMousePixelsMode()    {}
+func (r *TestRenderer) enableWin32InputMode()      {}
+func (r *TestRenderer) disableWin32InputMode()     {}
+func (r *TestRenderer) requestTerminalAttributes() {}
+func (r *TestRenderer) readClipboard()             {}
+func (r *TestRenderer) queryWindowSize()           {}
+
+func (r *TestRenderer) setProgress(ProgressState, int) {}
+
+func (r *TestRenderer) screenState() ScreenStateMsg {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return ScreenStateMsg{
+		AltScreen:      r.altScreenActive,
+		BracketedPaste: r.bpActive,
+		CursorHidden:   r.cursorHidden,
+		Width:          r.width,
+		Height:         r.height,
+	}
+}
+
+func (r *TestRenderer) currentFrame(bool) (string, int, int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return strings.Join(r.lines, "\n"), r.width, r.height
+}
@@ -0,0 +1,23 @@
+package tea
+
+import "testing"
+
+func TestTestRendererInTerminal(t *testing.T) {
+	// The size of the terminal the tests run in is left out of it.
+	tty := openPty(t, 100, 50)
+	r := NewTestRenderer(10, 5)
+	p := NewProgram(windowSizeModel{}, WithInput(nil), WithOutput(tty), WithRenderer(r))
+	go p.Send(WindowSize())
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := WindowSizeMsg{Width: 10, Height: 5}
+	if size := m.(windowSizeModel).size; size != expected {
+		t.Errorf("expected the size of the renderer, %+v, got %+v", expected, size)
+	}
+	if state := r.screenState(); state.Width != 10 || state.Height != 5 {
+		t.Errorf("expected the renderer to stay 10x5, got %dx%d", state.Width, state.Height)
+	}
+}
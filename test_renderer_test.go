@@ -0,0 +1,70 @@
+package tea
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestTestRendererMatchesStandardRenderer(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		frame         string
+	}{
+		{"fits", 20, 10, "a\nb\nc"},
+		{"too wide", 5, 10, "abcdefgh\nab\nabcdef"},
+		{"too tall", 20, 3, "a\nb\nc\nd\ne"},
+		{"too wide and tall", 4, 2, "abcdef\nghijkl\nmnopqr"},
+		{"wide characters", 5, 10, "日本語です\nab"},
+		{"styled", 3, 10, "\x1b[31mabcdef\x1b[0m\nx"},
+		{"tabs", 12, 10, "a\tb\tc"},
+		{"unknown size", 0, 0, "abcdefgh\nb"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := NewTestRenderer(tc.width, tc.height)
+			tr.write(tc.frame)
+
+			// The standard renderer paints a frame from scratch with its
+			// visible lines separated by newlines, then parks the cursor.
+			var buf bytes.Buffer
+			sr := newRenderer(termenv.NewOutput(&buf, termenv.WithProfile(termenv.TrueColor)), false, 60, false).(*standardRenderer)
+			sr.width, sr.height = tc.width, tc.height
+			sr.write(tc.frame)
+			sr.flush()
+			out := strings.TrimSuffix(buf.String(), fmt.Sprintf("\x1b[%dD", tc.width))
+
+			if expected := strings.Join(tr.LastVisibleLines(), "\r\n"); out != expected {
+				t.Errorf("expected the standard renderer to paint %q, got %q", expected, out)
+			}
+		})
+	}
+}
+
+func TestTestRenderer(t *testing.T) {
+	const view = "line 0\nline 1\nline 2 is long\nline 3\n"
+	r := NewTestRenderer(20, 3)
+	p := NewProgram(viewModel(view), WithInput(nil), WithRenderer(r))
+	resize := func() Msg { return WindowSizeMsg{Width: 6, Height: 4} }
+	go p.Send(sequenceMsg{resize, Quit})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frame := range r.Frames() {
+		if frame != view {
+			t.Errorf("expected every frame to be the view, got %q", frame)
+		}
+	}
+
+	// The last frame is rendered at the new size.
+	if expected := []string{"line 1", "line 2", "line 3", ""}; !reflect.DeepEqual(r.LastVisibleLines(), expected) {
+		t.Errorf("expected %q, got %q", expected, r.LastVisibleLines())
+	}
+}
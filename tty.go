@@ -1,6 +1,7 @@
 package tea
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +13,10 @@ import (
 )
 
 func (p *Program) initTerminal() error {
+	if p.headless() {
+		// Leave the terminal as it is, in cooked mode.
+		return nil
+	}
 	if err := p.initInput(); err != nil {
 		return err
 	}
@@ -24,11 +29,27 @@ func (p *Program) initTerminal() error {
 // Bubble Tea program.
 func (p *Program) restoreTerminalState() error {
 	if p.renderer != nil {
-		p.renderer.disableBracketedPaste()
-		p.renderer.showCursor()
-		p.disableMouse()
+		// Modes the program sharing the output set the same way stay on
+		// for it. Key releases are always turned off, as each program
+		// pushes keyboard flags of its own.
+		shared := p.sharedModes()
+		mine := p.renderer.screenState()
+
+		p.renderer.setProgress(ProgressNone, 0)
+		if !shared.BracketedPaste {
+			p.renderer.disableBracketedPaste()
+		}
+		p.renderer.disableKeyReleases()
+		p.renderer.disableWin32InputMode()
+		if !shared.CursorHidden {
+			p.renderer.showCursor()
+		}
+		if mine.MouseCellMotion != shared.MouseCellMotion || mine.MouseAllMotion != shared.MouseAllMotion ||
+			mine.MousePixels != shared.MousePixels {
+			p.disableMouse()
+		}
 
-		if p.renderer.altScreen() {
+		if p.renderer.altScreen() && !shared.AltScreen {
 			p.renderer.exitAltScreen()
 
 			// give the terminal a moment to catch up
@@ -63,11 +84,73 @@ func (p *Program) initCancelReader() error {
 	return nil
 }
 
+// enableWin32InputMode asks the terminal to report keys in win32-input-mode if
+// the program was started with WithWin32InputMode. It's skipped when reading
+// from the Windows console directly, which already reports full key events.
+func (p *Program) enableWin32InputMode() {
+	if p.startupOptions.has(withWin32InputMode) && !isConsoleInputReader(p.cancelReader) {
+		p.renderer.enableWin32InputMode()
+	}
+}
+
 func (p *Program) readLoop() {
 	defer close(p.readLoopDone)
 
-	err := readInputs(p.ctx, p.msgs, p.cancelReader)
-	if !errors.Is(err, io.EOF) && !errors.Is(err, cancelreader.ErrCanceled) {
+	msgs := p.msgs
+	if p.startupOptions.has(withMouseMotionCoalescing) {
+		in := make(chan Msg)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			coalesceMouseMotion(p.ctx, in, p.msgs)
+		}()
+		defer func() {
+			close(in)
+			<-done
+		}()
+		msgs = in
+	}
+
+	var in io.Reader = p.cancelReader
+	if p.recording != nil && p.startupOptions.has(withRecordingInput) {
+		in = io.TeeReader(in, p.recording.input())
+	}
+	if p.inputRecorder != nil {
+		in = io.TeeReader(in, p.inputRecorder)
+	}
+
+	var err error
+	if p.startupOptions.has(withLineInput) && p.tty == nil {
+		err = readLines(p.ctx, msgs, in)
+	} else {
+		parser := newInputParser(p.keySequences, p.startupOptions.has(withRawPaste))
+		if r, ok := p.renderer.(*standardRenderer); ok {
+			// Key releases are only delivered when asked for. Mouse events
+			// are only translated from pixels once the terminal reports
+			// them in pixels, and are placed in the view.
+			parser = parser.withKeyReleases(r.keyReleasesActive).
+				withPixelMouse(&p.pixelMouse).
+				withView(r)
+		}
+		err = readInputs(p.ctx, msgs, in, parser)
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+		// There won't be any more input, which the program may be waiting
+		// for.
+		eof := []Msg{InputEOFMsg{}}
+		if p.startupOptions.has(withQuitOnInputEOF) {
+			eof = append(eof, QuitMsg{})
+		}
+		for _, msg := range eof {
+			select {
+			case <-p.ctx.Done():
+				return
+			case msgs <- msg:
+			}
+		}
+
+	case !errors.Is(err, cancelreader.ErrCanceled):
 		select {
 		case <-p.ctx.Done():
 		case p.errs <- err:
@@ -86,11 +169,71 @@ func (p *Program) waitForReadLoop() {
 	}
 }
 
+// debounceResize calls resize for each notification received on notify until
+// the context is done. A burst of notifications is collapsed: the first one is
+// handled right away so layouts update promptly, and the rest result in a
+// single call once no notification has arrived for the given delay. A delay
+// of zero or less disables this.
+func debounceResize(ctx context.Context, notify <-chan os.Signal, delay time.Duration, resize func()) {
+	var (
+		timer   *time.Timer
+		quiet   <-chan time.Time
+		pending bool
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-notify:
+			if delay <= 0 {
+				resize()
+				continue
+			}
+			if quiet == nil {
+				resize()
+			} else {
+				pending = true
+				timer.Stop()
+			}
+			timer = time.NewTimer(delay)
+			quiet = timer.C
+
+		case <-quiet:
+			quiet = nil
+			if pending {
+				pending = false
+				resize()
+			}
+		}
+	}
+}
+
+// sizeFromTerminal returns the terminal the program's size is read from, if
+// any. A TestRenderer keeps the size it was made with, whatever terminal the
+// tests run in.
+func (p *Program) sizeFromTerminal() (*os.File, bool) {
+	if _, ok := p.renderer.(*TestRenderer); ok {
+		return nil, false
+	}
+	f, ok := p.output.TTY().(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return nil, false
+	}
+	return f, true
+}
+
 // checkResize detects the current size of the output and informs the program
 // via a WindowSizeMsg.
 func (p *Program) checkResize() {
-	f, ok := p.output.TTY().(*os.File)
-	if !ok || !term.IsTerminal(int(f.Fd())) {
+	f, ok := p.sizeFromTerminal()
+	if !ok {
 		// can't query window size
 		return
 	}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 
+	"golang.org/x/sys/unix"
 	"golang.org/x/term"
 )
 
@@ -26,7 +27,17 @@ func (p *Program) initInput() (err error) {
 func openInputTTY() (*os.File, error) {
 	f, err := os.Open("/dev/tty")
 	if err != nil {
-		return nil, fmt.Errorf("could not open a new TTY: %w", err)
+		return nil, fmt.Errorf("could not open the controlling terminal for input: %w", err)
 	}
 	return f, nil
 }
+
+// cellSize returns the size of a terminal cell in pixels, if the terminal
+// reports it.
+func cellSize(f *os.File) (width, height int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 || ws.Xpixel == 0 || ws.Ypixel == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Xpixel) / int(ws.Col), int(ws.Ypixel) / int(ws.Row), true
+}
@@ -40,7 +40,13 @@ func (p *Program) initInput() (err error) {
 func openInputTTY() (*os.File, error) {
 	f, err := os.OpenFile("CONIN$", os.O_RDWR, 0644)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not open the console for input: %w", err)
 	}
 	return f, nil
 }
+
+// cellSize returns the size of a terminal cell in pixels. The Windows console
+// doesn't report it.
+func cellSize(*os.File) (width, height int, ok bool) {
+	return 0, 0, false
+}
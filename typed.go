@@ -0,0 +1,33 @@
+package tea
+
+import "fmt"
+
+// TypedProgram is a Program whose Run returns the final model as the type of
+// the initial one, so it doesn't need a type assertion:
+//
+//	p := tea.NewTypedProgram(model{})
+//	m, err := p.Run() // m is a model
+//
+// Update still returns a Model; it must return a model of the same type for
+// the final model to be returned.
+type TypedProgram[M Model] struct {
+	*Program
+}
+
+// NewTypedProgram creates a new TypedProgram. It takes the same options as
+// NewProgram.
+func NewTypedProgram[M Model](model M, opts ...ProgramOption) *TypedProgram[M] {
+	return &TypedProgram[M]{Program: NewProgram(model, opts...)}
+}
+
+// Run runs the program like [Program.Run], returning the final model as the
+// type of the initial one. If Update returned a model of a different type, an
+// error is returned along with the zero value of the type.
+func (p *TypedProgram[M]) Run() (M, error) {
+	model, err := p.Program.Run()
+	m, ok := model.(M)
+	if !ok && err == nil {
+		err = fmt.Errorf("final model is a %T, not a %T", model, p.initialModel)
+	}
+	return m, err
+}
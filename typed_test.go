@@ -0,0 +1,81 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+)
+
+type typedModel struct {
+	count int
+}
+
+func (m typedModel) Init() Cmd { return nil }
+
+func (m typedModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(incrementMsg); ok {
+		m.count++
+		if m.count == 3 {
+			return m, Quit
+		}
+	}
+	return m, nil
+}
+
+func (m typedModel) View() string { return "" }
+
+func TestTypedProgram(t *testing.T) {
+	var buf bytes.Buffer
+	var filtered int
+	p := NewTypedProgram(typedModel{},
+		WithInput(nil),
+		WithOutput(&buf),
+		WithFilter(func(_ Model, msg Msg) Msg {
+			if _, ok := msg.(incrementMsg); ok {
+				filtered++
+			}
+			return msg
+		}))
+	if p.input != nil || p.inputType != customInput {
+		t.Errorf("expected input to be disabled")
+	}
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			p.Send(incrementMsg{})
+		}
+	}()
+
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// m is a typedModel, with no type assertion.
+	if m.count != 3 {
+		t.Errorf("expected a count of 3, got %d", m.count)
+	}
+	if filtered != 3 {
+		t.Errorf("expected the filter to see 3 messages, got %d", filtered)
+	}
+	if buf.Len() == 0 {
+		t.Errorf("expected output to be written to the buffer")
+	}
+}
+
+// switchingModel switches to a typedModel on its first update.
+type switchingModel struct{}
+
+func (m switchingModel) Init() Cmd {
+	return func() Msg { return incrementMsg{} }
+}
+
+func (m switchingModel) Update(msg Msg) (Model, Cmd) { return typedModel{}, Quit }
+
+func (m switchingModel) View() string { return "" }
+
+func TestTypedProgramWrongModel(t *testing.T) {
+	p := NewTypedProgram(switchingModel{}, WithInput(nil), WithOutput(&bytes.Buffer{}))
+
+	if _, err := p.Run(); err == nil {
+		t.Fatal("expected an error for a final model of the wrong type")
+	}
+}
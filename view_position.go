@@ -0,0 +1,110 @@
+package tea
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/muesli/termenv"
+)
+
+// requestCursorPositionSeq asks the terminal where the cursor is, with the
+// DEC variant of the cursor position report, whose answer can't be mistaken
+// for a function key with modifiers.
+const requestCursorPositionSeq = "?6n"
+
+// cursorPositionReportRe matches the terminal's answer to
+// requestCursorPositionSeq:
+//
+//	CSI ? row ; column [; page] R
+var cursorPositionReportRe = regexp.MustCompile(`^\x1b\[\?(\d+);(\d+)(?:;\d+)?R`)
+
+// cursorPositionReportMsg is the terminal's answer to a request for the
+// position of the cursor, which tells where the view is on screen. Rows and
+// columns start at 1.
+type cursorPositionReportMsg struct {
+	row, col int
+}
+
+func (cursorPositionReportMsg) internalMsg() {}
+
+// detectCursorPositionReport detects the terminal's answer to a request for
+// the position of the cursor.
+func detectCursorPositionReport(input []byte) (hasReport bool, width int, msg Msg) {
+	m := cursorPositionReportRe.FindSubmatch(input)
+	if m == nil {
+		return false, 0, nil
+	}
+	row, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return false, 0, nil
+	}
+	col, err := strconv.Atoi(string(m[2]))
+	if err != nil {
+		return false, 0, nil
+	}
+	return true, len(m[0]), cursorPositionReportMsg{row: row, col: col}
+}
+
+// requestViewPosition asks the terminal where the cursor is, to learn where
+// the inline view is on screen, remembering how far below the top of the
+// view's area the cursor is. It's asked for when the mouse is enabled with
+// WithMouseViewPosition, so mouse events can be placed in the view. The mutex
+// must be held.
+func (r *standardRenderer) requestViewPosition() {
+	if !r.viewPosition || r.altScreenActive || !r.mouseActive() {
+		return
+	}
+	r.positionRow = r.blankAbove + r.renderingHead
+	_, _ = r.out.WriteString(termenv.CSI + requestCursorPositionSeq)
+}
+
+// placeView learns where the inline view is from the terminal's answer to a
+// request for the position of the cursor.
+func (r *standardRenderer) placeView(msg cursorPositionReportMsg) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.altScreenActive {
+		r.areaTop = msg.row - 1 - r.positionRow
+		r.areaTopKnown = true
+		r.trackScrolling()
+	}
+}
+
+// mouseActive reports whether a mouse mode is enabled.
+func (r *standardRenderer) mouseActive() bool {
+	return r.mouseCellMotionActive || r.mouseAllMotionActive
+}
+
+// trackScrolling moves the top of the view's area up by the lines the
+// terminal scrolled, when the view reaches past the bottom of the screen.
+func (r *standardRenderer) trackScrolling() {
+	if !r.areaTopKnown || r.height <= 0 {
+		return
+	}
+	bottom := r.areaTop + r.blankAbove + r.linesRendered
+	if bottom > r.height {
+		r.areaTop -= bottom - r.height
+	}
+	if r.areaTop < 0 {
+		r.areaTop = 0
+	}
+}
+
+// viewMouseEvent sets where a mouse event is relative to the view.
+func (r *standardRenderer) viewMouseEvent(m MouseEvent) MouseEvent {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	top := 0
+	if !r.altScreenActive {
+		if !r.areaTopKnown {
+			return m
+		}
+		top = r.areaTop + r.blankAbove
+	}
+	m.ViewX = m.X
+	m.ViewY = m.Y - top
+	m.InView = m.ViewY >= 0 && m.ViewY < r.linesRendered
+	return m
+}
@@ -0,0 +1,138 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// mouseModel records mouse events, and quits after the given number.
+type mouseModel struct {
+	quitAfter int
+	events    []MouseEvent
+}
+
+func (m mouseModel) Init() Cmd { return nil }
+
+func (m mouseModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(MouseMsg); ok {
+		m.events = append(m.events, MouseEvent(msg))
+		if len(m.events) == m.quitAfter {
+			return m, Quit
+		}
+	}
+	return m, nil
+}
+
+func (m mouseModel) View() string { return "line 0\nline 1\nline 2\nline 3\nline 4" }
+
+func TestInlineMouseCoordinates(t *testing.T) {
+	// The view takes up rows 18 to 22 of a 24 row terminal, and the cursor
+	// is on the first of them when the program starts.
+	input := "\x1b[?18;1R" +
+		"\x1b[<0;3;10M" + // above the view
+		"\x1b[<0;3;18M" + // first line
+		"\x1b[<0;7;22M" + // last line
+		"\x1b[<0;3;23M" // below the view
+
+	var out bytes.Buffer
+	p := NewProgram(mouseModel{quitAfter: 4},
+		WithInput(strings.NewReader(input)),
+		WithOutput(&out),
+		WithInitialWindowSize(80, 24),
+		WithMouseCellMotion(),
+		WithMouseViewPosition())
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "\x1b[?6n") {
+		t.Errorf("expected the cursor position to be requested, got %q", out.String())
+	}
+	// Whether the events are in the view depends on whether it was drawn
+	// by then, so only their positions are checked.
+	type pos struct{ x, y int }
+	expected := []pos{{2, -8}, {2, 0}, {6, 4}, {2, 5}}
+	events := m.(mouseModel).events
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, e := range events {
+		if got := (pos{e.ViewX, e.ViewY}); got != expected[i] {
+			t.Errorf("event %d at %d,%d: expected %+v, got %+v", i, e.X, e.Y, expected[i], got)
+		}
+	}
+}
+
+func TestTrackViewPosition(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.height = 24
+
+	// The terminal is only asked where the cursor is when it's asked for.
+	r.enableMouseCellMotion()
+	if strings.Contains(buf.String(), "\x1b[?6n") {
+		t.Errorf("expected the cursor position not to be requested, got %q", buf.String())
+	}
+	r.disableMouseCellMotion()
+	buf.Reset()
+
+	r.viewPosition = true
+	r.enableMouseCellMotion()
+	if !strings.Contains(buf.String(), "\x1b[?6n") {
+		t.Errorf("expected the cursor position to be requested, got %q", buf.String())
+	}
+
+	// Before the terminal answers, events aren't placed in the view.
+	if m := r.viewMouseEvent(MouseEvent{X: 1, Y: 18}); m.InView || m.ViewY != 0 {
+		t.Errorf("expected the event not to be in the view, got %d (in view: %t)", m.ViewY, m.InView)
+	}
+
+	r.handleMessages(cursorPositionReportMsg{row: 18, col: 1})
+	r.write("a\nb\nc\nd\ne")
+	r.flush()
+	if m := r.viewMouseEvent(MouseEvent{X: 1, Y: 19}); !m.InView || m.ViewY != 2 {
+		t.Errorf("expected the event on the third line, got %d (in view: %t)", m.ViewY, m.InView)
+	}
+
+	// Printed lines push the view down, and the terminal scrolls once it
+	// reaches the bottom, leaving the view on rows 20 to 24.
+	r.handleMessages(printLineMessage{messageBody: "1\n2\n3"})
+	r.write("a\nb\nc\nd\ne")
+	r.flush()
+	if m := r.viewMouseEvent(MouseEvent{X: 1, Y: 19}); !m.InView || m.ViewY != 0 {
+		t.Errorf("expected the event on the first line, got %d (in view: %t)", m.ViewY, m.InView)
+	}
+	if m := r.viewMouseEvent(MouseEvent{X: 1, Y: 18}); m.InView || m.ViewY != -1 {
+		t.Errorf("expected the event above the view, got %d (in view: %t)", m.ViewY, m.InView)
+	}
+
+	// In the alt screen the view starts at the top.
+	r.enterAltScreen()
+	if m := r.viewMouseEvent(MouseEvent{X: 1, Y: 0}); m.ViewY != 0 {
+		t.Errorf("expected the event on the first line, got %d (in view: %t)", m.ViewY, m.InView)
+	}
+}
+
+func TestSentMouseEvents(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgram(mouseModel{quitAfter: 1},
+		WithInput(nil),
+		WithOutput(&out),
+		WithAltScreen(),
+		WithMouseCellMotion())
+
+	// Mouse events sent to the program are delivered as they are.
+	sent := MouseEvent{X: 3, Y: 4, Type: MouseLeft, Action: MouseActionPress, Button: MouseButtonLeft}
+	go p.Send(MouseMsg(sent))
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.(mouseModel).events[0]; got.ViewX != 0 || got.ViewY != 0 || got.InView {
+		t.Errorf("expected %#v, got %#v", sent, got)
+	}
+}
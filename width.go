@@ -0,0 +1,77 @@
+package tea
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbletea/internal/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// newWidthCondition returns the width function used to measure the cells a
+// rune takes up on screen. When eastAsian is true, characters of ambiguous
+// width (such as "α" or "☆") are treated as double width, as CJK terminals
+// render them.
+func newWidthCondition(eastAsian bool) *runewidth.Condition {
+	c := runewidth.NewCondition()
+	c.EastAsianWidth = eastAsian
+	return c
+}
+
+// detectEastAsianWidth reports whether ambiguous width characters should be
+// treated as double width, judging by the RUNEWIDTH_EASTASIAN environment
+// variable and, failing that, the locale.
+func detectEastAsianWidth() bool {
+	return runewidth.EastAsianWidth
+}
+
+// truncateLine truncates s to at most width cells as measured by cond,
+// ignoring ANSI escape sequences. If the line is cut and contains escape
+// sequences, a reset sequence is appended so styles don't bleed into the
+// next line.
+func truncateLine(s string, width int, cond *runewidth.Condition) string {
+	var (
+		b          strings.Builder
+		cells      int
+		seqChanged bool
+	)
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			n := ansi.SequenceLen(s[i:])
+			seq := s[i : i+n]
+			b.WriteString(seq)
+			seqChanged = !strings.HasSuffix(seq, "[0m")
+			i += n
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		w := cond.RuneWidth(c)
+		if cells+w > width {
+			if seqChanged {
+				b.WriteString("\x1b[0m")
+			}
+			return b.String()
+		}
+		cells += w
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+	return s
+}
+
+// stringWidth returns how many cells s takes up as measured by cond, ignoring
+// ANSI escape sequences.
+func stringWidth(s string, cond *runewidth.Condition) int {
+	cells := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			i += ansi.SequenceLen(s[i:])
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		cells += cond.RuneWidth(c)
+		i += size
+	}
+	return cells
+}
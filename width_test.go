@@ -0,0 +1,59 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		width     int
+		eastAsian bool
+		expected  string
+	}{
+		{"fits", "abc", 5, false, "abc"},
+		{"ascii", "abcdef", 3, false, "abc"},
+		{"wide", "日本語", 5, false, "日本"},
+		{"ambiguous narrow", "αβγδ", 3, false, "αβγ"},
+		{"ambiguous wide", "αβγδ", 3, true, "α"},
+		{"styled", "\x1b[1mabcdef", 3, false, "\x1b[1mabc\x1b[0m"},
+		{"styled reset", "\x1b[1mab\x1b[0mcdef", 3, false, "\x1b[1mab\x1b[0mc"},
+		{"styled fits", "\x1b[1mabc\x1b[0m", 3, false, "\x1b[1mabc\x1b[0m"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := truncateLine(test.line, test.width, newWidthCondition(test.eastAsian))
+			if got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRendererEastAsianWidth(t *testing.T) {
+	render := func(r *standardRenderer, buf *bytes.Buffer) string {
+		buf.Reset()
+		r.write("☆☆☆☆☆☆")
+		r.flush()
+		return buf.String()
+	}
+
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false, 60, false).(*standardRenderer)
+	r.handleMessages(WindowSizeMsg{Width: 4, Height: 10})
+
+	if out := render(r, &buf); !strings.Contains(out, "☆☆☆☆") {
+		t.Errorf("expected four stars to fit in narrow mode, got %q", out)
+	}
+
+	r.handleMessages(setEastAsianWidthMsg(true))
+	if out := render(r, &buf); !strings.Contains(out, "☆☆") || strings.Contains(out, "☆☆☆") {
+		t.Errorf("expected two stars to fit in East Asian mode, got %q", out)
+	}
+}
@@ -0,0 +1,157 @@
+package tea
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// win32InputRe matches a key event reported in win32-input-mode:
+//
+//	ESC [ Vk ; Sc ; Uc ; Kd ; Cs ; Rc _
+//
+// where Vk is the virtual key code, Sc the scan code, Uc the unicode
+// character, Kd whether the key is down, Cs the control key state and Rc the
+// repeat count. Any parameter but the first may be omitted.
+//
+// See: https://github.com/microsoft/terminal/blob/main/doc/specs/%234999%20-%20Improved%20keyboard%20handling%20in%20Conpty.md
+var win32InputRe = regexp.MustCompile(`^\x1b\[(\d+)(?:;(\d*))?(?:;(\d*))?(?:;(\d*))?(?:;(\d*))?(?:;(\d*))?_`)
+
+// Sequences to enable and disable win32-input-mode.
+const (
+	enableWin32InputModeSeq  = "?9001h"
+	disableWin32InputModeSeq = "?9001l"
+)
+
+// Control key state flags, as found in KEY_EVENT_RECORD.
+const (
+	win32RightAltPressed  = 0x0001
+	win32LeftAltPressed   = 0x0002
+	win32RightCtrlPressed = 0x0004
+	win32LeftCtrlPressed  = 0x0008
+	win32ShiftPressed     = 0x0010
+)
+
+// Virtual key codes we need to tell apart.
+const (
+	vkShift    = 0x10
+	vkControl  = 0x11
+	vkMenu     = 0x12
+	vkCapital  = 0x14
+	vkSpace    = 0x20
+	vkLWin     = 0x5b
+	vkRWin     = 0x5c
+	vkNumLock  = 0x90
+	vkScroll   = 0x91
+	vkLShift   = 0xa0
+	vkRMenu    = 0xa5
+	vkF1       = 0x70
+	vkF20      = 0x83
+	vkKeyFirst = 'A'
+	vkKeyLast  = 'Z'
+)
+
+// win32KeyTypes maps the virtual key codes of keys that don't produce
+// characters to their key types.
+var win32KeyTypes = func() map[int]KeyType {
+	m := map[int]KeyType{
+		0x21: KeyPgUp,
+		0x22: KeyPgDown,
+		0x23: KeyEnd,
+		0x24: KeyHome,
+		0x25: KeyLeft,
+		0x26: KeyUp,
+		0x27: KeyRight,
+		0x28: KeyDown,
+		0x2d: KeyInsert,
+		0x2e: KeyDelete,
+	}
+	for vk := vkF1; vk <= vkF20; vk++ {
+		m[vk] = KeyF1 - KeyType(vk-vkF1)
+	}
+	return m
+}()
+
+// detectWin32InputKey detects a key event reported in win32-input-mode. Key
+// up events are reported as KeyReleaseMsg. Events for modifier keys by
+// themselves are consumed without a message.
+func detectWin32InputKey(input []byte) (hasKey bool, width int, msg Msg) {
+	m := win32InputRe.FindSubmatch(input)
+	if m == nil {
+		return false, 0, nil
+	}
+	param := func(i, def int) int {
+		n, err := strconv.Atoi(string(m[i]))
+		if err != nil {
+			return def
+		}
+		return n
+	}
+	vk, uc, down, state, repeat := param(1, 0), param(3, 0), param(4, 0), param(5, 0), param(6, 1)
+
+	k, ok := win32InputKey(vk, rune(uc), state)
+	if !ok {
+		return true, len(m[0]), nil
+	}
+	if k.Type == KeyRunes {
+		for i := 1; i < repeat; i++ {
+			k.Runes = append(k.Runes, k.Runes[0])
+		}
+	}
+	if down == 0 {
+		return true, len(m[0]), KeyReleaseMsg(k)
+	}
+	return true, len(m[0]), KeyMsg(k)
+}
+
+// win32InputKey translates a virtual key code, the character it produced and
+// the control key state into a key. It reports false for keys we don't
+// report by themselves, such as modifiers.
+func win32InputKey(vk int, r rune, state int) (Key, bool) {
+	shift := state&win32ShiftPressed != 0
+	alt := state&(win32LeftAltPressed|win32RightAltPressed) != 0
+	ctrl := state&(win32LeftCtrlPressed|win32RightCtrlPressed) != 0
+
+	mod := 1
+	if shift {
+		mod += modShift
+	}
+	if alt {
+		mod += modAlt
+	}
+	if ctrl {
+		mod += modCtrl
+	}
+
+	if t, ok := win32KeyTypes[vk]; ok {
+		return modifiedKey(t, mod), true
+	}
+
+	switch {
+	case vk >= vkShift && vk <= vkMenu, vk == vkCapital, vk == vkLWin, vk == vkRWin,
+		vk == vkNumLock, vk == vkScroll, vk >= vkLShift && vk <= vkRMenu:
+		return Key{}, false
+	case ctrl && r < ' ' && vk >= vkKeyFirst && vk <= vkKeyLast:
+		// The character is a control character, if any; go by the key
+		// instead.
+		return csiuKey(rune(vk-vkKeyFirst+'a'), mod)
+	case vk == vkSpace && r == 0:
+		r = ' '
+	case r == 0:
+		// Dead keys and keys without a character.
+		return Key{}, false
+	}
+
+	if r < ' ' || r == rune(keyDEL) {
+		return csiuKey(r, mod)
+	}
+	if ctrl && alt {
+		// AltGr is reported as ctrl+alt, and is already accounted for in
+		// the character.
+		return Key{Type: KeyRunes, Runes: []rune{r}}, true
+	}
+	if shift {
+		// Shift is already accounted for in the character.
+		mod -= modShift
+	}
+	return csiuKey(r, mod)
+}
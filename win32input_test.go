@@ -0,0 +1,26 @@
+package tea
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWin32InputMode(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	// A modifier, a release and a press of q, which quits the program.
+	in.WriteString("\x1b[16;42;0;1;16;1_\x1b[81;16;113;0;0;1_\x1b[81;16;113;1;0;1_")
+
+	p := NewProgram(&testModel{}, WithInput(&in), WithOutput(&buf), WithWin32InputMode())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	enable := strings.Index(out, "\x1b[?9001h")
+	disable := strings.Index(out, "\x1b[?9001l")
+	if enable < 0 || disable < enable {
+		t.Errorf("expected win32-input-mode to be enabled and then disabled, got %q", out)
+	}
+}
@@ -0,0 +1,100 @@
+package tea
+
+import (
+	"regexp"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// WindowSize is a command that delivers the current size of the terminal as
+// a WindowSizeMsg, for models that need it when they're created partway
+// through a program, rather than waiting for the next resize.
+//
+// The size is read from the terminal where possible. Otherwise it's the size
+// the renderer last knew of, or, when the output isn't a terminal, the size
+// set with WithInitialWindowSize or the default size of plain output. Failing
+// all of those, the terminal is asked for its size, as with QueryWindowSize,
+// and the WindowSizeMsg arrives if it answers.
+func WindowSize() Msg {
+	return windowSizeMsg{}
+}
+
+// windowSizeMsg is an internal message that requests the current size of the
+// terminal. To send a windowSizeMsg, use the WindowSize command.
+type windowSizeMsg struct{}
+
+func (windowSizeMsg) internalMsg() {}
+
+// currentWindowSizeMsg is the size of the terminal requested with
+// WindowSize. It's delivered to the program as a WindowSizeMsg, even when the
+// size didn't change.
+type currentWindowSizeMsg WindowSizeMsg
+
+func (currentWindowSizeMsg) internalMsg() {}
+
+// windowSize finds out the current size of the terminal and sends it to the
+// program as a WindowSizeMsg.
+func (p *Program) windowSize() {
+	if f, ok := p.sizeFromTerminal(); ok {
+		if w, h, err := term.GetSize(int(f.Fd())); err == nil {
+			go p.Send(currentWindowSizeMsg{Width: w, Height: h})
+			return
+		}
+	}
+	if state := p.renderer.screenState(); state.Width > 0 && state.Height > 0 {
+		go p.Send(currentWindowSizeMsg{Width: state.Width, Height: state.Height})
+		return
+	}
+	if size, ok := p.syntheticWindowSize(); ok {
+		go p.Send(currentWindowSizeMsg(size))
+		return
+	}
+	p.renderer.queryWindowSize()
+}
+
+// QueryWindowSize is a command that asks the terminal for its size in
+// characters, with CSI 18 t. Terminals that support it answer with a
+// WindowSizeMsg.
+//
+// This is for when the program can't find out the size itself, such as when
+// it runs over a serial line or an SSH session without a PTY, where the
+// terminal's size can't be read and changes to it aren't signaled. See also
+// WithWindowSizeQuery.
+func QueryWindowSize() Msg {
+	return queryWindowSizeMsg{}
+}
+
+// queryWindowSizeMsg is an internal message that asks the terminal for its
+// size. To send a queryWindowSizeMsg, use the QueryWindowSize command.
+type queryWindowSizeMsg struct{}
+
+func (queryWindowSizeMsg) internalMsg() {}
+
+// querySizeSeq asks the terminal for the size of its text area.
+const querySizeSeq = "18t"
+
+// windowSizeReportRe matches the terminal's answer to querySizeSeq:
+//
+//	CSI 8 ; rows ; columns t
+//
+// Cursor position reports (CSI row ; column R) and keys with modifiers end
+// in other characters, and don't start with an 8 and three parameters.
+var windowSizeReportRe = regexp.MustCompile(`^\x1b\[8;(\d+);(\d+)t`)
+
+// detectWindowSizeReport detects the terminal's answer to a size query.
+func detectWindowSizeReport(input []byte) (hasSize bool, width int, msg Msg) {
+	m := windowSizeReportRe.FindSubmatch(input)
+	if m == nil {
+		return false, 0, nil
+	}
+	rows, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return false, 0, nil
+	}
+	cols, err := strconv.Atoi(string(m[2]))
+	if err != nil {
+		return false, 0, nil
+	}
+	return true, len(m[0]), WindowSizeMsg{Width: cols, Height: rows}
+}
@@ -0,0 +1,136 @@
+package tea
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// windowSizeModel quits once it learns the window size.
+type windowSizeModel struct {
+	size WindowSizeMsg
+}
+
+func (m windowSizeModel) Init() Cmd { return nil }
+
+func (m windowSizeModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(WindowSizeMsg); ok {
+		m.size = msg
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m windowSizeModel) View() string { return "" }
+
+func TestWindowSizeQuery(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(windowSizeModel{},
+		WithInput(strings.NewReader("\x1b[8;24;80t")),
+		WithOutput(&buf),
+		WithWindowSizeQuery())
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "\x1b[18t") {
+		t.Errorf("expected the size to be queried, got %q", buf.String())
+	}
+	expected := WindowSizeMsg{Width: 80, Height: 24}
+	if size := m.(windowSizeModel).size; size != expected {
+		t.Errorf("expected %+v, got %+v", expected, size)
+	}
+	r := p.renderer.(*standardRenderer)
+	if r.width != 80 || r.height != 24 {
+		t.Errorf("expected the renderer to be 80x24, got %dx%d", r.width, r.height)
+	}
+}
+
+func TestWindowSizeQueryPipe(t *testing.T) {
+	// Output to a pipe, such as a serial line, isn't a terminal the size can
+	// be read from.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close() //nolint:errcheck
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+
+	p := NewProgram(windowSizeModel{},
+		WithInput(strings.NewReader("\x1b[8;24;80t")),
+		WithOutput(w),
+		WithWindowSizeQuery())
+	m, err := p.Run()
+	_ = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := <-out; !bytes.Contains(b, []byte("\x1b[18t")) {
+		t.Errorf("expected the size to be queried, got %q", b)
+	}
+	expected := WindowSizeMsg{Width: 80, Height: 24}
+	if size := m.(windowSizeModel).size; size != expected {
+		t.Errorf("expected %+v, got %+v", expected, size)
+	}
+	if _, ok := p.renderer.(*standardRenderer); !ok {
+		t.Errorf("expected the standard renderer, got %T", p.renderer)
+	}
+}
+
+// resizingModel resizes the window once it learns its size, then asks for the
+// size with WindowSize.
+type resizingModel struct {
+	sizes []WindowSizeMsg
+}
+
+func (m resizingModel) Init() Cmd { return nil }
+
+func (m resizingModel) Update(msg Msg) (Model, Cmd) {
+	size, ok := msg.(WindowSizeMsg)
+	if !ok {
+		return m, nil
+	}
+	m.sizes = append(m.sizes, size)
+	switch len(m.sizes) {
+	case 1:
+		return m, func() Msg { return WindowSizeMsg{Width: 100, Height: 30} }
+	case 2:
+		return m, WindowSize
+	}
+	return m, Quit
+}
+
+func (m resizingModel) View() string { return "" }
+
+func TestWindowSize(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgram(resizingModel{},
+		WithInput(nil),
+		WithOutput(&buf),
+		WithInitialWindowSize(80, 24))
+	m, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := m.(resizingModel).sizes
+	if len(sizes) != 3 {
+		t.Fatalf("expected 3 sizes, got %+v", sizes)
+	}
+	r := p.renderer.(*standardRenderer)
+	expected := WindowSizeMsg{Width: r.width, Height: r.height}
+	if sizes[2] != expected {
+		t.Errorf("expected the renderer's size %+v, got %+v", expected, sizes[2])
+	}
+	if expected.Width != 100 || expected.Height != 30 {
+		t.Errorf("expected the renderer to be 100x30, got %+v", expected)
+	}
+}